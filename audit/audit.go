@@ -36,6 +36,14 @@ type Backend interface {
 	Invalidate(context.Context)
 }
 
+// Cleaner is implemented by the audit backends which run in the background,
+// such as to deliver entries asynchronously. Cleanup is called when the
+// backend is disabled or Vault seals, and must stop that work and release the
+// resources held by the backend.
+type Cleaner interface {
+	Cleanup(context.Context)
+}
+
 // BackendConfig contains configuration parameters used in the factory func to
 // instantiate audit backends
 type BackendConfig struct {
@@ -0,0 +1,379 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// FilterOptionKey is the audit device option holding the filter expression
+// which decides whether an entry is sent to the device
+const FilterOptionKey = "filter"
+
+// FilterInput is an audit entry being matched against a filter
+type FilterInput struct {
+	// Type is the type of the entry, either "request" or "response"
+	Type string
+
+	// MountPoint and MountType describe the mount the request is routed to.
+	// They are resolved by the caller, as the request may not have been
+	// routed yet when it is logged.
+	MountPoint string
+	MountType  string
+
+	Entry *logical.LogInput
+}
+
+// filterFields are the fields of an audit entry that a filter expression
+// can refer to
+var filterFields = map[string]func(context.Context, *FilterInput) string{
+	// type is either "request" or "response"
+	"type": func(_ context.Context, in *FilterInput) string {
+		return in.Type
+	},
+	"path": func(_ context.Context, in *FilterInput) string {
+		if in.Entry.Request == nil {
+			return ""
+		}
+		return in.Entry.Request.Path
+	},
+	"operation": func(_ context.Context, in *FilterInput) string {
+		if in.Entry.Request == nil {
+			return ""
+		}
+		return string(in.Entry.Request.Operation)
+	},
+	"mount_path": func(_ context.Context, in *FilterInput) string {
+		return in.MountPoint
+	},
+	"mount_type": func(_ context.Context, in *FilterInput) string {
+		return in.MountType
+	},
+	// namespace is the path of the request namespace, which is empty for the
+	// root namespace
+	"namespace": func(ctx context.Context, in *FilterInput) string {
+		ns, err := namespace.FromContext(ctx)
+		if err != nil {
+			return ""
+		}
+		return ns.Path
+	},
+	// auth_method is the type of the auth method being logged in to, and is
+	// empty for requests which are not logins
+	"auth_method": func(_ context.Context, in *FilterInput) string {
+		if in.Entry.Request == nil || !strings.HasPrefix(in.Entry.Request.Path, "auth/") {
+			return ""
+		}
+		return in.MountType
+	},
+	// display_name is the display name of the token making the request, which
+	// is prefixed with the path of the auth method that issued it
+	"display_name": func(_ context.Context, in *FilterInput) string {
+		if in.Entry.Auth == nil {
+			return ""
+		}
+		return in.Entry.Auth.DisplayName
+	},
+	// error is "true" if the request failed
+	"error": func(_ context.Context, in *FilterInput) string {
+		failed := in.Entry.OuterErr != nil || (in.Entry.Response != nil && in.Entry.Response.IsError())
+		return strconv.FormatBool(failed)
+	},
+}
+
+// Filter decides which audit entries an audit device receives. Filters are
+// expressions comparing entry fields to values, such as
+//
+//	mount_type == kv and operation != read
+//	namespace matches "eng/*" or error == true
+//
+// The comparison operators are "==", "!=" and "matches", which allows a
+// leading and/or trailing '*' wildcard in the value. Comparisons are combined
+// using "and", "or" and "not" and grouped with parentheses; "and" binds more
+// tightly than "or". Values containing spaces or parentheses must be quoted.
+type Filter struct {
+	expression string
+	root       filterNode
+}
+
+// ParseFilter parses a filter expression
+func ParseFilter(expression string) (*Filter, error) {
+	tokens, err := tokenizeFilter(expression)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("filter expression is empty")
+	}
+
+	p := &filterParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in filter expression", p.tokens[p.pos].value)
+	}
+
+	return &Filter{
+		expression: expression,
+		root:       root,
+	}, nil
+}
+
+// Matches returns whether the given entry passes the filter
+func (f *Filter) Matches(ctx context.Context, in *FilterInput) bool {
+	return f.root.matches(ctx, in)
+}
+
+// String returns the filter expression
+func (f *Filter) String() string {
+	return f.expression
+}
+
+type filterNode interface {
+	matches(context.Context, *FilterInput) bool
+}
+
+type filterAnd []filterNode
+
+func (n filterAnd) matches(ctx context.Context, in *FilterInput) bool {
+	for _, child := range n {
+		if !child.matches(ctx, in) {
+			return false
+		}
+	}
+	return true
+}
+
+type filterOr []filterNode
+
+func (n filterOr) matches(ctx context.Context, in *FilterInput) bool {
+	for _, child := range n {
+		if child.matches(ctx, in) {
+			return true
+		}
+	}
+	return false
+}
+
+type filterNot struct {
+	child filterNode
+}
+
+func (n filterNot) matches(ctx context.Context, in *FilterInput) bool {
+	return !n.child.matches(ctx, in)
+}
+
+type filterComparison struct {
+	field    func(context.Context, *FilterInput) string
+	operator string
+	value    string
+}
+
+func (n filterComparison) matches(ctx context.Context, in *FilterInput) bool {
+	actual := n.field(ctx, in)
+	switch n.operator {
+	case "==":
+		return actual == n.value
+	case "!=":
+		return actual != n.value
+	default:
+		return strutil.GlobbedStringsMatch(n.value, actual)
+	}
+}
+
+type filterTokenKind int
+
+const (
+	filterTokenWord filterTokenKind = iota
+	filterTokenQuoted
+	filterTokenOperator
+	filterTokenOpen
+	filterTokenClose
+)
+
+type filterToken struct {
+	kind  filterTokenKind
+	value string
+}
+
+func tokenizeFilter(expression string) ([]filterToken, error) {
+	var tokens []filterToken
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, filterToken{kind: filterTokenOpen, value: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, filterToken{kind: filterTokenClose, value: ")"})
+			i++
+		case (r == '=' || r == '!') && i+1 < len(runes) && runes[i+1] == '=':
+			tokens = append(tokens, filterToken{kind: filterTokenOperator, value: string(runes[i : i+2])})
+			i += 2
+		case r == '"':
+			end := i + 1
+			for ; end < len(runes) && runes[end] != '"'; end++ {
+				if runes[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(runes) {
+				return nil, fmt.Errorf("unterminated quoted value in filter expression")
+			}
+			value, err := strconv.Unquote(string(runes[i : end+1]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid quoted value %s in filter expression", string(runes[i:end+1]))
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenQuoted, value: value})
+			i = end + 1
+		default:
+			end := i
+			for ; end < len(runes); end++ {
+				c := runes[end]
+				if unicode.IsSpace(c) || c == '(' || c == ')' || c == '"' ||
+					((c == '=' || c == '!') && end+1 < len(runes) && runes[end+1] == '=') {
+					break
+				}
+			}
+			if end == i {
+				return nil, fmt.Errorf("unexpected %q in filter expression", string(r))
+			}
+			word := string(runes[i:end])
+			kind := filterTokenWord
+			if word == "matches" {
+				kind = filterTokenOperator
+			}
+			tokens = append(tokens, filterToken{kind: kind, value: word})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens []filterToken
+	pos    int
+}
+
+func (p *filterParser) peekKeyword(keyword string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == filterTokenWord && p.tokens[p.pos].value == keyword
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	or := filterOr{node}
+	for p.peekKeyword("or") {
+		p.pos++
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, node)
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	and := filterAnd{node}
+	for p.peekKeyword("and") {
+		p.pos++
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, node)
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of filter expression")
+	}
+
+	if p.peekKeyword("not") {
+		p.pos++
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{child: child}, nil
+	}
+
+	if p.tokens[p.pos].kind == filterTokenOpen {
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != filterTokenClose {
+			return nil, fmt.Errorf("missing closing parenthesis in filter expression")
+		}
+		p.pos++
+		return node, nil
+	}
+
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (filterNode, error) {
+	if p.pos+3 > len(p.tokens) {
+		return nil, fmt.Errorf("incomplete comparison in filter expression")
+	}
+	name, operator, value := p.tokens[p.pos], p.tokens[p.pos+1], p.tokens[p.pos+2]
+
+	if name.kind != filterTokenWord {
+		return nil, fmt.Errorf("expected a field name, got %q", name.value)
+	}
+	field, ok := filterFields[name.value]
+	if !ok {
+		return nil, fmt.Errorf("unknown field %q in filter expression, valid fields are %s", name.value, strings.Join(filterFieldNames(), ", "))
+	}
+	if operator.kind != filterTokenOperator {
+		return nil, fmt.Errorf("expected one of ==, != or matches after %q, got %q", name.value, operator.value)
+	}
+	if value.kind != filterTokenWord && value.kind != filterTokenQuoted {
+		return nil, fmt.Errorf("expected a value after %q, got %q", operator.value, value.value)
+	}
+
+	p.pos += 3
+	return filterComparison{
+		field:    field,
+		operator: operator.value,
+		value:    value.value,
+	}, nil
+}
+
+func filterFieldNames() []string {
+	names := make([]string, 0, len(filterFields))
+	for name := range filterFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
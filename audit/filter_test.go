@@ -0,0 +1,103 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestFilter_Matches(t *testing.T) {
+	kvRead := &FilterInput{
+		Type:       "request",
+		MountPoint: "secret/",
+		MountType:  "kv",
+		Entry: &logical.LogInput{
+			Auth: &logical.Auth{
+				DisplayName: "userpass-alice",
+			},
+			Request: &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      "secret/data/foo",
+			},
+		},
+	}
+	login := &FilterInput{
+		Type:       "response",
+		MountPoint: "auth/userpass/",
+		MountType:  "userpass",
+		Entry: &logical.LogInput{
+			Request: &logical.Request{
+				Operation: logical.UpdateOperation,
+				Path:      "auth/userpass/login/alice",
+			},
+			OuterErr: errors.New("permission denied"),
+		},
+	}
+
+	ctx := namespace.RootContext(context.Background())
+	engCtx := namespace.ContextWithNamespace(context.Background(), &namespace.Namespace{
+		ID:   "eng",
+		Path: "eng/",
+	})
+
+	cases := []struct {
+		expression string
+		ctx        context.Context
+		in         *FilterInput
+		expected   bool
+	}{
+		{"mount_type == kv", ctx, kvRead, true},
+		{"mount_type != kv", ctx, kvRead, false},
+		{"mount_type == kv and operation != read", ctx, kvRead, false},
+		{"mount_type == kv and not operation == read", ctx, login, false},
+		{`mount_path == "secret/"`, ctx, kvRead, true},
+		{"path matches secret/*", ctx, kvRead, true},
+		{"path matches *alice", ctx, login, true},
+		{"display_name matches userpass-*", ctx, kvRead, true},
+		{"auth_method == userpass", ctx, login, true},
+		{"auth_method == userpass", ctx, kvRead, false},
+		{"error == true", ctx, login, true},
+		{"error == true", ctx, kvRead, false},
+		{"type == response and error == true", ctx, login, true},
+		{`namespace == ""`, ctx, kvRead, true},
+		{"namespace matches eng/*", engCtx, kvRead, true},
+		{"namespace matches eng/*", ctx, kvRead, false},
+		{"operation == update or mount_type == kv and error == true", ctx, kvRead, false},
+		{"(operation == update or mount_type == kv) and error == false", ctx, kvRead, true},
+		{"not (operation == read)", ctx, kvRead, false},
+		{"mount_type==kv and operation!=update", ctx, kvRead, true},
+	}
+
+	for _, tc := range cases {
+		filter, err := ParseFilter(tc.expression)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.expression, err)
+		}
+		if actual := filter.Matches(tc.ctx, tc.in); actual != tc.expected {
+			t.Fatalf("%q: expected %t, got %t", tc.expression, tc.expected, actual)
+		}
+	}
+}
+
+func TestFilter_ParseErrors(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"mount_type",
+		"mount_type ==",
+		"mount_type = kv",
+		"unknown == foo",
+		"mount_type == kv and",
+		"(mount_type == kv",
+		"mount_type == kv)",
+		`path == "secret/`,
+		"mount_type kv == foo",
+		"== kv",
+	} {
+		if _, err := ParseFilter(expression); err == nil {
+			t.Fatalf("expected %q to fail to parse", expression)
+		}
+	}
+}
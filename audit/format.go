@@ -141,6 +141,10 @@ func (f *AuditFormatter) FormatRequest(ctx context.Context, w io.Writer, config
 		reqEntry.Time = time.Now().UTC().Format(time.RFC3339Nano)
 	}
 
+	if config.HashChain != nil {
+		reqEntry.PrevHMAC = config.HashChain.Prev()
+	}
+
 	return f.AuditFormatWriter.WriteRequest(w, reqEntry)
 }
 
@@ -313,6 +317,10 @@ func (f *AuditFormatter) FormatResponse(ctx context.Context, w io.Writer, config
 		respEntry.Time = time.Now().UTC().Format(time.RFC3339Nano)
 	}
 
+	if config.HashChain != nil {
+		respEntry.PrevHMAC = config.HashChain.Prev()
+	}
+
 	return f.AuditFormatWriter.WriteResponse(w, respEntry)
 }
 
@@ -323,6 +331,10 @@ type AuditRequestEntry struct {
 	Auth    *AuditAuth    `json:"auth,omitempty"`
 	Request *AuditRequest `json:"request,omitempty"`
 	Error   string        `json:"error,omitempty"`
+
+	// PrevHMAC is the HMAC of the previous entry when hash chaining is
+	// enabled
+	PrevHMAC string `json:"prev_hmac,omitempty"`
 }
 
 // AuditResponseEntry is the structure of a response audit log entry in Audit.
@@ -333,6 +345,10 @@ type AuditResponseEntry struct {
 	Request  *AuditRequest  `json:"request,omitempty"`
 	Response *AuditResponse `json:"response,omitempty"`
 	Error    string         `json:"error,omitempty"`
+
+	// PrevHMAC is the HMAC of the previous entry when hash chaining is
+	// enabled
+	PrevHMAC string `json:"prev_hmac,omitempty"`
 }
 
 type AuditRequest struct {
@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/helper/salt"
+	"github.com/hashicorp/vault/sdk/version"
+)
+
+const (
+	// Severities used for the CEF and LEEF formats, on a scale of 0 to 10
+	eventSeverity      = 3
+	errorEventSeverity = 6
+)
+
+var (
+	cefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefExtensionEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+)
+
+// CEFFormatWriter is an AuditFormatWriter implementation that structures data
+// into the ArcSight Common Event Format.
+type CEFFormatWriter struct {
+	Prefix   string
+	SaltFunc func(context.Context) (*salt.Salt, error)
+}
+
+func (f *CEFFormatWriter) WriteRequest(w io.Writer, req *AuditRequestEntry) error {
+	if req == nil {
+		return fmt.Errorf("request entry was nil, cannot encode")
+	}
+
+	return f.write(w, requestEvent(req))
+}
+
+func (f *CEFFormatWriter) WriteResponse(w io.Writer, resp *AuditResponseEntry) error {
+	if resp == nil {
+		return fmt.Errorf("response entry was nil, cannot encode")
+	}
+
+	return f.write(w, responseEvent(resp))
+}
+
+func (f *CEFFormatWriter) write(w io.Writer, e *auditEvent) error {
+	severity := eventSeverity
+	outcome := "success"
+	if e.Error != "" {
+		severity = errorEventSeverity
+		outcome = "failure"
+	}
+
+	var b strings.Builder
+	b.WriteString(f.Prefix)
+	fmt.Fprintf(&b, "CEF:0|HashiCorp|Vault|%s|%s|%s|%d|",
+		cefHeaderEscaper.Replace(version.GetVersion().Version),
+		cefHeaderEscaper.Replace(e.eventID()),
+		cefHeaderEscaper.Replace(e.name()),
+		severity)
+
+	var extensions []string
+	add := func(key, value string) {
+		if value != "" {
+			extensions = append(extensions, key+"="+cefExtensionEscaper.Replace(value))
+		}
+	}
+	if !e.Time.IsZero() {
+		add("rt", strconv.FormatInt(e.Time.UnixNano()/1e6, 10))
+	}
+	add("suser", e.DisplayName)
+	add("suid", e.EntityID)
+	add("src", e.RemoteAddr)
+	add("externalId", e.RequestID)
+	add("requestMethod", e.Operation)
+	add("request", e.Path)
+	add("outcome", outcome)
+	add("reason", e.Error)
+	if e.Namespace != "" {
+		add("cs1Label", "namespace")
+		add("cs1", e.Namespace)
+	}
+	if e.MountType != "" {
+		add("cs2Label", "mount_type")
+		add("cs2", e.MountType)
+	}
+	if e.PrevHMAC != "" {
+		add("cs3Label", "prev_hmac")
+		add("cs3", e.PrevHMAC)
+	}
+	b.WriteString(strings.Join(extensions, " "))
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (f *CEFFormatWriter) Salt(ctx context.Context) (*salt.Salt, error) {
+	return f.SaltFunc(ctx)
+}
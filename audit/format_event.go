@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/salt"
+)
+
+// NewFormatWriter returns the AuditFormatWriter for the given format, as
+// configured by the "format" option of audit devices
+func NewFormatWriter(format, prefix string, saltFunc func(context.Context) (*salt.Salt, error)) (AuditFormatWriter, error) {
+	switch format {
+	case "json":
+		return &JSONFormatWriter{Prefix: prefix, SaltFunc: saltFunc}, nil
+	case "jsonx":
+		return &JSONxFormatWriter{Prefix: prefix, SaltFunc: saltFunc}, nil
+	case "cef":
+		return &CEFFormatWriter{Prefix: prefix, SaltFunc: saltFunc}, nil
+	case "leef":
+		return &LEEFFormatWriter{Prefix: prefix, SaltFunc: saltFunc}, nil
+	case "otel":
+		return &OTelFormatWriter{Prefix: prefix, SaltFunc: saltFunc}, nil
+	default:
+		return nil, fmt.Errorf("unknown format type %q", format)
+	}
+}
+
+// auditEvent holds the fields of an audit entry which are mapped to the
+// standard fields of the SIEM oriented formats
+type auditEvent struct {
+	Time        time.Time
+	Type        string
+	DisplayName string
+	EntityID    string
+	RemoteAddr  string
+	RequestID   string
+	Operation   string
+	Path        string
+	MountType   string
+	Namespace   string
+	Error       string
+	PrevHMAC    string
+}
+
+func requestEvent(entry *AuditRequestEntry) *auditEvent {
+	e := &auditEvent{
+		Type:     entry.Type,
+		Error:    entry.Error,
+		PrevHMAC: entry.PrevHMAC,
+	}
+	e.setTime(entry.Time)
+	e.setAuth(entry.Auth)
+	e.setRequest(entry.Request)
+	return e
+}
+
+func responseEvent(entry *AuditResponseEntry) *auditEvent {
+	e := &auditEvent{
+		Type:     entry.Type,
+		Error:    entry.Error,
+		PrevHMAC: entry.PrevHMAC,
+	}
+	e.setTime(entry.Time)
+	e.setAuth(entry.Auth)
+	e.setRequest(entry.Request)
+	return e
+}
+
+func (e *auditEvent) setTime(raw string) {
+	if raw == "" {
+		return
+	}
+	if t, err := time.Parse(time.RFC3339Nano, raw); err == nil {
+		e.Time = t
+	}
+}
+
+func (e *auditEvent) setAuth(auth *AuditAuth) {
+	if auth == nil {
+		return
+	}
+	e.DisplayName = auth.DisplayName
+	e.EntityID = auth.EntityID
+}
+
+func (e *auditEvent) setRequest(req *AuditRequest) {
+	if req == nil {
+		return
+	}
+	e.RemoteAddr = req.RemoteAddr
+	e.RequestID = req.ID
+	e.Operation = string(req.Operation)
+	e.Path = req.Path
+	e.MountType = req.MountType
+	if req.Namespace != nil {
+		e.Namespace = req.Namespace.Path
+	}
+}
+
+// name returns a short human readable description of the event
+func (e *auditEvent) name() string {
+	return fmt.Sprintf("%s %s %s", e.Type, e.Operation, e.Path)
+}
+
+// eventID identifies the class of the event, such as "request:update"
+func (e *auditEvent) eventID() string {
+	return e.Type + ":" + e.Operation
+}
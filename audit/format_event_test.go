@@ -0,0 +1,181 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/helper/salt"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/version"
+)
+
+func testFormatEvent(t *testing.T, format, prefix string) string {
+	t.Helper()
+
+	salter, err := salt.NewSalt(context.Background(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := NewFormatWriter(format, prefix, func(context.Context) (*salt.Salt, error) {
+		return salter, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := &logical.LogInput{
+		Auth: &logical.Auth{
+			ClientToken: "foo",
+			DisplayName: "token-a|b=c",
+			EntityID:    "foobarentity",
+		},
+		Request: &logical.Request{
+			ID:        "req-id",
+			Operation: logical.UpdateOperation,
+			Path:      "secret/foo",
+			MountType: "kv",
+			Connection: &logical.Connection{
+				RemoteAddr: "127.0.0.1",
+			},
+		},
+		OuterErr: errors.New("permission denied\n"),
+	}
+
+	var buf bytes.Buffer
+	formatter := AuditFormatter{AuditFormatWriter: writer}
+	if err := formatter.FormatRequest(namespace.RootContext(nil), &buf, FormatterConfig{}, in); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+	if !strings.HasPrefix(out, prefix) {
+		t.Fatalf("no prefix %q: %s", prefix, out)
+	}
+	if !strings.HasSuffix(out, "\n") || strings.Count(out, "\n") != 1 {
+		t.Fatalf("expected a single line: %q", out)
+	}
+	return strings.TrimSuffix(out[len(prefix):], "\n")
+}
+
+func TestFormatCEF_formatRequest(t *testing.T) {
+	out := testFormatEvent(t, "cef", "@cee: ")
+
+	header := "CEF:0|HashiCorp|Vault|" + version.GetVersion().Version + "|request:update|request update secret/foo|6|"
+	if !strings.HasPrefix(out, header) {
+		t.Fatalf("bad header, expected %q: %s", header, out)
+	}
+
+	extensions := strings.TrimPrefix(out, header)
+	for _, expected := range []string{
+		`suser=token-a|b\=c`,
+		"suid=foobarentity",
+		"src=127.0.0.1",
+		"externalId=req-id",
+		"requestMethod=update",
+		"request=secret/foo",
+		"outcome=failure",
+		`reason=permission denied\n`,
+		"cs2Label=mount_type cs2=kv",
+	} {
+		if !strings.Contains(extensions, expected) {
+			t.Fatalf("expected %q in extensions: %s", expected, extensions)
+		}
+	}
+}
+
+func TestFormatLEEF_formatRequest(t *testing.T) {
+	out := testFormatEvent(t, "leef", "")
+
+	header := "LEEF:1.0|HashiCorp|Vault|" + version.GetVersion().Version + "|request:update|"
+	if !strings.HasPrefix(out, header) {
+		t.Fatalf("bad header, expected %q: %s", header, out)
+	}
+
+	attributes := make(map[string]string)
+	for _, attribute := range strings.Split(strings.TrimPrefix(out, header), "\t") {
+		kv := strings.SplitN(attribute, "=", 2)
+		if len(kv) != 2 {
+			t.Fatalf("bad attribute %q", attribute)
+		}
+		attributes[kv[0]] = kv[1]
+	}
+
+	for key, expected := range map[string]string{
+		"cat":       "request",
+		"sev":       "6",
+		"usrName":   "token-a|b=c",
+		"entityId":  "foobarentity",
+		"src":       "127.0.0.1",
+		"requestId": "req-id",
+		"operation": "update",
+		"resource":  "secret/foo",
+		"mountType": "kv",
+		"error":     `permission denied\n`,
+	} {
+		if attributes[key] != expected {
+			t.Fatalf("expected %s=%q, got %q", key, expected, attributes[key])
+		}
+	}
+	if attributes["devTime"] == "" {
+		t.Fatal("expected devTime to be set")
+	}
+}
+
+func TestFormatOTel_formatRequest(t *testing.T) {
+	out := testFormatEvent(t, "otel", "")
+
+	var record struct {
+		Timestamp      string
+		SeverityText   string
+		SeverityNumber int
+		Body           AuditRequestEntry
+		Resource       map[string]string
+		Attributes     map[string]string
+	}
+	if err := json.Unmarshal([]byte(out), &record); err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Timestamp == "" {
+		t.Fatal("expected timestamp to be set")
+	}
+	if record.SeverityText != "ERROR" || record.SeverityNumber != otelSeverityError {
+		t.Fatalf("bad severity %q (%d)", record.SeverityText, record.SeverityNumber)
+	}
+	if record.Resource["service.name"] != "vault" {
+		t.Fatalf("bad resource: %v", record.Resource)
+	}
+	if record.Body.Request == nil || record.Body.Request.Path != "secret/foo" {
+		t.Fatalf("expected audit entry as body: %s", out)
+	}
+	if record.Body.Auth == nil || record.Body.Auth.ClientToken == "foo" {
+		t.Fatalf("expected client token to be hashed: %s", out)
+	}
+
+	for key, expected := range map[string]string{
+		"vault.audit.type":         "request",
+		"enduser.id":               "token-a|b=c",
+		"vault.entity_id":          "foobarentity",
+		"client.address":           "127.0.0.1",
+		"vault.request.id":         "req-id",
+		"vault.request.operation":  "update",
+		"vault.request.path":       "secret/foo",
+		"vault.request.mount_type": "kv",
+		"vault.error":              "permission denied\n",
+	} {
+		if record.Attributes[key] != expected {
+			t.Fatalf("expected %s=%q, got %q", key, expected, record.Attributes[key])
+		}
+	}
+}
+
+func TestNewFormatWriter_unknown(t *testing.T) {
+	if _, err := NewFormatWriter("xml", "", nil); err == nil {
+		t.Fatal("expected error for unknown format")
+	}
+}
@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/helper/salt"
+	"github.com/hashicorp/vault/sdk/version"
+)
+
+var (
+	leefHeaderEscaper    = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	leefAttributeEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+)
+
+// LEEFFormatWriter is an AuditFormatWriter implementation that structures
+// data into the IBM QRadar Log Event Extended Format, version 1.0.
+type LEEFFormatWriter struct {
+	Prefix   string
+	SaltFunc func(context.Context) (*salt.Salt, error)
+}
+
+func (f *LEEFFormatWriter) WriteRequest(w io.Writer, req *AuditRequestEntry) error {
+	if req == nil {
+		return fmt.Errorf("request entry was nil, cannot encode")
+	}
+
+	return f.write(w, requestEvent(req))
+}
+
+func (f *LEEFFormatWriter) WriteResponse(w io.Writer, resp *AuditResponseEntry) error {
+	if resp == nil {
+		return fmt.Errorf("response entry was nil, cannot encode")
+	}
+
+	return f.write(w, responseEvent(resp))
+}
+
+func (f *LEEFFormatWriter) write(w io.Writer, e *auditEvent) error {
+	severity := eventSeverity
+	if e.Error != "" {
+		severity = errorEventSeverity
+	}
+
+	var b strings.Builder
+	b.WriteString(f.Prefix)
+	fmt.Fprintf(&b, "LEEF:1.0|HashiCorp|Vault|%s|%s|",
+		leefHeaderEscaper.Replace(version.GetVersion().Version),
+		leefHeaderEscaper.Replace(e.eventID()))
+
+	var attributes []string
+	add := func(key, value string) {
+		if value != "" {
+			attributes = append(attributes, key+"="+leefAttributeEscaper.Replace(value))
+		}
+	}
+	if !e.Time.IsZero() {
+		// Milliseconds since the epoch are always accepted as the devTime
+		add("devTime", strconv.FormatInt(e.Time.UnixNano()/1e6, 10))
+	}
+	add("cat", e.Type)
+	add("sev", strconv.Itoa(severity))
+	add("usrName", e.DisplayName)
+	add("entityId", e.EntityID)
+	add("src", e.RemoteAddr)
+	add("requestId", e.RequestID)
+	add("operation", e.Operation)
+	add("resource", e.Path)
+	add("mountType", e.MountType)
+	add("namespace", e.Namespace)
+	add("error", e.Error)
+	add("prevHmac", e.PrevHMAC)
+	b.WriteString(strings.Join(attributes, "\t"))
+	b.WriteString("\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (f *LEEFFormatWriter) Salt(ctx context.Context) (*salt.Salt, error) {
+	return f.SaltFunc(ctx)
+}
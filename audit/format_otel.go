@@ -0,0 +1,108 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/hashicorp/vault/sdk/helper/salt"
+	"github.com/hashicorp/vault/sdk/version"
+)
+
+const (
+	// Severity numbers of the OpenTelemetry log data model
+	otelSeverityInfo  = 9
+	otelSeverityError = 17
+)
+
+// otelLogRecord is a log record as defined by the OpenTelemetry log data
+// model. The body is the complete audit entry.
+type otelLogRecord struct {
+	Timestamp            string                 `json:"Timestamp,omitempty"`
+	SeverityText         string                 `json:"SeverityText"`
+	SeverityNumber       int                    `json:"SeverityNumber"`
+	Body                 interface{}            `json:"Body"`
+	Resource             map[string]string      `json:"Resource"`
+	InstrumentationScope map[string]string      `json:"InstrumentationScope"`
+	Attributes           map[string]interface{} `json:"Attributes"`
+}
+
+// OTelFormatWriter is an AuditFormatWriter implementation that structures
+// data into OpenTelemetry log records, one JSON record per line.
+type OTelFormatWriter struct {
+	Prefix   string
+	SaltFunc func(context.Context) (*salt.Salt, error)
+}
+
+func (f *OTelFormatWriter) WriteRequest(w io.Writer, req *AuditRequestEntry) error {
+	if req == nil {
+		return fmt.Errorf("request entry was nil, cannot encode")
+	}
+
+	return f.write(w, requestEvent(req), req)
+}
+
+func (f *OTelFormatWriter) WriteResponse(w io.Writer, resp *AuditResponseEntry) error {
+	if resp == nil {
+		return fmt.Errorf("response entry was nil, cannot encode")
+	}
+
+	return f.write(w, responseEvent(resp), resp)
+}
+
+func (f *OTelFormatWriter) write(w io.Writer, e *auditEvent, entry interface{}) error {
+	record := &otelLogRecord{
+		SeverityText:   "INFO",
+		SeverityNumber: otelSeverityInfo,
+		Body:           entry,
+		Resource: map[string]string{
+			"service.name":    "vault",
+			"service.version": version.GetVersion().Version,
+		},
+		InstrumentationScope: map[string]string{
+			"Name": "vault.audit",
+		},
+		Attributes: make(map[string]interface{}),
+	}
+	if !e.Time.IsZero() {
+		// Nanoseconds since the epoch, as a string as they exceed the
+		// precision of JSON numbers
+		record.Timestamp = strconv.FormatInt(e.Time.UnixNano(), 10)
+	}
+	if e.Error != "" {
+		record.SeverityText = "ERROR"
+		record.SeverityNumber = otelSeverityError
+	}
+
+	add := func(key, value string) {
+		if value != "" {
+			record.Attributes[key] = value
+		}
+	}
+	add("vault.audit.type", e.Type)
+	add("enduser.id", e.DisplayName)
+	add("vault.entity_id", e.EntityID)
+	add("client.address", e.RemoteAddr)
+	add("vault.request.id", e.RequestID)
+	add("vault.request.operation", e.Operation)
+	add("vault.request.path", e.Path)
+	add("vault.request.mount_type", e.MountType)
+	add("vault.namespace", e.Namespace)
+	add("vault.error", e.Error)
+
+	if len(f.Prefix) > 0 {
+		_, err := w.Write([]byte(f.Prefix))
+		if err != nil {
+			return err
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	return enc.Encode(record)
+}
+
+func (f *OTelFormatWriter) Salt(ctx context.Context) (*salt.Salt, error) {
+	return f.SaltFunc(ctx)
+}
@@ -21,6 +21,9 @@ type FormatterConfig struct {
 	Raw          bool
 	HMACAccessor bool
 
+	// HashChain, if set, links each entry to the previous one
+	HashChain *HashChain
+
 	// This should only ever be used in a testing context
 	OmitTime bool
 }
@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"sync"
+
+	"github.com/hashicorp/vault/sdk/helper/salt"
+)
+
+// HashChain makes an audit log tamper-evident by having every entry carry
+// the HMAC of the previous entry, as written, keyed by the device salt.
+// Removing or editing an entry breaks the chain at the entry following it.
+//
+// The chain must be locked from formatting an entry until it has been
+// written and appended, so that entries are written in chain order.
+type HashChain struct {
+	sync.Mutex
+	prev string
+}
+
+// Prev returns the HMAC of the last entry appended to the chain
+func (c *HashChain) Prev() string {
+	return c.prev
+}
+
+// Append records that the given entry was written, making it the entry the
+// next one is linked to
+func (c *HashChain) Append(salt *salt.Salt, entry []byte) {
+	c.prev = HashString(salt, string(entry))
+}
@@ -46,11 +46,6 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 	if !ok {
 		format = "json"
 	}
-	switch format {
-	case "json", "jsonx":
-	default:
-		return nil, fmt.Errorf("unknown format type %q", format)
-	}
 
 	// Check if hashing of accessor is disabled
 	hmacAccessor := true
@@ -84,6 +79,16 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 		}
 	}
 
+	// Check if hash chaining is enabled
+	hashChain := false
+	if raw, ok := conf.Config["hash_chain"]; ok {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		hashChain = b
+	}
+
 	b := &Backend{
 		path:       path,
 		mode:       mode,
@@ -95,23 +100,19 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 			HMACAccessor: hmacAccessor,
 		},
 	}
+	if hashChain {
+		b.formatConfig.HashChain = new(audit.HashChain)
+	}
 
 	// Ensure we are working with the right type by explicitly storing a nil of
 	// the right type
 	b.salt.Store((*salt.Salt)(nil))
 
-	switch format {
-	case "json":
-		b.formatter.AuditFormatWriter = &audit.JSONFormatWriter{
-			Prefix:   conf.Config["prefix"],
-			SaltFunc: b.Salt,
-		}
-	case "jsonx":
-		b.formatter.AuditFormatWriter = &audit.JSONxFormatWriter{
-			Prefix:   conf.Config["prefix"],
-			SaltFunc: b.Salt,
-		}
+	formatWriter, err := audit.NewFormatWriter(format, conf.Config["prefix"], b.Salt)
+	if err != nil {
+		return nil, err
 	}
+	b.formatter.AuditFormatWriter = formatWriter
 
 	switch path {
 	case "stdout", "discard":
@@ -143,6 +144,10 @@ type Backend struct {
 	f        *os.File
 	mode     os.FileMode
 
+	// hashChainSeeded is set once the hash chain has been linked to the last
+	// entry already in the file. It is guarded by the hash chain lock.
+	hashChainSeeded bool
+
 	saltMutex  sync.RWMutex
 	salt       *atomic.Value
 	saltConfig *salt.Config
@@ -193,13 +198,22 @@ func (b *Backend) LogRequest(ctx context.Context, in *logical.LogInput) error {
 		return nil
 	}
 
+	chain := b.formatConfig.HashChain
+	if chain != nil {
+		chain.Lock()
+		defer chain.Unlock()
+		if err := b.seedHashChain(ctx); err != nil {
+			return err
+		}
+	}
+
 	buf := bytes.NewBuffer(make([]byte, 0, 2000))
 	err := b.formatter.FormatRequest(ctx, buf, b.formatConfig, in)
 	if err != nil {
 		return err
 	}
 
-	return b.log(ctx, buf, writer)
+	return b.logChained(ctx, buf, writer)
 }
 
 func (b *Backend) log(ctx context.Context, buf *bytes.Buffer, writer io.Writer) error {
@@ -249,13 +263,109 @@ func (b *Backend) LogResponse(ctx context.Context, in *logical.LogInput) error {
 		return nil
 	}
 
+	chain := b.formatConfig.HashChain
+	if chain != nil {
+		chain.Lock()
+		defer chain.Unlock()
+		if err := b.seedHashChain(ctx); err != nil {
+			return err
+		}
+	}
+
 	buf := bytes.NewBuffer(make([]byte, 0, 6000))
 	err := b.formatter.FormatResponse(ctx, buf, b.formatConfig, in)
 	if err != nil {
 		return err
 	}
 
-	return b.log(ctx, buf, writer)
+	return b.logChained(ctx, buf, writer)
+}
+
+// logChained writes the entry, appending it to the hash chain once written.
+// The hash chain lock must be held if chaining is enabled.
+func (b *Backend) logChained(ctx context.Context, buf *bytes.Buffer, writer io.Writer) error {
+	entry := buf.Bytes()
+	if err := b.log(ctx, buf, writer); err != nil {
+		return err
+	}
+
+	if chain := b.formatConfig.HashChain; chain != nil {
+		salt, err := b.Salt(ctx)
+		if err != nil {
+			return err
+		}
+		chain.Append(salt, entry)
+	}
+
+	return nil
+}
+
+// seedHashChain links the hash chain to the last entry in the file, so that
+// the chain continues across restarts. The hash chain lock must be held.
+func (b *Backend) seedHashChain(ctx context.Context) error {
+	if b.hashChainSeeded {
+		return nil
+	}
+
+	if b.path != "stdout" {
+		b.fileLock.RLock()
+		entry, err := lastLine(b.path)
+		b.fileLock.RUnlock()
+		if err != nil {
+			return errwrap.Wrapf("error reading last audit entry: {{err}}", err)
+		}
+
+		if len(entry) > 0 {
+			salt, err := b.Salt(ctx)
+			if err != nil {
+				return err
+			}
+			b.formatConfig.HashChain.Append(salt, entry)
+		}
+	}
+
+	b.hashChainSeeded = true
+	return nil
+}
+
+// lastLine returns the last line of the file, including the trailing
+// newline, or nil if the file is empty or doesn't exist
+func lastLine(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// Read backwards from the end of the file until the newline ending the
+	// previous line is found
+	var line []byte
+	chunk := make([]byte, 4096)
+	for offset := info.Size(); offset > 0; {
+		n := int64(len(chunk))
+		if n > offset {
+			n = offset
+		}
+		offset -= n
+		if _, err := f.ReadAt(chunk[:n], offset); err != nil {
+			return nil, err
+		}
+		line = append(append([]byte(nil), chunk[:n]...), line...)
+
+		if i := bytes.LastIndexByte(line[:len(line)-1], '\n'); i >= 0 {
+			return line[i+1:], nil
+		}
+	}
+
+	return line, nil
 }
 
 // The file lock must be held before calling this
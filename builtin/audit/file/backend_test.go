@@ -2,10 +2,12 @@ package file
 
 import (
 	"context"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -93,6 +95,83 @@ func TestAuditFile_fileModeExisting(t *testing.T) {
 	}
 }
 
+func TestAuditFile_hashChain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_file-hash_chain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "audit.log")
+	saltView := &logical.InmemStorage{}
+	newBackend := func() audit.Backend {
+		t.Helper()
+		b, err := Factory(context.Background(), &audit.BackendConfig{
+			Config: map[string]string{
+				"path":       file,
+				"hash_chain": "true",
+			},
+			SaltConfig: &salt.Config{},
+			SaltView:   saltView,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+	logRequest := func(b audit.Backend, path string) {
+		t.Helper()
+		err := b.LogRequest(namespace.RootContext(nil), &logical.LogInput{
+			Request: &logical.Request{
+				Operation: logical.ReadOperation,
+				Path:      path,
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b := newBackend()
+	logRequest(b, "a")
+	logRequest(b, "b")
+
+	// The chain continues across restarts
+	b = newBackend()
+	logRequest(b, "c")
+
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(contents), "\n")
+	lines = lines[:len(lines)-1]
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(lines))
+	}
+
+	for i, line := range lines {
+		var entry audit.AuditRequestEntry
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			if entry.PrevHMAC != "" {
+				t.Fatalf("expected first entry not to be linked, got %q", entry.PrevHMAC)
+			}
+			continue
+		}
+
+		expected, err := b.GetHash(context.Background(), lines[i-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry.PrevHMAC != expected {
+			t.Fatalf("entry %d: expected prev_hmac %q, got %q", i, expected, entry.PrevHMAC)
+		}
+	}
+}
+
 func BenchmarkAuditFile_request(b *testing.B) {
 	config := map[string]string{
 		"path": "/dev/null",
@@ -0,0 +1,591 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	cleanhttp "github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/sdk/helper/parseutil"
+	"github.com/hashicorp/vault/sdk/helper/salt"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	defaultBatchSize      = 100
+	defaultBatchInterval  = time.Second
+	defaultQueueSize      = 10000
+	defaultRequestTimeout = 10 * time.Second
+	defaultMaxRetries     = 3
+	defaultMinBackoff     = 100 * time.Millisecond
+	defaultMaxBackoff     = 5 * time.Second
+	defaultSpoolMaxSize   = 100 * 1024 * 1024
+)
+
+// formatContentTypes are the content types of the batches for each format,
+// whose entries are each written on a line of their own
+var formatContentTypes = map[string]string{
+	"json":  "application/x-ndjson",
+	"jsonx": "application/xml",
+	"cef":   "text/plain",
+	"leef":  "text/plain",
+	"otel":  "application/x-ndjson",
+}
+
+func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, error) {
+	if conf.SaltConfig == nil {
+		return nil, fmt.Errorf("nil salt config")
+	}
+	if conf.SaltView == nil {
+		return nil, fmt.Errorf("nil salt view")
+	}
+
+	address, ok := conf.Config["address"]
+	if !ok {
+		return nil, fmt.Errorf("address is required")
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address: %v", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("address must be an http or https URL")
+	}
+
+	format, ok := conf.Config["format"]
+	if !ok {
+		format = "json"
+	}
+
+	// Check if hashing of accessor is disabled
+	hmacAccessor := true
+	if hmacAccessorRaw, ok := conf.Config["hmac_accessor"]; ok {
+		value, err := strconv.ParseBool(hmacAccessorRaw)
+		if err != nil {
+			return nil, err
+		}
+		hmacAccessor = value
+	}
+
+	// Check if raw logging is enabled
+	logRaw := false
+	if raw, ok := conf.Config["log_raw"]; ok {
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, err
+		}
+		logRaw = b
+	}
+
+	headers := make(map[string]string)
+	if raw, ok := conf.Config["headers"]; ok {
+		if err := json.Unmarshal([]byte(raw), &headers); err != nil {
+			return nil, fmt.Errorf("headers must be a JSON object of header names to values: %v", err)
+		}
+	}
+
+	batchSize, err := intOption(conf.Config, "batch_size", defaultBatchSize, 1)
+	if err != nil {
+		return nil, err
+	}
+	queueSize, err := intOption(conf.Config, "queue_size", defaultQueueSize, 1)
+	if err != nil {
+		return nil, err
+	}
+	if queueSize < batchSize {
+		return nil, fmt.Errorf("queue_size must be at least batch_size")
+	}
+	maxRetries, err := intOption(conf.Config, "max_retries", defaultMaxRetries, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	batchInterval, err := durationOption(conf.Config, "batch_interval", defaultBatchInterval)
+	if err != nil {
+		return nil, err
+	}
+	requestTimeout, err := durationOption(conf.Config, "request_timeout", defaultRequestTimeout)
+	if err != nil {
+		return nil, err
+	}
+	minBackoff, err := durationOption(conf.Config, "retry_min_backoff", defaultMinBackoff)
+	if err != nil {
+		return nil, err
+	}
+	maxBackoff, err := durationOption(conf.Config, "retry_max_backoff", defaultMaxBackoff)
+	if err != nil {
+		return nil, err
+	}
+	if maxBackoff < minBackoff {
+		return nil, fmt.Errorf("retry_max_backoff must be at least retry_min_backoff")
+	}
+
+	b := &Backend{
+		saltConfig: conf.SaltConfig,
+		saltView:   conf.SaltView,
+		formatConfig: audit.FormatterConfig{
+			Raw:          logRaw,
+			HMACAccessor: hmacAccessor,
+		},
+
+		config:         conf.Config,
+		address:        address,
+		headers:        headers,
+		requestTimeout: requestTimeout,
+		batchSize:      batchSize,
+		batchInterval:  batchInterval,
+		queueSize:      queueSize,
+		maxRetries:     maxRetries,
+		minBackoff:     minBackoff,
+		maxBackoff:     maxBackoff,
+		batchReady:     make(chan struct{}, 1),
+	}
+	b.stopCtx, b.stop = context.WithCancel(context.Background())
+
+	formatWriter, err := audit.NewFormatWriter(format, conf.Config["prefix"], b.Salt)
+	if err != nil {
+		return nil, err
+	}
+	b.formatter.AuditFormatWriter = formatWriter
+	b.contentType = formatContentTypes[format]
+
+	if b.client, err = b.newClient(); err != nil {
+		return nil, err
+	}
+
+	if spoolPath, ok := conf.Config["spool_path"]; ok {
+		spoolMaxSize, err := intOption(conf.Config, "spool_max_size", defaultSpoolMaxSize, 1)
+		if err != nil {
+			return nil, err
+		}
+		b.spool, err = newSpool(spoolPath, int64(spoolMaxSize))
+		if err != nil {
+			return nil, err
+		}
+
+		// Deliver any entries spooled before a restart
+		if !b.spool.empty() {
+			b.l.Lock()
+			b.startLocked()
+			b.l.Unlock()
+		}
+	}
+
+	return b, nil
+}
+
+func intOption(config map[string]string, key string, def, min int) (int, error) {
+	raw, ok := config[key]
+	if !ok {
+		return def, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	if value < min {
+		return 0, fmt.Errorf("%s must be at least %d", key, min)
+	}
+	return value, nil
+}
+
+func durationOption(config map[string]string, key string, def time.Duration) (time.Duration, error) {
+	raw, ok := config[key]
+	if !ok {
+		return def, nil
+	}
+	value, err := parseutil.ParseDurationSecond(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	if value <= 0 {
+		return 0, fmt.Errorf("%s must be positive", key)
+	}
+	return value, nil
+}
+
+// Backend is the audit backend for the HTTP audit transport. Formatted
+// entries are POSTed to the collector in batches by a background worker,
+// which runs for as long as there are entries to deliver. If a spool is
+// configured, every entry is written to it and synced to disk before being
+// acknowledged, and delivered from it, so entries survive restarts and
+// collector outages. Otherwise entries are queued in memory, and rejected
+// once the queue is full. The worker is stopped by Cleanup, when the device
+// is disabled or Vault seals.
+type Backend struct {
+	formatter    audit.AuditFormatter
+	formatConfig audit.FormatterConfig
+
+	config         map[string]string
+	address        string
+	headers        map[string]string
+	contentType    string
+	requestTimeout time.Duration
+	batchSize      int
+	batchInterval  time.Duration
+	queueSize      int
+	maxRetries     int
+	minBackoff     time.Duration
+	maxBackoff     time.Duration
+	spool          *spool
+
+	clientLock sync.RWMutex
+	client     *http.Client
+
+	// l guards queue, running and stopped
+	l          sync.Mutex
+	queue      [][]byte
+	running    bool
+	stopped    bool
+	batchReady chan struct{}
+
+	// stopCtx is cancelled by Cleanup to stop the worker, which wg tracks
+	stopCtx context.Context
+	stop    context.CancelFunc
+	wg      sync.WaitGroup
+
+	saltMutex  sync.RWMutex
+	salt       *salt.Salt
+	saltConfig *salt.Config
+	saltView   logical.Storage
+}
+
+var (
+	_ audit.Backend = (*Backend)(nil)
+	_ audit.Cleaner = (*Backend)(nil)
+)
+
+func (b *Backend) GetHash(ctx context.Context, data string) (string, error) {
+	salt, err := b.Salt(ctx)
+	if err != nil {
+		return "", err
+	}
+	return audit.HashString(salt, data), nil
+}
+
+func (b *Backend) LogRequest(ctx context.Context, in *logical.LogInput) error {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatRequest(ctx, &buf, b.formatConfig, in); err != nil {
+		return err
+	}
+
+	return b.enqueue(buf.Bytes())
+}
+
+func (b *Backend) LogResponse(ctx context.Context, in *logical.LogInput) error {
+	var buf bytes.Buffer
+	if err := b.formatter.FormatResponse(ctx, &buf, b.formatConfig, in); err != nil {
+		return err
+	}
+
+	return b.enqueue(buf.Bytes())
+}
+
+// enqueue spools or queues a formatted entry for delivery, starting the
+// worker if it isn't running
+func (b *Backend) enqueue(entry []byte) error {
+	b.l.Lock()
+	stopped := b.stopped
+	b.l.Unlock()
+	if stopped {
+		return fmt.Errorf("audit device for %s is closed", b.address)
+	}
+
+	// The entry is spooled before taking the lock, as the worker only marks
+	// itself as stopped under the lock once the spool is empty
+	var ready bool
+	if b.spool != nil {
+		if err := b.spool.write(entry); err != nil {
+			return fmt.Errorf("error spooling audit entry for %s: %v", b.address, err)
+		}
+		ready = b.spool.len() >= b.batchSize
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if b.spool == nil {
+		if len(b.queue) >= b.queueSize {
+			return fmt.Errorf("audit queue is full, entries are not being delivered to %s", b.address)
+		}
+		b.queue = append(b.queue, entry)
+		ready = len(b.queue) >= b.batchSize
+	}
+
+	b.startLocked()
+	if ready {
+		select {
+		case b.batchReady <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// startLocked starts the worker unless it is running or the backend was
+// cleaned up. The lock must be held.
+func (b *Backend) startLocked() {
+	if b.running || b.stopped {
+		return
+	}
+	b.running = true
+	b.wg.Add(1)
+	go b.run()
+}
+
+// Cleanup stops the worker and releases the spool. Entries which weren't
+// delivered are left in the spool, if one is configured, and delivered by the
+// next backend using it.
+func (b *Backend) Cleanup(_ context.Context) {
+	b.l.Lock()
+	if b.stopped {
+		b.l.Unlock()
+		return
+	}
+	b.stopped = true
+	b.l.Unlock()
+
+	b.stop()
+	b.wg.Wait()
+
+	if b.spool != nil {
+		b.spool.close()
+	}
+}
+
+// run delivers batches until there is nothing left to deliver or the backend
+// is cleaned up. A batch is sent once it is full or the batch interval has
+// elapsed.
+func (b *Backend) run() {
+	defer b.wg.Done()
+
+	for {
+		timer := time.NewTimer(b.batchInterval)
+		select {
+		case <-timer.C:
+		case <-b.batchReady:
+			timer.Stop()
+		case <-b.stopCtx.Done():
+			timer.Stop()
+			return
+		}
+
+		if b.flush() {
+			return
+		}
+	}
+}
+
+// flush delivers the spooled or queued entries. It returns true, marking the
+// worker as stopped, if everything was delivered.
+func (b *Backend) flush() bool {
+	if b.spool != nil {
+		return b.flushSpool()
+	}
+
+	for {
+		b.l.Lock()
+		n := len(b.queue)
+		if n == 0 {
+			b.running = false
+			b.l.Unlock()
+			return true
+		}
+		if n > b.batchSize {
+			n = b.batchSize
+		}
+		batch := bytes.Join(b.queue[:n], nil)
+		b.l.Unlock()
+
+		if err := b.send(batch); err != nil {
+			// Leave the entries queued to be retried
+			return false
+		}
+
+		// Entries are only removed from the front of the queue by the
+		// worker, so the first n are still those that were handled
+		b.l.Lock()
+		b.queue = b.queue[n:]
+		b.l.Unlock()
+	}
+}
+
+// flushSpool delivers the spooled entries in batches, oldest first
+func (b *Backend) flushSpool() bool {
+	for {
+		names, batch, err := b.spool.oldest(b.batchSize)
+		if err != nil {
+			return false
+		}
+		if len(names) == 0 {
+			b.l.Lock()
+			defer b.l.Unlock()
+			if b.spool.empty() {
+				b.running = false
+				return true
+			}
+			return false
+		}
+		if err := b.send(batch); err != nil {
+			return false
+		}
+		if err := b.spool.remove(names...); err != nil {
+			return false
+		}
+	}
+}
+
+// send POSTs a batch to the collector, retrying with exponential backoff
+// until the backend is cleaned up
+func (b *Backend) send(batch []byte) error {
+	var err error
+	backoff := b.minBackoff
+	for attempt := 0; attempt <= b.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-b.stopCtx.Done():
+				return b.stopCtx.Err()
+			}
+			backoff *= 2
+			if backoff > b.maxBackoff {
+				backoff = b.maxBackoff
+			}
+		}
+
+		if err = b.post(batch); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func (b *Backend) post(batch []byte) error {
+	req, err := http.NewRequest(http.MethodPost, b.address, bytes.NewReader(batch))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(b.stopCtx)
+	req.Header.Set("Content-Type", b.contentType)
+	for name, value := range b.headers {
+		req.Header.Set(name, value)
+	}
+
+	b.clientLock.RLock()
+	client := b.client
+	b.clientLock.RUnlock()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, b.address)
+	}
+	return nil
+}
+
+// newClient creates the HTTP client, loading the configured TLS CA and
+// client certificate
+func (b *Backend) newClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: b.config["tls_server_name"],
+	}
+
+	if raw, ok := b.config["tls_skip_verify"]; ok {
+		skipVerify, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tls_skip_verify: %v", err)
+		}
+		tlsConfig.InsecureSkipVerify = skipVerify
+	}
+
+	if caCert, ok := b.config["tls_ca_cert"]; ok {
+		pem, err := ioutil.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("error reading tls_ca_cert: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in tls_ca_cert")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	clientCert, hasCert := b.config["tls_client_cert"]
+	clientKey, hasKey := b.config["tls_client_key"]
+	switch {
+	case hasCert && hasKey:
+		cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	case hasCert || hasKey:
+		return nil, fmt.Errorf("tls_client_cert and tls_client_key must be specified together")
+	}
+
+	transport := cleanhttp.DefaultPooledTransport()
+	transport.TLSClientConfig = tlsConfig
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   b.requestTimeout,
+	}, nil
+}
+
+// Reload reloads the TLS certificates, which may have been rotated
+func (b *Backend) Reload(_ context.Context) error {
+	client, err := b.newClient()
+	if err != nil {
+		return err
+	}
+
+	b.clientLock.Lock()
+	b.client = client
+	b.clientLock.Unlock()
+
+	return nil
+}
+
+func (b *Backend) Salt(ctx context.Context) (*salt.Salt, error) {
+	b.saltMutex.RLock()
+	if b.salt != nil {
+		defer b.saltMutex.RUnlock()
+		return b.salt, nil
+	}
+	b.saltMutex.RUnlock()
+	b.saltMutex.Lock()
+	defer b.saltMutex.Unlock()
+	if b.salt != nil {
+		return b.salt, nil
+	}
+	salt, err := salt.NewSalt(ctx, b.saltView, b.saltConfig)
+	if err != nil {
+		return nil, err
+	}
+	b.salt = salt
+	return salt, nil
+}
+
+func (b *Backend) Invalidate(_ context.Context) {
+	b.saltMutex.Lock()
+	defer b.saltMutex.Unlock()
+	b.salt = nil
+}
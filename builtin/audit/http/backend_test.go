@@ -0,0 +1,382 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/helper/salt"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// testCollector records the entries POSTed to it, failing requests while
+// failing is set
+type testCollector struct {
+	sync.Mutex
+	failing bool
+	batches int
+	paths   []string
+	headers http.Header
+}
+
+func (c *testCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.failing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	c.batches++
+	c.headers = r.Header
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		var entry audit.AuditRequestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c.paths = append(c.paths, entry.Request.Path)
+	}
+}
+
+func (c *testCollector) received() (int, []string) {
+	c.Lock()
+	defer c.Unlock()
+	return c.batches, append([]string(nil), c.paths...)
+}
+
+func (c *testCollector) waitFor(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, paths := c.received(); len(paths) >= n {
+			return paths
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_, paths := c.received()
+	t.Fatalf("expected %d entries, got %d", n, len(paths))
+	return nil
+}
+
+func testBackend(t *testing.T, config map[string]string) audit.Backend {
+	t.Helper()
+	b, err := Factory(context.Background(), &audit.BackendConfig{
+		SaltConfig: &salt.Config{},
+		SaltView:   &logical.InmemStorage{},
+		Config:     config,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func logRequest(t *testing.T, b audit.Backend, path string) error {
+	t.Helper()
+	return b.LogRequest(namespace.RootContext(nil), &logical.LogInput{
+		Request: &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+		},
+	})
+}
+
+func TestAuditHTTP_Batching(t *testing.T) {
+	collector := &testCollector{}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	b := testBackend(t, map[string]string{
+		"address":        server.URL,
+		"batch_size":     "3",
+		"batch_interval": "1h",
+		"headers":        `{"Authorization": "Bearer token"}`,
+	})
+
+	for _, path := range []string{"a", "b", "c"} {
+		if err := logRequest(t, b, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A full batch is sent without waiting for the batch interval
+	paths := collector.waitFor(t, 3)
+	batches, _ := collector.received()
+	if batches != 1 {
+		t.Fatalf("expected a single batch, got %d", batches)
+	}
+	if len(paths) != 3 || paths[0] != "a" || paths[1] != "b" || paths[2] != "c" {
+		t.Fatalf("unexpected entries: %v", paths)
+	}
+	if collector.headers.Get("Authorization") != "Bearer token" {
+		t.Fatalf("expected configured header, got %v", collector.headers)
+	}
+	if collector.headers.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("unexpected content type %q", collector.headers.Get("Content-Type"))
+	}
+}
+
+func TestAuditHTTP_QueueFull(t *testing.T) {
+	collector := &testCollector{failing: true}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	b := testBackend(t, map[string]string{
+		"address":           server.URL,
+		"batch_size":        "2",
+		"queue_size":        "2",
+		"batch_interval":    "10ms",
+		"retry_min_backoff": "1ms",
+		"retry_max_backoff": "1ms",
+	})
+
+	for _, path := range []string{"a", "b"} {
+		if err := logRequest(t, b, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Undeliverable entries stay queued, and once the queue is full entries
+	// are rejected
+	if err := logRequest(t, b, "c"); err == nil {
+		t.Fatal("expected entry to be rejected")
+	}
+
+	collector.Lock()
+	collector.failing = false
+	collector.Unlock()
+	paths := collector.waitFor(t, 2)
+	if paths[0] != "a" || paths[1] != "b" {
+		t.Fatalf("unexpected entries: %v", paths)
+	}
+}
+
+func TestAuditHTTP_Spool(t *testing.T) {
+	collector := &testCollector{failing: true}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "vault-test_audit_http-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := map[string]string{
+		"address":           server.URL,
+		"batch_size":        "1",
+		"queue_size":        "1",
+		"batch_interval":    "10ms",
+		"max_retries":       "0",
+		"retry_min_backoff": "1ms",
+		"spool_path":        dir,
+	}
+	b := testBackend(t, config)
+	defer b.(audit.Cleaner).Cleanup(context.Background())
+
+	// Entries are spooled, and on disk, before being acknowledged, so the
+	// queue size doesn't limit them
+	for i, path := range []string{"a", "b", "c"} {
+		if err := logRequest(t, b, path); err != nil {
+			t.Fatal(err)
+		}
+		if n := len(spooledFiles(t, dir)); n != i+1 {
+			t.Fatalf("expected %d spooled entries, found %d", i+1, n)
+		}
+	}
+
+	// Once the collector is back the spool is delivered in order
+	collector.Lock()
+	collector.failing = false
+	collector.Unlock()
+	paths := collector.waitFor(t, 3)
+	if paths[0] != "a" || paths[1] != "b" || paths[2] != "c" {
+		t.Fatalf("unexpected entries: %v", paths)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		files := spooledFiles(t, dir)
+		if len(files) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected spool to be emptied, found %d files", len(files))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// spooledFiles returns the names of the files of the spool directory, apart
+// from its lock file
+func spooledFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, file := range files {
+		if file.Name() != spoolLockFile {
+			names = append(names, file.Name())
+		}
+	}
+	return names
+}
+
+func TestAuditHTTP_Cleanup(t *testing.T) {
+	collector := &testCollector{failing: true}
+	server := httptest.NewServer(collector)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "vault-test_audit_http-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := map[string]string{
+		"address":           server.URL,
+		"batch_size":        "1",
+		"batch_interval":    "10ms",
+		"max_retries":       "1000",
+		"retry_min_backoff": "1ms",
+		"retry_max_backoff": "1ms",
+		"spool_path":        dir,
+	}
+	b := testBackend(t, config)
+	if err := logRequest(t, b, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second device can't use the spool while the first one delivers it
+	_, err = Factory(context.Background(), &audit.BackendConfig{
+		SaltConfig: &salt.Config{},
+		SaltView:   &logical.InmemStorage{},
+		Config:     config,
+	})
+	if err == nil {
+		t.Fatal("expected the spool to be locked")
+	}
+
+	// Cleanup stops the worker while it is retrying
+	done := make(chan struct{})
+	go func() {
+		b.(audit.Cleaner).Cleanup(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("cleanup didn't stop the worker")
+	}
+	if err := logRequest(t, b, "b"); err == nil {
+		t.Fatal("expected entries to be rejected once cleaned up")
+	}
+
+	collector.Lock()
+	collector.failing = false
+	collector.Unlock()
+	time.Sleep(100 * time.Millisecond)
+	if _, paths := collector.received(); len(paths) != 0 {
+		t.Fatalf("expected no entries to be delivered after cleanup, got %v", paths)
+	}
+
+	// The spool is delivered once, by the device taking it over
+	b = testBackend(t, config)
+	defer b.(audit.Cleaner).Cleanup(context.Background())
+	paths := collector.waitFor(t, 1)
+	time.Sleep(100 * time.Millisecond)
+	if _, paths = collector.received(); len(paths) != 1 || paths[0] != "a" {
+		t.Fatalf("unexpected entries: %v", paths)
+	}
+}
+
+func TestAuditHTTP_SpoolMaxSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-test_audit_http-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newSpool(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.write([]byte("12345678")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.write([]byte("123")); err == nil {
+		t.Fatal("expected spool to be full")
+	}
+
+	// The spool is locked while open
+	if _, err := newSpool(dir, 10); err == nil {
+		t.Fatal("expected spool to be locked")
+	}
+	if err := s.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The size of existing entries is accounted for when reopened
+	s, err = newSpool(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.close()
+	if err := s.write([]byte("123")); err == nil {
+		t.Fatal("expected reopened spool to be full")
+	}
+
+	names, batch, err := s.oldest(10)
+	if err != nil || len(names) != 1 || string(batch) != "12345678" {
+		t.Fatalf("unexpected oldest batch %q: %v", batch, err)
+	}
+	if err := s.remove(names...); err != nil {
+		t.Fatal(err)
+	}
+	if !s.empty() {
+		t.Fatal("expected spool to be empty")
+	}
+}
+
+func TestAuditHTTP_InvalidConfig(t *testing.T) {
+	for _, config := range []map[string]string{
+		{},
+		{"address": "tcp://localhost:9090"},
+		{"address": "https://localhost", "format": "yaml"},
+		{"address": "https://localhost", "headers": "Authorization: token"},
+		{"address": "https://localhost", "batch_size": "0"},
+		{"address": "https://localhost", "batch_size": "10", "queue_size": "5"},
+		{"address": "https://localhost", "tls_client_cert": "cert.pem"},
+		{"address": "https://localhost", "tls_ca_cert": "/nonexistent/ca.pem"},
+		{"address": "https://localhost", "retry_min_backoff": "10s", "retry_max_backoff": "1s"},
+	} {
+		_, err := Factory(context.Background(), &audit.BackendConfig{
+			SaltConfig: &salt.Config{},
+			SaltView:   &logical.InmemStorage{},
+			Config:     config,
+		})
+		if err == nil {
+			t.Fatalf("expected error for config %v", config)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package http
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/pkg/fileutil"
+)
+
+const (
+	spoolFileSuffix = ".batch"
+	spoolLockFile   = ".lock"
+)
+
+// spool stores entries on disk until they are delivered, so that they survive
+// restarts and collector outages. Entries are stored one per file, named such
+// that lexical order is the order they were spooled in. The spool directory
+// is locked so that a single audit device delivers its entries.
+type spool struct {
+	dir     string
+	maxSize int64
+	lock    *fileutil.LockedFile
+
+	l     sync.Mutex
+	size  int64
+	count int
+	seq   uint64
+}
+
+func newSpool(dir string, maxSize int64) (*spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	lock, err := fileutil.TryLockFile(filepath.Join(dir, spoolLockFile), os.O_WRONLY|os.O_CREATE, 0600)
+	if err == fileutil.ErrLocked {
+		return nil, fmt.Errorf("spool_path %q is in use by another audit device", dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error locking spool_path: %v", err)
+	}
+
+	s := &spool{
+		dir:     dir,
+		maxSize: maxSize,
+		lock:    lock,
+	}
+
+	// Account for entries left over from before a restart
+	files, err := s.files()
+	if err != nil {
+		lock.Close()
+		return nil, err
+	}
+	for _, file := range files {
+		s.size += file.Size()
+	}
+	s.count = len(files)
+
+	return s, nil
+}
+
+// close releases the lock on the spool directory
+func (s *spool) close() error {
+	return s.lock.Close()
+}
+
+// files returns the spooled entries, oldest first
+func (s *spool) files() ([]os.FileInfo, error) {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []os.FileInfo
+	for _, info := range infos {
+		if info.Mode().IsRegular() && strings.HasSuffix(info.Name(), spoolFileSuffix) {
+			files = append(files, info)
+		}
+	}
+	return files, nil
+}
+
+// write spools an entry, failing if this would exceed the maximum size of
+// the spool. The entry is synced to disk when write returns.
+func (s *spool) write(entry []byte) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if s.size+int64(len(entry)) > s.maxSize {
+		return fmt.Errorf("audit spool is full")
+	}
+
+	s.seq++
+	name := fmt.Sprintf("%020d-%010d%s", time.Now().UnixNano(), s.seq, spoolFileSuffix)
+
+	// Write to a temporary file first so a partially written entry is never
+	// delivered
+	tmp := filepath.Join(s.dir, name+".tmp")
+	if err := writeFileSync(tmp, entry); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// Persist the rename. Directories can't be synced on all platforms, so
+	// this is best effort.
+	if dir, err := os.Open(s.dir); err == nil {
+		dir.Sync()
+		dir.Close()
+	}
+
+	s.size += int64(len(entry))
+	s.count++
+	return nil
+}
+
+func writeFileSync(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// oldest returns the names of up to max of the oldest spooled entries and
+// their contents joined into a batch, or no names if the spool is empty
+func (s *spool) oldest(max int) ([]string, []byte, error) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	files, err := s.files()
+	if err != nil || len(files) == 0 {
+		return nil, nil, err
+	}
+	if len(files) > max {
+		files = files[:max]
+	}
+
+	var names []string
+	var batch bytes.Buffer
+	for _, file := range files {
+		entry, err := ioutil.ReadFile(filepath.Join(s.dir, file.Name()))
+		if err != nil {
+			return nil, nil, err
+		}
+		names = append(names, file.Name())
+		batch.Write(entry)
+	}
+	return names, batch.Bytes(), nil
+}
+
+// remove deletes delivered entries from the spool
+func (s *spool) remove(names ...string) error {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	for _, name := range names {
+		path := filepath.Join(s.dir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+
+		s.size -= info.Size()
+		s.count--
+	}
+	return nil
+}
+
+// len returns the number of spooled entries
+func (s *spool) len() int {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	return s.count
+}
+
+// empty returns whether there are no spooled entries
+func (s *spool) empty() bool {
+	return s.len() == 0
+}
@@ -47,11 +47,6 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 	if !ok {
 		format = "json"
 	}
-	switch format {
-	case "json", "jsonx":
-	default:
-		return nil, fmt.Errorf("unknown format type %q", format)
-	}
 
 	// Check if hashing of accessor is disabled
 	hmacAccessor := true
@@ -86,18 +81,11 @@ func Factory(ctx context.Context, conf *audit.BackendConfig) (audit.Backend, err
 		socketType:    socketType,
 	}
 
-	switch format {
-	case "json":
-		b.formatter.AuditFormatWriter = &audit.JSONFormatWriter{
-			Prefix:   conf.Config["prefix"],
-			SaltFunc: b.Salt,
-		}
-	case "jsonx":
-		b.formatter.AuditFormatWriter = &audit.JSONxFormatWriter{
-			Prefix:   conf.Config["prefix"],
-			SaltFunc: b.Salt,
-		}
+	formatWriter, err := audit.NewFormatWriter(format, conf.Config["prefix"], b.Salt)
+	if err != nil {
+		return nil, err
 	}
+	b.formatter.AuditFormatWriter = formatWriter
 
 	return b, nil
 }
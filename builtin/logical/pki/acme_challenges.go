@@ -0,0 +1,180 @@
+package pki
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	acmeChallengeHTTP01 = "http-01"
+	acmeChallengeDNS01  = "dns-01"
+
+	acmeValidationTimeout = 10 * time.Second
+
+	// acmeMaxRedirects is the number of redirects followed when fetching
+	// the key authorization of an http-01 challenge
+	acmeMaxRedirects = 10
+)
+
+// acmeChallengeValidator proves control of an identifier by checking for
+// the key authorization of a challenge, as described in RFC 8555 Section 8.
+// Validators are looked up by challenge type, allowing further challenge
+// types to be added.
+type acmeChallengeValidator interface {
+	// Validate returns an error if the key authorization could not be
+	// found for the given domain.
+	Validate(ctx context.Context, config *acmeConfigEntry, domain, token, keyAuthorization string) error
+
+	// SupportsWildcard reports whether the challenge type may be used to
+	// prove control of a wildcard domain.
+	SupportsWildcard() bool
+}
+
+func defaultACMEValidators() map[string]acmeChallengeValidator {
+	return map[string]acmeChallengeValidator{
+		acmeChallengeHTTP01: newACMEHTTP01Validator(acmeValidationTimeout),
+		acmeChallengeDNS01:  &acmeDNS01Validator{},
+	}
+}
+
+// acmeHTTP01Validator implements the http-01 challenge of RFC 8555 Section 8.3
+type acmeHTTP01Validator struct {
+	client *http.Client
+}
+
+// newACMEHTTP01Validator returns an http-01 validator whose requests time out
+// after the given duration. The domains being validated are chosen by ACME
+// clients, so redirects are only followed to the same host, on the port of
+// the original request or the default HTTPS port, and proxies from the
+// environment are not used.
+func newACMEHTTP01Validator(timeout time.Duration) *acmeHTTP01Validator {
+	return &acmeHTTP01Validator{
+		client: &http.Client{
+			Timeout: timeout,
+			Transport: &http.Transport{
+				DialContext: (&net.Dialer{
+					Timeout: timeout,
+				}).DialContext,
+				TLSHandshakeTimeout:   timeout,
+				ResponseHeaderTimeout: timeout,
+				DisableKeepAlives:     true,
+			},
+			CheckRedirect: checkACMERedirect,
+		},
+	}
+}
+
+func checkACMERedirect(req *http.Request, via []*http.Request) error {
+	if len(via) > acmeMaxRedirects {
+		return fmt.Errorf("stopped after %d redirects", acmeMaxRedirects)
+	}
+
+	orig := via[0].URL
+	if req.URL.Hostname() != orig.Hostname() {
+		return fmt.Errorf("redirect to another host %q is not allowed", req.URL.Hostname())
+	}
+
+	port := req.URL.Port()
+	if port == "" {
+		switch req.URL.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		default:
+			return fmt.Errorf("redirect to scheme %q is not allowed", req.URL.Scheme)
+		}
+	}
+	origPort := orig.Port()
+	if origPort == "" {
+		origPort = "80"
+	}
+	if port != origPort && !(req.URL.Scheme == "https" && port == "443") {
+		return fmt.Errorf("redirect to port %s is not allowed", port)
+	}
+
+	return nil
+}
+
+func (v *acmeHTTP01Validator) SupportsWildcard() bool {
+	return false
+}
+
+func (v *acmeHTTP01Validator) Validate(ctx context.Context, config *acmeConfigEntry, domain, token, keyAuthorization string) error {
+	ctx, cancel := context.WithTimeout(ctx, acmeValidationTimeout)
+	defer cancel()
+
+	url := fmt.Sprintf("http://%s/.well-known/acme-challenge/%s", domain, token)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error fetching %s: %s", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d fetching %s", resp.StatusCode, url)
+	}
+
+	// The key authorization is short, so there is no need to read more
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	if err != nil {
+		return fmt.Errorf("error reading %s: %s", url, err)
+	}
+	if strings.TrimSpace(string(body)) != keyAuthorization {
+		return fmt.Errorf("key authorization at %s did not match", url)
+	}
+
+	return nil
+}
+
+// acmeDNS01Validator implements the dns-01 challenge of RFC 8555 Section 8.4
+type acmeDNS01Validator struct{}
+
+func (v *acmeDNS01Validator) SupportsWildcard() bool {
+	return true
+}
+
+func (v *acmeDNS01Validator) Validate(ctx context.Context, config *acmeConfigEntry, domain, token, keyAuthorization string) error {
+	ctx, cancel := context.WithTimeout(ctx, acmeValidationTimeout)
+	defer cancel()
+
+	resolver := net.DefaultResolver
+	if config.DNSResolver != "" {
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, config.DNSResolver)
+			},
+		}
+	}
+
+	name := "_acme-challenge." + domain
+	records, err := resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return fmt.Errorf("error looking up TXT records of %s: %s", name, err)
+	}
+
+	digest := sha256.Sum256([]byte(keyAuthorization))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+	for _, record := range records {
+		if record == expected {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no TXT record of %s matched the key authorization", name)
+}
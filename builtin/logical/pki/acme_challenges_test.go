@@ -0,0 +1,90 @@
+package pki
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPki_ACMEHTTP01Validator(t *testing.T) {
+	const (
+		token            = "token"
+		keyAuthorization = "token.thumbprint"
+		challengePath    = "/.well-known/acme-challenge/" + token
+	)
+
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, keyAuthorization)
+	}))
+	defer other.Close()
+
+	hang := make(chan struct{})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/key-authorization", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, keyAuthorization)
+	})
+	mux.HandleFunc("/same-host", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/key-authorization", http.StatusFound)
+	})
+	mux.HandleFunc("/other-host", func(w http.ResponseWriter, r *http.Request) {
+		// The same server, under another name
+		url := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+		http.Redirect(w, r, url+challengePath, http.StatusFound)
+	})
+	mux.HandleFunc("/other-port", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL+challengePath, http.StatusFound)
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	mux.HandleFunc("/hang", func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	})
+
+	// Rewrite the challenge path to the handler under test
+	var target string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == challengePath {
+			r.URL.Path = target
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	// Unblock hanging requests before the server waits for them to finish
+	defer close(hang)
+	domain := strings.TrimPrefix(srv.URL, "http://")
+
+	validator := newACMEHTTP01Validator(500 * time.Millisecond)
+
+	cases := []struct {
+		target string
+		err    string
+	}{
+		{"/key-authorization", ""},
+		{"/same-host", ""},
+		{"/other-host", "another host"},
+		{"/other-port", "port"},
+		{"/loop", "redirects"},
+		{"/hang", "Timeout"},
+	}
+
+	for _, tc := range cases {
+		target = tc.target
+		start := time.Now()
+		err := validator.Validate(context.Background(), &acmeConfigEntry{}, domain, token, keyAuthorization)
+		switch {
+		case tc.err == "" && err != nil:
+			t.Fatalf("%q: unexpected error: %v", tc.target, err)
+		case tc.err != "" && (err == nil || !strings.Contains(err.Error(), tc.err)):
+			t.Fatalf("%q: expected error containing %q, got %v", tc.target, tc.err, err)
+		}
+		if time.Since(start) > 5*time.Second {
+			t.Fatalf("%q: validation took %s", tc.target, time.Since(start))
+		}
+	}
+}
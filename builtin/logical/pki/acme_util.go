@@ -0,0 +1,555 @@
+package pki
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const (
+	acmeAccountPrefix       = "acme/accounts/"
+	acmeThumbprintPrefix    = "acme/thumbprints/"
+	acmeOrderPrefix         = "acme/orders/"
+	acmeAuthorizationPrefix = "acme/authorizations/"
+	acmeCertPrefix          = "acme/certs/"
+
+	acmeStatusPending     = "pending"
+	acmeStatusReady       = "ready"
+	acmeStatusValid       = "valid"
+	acmeStatusInvalid     = "invalid"
+	acmeStatusDeactivated = "deactivated"
+
+	acmeErrorPrefix = "urn:ietf:params:acme:error:"
+
+	// How long clients have to complete an order, and how long a nonce
+	// remains usable after being handed out
+	acmeOrderLifetime = 24 * time.Hour
+	acmeNonceLifetime = 15 * time.Minute
+)
+
+// acmeProblem is an RFC 7807 problem document, used for all errors returned
+// to ACME clients.
+type acmeProblem struct {
+	Type   string `json:"type"`
+	Detail string `json:"detail,omitempty"`
+	Status int    `json:"status,omitempty"`
+}
+
+func (p *acmeProblem) Error() string {
+	return fmt.Sprintf("%s: %s", p.Type, p.Detail)
+}
+
+func newACMEProblem(status int, errType, detail string) *acmeProblem {
+	return &acmeProblem{
+		Type:   acmeErrorPrefix + errType,
+		Detail: detail,
+		Status: status,
+	}
+}
+
+type acmeAccountEntry struct {
+	ID         string           `json:"id"`
+	Key        *jose.JSONWebKey `json:"key"`
+	Thumbprint string           `json:"thumbprint"`
+	Status     string           `json:"status"`
+	Contact    []string         `json:"contact"`
+	CreatedAt  time.Time        `json:"created_at"`
+}
+
+type acmeIdentifier struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type acmeOrderEntry struct {
+	ID                string           `json:"id"`
+	AccountID         string           `json:"account_id"`
+	Status            string           `json:"status"`
+	Identifiers       []acmeIdentifier `json:"identifiers"`
+	AuthorizationIDs  []string         `json:"authorization_ids"`
+	Expires           time.Time        `json:"expires"`
+	CertificateSerial string           `json:"certificate_serial"`
+	CertificateChain  string           `json:"certificate_chain"`
+}
+
+type acmeAuthorizationEntry struct {
+	ID         string                `json:"id"`
+	AccountID  string                `json:"account_id"`
+	Identifier acmeIdentifier        `json:"identifier"`
+	Wildcard   bool                  `json:"wildcard"`
+	Status     string                `json:"status"`
+	Expires    time.Time             `json:"expires"`
+	Challenges []*acmeChallengeEntry `json:"challenges"`
+}
+
+type acmeChallengeEntry struct {
+	Type      string       `json:"type"`
+	Token     string       `json:"token"`
+	Status    string       `json:"status"`
+	Validated time.Time    `json:"validated"`
+	Error     *acmeProblem `json:"error"`
+}
+
+// acmeThumbprintEntry maps the thumbprint of an account key to its account
+type acmeThumbprintEntry struct {
+	AccountID string `json:"account_id"`
+}
+
+// acmeCertEntry records which account a certificate was issued to, so that
+// it may later be revoked by that account.
+type acmeCertEntry struct {
+	AccountID string `json:"account_id"`
+	OrderID   string `json:"order_id"`
+}
+
+// acmeRequest is a verified JWS-signed request from an ACME client
+type acmeRequest struct {
+	req     *logical.Request
+	data    *framework.FieldData
+	config  *acmeConfigEntry
+	payload []byte
+	jwk     *jose.JSONWebKey
+	account *acmeAccountEntry
+
+	// thumbprint is the base64url-encoded RFC 7638 thumbprint of jwk
+	thumbprint string
+}
+
+// isPostAsGet reports whether the request has an empty payload, which RFC
+// 8555 uses in place of GET requests.
+func (r *acmeRequest) isPostAsGet() bool {
+	return len(r.payload) == 0
+}
+
+func (r *acmeRequest) decodePayload(out interface{}) error {
+	if err := json.Unmarshal(r.payload, out); err != nil {
+		return newACMEProblem(http.StatusBadRequest, "malformed", fmt.Sprintf("unable to parse payload: %s", err))
+	}
+	return nil
+}
+
+// acmeReply is the body and headers of a successful ACME response
+type acmeReply struct {
+	status      int
+	body        interface{}
+	contentType string
+	rawBody     []byte
+	location    string
+	links       []string
+}
+
+type acmeOperationFunc func(context.Context, *acmeRequest) (*acmeReply, error)
+
+// acmeNonceStore holds the nonces handed out to clients. Nonces are kept in
+// memory, so any node other than the one which issued a nonce will reject
+// it; clients retry with the fresh nonce returned alongside the error.
+type acmeNonceStore struct {
+	l      sync.Mutex
+	nonces map[string]time.Time
+}
+
+func newACMENonceStore() *acmeNonceStore {
+	return &acmeNonceStore{
+		nonces: make(map[string]time.Time),
+	}
+}
+
+func (n *acmeNonceStore) issue() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(buf)
+
+	n.l.Lock()
+	defer n.l.Unlock()
+
+	now := time.Now()
+	for existing, expires := range n.nonces {
+		if now.After(expires) {
+			delete(n.nonces, existing)
+		}
+	}
+	n.nonces[nonce] = now.Add(acmeNonceLifetime)
+
+	return nonce, nil
+}
+
+func (n *acmeNonceStore) redeem(nonce string) bool {
+	n.l.Lock()
+	defer n.l.Unlock()
+
+	expires, ok := n.nonces[nonce]
+	if !ok {
+		return false
+	}
+	delete(n.nonces, nonce)
+
+	return time.Now().Before(expires)
+}
+
+// acmeURL returns the absolute URL of the given ACME endpoint
+func acmeURL(config *acmeConfigEntry, path string) string {
+	return config.BaseURL + "/acme/" + path
+}
+
+// acmeRandomToken returns a challenge token, as described in RFC 8555
+// Section 8.3
+func acmeRandomToken() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func getACMEEntry(ctx context.Context, s logical.Storage, key string, out interface{}) (bool, error) {
+	entry, err := s.Get(ctx, key)
+	if err != nil {
+		return false, err
+	}
+	if entry == nil {
+		return false, nil
+	}
+	if err := entry.DecodeJSON(out); err != nil {
+		return false, errwrap.Wrapf(fmt.Sprintf("error decoding %s: {{err}}", key), err)
+	}
+	return true, nil
+}
+
+func putACMEEntry(ctx context.Context, s logical.Storage, key string, value interface{}) error {
+	entry, err := logical.StorageEntryJSON(key, value)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// acmeJWSFields are the fields of a flattened JWS, which is how every ACME
+// POST body is encoded
+func acmeJWSFields(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["protected"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Base64url-encoded JWS protected header.`,
+	}
+	fields["payload"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Base64url-encoded JWS payload.`,
+	}
+	fields["signature"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `Base64url-encoded JWS signature.`,
+	}
+	return fields
+}
+
+// acmeOperation wraps an ACME handler, verifying the JWS of the request and
+// converting the handler's result into a raw HTTP response. If useJWK is set
+// the request must be signed with an embedded key rather than by an existing
+// account.
+func (b *backend) acmeOperation(useJWK bool, op acmeOperationFunc) framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+		config, resp, err := b.getEnabledACMEConfig(ctx, req)
+		if resp != nil || err != nil {
+			return resp, err
+		}
+
+		acmeReq, err := b.verifyACMERequest(ctx, req, data, config, useJWK)
+		if err != nil {
+			return b.acmeErrorResponse(config, err)
+		}
+
+		// Serialize requests from the same client, as most update a number
+		// of related storage entries
+		lock := locksutil.LockForKey(b.acmeLocks, acmeReq.thumbprint)
+		lock.Lock()
+		defer lock.Unlock()
+
+		reply, err := op(ctx, acmeReq)
+		if err != nil {
+			return b.acmeErrorResponse(config, err)
+		}
+		return b.acmeResponse(config, reply)
+	}
+}
+
+// getEnabledACMEConfig returns the ACME configuration, or a problem response
+// if the ACME server is disabled.
+func (b *backend) getEnabledACMEConfig(ctx context.Context, req *logical.Request) (*acmeConfigEntry, *logical.Response, error) {
+	// Nonces are held in memory, so all ACME requests are served by the
+	// active node
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return nil, nil, logical.ErrReadOnly
+	}
+
+	config, err := getACMEConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !config.Enabled {
+		resp, err := b.acmeErrorResponse(config, newACMEProblem(http.StatusForbidden, "unauthorized", "ACME is not enabled on this mount"))
+		return nil, resp, err
+	}
+
+	return config, nil, nil
+}
+
+// verifyACMERequest checks the URL, signature and nonce of a JWS-encoded
+// ACME request as described in RFC 8555 Section 6.
+func (b *backend) verifyACMERequest(ctx context.Context, req *logical.Request, data *framework.FieldData, config *acmeConfigEntry, useJWK bool) (*acmeRequest, error) {
+	protected := data.Get("protected").(string)
+	signature := data.Get("signature").(string)
+	if protected == "" || signature == "" {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "request must be a flattened JWS")
+	}
+
+	raw, err := json.Marshal(map[string]string{
+		"protected": protected,
+		"payload":   data.Get("payload").(string),
+		"signature": signature,
+	})
+	if err != nil {
+		return nil, err
+	}
+	jws, err := jose.ParseSigned(string(raw))
+	if err != nil {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", fmt.Sprintf("unable to parse JWS: %s", err))
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "JWS must have exactly one signature")
+	}
+	header := jws.Signatures[0].Protected
+
+	switch jose.SignatureAlgorithm(header.Algorithm) {
+	case jose.RS256, jose.ES256, jose.ES384, jose.ES512, jose.EdDSA:
+	default:
+		return nil, newACMEProblem(http.StatusBadRequest, "badSignatureAlgorithm", fmt.Sprintf("unsupported signature algorithm %q", header.Algorithm))
+	}
+
+	url, _ := header.ExtraHeaders["url"].(string)
+	if url != acmeURL(config, strings.TrimPrefix(req.Path, "acme/")) {
+		return nil, newACMEProblem(http.StatusUnauthorized, "unauthorized", "JWS url does not match the request URL")
+	}
+
+	acmeReq := &acmeRequest{
+		req:    req,
+		data:   data,
+		config: config,
+	}
+
+	switch {
+	case header.JSONWebKey != nil && header.KeyID != "":
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "JWS must not contain both jwk and kid")
+
+	case useJWK:
+		if header.JSONWebKey == nil || !header.JSONWebKey.IsPublic() || !header.JSONWebKey.Valid() {
+			return nil, newACMEProblem(http.StatusBadRequest, "malformed", "JWS must contain a valid public jwk")
+		}
+		thumbprint, err := header.JSONWebKey.Thumbprint(crypto.SHA256)
+		if err != nil {
+			return nil, newACMEProblem(http.StatusBadRequest, "badPublicKey", err.Error())
+		}
+		acmeReq.jwk = header.JSONWebKey
+		acmeReq.thumbprint = base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	default:
+		accountPrefix := acmeURL(config, "account/")
+		if !strings.HasPrefix(header.KeyID, accountPrefix) {
+			return nil, newACMEProblem(http.StatusBadRequest, "malformed", "JWS must contain the kid of an account")
+		}
+		account, err := getACMEAccount(ctx, req.Storage, strings.TrimPrefix(header.KeyID, accountPrefix))
+		if err != nil {
+			return nil, err
+		}
+		if account == nil {
+			return nil, newACMEProblem(http.StatusBadRequest, "accountDoesNotExist", "unknown account")
+		}
+		if account.Status != acmeStatusValid {
+			return nil, newACMEProblem(http.StatusUnauthorized, "unauthorized", fmt.Sprintf("account is %s", account.Status))
+		}
+		acmeReq.account = account
+		acmeReq.jwk = account.Key
+		acmeReq.thumbprint = account.Thumbprint
+	}
+
+	payload, err := jws.Verify(acmeReq.jwk.Key)
+	if err != nil {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "JWS signature verification failed")
+	}
+	acmeReq.payload = payload
+
+	// The nonce is only consumed once the signature is verified, so that
+	// unsigned or forged requests can't burn the nonces of other clients
+	if !b.acmeNonces.redeem(header.Nonce) {
+		return nil, newACMEProblem(http.StatusBadRequest, "badNonce", "invalid or expired nonce")
+	}
+
+	return acmeReq, nil
+}
+
+func acmeProblemReply(problem *acmeProblem) *acmeReply {
+	return &acmeReply{
+		status:      problem.Status,
+		body:        problem,
+		contentType: "application/problem+json",
+	}
+}
+
+// acmeErrorResponse returns problems to the client, and any other error to
+// Vault.
+func (b *backend) acmeErrorResponse(config *acmeConfigEntry, err error) (*logical.Response, error) {
+	if problem, ok := err.(*acmeProblem); ok {
+		return b.acmeResponse(config, acmeProblemReply(problem))
+	}
+	return nil, err
+}
+
+// acmeResponse builds a raw response, including the fresh nonce that RFC
+// 8555 requires on every response.
+func (b *backend) acmeResponse(config *acmeConfigEntry, reply *acmeReply) (*logical.Response, error) {
+	nonce, err := b.acmeNonces.issue()
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string][]string{
+		"Replay-Nonce":  []string{nonce},
+		"Cache-Control": []string{"no-store"},
+	}
+	if config.BaseURL != "" {
+		headers["Link"] = append(reply.links, fmt.Sprintf("<%s>;rel=\"index\"", acmeURL(config, "directory")))
+	}
+	if reply.location != "" {
+		headers["Location"] = []string{reply.location}
+	}
+
+	contentType := reply.contentType
+	body := reply.rawBody
+	if reply.body != nil {
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		body, err = json.Marshal(reply.body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data := map[string]interface{}{
+		logical.HTTPStatusCode: reply.status,
+	}
+	if len(body) > 0 {
+		data[logical.HTTPContentType] = contentType
+		data[logical.HTTPRawBody] = body
+	}
+
+	return &logical.Response{
+		Data:    data,
+		Headers: headers,
+	}, nil
+}
+
+func getACMEAccount(ctx context.Context, s logical.Storage, id string) (*acmeAccountEntry, error) {
+	var account acmeAccountEntry
+	ok, err := getACMEEntry(ctx, s, acmeAccountPrefix+id, &account)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func getACMEOrder(ctx context.Context, s logical.Storage, id string) (*acmeOrderEntry, error) {
+	var order acmeOrderEntry
+	ok, err := getACMEEntry(ctx, s, acmeOrderPrefix+id, &order)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &order, nil
+}
+
+func getACMEAuthorization(ctx context.Context, s logical.Storage, id string) (*acmeAuthorizationEntry, error) {
+	var authz acmeAuthorizationEntry
+	ok, err := getACMEEntry(ctx, s, acmeAuthorizationPrefix+id, &authz)
+	if err != nil || !ok {
+		return nil, err
+	}
+	return &authz, nil
+}
+
+func (a *acmeAccountEntry) toACME(config *acmeConfigEntry) map[string]interface{} {
+	contact := a.Contact
+	if contact == nil {
+		contact = []string{}
+	}
+	return map[string]interface{}{
+		"status":  a.Status,
+		"contact": contact,
+	}
+}
+
+func (o *acmeOrderEntry) toACME(config *acmeConfigEntry) map[string]interface{} {
+	authorizations := make([]string, 0, len(o.AuthorizationIDs))
+	for _, id := range o.AuthorizationIDs {
+		authorizations = append(authorizations, acmeURL(config, "authorization/"+id))
+	}
+
+	ret := map[string]interface{}{
+		"status":         o.Status,
+		"expires":        o.Expires.Format(time.RFC3339),
+		"identifiers":    o.Identifiers,
+		"authorizations": authorizations,
+		"finalize":       acmeURL(config, "order/"+o.ID+"/finalize"),
+	}
+	if o.Status == acmeStatusValid {
+		ret["certificate"] = acmeURL(config, "cert/"+o.ID)
+	}
+	return ret
+}
+
+func (a *acmeAuthorizationEntry) toACME(config *acmeConfigEntry) map[string]interface{} {
+	challenges := make([]map[string]interface{}, 0, len(a.Challenges))
+	for _, challenge := range a.Challenges {
+		challenges = append(challenges, challenge.toACME(config, a.ID))
+	}
+
+	ret := map[string]interface{}{
+		"identifier": a.Identifier,
+		"status":     a.Status,
+		"expires":    a.Expires.Format(time.RFC3339),
+		"challenges": challenges,
+	}
+	if a.Wildcard {
+		ret["wildcard"] = true
+	}
+	return ret
+}
+
+func (c *acmeChallengeEntry) toACME(config *acmeConfigEntry, authzID string) map[string]interface{} {
+	ret := map[string]interface{}{
+		"type":   c.Type,
+		"url":    acmeURL(config, "challenge/"+authzID+"/"+c.Type),
+		"token":  c.Token,
+		"status": c.Status,
+	}
+	if !c.Validated.IsZero() {
+		ret["validated"] = c.Validated.Format(time.RFC3339)
+	}
+	if c.Error != nil {
+		ret["error"] = c.Error
+	}
+	return ret
+}
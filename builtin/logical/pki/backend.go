@@ -6,7 +6,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -33,12 +36,24 @@ func Backend(conf *logical.BackendConfig) *backend {
 				"ca",
 				"crl/pem",
 				"crl",
+				"crl/delta",
+				"crl/delta/pem",
+				"ca/issuer/*",
+				"crl/issuer/*",
+				"ocsp",
+				"ocsp/*",
+				"acme/*",
 			},
 
 			LocalStorage: []string{
 				"revoked/",
 				"crl",
+				"crls/",
+				"delta-wal/",
 				"certs/",
+				"cert-metadata/",
+				"cert-index/",
+				"acme/",
 			},
 
 			Root: []string{
@@ -48,6 +63,7 @@ func Backend(conf *logical.BackendConfig) *backend {
 
 			SealWrapStorage: []string{
 				"config/ca_bundle",
+				"key/",
 			},
 		},
 
@@ -67,26 +83,72 @@ func Backend(conf *logical.BackendConfig) *backend {
 			pathSign(&b),
 			pathIssue(&b),
 			pathRotateCRL(&b),
+			pathRotateDeltaCRL(&b),
 			pathFetchCA(&b),
 			pathFetchCAChain(&b),
 			pathFetchCRL(&b),
 			pathFetchCRLViaCertPath(&b),
+			pathFetchDeltaCRL(&b),
 			pathFetchValid(&b),
 			pathFetchListCerts(&b),
+			pathSearchCerts(&b),
+			pathCertMetadata(&b),
 			pathRevoke(&b),
 			pathTidy(&b),
+
+			// Multiple issuer support
+			pathListIssuers(&b),
+			pathIssuer(&b),
+			pathFetchIssuer(&b),
+			pathFetchIssuerRaw(&b),
+			pathFetchIssuerDeltaCRL(&b),
+			pathImportIssuer(&b),
+			pathConfigIssuers(&b),
+			pathGenerateIssuerRoot(&b),
+			pathGenerateIssuerIntermediate(&b),
+			pathIssuerIssue(&b),
+			pathIssuerSign(&b),
+			pathIssuerSignVerbatim(&b),
+			pathIssuerSignIntermediate(&b),
+			pathIssuerSignSelfIssued(&b),
+			pathListKeys(&b),
+			pathKey(&b),
+
+			// OCSP responder
+			pathOCSP(&b),
+			pathOCSPGet(&b),
+
+			// ACME server
+			pathConfigACME(&b),
+			pathACMEDirectory(&b),
+			pathACMENewNonce(&b),
+			pathACMENewAccount(&b),
+			pathACMEAccount(&b),
+			pathACMENewOrder(&b),
+			pathACMEOrder(&b),
+			pathACMEAuthorization(&b),
+			pathACMEChallenge(&b),
+			pathACMECert(&b),
+			pathACMERevokeCert(&b),
 		},
 
 		Secrets: []*framework.Secret{
 			secretCerts(&b),
 		},
 
+		InitializeFunc: b.initialize,
+		PeriodicFunc:   b.periodicFunc,
+
 		BackendType: logical.TypeLogical,
 	}
 
 	b.crlLifetime = time.Hour * 72
+	b.ocspLifetime = time.Hour * 12
 	b.tidyCASGuard = new(uint32)
 	b.storage = conf.StorageView
+	b.acmeNonces = newACMENonceStore()
+	b.acmeLocks = locksutil.CreateLocks()
+	b.acmeValidators = defaultACMEValidators()
 
 	return &b
 }
@@ -96,8 +158,88 @@ type backend struct {
 
 	storage           logical.Storage
 	crlLifetime       time.Duration
+	ocspLifetime      time.Duration
 	revokeStorageLock sync.RWMutex
 	tidyCASGuard      *uint32
+
+	acmeNonces     *acmeNonceStore
+	acmeLocks      []*locksutil.LockEntry
+	acmeValidators map[string]acmeChallengeValidator
+}
+
+func (b *backend) initialize(ctx context.Context, req *logical.InitializationRequest) error {
+	// On standbys and DR secondaries we do not want to run any kind of
+	// upgrade logic
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby | consts.ReplicationDRSecondary) {
+		return nil
+	}
+
+	if b.System().LocalMount() || !b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary) {
+		b.revokeStorageLock.Lock()
+		defer b.revokeStorageLock.Unlock()
+
+		return b.migrateLegacyBundle(ctx, req.Storage)
+	}
+
+	return nil
+}
+
+// periodicFunc rebuilds the CRLs when automatic rebuilding is enabled: the
+// complete CRLs once they are within the grace period of expiring, and the
+// delta CRLs once the rebuild interval has passed and new certificates have
+// been revoked.
+func (b *backend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby | consts.ReplicationDRSecondary) {
+		return nil
+	}
+
+	crlInfo, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+	if crlInfo == nil || !crlInfo.AutoRebuild || crlInfo.Disable {
+		return nil
+	}
+
+	gracePeriod, err := time.ParseDuration(crlInfo.AutoRebuildGracePeriod)
+	if err != nil {
+		return errwrap.Wrapf("error parsing CRL rebuild grace period: {{err}}", err)
+	}
+
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
+
+	state, err := getCRLState(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if now.Add(gracePeriod).After(state.NextUpdate) {
+		return buildCRL(ctx, b, req, false)
+	}
+
+	if !crlInfo.EnableDelta {
+		return nil
+	}
+
+	deltaInterval, err := time.ParseDuration(crlInfo.DeltaRebuildInterval)
+	if err != nil {
+		return errwrap.Wrapf("error parsing delta CRL rebuild interval: {{err}}", err)
+	}
+	if now.Sub(state.LastDeltaBuild) < deltaInterval {
+		return nil
+	}
+
+	walSerials, err := req.Storage.List(ctx, deltaWALPrefix)
+	if err != nil {
+		return err
+	}
+	if len(walSerials) == 0 {
+		return nil
+	}
+
+	return buildDeltaCRL(ctx, b, req)
 }
 
 const backendHelp = `
@@ -67,47 +67,9 @@ func getFormat(data *framework.FieldData) string {
 	return format
 }
 
-// Fetches the CA info. Unlike other certificates, the CA info is stored
-// in the backend as a CertBundle, because we are storing its private key
+// Fetches the CA info of the mount's default issuer
 func fetchCAInfo(ctx context.Context, req *logical.Request) (*certutil.CAInfoBundle, error) {
-	bundleEntry, err := req.Storage.Get(ctx, "config/ca_bundle")
-	if err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch local CA certificate/key: %v", err)}
-	}
-	if bundleEntry == nil {
-		return nil, errutil.UserError{Err: "backend must be configured with a CA certificate/key"}
-	}
-
-	var bundle certutil.CertBundle
-	if err := bundleEntry.DecodeJSON(&bundle); err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode local CA certificate/key: %v", err)}
-	}
-
-	parsedBundle, err := bundle.ToParsedCertBundle()
-	if err != nil {
-		return nil, errutil.InternalError{Err: err.Error()}
-	}
-
-	if parsedBundle.Certificate == nil {
-		return nil, errutil.InternalError{Err: "stored CA information not able to be parsed"}
-	}
-
-	caInfo := &certutil.CAInfoBundle{*parsedBundle, nil}
-
-	entries, err := getURLs(ctx, req)
-	if err != nil {
-		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch URL information: %v", err)}
-	}
-	if entries == nil {
-		entries = &certutil.URLEntries{
-			IssuingCertificates:   []string{},
-			CRLDistributionPoints: []string{},
-			OCSPServers:           []string{},
-		}
-	}
-	caInfo.URLs = entries
-
-	return caInfo, nil
+	return fetchCAInfoByIssuer(ctx, req, defaultRef)
 }
 
 // Allows fetching certificates from the backend; it handles the slightly
@@ -127,9 +89,21 @@ func fetchCertBySerial(ctx context.Context, req *logical.Request, prefix, serial
 		legacyPath = "revoked/" + colonSerial
 		path = "revoked/" + hyphenSerial
 	case serial == "ca":
-		path = "ca"
+		certEntry, err = fetchIssuerCertEntry(ctx, req.Storage, defaultRef)
+		if err != nil || certEntry != nil {
+			return certEntry, err
+		}
+		// Fall back to the location used before multiple issuers
+		path = legacyCAPath
 	case serial == "crl":
-		path = "crl"
+		certEntry, err = fetchIssuerCRLEntry(ctx, req.Storage, defaultRef, false)
+		if err != nil || certEntry != nil {
+			return certEntry, err
+		}
+		path = legacyCRLPath
+	case serial == "delta_crl":
+		// Delta CRLs were never stored in a legacy location
+		return fetchIssuerCRLEntry(ctx, req.Storage, defaultRef, true)
 	default:
 		legacyPath = "certs/" + colonSerial
 		path = "certs/" + hyphenSerial
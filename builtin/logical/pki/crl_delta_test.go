@@ -0,0 +1,189 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestPki_DeltaCRL(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	write := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(logical.UpdateOperation, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	parseCert := func(certPem string) *x509.Certificate {
+		t.Helper()
+		block, _ := pem.Decode([]byte(certPem))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	resp := write("root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "8760h",
+		"key_type":    "ec",
+		"key_bits":    256,
+	})
+	issuer := parseCert(resp.Data["certificate"].(string))
+
+	write("roles/example", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	})
+	resp = write("issue/example", map[string]interface{}{
+		"common_name": "a.example.com",
+	})
+	leaf := parseCert(resp.Data["certificate"].(string))
+	serial := resp.Data["serial_number"].(string)
+
+	fetchCRL := func(path string) *pkix.CertificateList {
+		t.Helper()
+		resp, err := request(logical.ReadOperation, path, nil)
+		if err != nil || resp == nil {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		crl, err := x509.ParseCRL(resp.Data[logical.HTTPRawBody].([]byte))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := issuer.CheckCRLSignature(crl); err != nil {
+			t.Fatal(err)
+		}
+		return crl
+	}
+	extensionValue := func(crl *pkix.CertificateList, oid asn1.ObjectIdentifier) *big.Int {
+		t.Helper()
+		for _, ext := range crl.TBSCertList.Extensions {
+			if ext.Id.Equal(oid) {
+				value := new(big.Int)
+				if _, err := asn1.Unmarshal(ext.Value, &value); err != nil {
+					t.Fatal(err)
+				}
+				return value
+			}
+		}
+		return nil
+	}
+	isRevoked := func(crl *pkix.CertificateList) bool {
+		for _, revoked := range crl.TBSCertList.RevokedCertificates {
+			if revoked.SerialNumber.Cmp(leaf.SerialNumber) == 0 {
+				return true
+			}
+		}
+		return false
+	}
+
+	// Delta CRLs are only useful if revocations don't rebuild the CRL
+	resp, err := request(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"enable_delta": true,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected enabling delta CRLs without auto_rebuild to fail, got err: %v resp: %#v", err, resp)
+	}
+	resp, err = request(logical.UpdateOperation, "config/crl", map[string]interface{}{
+		"expiry":                    "1h",
+		"auto_rebuild":              true,
+		"auto_rebuild_grace_period": "2h",
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected a grace period longer than the expiry to fail, got err: %v resp: %#v", err, resp)
+	}
+
+	write("config/crl", map[string]interface{}{
+		"auto_rebuild": true,
+		"enable_delta": true,
+	})
+
+	complete := fetchCRL("crl")
+	completeNumber := extensionValue(complete, oidExtensionCRLNumber)
+	if completeNumber == nil {
+		t.Fatal("expected complete CRL to have a CRL number")
+	}
+	if extensionValue(complete, oidExtensionDeltaCRLIndicator) != nil {
+		t.Fatal("expected complete CRL not to have a delta CRL indicator")
+	}
+
+	write("revoke", map[string]interface{}{
+		"serial_number": serial,
+	})
+	if isRevoked(fetchCRL("crl")) || isRevoked(fetchCRL("crl/delta")) {
+		t.Fatal("expected revocation not to rebuild the CRLs")
+	}
+
+	resp, err = request(logical.ReadOperation, "crl/rotate-delta", nil)
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	delta := fetchCRL("crl/delta")
+	if !isRevoked(delta) {
+		t.Fatal("expected certificate on the delta CRL")
+	}
+	if base := extensionValue(delta, oidExtensionDeltaCRLIndicator); base == nil || base.Cmp(completeNumber) != 0 {
+		t.Fatalf("expected delta CRL to refer to complete CRL %v, got %v", completeNumber, base)
+	}
+	if number := extensionValue(delta, oidExtensionCRLNumber); number == nil || number.Cmp(completeNumber) <= 0 {
+		t.Fatalf("expected delta CRL number to follow %v, got %v", completeNumber, number)
+	}
+
+	issuerDelta := fetchCRL("crl/issuer/default/delta")
+	if !isRevoked(issuerDelta) {
+		t.Fatal("expected certificate on the issuer's delta CRL")
+	}
+
+	// Nothing is due yet, so the periodic function leaves the CRL alone
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	if isRevoked(fetchCRL("crl")) {
+		t.Fatal("expected CRL not to be rebuilt before the grace period")
+	}
+
+	state, err := getCRLState(context.Background(), storage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.NextUpdate = time.Now()
+	if err := putCRLState(context.Background(), storage, state); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+
+	complete = fetchCRL("crl")
+	if !isRevoked(complete) {
+		t.Fatal("expected certificate on the rebuilt CRL")
+	}
+	delta = fetchCRL("crl/delta")
+	if isRevoked(delta) {
+		t.Fatal("expected certificate to be dropped from the delta CRL")
+	}
+	if base := extensionValue(delta, oidExtensionDeltaCRLIndicator); base.Cmp(extensionValue(complete, oidExtensionCRLNumber)) != 0 {
+		t.Fatalf("expected delta CRL to refer to the rebuilt CRL, got %v", base)
+	}
+}
@@ -2,11 +2,16 @@ package pki
 
 import (
 	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"errors"
+	"encoding/asn1"
 	"fmt"
+	"math/big"
 	"strings"
 	"time"
 
@@ -16,10 +21,69 @@ import (
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
+var (
+	oidExtensionAuthorityKeyID    = asn1.ObjectIdentifier{2, 5, 29, 35}
+	oidExtensionCRLNumber         = asn1.ObjectIdentifier{2, 5, 29, 20}
+	oidExtensionDeltaCRLIndicator = asn1.ObjectIdentifier{2, 5, 29, 27}
+
+	oidSignatureSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSignatureECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidSignatureECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidSignatureECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+)
+
+// crlState tracks the numbering of the CRLs built by this mount, and when
+// they were last built
+type crlState struct {
+	// NextCRLNumber is shared by the complete and delta CRLs of all issuers,
+	// so CRL numbers increase monotonically as required by RFC 5280
+	NextCRLNumber int64 `json:"next_crl_number"`
+
+	// CompleteCRLNumbers holds the number of the current complete CRL of
+	// each issuer, which delta CRLs refer to as their base
+	CompleteCRLNumbers map[string]int64 `json:"complete_crl_numbers"`
+
+	NextUpdate     time.Time `json:"next_update"`
+	LastDeltaBuild time.Time `json:"last_delta_build"`
+}
+
+func getCRLState(ctx context.Context, s logical.Storage) (*crlState, error) {
+	state := &crlState{
+		NextCRLNumber: 1,
+	}
+
+	entry, err := s.Get(ctx, crlStatePath)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching CRL state: %s", err)}
+	}
+	if entry != nil {
+		if err := entry.DecodeJSON(state); err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("error decoding CRL state: %s", err)}
+		}
+	}
+	if state.CompleteCRLNumbers == nil {
+		state.CompleteCRLNumbers = make(map[string]int64)
+	}
+
+	return state, nil
+}
+
+func putCRLState(ctx context.Context, s logical.Storage, state *crlState) error {
+	entry, err := logical.StorageEntryJSON(crlStatePath, state)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error encoding CRL state: %s", err)}
+	}
+	if err := s.Put(ctx, entry); err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error storing CRL state: %s", err)}
+	}
+	return nil
+}
+
 type revocationInfo struct {
 	CertificateBytes  []byte    `json:"certificate_bytes"`
 	RevocationTime    int64     `json:"revocation_time"`
 	RevocationTimeUTC time.Time `json:"revocation_time_utc"`
+	CertificateIssuer string    `json:"issuer_id"`
 }
 
 // Revokes a cert, and tries to be smart about error recovery
@@ -33,18 +97,19 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 		return nil, nil
 	}
 
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	_, caErr := resolveIssuerReference(ctx, req.Storage, defaultRef)
 	switch caErr.(type) {
 	case errutil.UserError:
 		return logical.ErrorResponse(fmt.Sprintf("could not fetch the CA certificate: %s", caErr)), nil
 	case errutil.InternalError:
 		return nil, fmt.Errorf("error fetching CA certificate: %s", caErr)
 	}
-	if signingBundle == nil {
-		return nil, errors.New("CA info not found")
-	}
 	colonSerial := strings.Replace(strings.ToLower(serial), "-", ":", -1)
-	if colonSerial == certutil.GetHexFormatted(signingBundle.Certificate.SerialNumber.Bytes(), ":") {
+	isIssuer, err := isIssuerSerial(ctx, req.Storage, colonSerial)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching issuers: %s", err)
+	}
+	if isIssuer {
 		return logical.ErrorResponse("adding CA to CRL is not allowed"), nil
 	}
 
@@ -110,7 +175,13 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 			return nil, nil
 		}
 
+		issuerID, err := findIssuerForCert(ctx, req.Storage, cert)
+		if err != nil {
+			return nil, errwrap.Wrapf("error finding issuer of certificate: {{err}}", err)
+		}
+
 		currTime := time.Now()
+		revInfo.CertificateIssuer = issuerID
 		revInfo.CertificateBytes = certEntry.Value
 		revInfo.RevocationTime = currTime.Unix()
 		revInfo.RevocationTimeUTC = currTime.UTC()
@@ -127,12 +198,30 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 
 	}
 
-	crlErr := buildCRL(ctx, b, req, false)
-	switch crlErr.(type) {
-	case errutil.UserError:
-		return logical.ErrorResponse(fmt.Sprintf("Error during CRL building: %s", crlErr)), nil
-	case errutil.InternalError:
-		return nil, errwrap.Wrapf("error encountered during CRL building: {{err}}", crlErr)
+	crlInfo, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return nil, errwrap.Wrapf("error fetching CRL config information: {{err}}", err)
+	}
+
+	if crlInfo != nil && crlInfo.AutoRebuild {
+		// The CRL will be rebuilt by the periodic function; just record the
+		// revocation for the next delta CRL
+		if crlInfo.EnableDelta && !alreadyRevoked {
+			err = req.Storage.Put(ctx, &logical.StorageEntry{
+				Key: deltaWALPrefix + normalizeSerial(serial),
+			})
+			if err != nil {
+				return nil, errwrap.Wrapf("error saving delta CRL entry: {{err}}", err)
+			}
+		}
+	} else {
+		crlErr := buildCRL(ctx, b, req, false)
+		switch crlErr.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(fmt.Sprintf("Error during CRL building: %s", crlErr)), nil
+		case errutil.InternalError:
+			return nil, errwrap.Wrapf("error encountered during CRL building: {{err}}", crlErr)
+		}
 	}
 
 	resp := &logical.Response{
@@ -146,8 +235,10 @@ func revokeCert(ctx context.Context, b *backend, req *logical.Request, serial st
 	return resp, nil
 }
 
-// Builds a CRL by going through the list of revoked certificates and building
-// a new CRL with the stored revocation times and serial numbers.
+// Builds a CRL for each issuer by going through the list of revoked
+// certificates and building a new CRL with the stored revocation times and
+// serial numbers. If delta CRLs are enabled, they are rebuilt on top of the
+// new complete CRLs.
 func buildCRL(ctx context.Context, b *backend, req *logical.Request, forceNew bool) error {
 	crlInfo, err := b.CRL(ctx, req.Storage)
 	if err != nil {
@@ -155,9 +246,7 @@ func buildCRL(ctx context.Context, b *backend, req *logical.Request, forceNew bo
 	}
 
 	crlLifetime := b.crlLifetime
-	var revokedCerts []pkix.RevokedCertificate
-	var revInfo revocationInfo
-	var revokedSerials []string
+	revokedCerts := make(map[string][]pkix.RevokedCertificate)
 
 	if crlInfo != nil {
 		if crlInfo.Expiry != "" {
@@ -176,70 +265,347 @@ func buildCRL(ctx context.Context, b *backend, req *logical.Request, forceNew bo
 		}
 	}
 
-	revokedSerials, err = req.Storage.List(ctx, "revoked/")
+	revokedCerts, err = fetchRevokedCertsByIssuer(ctx, req)
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("error fetching list of revoked certs: %s", err)}
+		return err
 	}
 
-	for _, serial := range revokedSerials {
+WRITE:
+	issuerIDs, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching list of issuers: %s", err)}
+	}
+
+	state, err := getCRLState(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	built := false
+	for _, issuerID := range issuerIDs {
+		issuer, err := fetchIssuerById(ctx, req.Storage, issuerID)
+		if err != nil {
+			return err
+		}
+		if issuer == nil || issuer.KeyID == "" {
+			// Issuers without a key cannot sign a CRL
+			continue
+		}
+
+		signingBundle, caErr := fetchCAInfoByIssuer(ctx, req, issuerID)
+		switch caErr.(type) {
+		case errutil.UserError:
+			return errutil.UserError{Err: fmt.Sprintf("could not fetch the CA certificate: %s", caErr)}
+		case errutil.InternalError:
+			return errutil.InternalError{Err: fmt.Sprintf("error fetching CA certificate: %s", caErr)}
+		}
+
+		crlNumber := state.NextCRLNumber
+		state.NextCRLNumber++
+
+		crlBytes, err := createCRL(signingBundle, revokedCerts[issuerID], now, now.Add(crlLifetime), crlNumber, 0)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error creating new CRL: %s", err)}
+		}
+
+		err = req.Storage.Put(ctx, &logical.StorageEntry{
+			Key:   crlPrefix + issuerID,
+			Value: crlBytes,
+		})
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error storing CRL: %s", err)}
+		}
+		state.CompleteCRLNumbers[issuerID] = crlNumber
+		built = true
+	}
+
+	if !built {
+		return errutil.UserError{Err: "could not fetch the CA certificate: backend must be configured with a CA certificate/key"}
+	}
+
+	state.NextUpdate = now.Add(crlLifetime)
+	if err := putCRLState(ctx, req.Storage, state); err != nil {
+		return err
+	}
+
+	// Every revocation up to now is on the complete CRLs, so the delta CRLs
+	// start over
+	walSerials, err := req.Storage.List(ctx, deltaWALPrefix)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching list of delta CRL entries: %s", err)}
+	}
+	for _, serial := range walSerials {
+		if err := req.Storage.Delete(ctx, deltaWALPrefix+serial); err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error removing delta CRL entry for serial %s: %s", serial, err)}
+		}
+	}
+
+	if crlInfo != nil && crlInfo.EnableDelta && !crlInfo.Disable {
+		return buildDeltaCRL(ctx, b, req)
+	}
+
+	for _, issuerID := range issuerIDs {
+		if err := req.Storage.Delete(ctx, crlPrefix+issuerID+deltaCRLSuffix); err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error removing delta CRL: %s", err)}
+		}
+	}
+
+	return nil
+}
+
+// buildDeltaCRL builds a delta CRL for each issuer with a complete CRL,
+// listing the certificates revoked since that CRL was built.
+func buildDeltaCRL(ctx context.Context, b *backend, req *logical.Request) error {
+	crlInfo, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching CRL config information: %s", err)}
+	}
+
+	crlLifetime := b.crlLifetime
+	if crlInfo != nil && crlInfo.Expiry != "" {
+		crlDur, err := time.ParseDuration(crlInfo.Expiry)
+		if err != nil {
+			return errutil.InternalError{Err: fmt.Sprintf("error parsing CRL duration of %s", crlInfo.Expiry)}
+		}
+		crlLifetime = crlDur
+	}
+
+	state, err := getCRLState(ctx, req.Storage)
+	if err != nil {
+		return err
+	}
+
+	walSerials, err := req.Storage.List(ctx, deltaWALPrefix)
+	if err != nil {
+		return errutil.InternalError{Err: fmt.Sprintf("error fetching list of delta CRL entries: %s", err)}
+	}
+
+	revokedCerts := make(map[string][]pkix.RevokedCertificate)
+	for _, serial := range walSerials {
 		revokedEntry, err := req.Storage.Get(ctx, "revoked/"+serial)
 		if err != nil {
 			return errutil.InternalError{Err: fmt.Sprintf("unable to fetch revoked cert with serial %s: %s", serial, err)}
 		}
 		if revokedEntry == nil {
-			return errutil.InternalError{Err: fmt.Sprintf("revoked certificate entry for serial %s is nil", serial)}
+			// The revocation entry was tidied, so the certificate has
+			// expired and need not be listed
+			if err := req.Storage.Delete(ctx, deltaWALPrefix+serial); err != nil {
+				return errutil.InternalError{Err: fmt.Sprintf("error removing delta CRL entry for serial %s: %s", serial, err)}
+			}
+			continue
 		}
-		if revokedEntry.Value == nil || len(revokedEntry.Value) == 0 {
-			// TODO: In this case, remove it and continue? How likely is this to
-			// happen? Alternately, could skip it entirely, or could implement a
-			// delete function so that there is a way to remove these
-			return errutil.InternalError{Err: fmt.Sprintf("found revoked serial but actual certificate is empty")}
+
+		issuerID, revokedCert, err := parseRevokedEntry(ctx, req.Storage, serial, revokedEntry)
+		if err != nil {
+			return err
 		}
+		revokedCerts[issuerID] = append(revokedCerts[issuerID], revokedCert)
+	}
 
-		err = revokedEntry.DecodeJSON(&revInfo)
+	now := time.Now()
+	for issuerID, baseCRLNumber := range state.CompleteCRLNumbers {
+		signingBundle, caErr := fetchCAInfoByIssuer(ctx, req, issuerID)
+		switch caErr.(type) {
+		case nil:
+		case errutil.UserError:
+			// The issuer or its key was removed since the complete CRL
+			// was built
+			continue
+		default:
+			return errutil.InternalError{Err: fmt.Sprintf("error fetching CA certificate: %s", caErr)}
+		}
+
+		crlNumber := state.NextCRLNumber
+		state.NextCRLNumber++
+
+		crlBytes, err := createCRL(signingBundle, revokedCerts[issuerID], now, now.Add(crlLifetime), crlNumber, baseCRLNumber)
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("error decoding revocation entry for serial %s: %s", serial, err)}
+			return errutil.InternalError{Err: fmt.Sprintf("error creating new delta CRL: %s", err)}
 		}
 
-		revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
+		err = req.Storage.Put(ctx, &logical.StorageEntry{
+			Key:   crlPrefix + issuerID + deltaCRLSuffix,
+			Value: crlBytes,
+		})
 		if err != nil {
-			return errutil.InternalError{Err: fmt.Sprintf("unable to parse stored revoked certificate with serial %s: %s", serial, err)}
+			return errutil.InternalError{Err: fmt.Sprintf("error storing delta CRL: %s", err)}
 		}
+	}
 
-		// NOTE: We have to change this to UTC time because the CRL standard
-		// mandates it but Go will happily encode the CRL without this.
-		newRevCert := pkix.RevokedCertificate{
-			SerialNumber: revokedCert.SerialNumber,
+	state.LastDeltaBuild = now
+	return putCRLState(ctx, req.Storage, state)
+}
+
+// createCRL signs a CRL carrying a CRL number, and if baseCRLNumber is
+// positive, a delta CRL indicator referring to that complete CRL. The
+// standard library cannot add these extensions itself.
+func createCRL(signingBundle *certutil.CAInfoBundle, revokedCerts []pkix.RevokedCertificate, thisUpdate, nextUpdate time.Time, crlNumber, baseCRLNumber int64) ([]byte, error) {
+	caCert := signingBundle.Certificate
+
+	hashFunc, sigAlg, err := crlSignatureAlgorithm(signingBundle.PrivateKey.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	var issuer pkix.RDNSequence
+	if _, err := asn1.Unmarshal(caCert.RawSubject, &issuer); err != nil {
+		return nil, err
+	}
+
+	// Revocation times must be encoded as UTC
+	revokedUTC := make([]pkix.RevokedCertificate, len(revokedCerts))
+	for i, rc := range revokedCerts {
+		rc.RevocationTime = rc.RevocationTime.UTC()
+		revokedUTC[i] = rc
+	}
+
+	var extensions []pkix.Extension
+	if len(caCert.SubjectKeyId) > 0 {
+		value, err := asn1.Marshal(struct {
+			ID []byte `asn1:"optional,tag:0"`
+		}{caCert.SubjectKeyId})
+		if err != nil {
+			return nil, err
 		}
-		if !revInfo.RevocationTimeUTC.IsZero() {
-			newRevCert.RevocationTime = revInfo.RevocationTimeUTC
-		} else {
-			newRevCert.RevocationTime = time.Unix(revInfo.RevocationTime, 0).UTC()
+		extensions = append(extensions, pkix.Extension{Id: oidExtensionAuthorityKeyID, Value: value})
+	}
+
+	value, err := asn1.Marshal(big.NewInt(crlNumber))
+	if err != nil {
+		return nil, err
+	}
+	extensions = append(extensions, pkix.Extension{Id: oidExtensionCRLNumber, Value: value})
+
+	if baseCRLNumber > 0 {
+		value, err := asn1.Marshal(big.NewInt(baseCRLNumber))
+		if err != nil {
+			return nil, err
 		}
-		revokedCerts = append(revokedCerts, newRevCert)
+		extensions = append(extensions, pkix.Extension{Id: oidExtensionDeltaCRLIndicator, Critical: true, Value: value})
 	}
 
-WRITE:
-	signingBundle, caErr := fetchCAInfo(ctx, req)
-	switch caErr.(type) {
-	case errutil.UserError:
-		return errutil.UserError{Err: fmt.Sprintf("could not fetch the CA certificate: %s", caErr)}
-	case errutil.InternalError:
-		return errutil.InternalError{Err: fmt.Sprintf("error fetching CA certificate: %s", caErr)}
+	tbsCertList := pkix.TBSCertificateList{
+		Version:             1,
+		Signature:           sigAlg,
+		Issuer:              issuer,
+		ThisUpdate:          thisUpdate.UTC(),
+		NextUpdate:          nextUpdate.UTC(),
+		RevokedCertificates: revokedUTC,
+		Extensions:          extensions,
 	}
 
-	crlBytes, err := signingBundle.Certificate.CreateCRL(rand.Reader, signingBundle.PrivateKey, revokedCerts, time.Now(), time.Now().Add(crlLifetime))
+	tbsCertListContents, err := asn1.Marshal(tbsCertList)
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("error creating new CRL: %s", err)}
+		return nil, err
 	}
 
-	err = req.Storage.Put(ctx, &logical.StorageEntry{
-		Key:   "crl",
-		Value: crlBytes,
+	h := hashFunc.New()
+	h.Write(tbsCertListContents)
+	signature, err := signingBundle.PrivateKey.Sign(rand.Reader, h.Sum(nil), hashFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pkix.CertificateList{
+		TBSCertList:        tbsCertList,
+		SignatureAlgorithm: sigAlg,
+		SignatureValue:     asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
 	})
+}
+
+// crlSignatureAlgorithm picks the same signature algorithms as the
+// standard library does when signing with the given key
+func crlSignatureAlgorithm(pub crypto.PublicKey) (crypto.Hash, pkix.AlgorithmIdentifier, error) {
+	switch pub := pub.(type) {
+	case *rsa.PublicKey:
+		return crypto.SHA256, pkix.AlgorithmIdentifier{
+			Algorithm:  oidSignatureSHA256WithRSA,
+			Parameters: asn1.NullRawValue,
+		}, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P384():
+			return crypto.SHA384, pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA384}, nil
+		case elliptic.P521():
+			return crypto.SHA512, pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA512}, nil
+		default:
+			return crypto.SHA256, pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA256}, nil
+		}
+	default:
+		return 0, pkix.AlgorithmIdentifier{}, fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+// fetchRevokedCertsByIssuer loads all revocation entries, grouped by the ID
+// of the issuer which signed the revoked certificate.
+func fetchRevokedCertsByIssuer(ctx context.Context, req *logical.Request) (map[string][]pkix.RevokedCertificate, error) {
+	revokedCerts := make(map[string][]pkix.RevokedCertificate)
+
+	revokedSerials, err := req.Storage.List(ctx, "revoked/")
 	if err != nil {
-		return errutil.InternalError{Err: fmt.Sprintf("error storing CRL: %s", err)}
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching list of revoked certs: %s", err)}
 	}
 
-	return nil
+	for _, serial := range revokedSerials {
+		revokedEntry, err := req.Storage.Get(ctx, "revoked/"+serial)
+		if err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch revoked cert with serial %s: %s", serial, err)}
+		}
+		if revokedEntry == nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("revoked certificate entry for serial %s is nil", serial)}
+		}
+		if revokedEntry.Value == nil || len(revokedEntry.Value) == 0 {
+			// TODO: In this case, remove it and continue? How likely is this to
+			// happen? Alternately, could skip it entirely, or could implement a
+			// delete function so that there is a way to remove these
+			return nil, errutil.InternalError{Err: fmt.Sprintf("found revoked serial but actual certificate is empty")}
+		}
+
+		issuerID, newRevCert, err := parseRevokedEntry(ctx, req.Storage, serial, revokedEntry)
+		if err != nil {
+			return nil, err
+		}
+		revokedCerts[issuerID] = append(revokedCerts[issuerID], newRevCert)
+	}
+
+	return revokedCerts, nil
+}
+
+// parseRevokedEntry decodes a revocation entry into a CRL entry, and finds
+// the ID of the issuer which signed the revoked certificate.
+func parseRevokedEntry(ctx context.Context, s logical.Storage, serial string, revokedEntry *logical.StorageEntry) (string, pkix.RevokedCertificate, error) {
+	var revInfo revocationInfo
+	err := revokedEntry.DecodeJSON(&revInfo)
+	if err != nil {
+		return "", pkix.RevokedCertificate{}, errutil.InternalError{Err: fmt.Sprintf("error decoding revocation entry for serial %s: %s", serial, err)}
+	}
+
+	revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
+	if err != nil {
+		return "", pkix.RevokedCertificate{}, errutil.InternalError{Err: fmt.Sprintf("unable to parse stored revoked certificate with serial %s: %s", serial, err)}
+	}
+
+	// Entries written before multiple issuers were supported don't
+	// record their issuer, so find it from the certificate itself.
+	issuerID := revInfo.CertificateIssuer
+	if issuerID == "" {
+		issuerID, err = findIssuerForCert(ctx, s, revokedCert)
+		if err != nil {
+			return "", pkix.RevokedCertificate{}, errutil.InternalError{Err: fmt.Sprintf("unable to find issuer of revoked certificate with serial %s: %s", serial, err)}
+		}
+	}
+
+	// NOTE: We have to change this to UTC time because the CRL standard
+	// mandates it but Go will happily encode the CRL without this.
+	newRevCert := pkix.RevokedCertificate{
+		SerialNumber: revokedCert.SerialNumber,
+	}
+	if !revInfo.RevocationTimeUTC.IsZero() {
+		newRevCert.RevocationTime = revInfo.RevocationTimeUTC
+	} else {
+		newRevCert.RevocationTime = time.Unix(revInfo.RevocationTime, 0).UTC()
+	}
+
+	return issuerID, newRevCert, nil
 }
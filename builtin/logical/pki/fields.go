@@ -264,6 +264,40 @@ and "ec" are the only valid values.`,
 			Value: "rsa",
 		},
 	}
+
+	fields["key_name"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Optional name to assign to the generated key,
+which can be used in place of its ID. Must be
+unique within the mount.`,
+	}
+	return fields
+}
+
+// addIssuerNameField adds the name field for paths which create a new
+// issuer
+func addIssuerNameField(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["issuer_name"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Optional name to assign to the new issuer,
+which can be used in place of its ID. Must be
+unique within the mount and may not be "default".`,
+	}
+
+	return fields
+}
+
+// addIssuerRefField adds the issuer reference for paths which sign with a
+// specific issuer
+func addIssuerRefField(fields map[string]*framework.FieldSchema) map[string]*framework.FieldSchema {
+	fields["issuer_ref"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `Reference to an existing issuer; either
+"default" for the mount's default issuer, an
+issuer ID, or an issuer name.`,
+		Default: defaultRef,
+	}
+
 	return fields
 }
 
@@ -0,0 +1,794 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathACMEDirectory(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/directory",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathACMEDirectoryRead,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewNonce(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/new-nonce",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathACMENewNonce,
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewAccount(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/new-account",
+		Fields:  acmeJWSFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeOperation(true, b.acmeNewAccount),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEAccount(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/account/" + framework.GenericNameRegex("account_id"),
+		Fields: acmeJWSFields(map[string]*framework.FieldSchema{
+			"account_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the ACME account.`,
+			},
+		}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeOperation(false, b.acmeAccount),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMENewOrder(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/new-order",
+		Fields:  acmeJWSFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeOperation(false, b.acmeNewOrder),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEOrder(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/order/" + framework.GenericNameRegex("order_id") + "(?P<finalize>/finalize)?",
+		Fields: acmeJWSFields(map[string]*framework.FieldSchema{
+			"order_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the ACME order.`,
+			},
+			"finalize": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Set when finalizing the order.`,
+			},
+		}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeOperation(false, b.acmeOrder),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEAuthorization(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/authorization/" + framework.GenericNameRegex("authorization_id"),
+		Fields: acmeJWSFields(map[string]*framework.FieldSchema{
+			"authorization_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the ACME authorization.`,
+			},
+		}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeOperation(false, b.acmeAuthorization),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMEChallenge(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/challenge/" + framework.GenericNameRegex("authorization_id") + "/" + framework.GenericNameRegex("challenge_type"),
+		Fields: acmeJWSFields(map[string]*framework.FieldSchema{
+			"authorization_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the ACME authorization.`,
+			},
+			"challenge_type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Type of the challenge.`,
+			},
+		}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeOperation(false, b.acmeChallenge),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMECert(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/cert/" + framework.GenericNameRegex("order_id"),
+		Fields: acmeJWSFields(map[string]*framework.FieldSchema{
+			"order_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `ID of the ACME order.`,
+			},
+		}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeOperation(false, b.acmeCert),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func pathACMERevokeCert(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "acme/revoke-cert",
+		Fields:  acmeJWSFields(map[string]*framework.FieldSchema{}),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.acmeOperation(false, b.acmeRevokeCert),
+		},
+
+		HelpSynopsis:    pathACMEHelpSyn,
+		HelpDescription: pathACMEHelpDesc,
+	}
+}
+
+func (b *backend) pathACMEDirectoryRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, resp, err := b.getEnabledACMEConfig(ctx, req)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	return b.acmeResponse(config, &acmeReply{
+		status: http.StatusOK,
+		body: map[string]interface{}{
+			"newNonce":   acmeURL(config, "new-nonce"),
+			"newAccount": acmeURL(config, "new-account"),
+			"newOrder":   acmeURL(config, "new-order"),
+			"revokeCert": acmeURL(config, "revoke-cert"),
+			"meta": map[string]interface{}{
+				"externalAccountRequired": false,
+			},
+		},
+	})
+}
+
+func (b *backend) pathACMENewNonce(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, resp, err := b.getEnabledACMEConfig(ctx, req)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	return b.acmeResponse(config, &acmeReply{
+		status: http.StatusNoContent,
+	})
+}
+
+func (b *backend) acmeNewAccount(ctx context.Context, r *acmeRequest) (*acmeReply, error) {
+	var payload struct {
+		Contact              []string `json:"contact"`
+		TermsOfServiceAgreed bool     `json:"termsOfServiceAgreed"`
+		OnlyReturnExisting   bool     `json:"onlyReturnExisting"`
+	}
+	if err := r.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+
+	// Accounts are found by the thumbprint of their key
+	var existing acmeThumbprintEntry
+	ok, err := getACMEEntry(ctx, r.req.Storage, acmeThumbprintPrefix+r.thumbprint, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		account, err := getACMEAccount(ctx, r.req.Storage, existing.AccountID)
+		if err != nil {
+			return nil, err
+		}
+		if account != nil {
+			return &acmeReply{
+				status:   http.StatusOK,
+				body:     account.toACME(r.config),
+				location: acmeURL(r.config, "account/"+account.ID),
+			}, nil
+		}
+	}
+
+	if payload.OnlyReturnExisting {
+		return nil, newACMEProblem(http.StatusBadRequest, "accountDoesNotExist", "no account exists with the provided key")
+	}
+	if err := validateACMEContacts(payload.Contact); err != nil {
+		return nil, err
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	account := &acmeAccountEntry{
+		ID:         id,
+		Key:        r.jwk,
+		Thumbprint: r.thumbprint,
+		Status:     acmeStatusValid,
+		Contact:    payload.Contact,
+		CreatedAt:  time.Now(),
+	}
+	if err := putACMEEntry(ctx, r.req.Storage, acmeAccountPrefix+id, account); err != nil {
+		return nil, err
+	}
+	if err := putACMEEntry(ctx, r.req.Storage, acmeThumbprintPrefix+r.thumbprint, &acmeThumbprintEntry{AccountID: id}); err != nil {
+		return nil, err
+	}
+
+	return &acmeReply{
+		status:   http.StatusCreated,
+		body:     account.toACME(r.config),
+		location: acmeURL(r.config, "account/"+id),
+	}, nil
+}
+
+func (b *backend) acmeAccount(ctx context.Context, r *acmeRequest) (*acmeReply, error) {
+	if r.data.Get("account_id").(string) != r.account.ID {
+		return nil, newACMEProblem(http.StatusUnauthorized, "unauthorized", "account does not match the signing key")
+	}
+	account := r.account
+
+	if !r.isPostAsGet() {
+		var payload struct {
+			Contact []string `json:"contact"`
+			Status  string   `json:"status"`
+		}
+		if err := r.decodePayload(&payload); err != nil {
+			return nil, err
+		}
+
+		switch payload.Status {
+		case "":
+		case acmeStatusDeactivated:
+			account.Status = acmeStatusDeactivated
+		default:
+			return nil, newACMEProblem(http.StatusBadRequest, "malformed", "accounts may only be deactivated")
+		}
+		if payload.Contact != nil {
+			if err := validateACMEContacts(payload.Contact); err != nil {
+				return nil, err
+			}
+			account.Contact = payload.Contact
+		}
+
+		if err := putACMEEntry(ctx, r.req.Storage, acmeAccountPrefix+account.ID, account); err != nil {
+			return nil, err
+		}
+	}
+
+	return &acmeReply{
+		status: http.StatusOK,
+		body:   account.toACME(r.config),
+	}, nil
+}
+
+func (b *backend) acmeNewOrder(ctx context.Context, r *acmeRequest) (*acmeReply, error) {
+	var payload struct {
+		Identifiers []acmeIdentifier `json:"identifiers"`
+		NotBefore   string           `json:"notBefore"`
+		NotAfter    string           `json:"notAfter"`
+	}
+	if err := r.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+	if payload.NotBefore != "" || payload.NotAfter != "" {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "notBefore and notAfter are not supported; validity is controlled by the role")
+	}
+	if len(payload.Identifiers) == 0 {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "at least one identifier is required")
+	}
+
+	role, err := b.getACMERole(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	orderID, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+	order := &acmeOrderEntry{
+		ID:        orderID,
+		AccountID: r.account.ID,
+		Status:    acmeStatusPending,
+		Expires:   time.Now().Add(acmeOrderLifetime),
+	}
+
+	seen := make(map[string]bool, len(payload.Identifiers))
+	for _, identifier := range payload.Identifiers {
+		if identifier.Type != "dns" {
+			return nil, newACMEProblem(http.StatusBadRequest, "unsupportedIdentifier", fmt.Sprintf("unsupported identifier type %q", identifier.Type))
+		}
+		identifier.Value = strings.ToLower(identifier.Value)
+		if seen[identifier.Value] {
+			continue
+		}
+		seen[identifier.Value] = true
+
+		if badName := validateNames(b, &inputBundle{req: r.req, role: role}, []string{identifier.Value}); badName != "" {
+			return nil, newACMEProblem(http.StatusBadRequest, "rejectedIdentifier", fmt.Sprintf("identifier %q is not allowed by the ACME role", badName))
+		}
+
+		authz, err := b.newACMEAuthorization(r, identifier, order.Expires)
+		if err != nil {
+			return nil, err
+		}
+		if err := putACMEEntry(ctx, r.req.Storage, acmeAuthorizationPrefix+authz.ID, authz); err != nil {
+			return nil, err
+		}
+
+		order.Identifiers = append(order.Identifiers, identifier)
+		order.AuthorizationIDs = append(order.AuthorizationIDs, authz.ID)
+	}
+
+	if err := putACMEEntry(ctx, r.req.Storage, acmeOrderPrefix+orderID, order); err != nil {
+		return nil, err
+	}
+
+	return &acmeReply{
+		status:   http.StatusCreated,
+		body:     order.toACME(r.config),
+		location: acmeURL(r.config, "order/"+orderID),
+	}, nil
+}
+
+// newACMEAuthorization creates an authorization for the given identifier,
+// offering every configured challenge type able to validate it
+func (b *backend) newACMEAuthorization(r *acmeRequest, identifier acmeIdentifier, expires time.Time) (*acmeAuthorizationEntry, error) {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	authz := &acmeAuthorizationEntry{
+		ID:         id,
+		AccountID:  r.account.ID,
+		Identifier: identifier,
+		Status:     acmeStatusPending,
+		Expires:    expires,
+	}
+	if strings.HasPrefix(identifier.Value, "*.") {
+		authz.Identifier.Value = identifier.Value[2:]
+		authz.Wildcard = true
+	}
+
+	for _, challengeType := range r.config.ChallengeTypes {
+		validator, ok := b.acmeValidators[challengeType]
+		if !ok || (authz.Wildcard && !validator.SupportsWildcard()) {
+			continue
+		}
+		token, err := acmeRandomToken()
+		if err != nil {
+			return nil, err
+		}
+		authz.Challenges = append(authz.Challenges, &acmeChallengeEntry{
+			Type:   challengeType,
+			Token:  token,
+			Status: acmeStatusPending,
+		})
+	}
+	if len(authz.Challenges) == 0 {
+		return nil, newACMEProblem(http.StatusBadRequest, "rejectedIdentifier", fmt.Sprintf("no configured challenge type can validate %q", identifier.Value))
+	}
+
+	return authz, nil
+}
+
+func (b *backend) acmeOrder(ctx context.Context, r *acmeRequest) (*acmeReply, error) {
+	order, err := b.fetchACMEOrder(ctx, r, r.data.Get("order_id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if r.data.Get("finalize").(string) != "" {
+		if err := b.finalizeACMEOrder(ctx, r, order); err != nil {
+			return nil, err
+		}
+	}
+
+	return &acmeReply{
+		status:   http.StatusOK,
+		body:     order.toACME(r.config),
+		location: acmeURL(r.config, "order/"+order.ID),
+	}, nil
+}
+
+// fetchACMEOrder loads an order of the requesting account, bringing its
+// status up to date with that of its authorizations
+func (b *backend) fetchACMEOrder(ctx context.Context, r *acmeRequest, id string) (*acmeOrderEntry, error) {
+	order, err := getACMEOrder(ctx, r.req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if order == nil || order.AccountID != r.account.ID {
+		return nil, newACMEProblem(http.StatusNotFound, "malformed", "unknown order")
+	}
+
+	if order.Status != acmeStatusPending && order.Status != acmeStatusReady {
+		return order, nil
+	}
+
+	status := acmeStatusReady
+	if time.Now().After(order.Expires) {
+		status = acmeStatusInvalid
+	}
+	for _, authzID := range order.AuthorizationIDs {
+		if status == acmeStatusInvalid {
+			break
+		}
+		authz, err := getACMEAuthorization(ctx, r.req.Storage, authzID)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case authz == nil:
+			status = acmeStatusInvalid
+		case authz.Status == acmeStatusValid:
+		case authz.Status == acmeStatusPending:
+			status = acmeStatusPending
+		default:
+			status = acmeStatusInvalid
+		}
+	}
+
+	if status != order.Status {
+		order.Status = status
+		if err := putACMEEntry(ctx, r.req.Storage, acmeOrderPrefix+order.ID, order); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// finalizeACMEOrder issues the certificate of a ready order from the CSR in
+// the request, using the ACME role
+func (b *backend) finalizeACMEOrder(ctx context.Context, r *acmeRequest, order *acmeOrderEntry) error {
+	if order.Status != acmeStatusReady {
+		return newACMEProblem(http.StatusForbidden, "orderNotReady", fmt.Sprintf("order is %s", order.Status))
+	}
+
+	var payload struct {
+		CSR string `json:"csr"`
+	}
+	if err := r.decodePayload(&payload); err != nil {
+		return err
+	}
+	csrBytes, err := base64.RawURLEncoding.DecodeString(payload.CSR)
+	if err != nil {
+		return newACMEProblem(http.StatusBadRequest, "badCSR", "csr is not base64url-encoded")
+	}
+	csr, err := x509.ParseCertificateRequest(csrBytes)
+	if err != nil {
+		return newACMEProblem(http.StatusBadRequest, "badCSR", fmt.Sprintf("unable to parse csr: %s", err))
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return newACMEProblem(http.StatusBadRequest, "badCSR", fmt.Sprintf("invalid csr signature: %s", err))
+	}
+
+	// The CSR must request exactly the identifiers of the order
+	var csrNames []string
+	if csr.Subject.CommonName != "" {
+		csrNames = append(csrNames, csr.Subject.CommonName)
+	}
+	csrNames = strutil.RemoveDuplicates(append(csrNames, csr.DNSNames...), true)
+	var orderNames []string
+	for _, identifier := range order.Identifiers {
+		orderNames = append(orderNames, identifier.Value)
+	}
+	if !strutil.EquivalentSlices(csrNames, orderNames) {
+		return newACMEProblem(http.StatusBadRequest, "badCSR", "csr names do not match the identifiers of the order")
+	}
+	if len(csr.EmailAddresses) > 0 || len(csr.IPAddresses) > 0 || len(csr.URIs) > 0 {
+		return newACMEProblem(http.StatusBadRequest, "badCSR", "csr may only contain DNS names")
+	}
+
+	role, err := b.getACMERole(ctx, r)
+	if err != nil {
+		return err
+	}
+	role.UseCSRCommonName = true
+	role.UseCSRSANs = true
+	role.GenerateLease = new(bool)
+
+	signPath := pathSign(b)
+	data := &framework.FieldData{
+		Raw: map[string]interface{}{
+			"csr": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "CERTIFICATE REQUEST",
+				Bytes: csrBytes,
+			})),
+			"format": "pem",
+			"role":   r.config.Role,
+		},
+		Schema: signPath.Fields,
+	}
+	resp, err := b.pathIssueSignCert(ctx, r.req, data, role, true, false)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return newACMEProblem(http.StatusBadRequest, "badCSR", err.Error())
+		default:
+			return err
+		}
+	}
+	if resp.IsError() {
+		return newACMEProblem(http.StatusBadRequest, "badCSR", resp.Error().Error())
+	}
+
+	chain := []string{resp.Data["certificate"].(string)}
+	if caChain, ok := resp.Data["ca_chain"].([]string); ok {
+		chain = append(chain, caChain...)
+	} else {
+		chain = append(chain, resp.Data["issuing_ca"].(string))
+	}
+
+	order.Status = acmeStatusValid
+	order.CertificateSerial = resp.Data["serial_number"].(string)
+	order.CertificateChain = strings.Join(chain, "\n") + "\n"
+	if err := putACMEEntry(ctx, r.req.Storage, acmeOrderPrefix+order.ID, order); err != nil {
+		return err
+	}
+
+	return putACMEEntry(ctx, r.req.Storage, acmeCertPrefix+normalizeSerial(order.CertificateSerial), &acmeCertEntry{
+		AccountID: r.account.ID,
+		OrderID:   order.ID,
+	})
+}
+
+func (b *backend) acmeAuthorization(ctx context.Context, r *acmeRequest) (*acmeReply, error) {
+	authz, err := b.fetchACMEAuthorization(ctx, r, r.data.Get("authorization_id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	if !r.isPostAsGet() {
+		var payload struct {
+			Status string `json:"status"`
+		}
+		if err := r.decodePayload(&payload); err != nil {
+			return nil, err
+		}
+		if payload.Status != acmeStatusDeactivated {
+			return nil, newACMEProblem(http.StatusBadRequest, "malformed", "authorizations may only be deactivated")
+		}
+		authz.Status = acmeStatusDeactivated
+		if err := putACMEEntry(ctx, r.req.Storage, acmeAuthorizationPrefix+authz.ID, authz); err != nil {
+			return nil, err
+		}
+	}
+
+	return &acmeReply{
+		status: http.StatusOK,
+		body:   authz.toACME(r.config),
+	}, nil
+}
+
+func (b *backend) fetchACMEAuthorization(ctx context.Context, r *acmeRequest, id string) (*acmeAuthorizationEntry, error) {
+	authz, err := getACMEAuthorization(ctx, r.req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if authz == nil || authz.AccountID != r.account.ID {
+		return nil, newACMEProblem(http.StatusNotFound, "malformed", "unknown authorization")
+	}
+
+	if authz.Status == acmeStatusPending && time.Now().After(authz.Expires) {
+		authz.Status = acmeStatusInvalid
+		if err := putACMEEntry(ctx, r.req.Storage, acmeAuthorizationPrefix+authz.ID, authz); err != nil {
+			return nil, err
+		}
+	}
+
+	return authz, nil
+}
+
+func (b *backend) acmeChallenge(ctx context.Context, r *acmeRequest) (*acmeReply, error) {
+	authz, err := b.fetchACMEAuthorization(ctx, r, r.data.Get("authorization_id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	challengeType := r.data.Get("challenge_type").(string)
+	var challenge *acmeChallengeEntry
+	for _, c := range authz.Challenges {
+		if c.Type == challengeType {
+			challenge = c
+		}
+	}
+	if challenge == nil {
+		return nil, newACMEProblem(http.StatusNotFound, "malformed", "unknown challenge")
+	}
+
+	// An empty payload only fetches the challenge, while any other payload
+	// asks for it to be validated
+	if !r.isPostAsGet() && authz.Status == acmeStatusPending && challenge.Status == acmeStatusPending {
+		validator, ok := b.acmeValidators[challenge.Type]
+		if !ok {
+			return nil, fmt.Errorf("no validator for challenge type %q", challenge.Type)
+		}
+
+		keyAuthorization := challenge.Token + "." + r.thumbprint
+		if err := validator.Validate(ctx, r.config, authz.Identifier.Value, challenge.Token, keyAuthorization); err != nil {
+			challenge.Status = acmeStatusInvalid
+			challenge.Error = newACMEProblem(http.StatusForbidden, "incorrectResponse", err.Error())
+			authz.Status = acmeStatusInvalid
+		} else {
+			challenge.Status = acmeStatusValid
+			challenge.Validated = time.Now()
+			authz.Status = acmeStatusValid
+		}
+
+		if err := putACMEEntry(ctx, r.req.Storage, acmeAuthorizationPrefix+authz.ID, authz); err != nil {
+			return nil, err
+		}
+	}
+
+	return &acmeReply{
+		status: http.StatusOK,
+		body:   challenge.toACME(r.config, authz.ID),
+		links:  []string{fmt.Sprintf("<%s>;rel=\"up\"", acmeURL(r.config, "authorization/"+authz.ID))},
+	}, nil
+}
+
+func (b *backend) acmeCert(ctx context.Context, r *acmeRequest) (*acmeReply, error) {
+	order, err := getACMEOrder(ctx, r.req.Storage, r.data.Get("order_id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if order == nil || order.AccountID != r.account.ID || order.Status != acmeStatusValid {
+		return nil, newACMEProblem(http.StatusNotFound, "malformed", "unknown certificate")
+	}
+
+	return &acmeReply{
+		status:      http.StatusOK,
+		contentType: "application/pem-certificate-chain",
+		rawBody:     []byte(order.CertificateChain),
+	}, nil
+}
+
+func (b *backend) acmeRevokeCert(ctx context.Context, r *acmeRequest) (*acmeReply, error) {
+	var payload struct {
+		Certificate string `json:"certificate"`
+	}
+	if err := r.decodePayload(&payload); err != nil {
+		return nil, err
+	}
+	certBytes, err := base64.RawURLEncoding.DecodeString(payload.Certificate)
+	if err != nil {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", "certificate is not base64url-encoded")
+	}
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", fmt.Sprintf("unable to parse certificate: %s", err))
+	}
+
+	// Only the account a certificate was issued to may revoke it
+	serial := certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":")
+	var certEntry acmeCertEntry
+	ok, err := getACMEEntry(ctx, r.req.Storage, acmeCertPrefix+normalizeSerial(serial), &certEntry)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || certEntry.AccountID != r.account.ID {
+		return nil, newACMEProblem(http.StatusForbidden, "unauthorized", "certificate was not issued to this account")
+	}
+
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
+
+	resp, err := revokeCert(ctx, b, r.req, serial, false)
+	if err != nil {
+		return nil, err
+	}
+	if resp.IsError() {
+		return nil, newACMEProblem(http.StatusBadRequest, "malformed", resp.Error().Error())
+	}
+
+	return &acmeReply{
+		status: http.StatusOK,
+	}, nil
+}
+
+// getACMERole returns a copy of the role configured for ACME issuance
+func (b *backend) getACMERole(ctx context.Context, r *acmeRequest) (*roleEntry, error) {
+	role, err := b.getRole(ctx, r.req.Storage, r.config.Role)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, newACMEProblem(http.StatusInternalServerError, "serverInternal", fmt.Sprintf("ACME role %q does not exist", r.config.Role))
+	}
+	return role, nil
+}
+
+// validateACMEContacts checks that account contacts are mailto URLs, the
+// only kind RFC 8555 requires servers to support
+func validateACMEContacts(contacts []string) error {
+	for _, contact := range contacts {
+		if !strings.HasPrefix(contact, "mailto:") || strings.Contains(contact, ",") {
+			return newACMEProblem(http.StatusBadRequest, "invalidContact", fmt.Sprintf("unsupported contact %q", contact))
+		}
+	}
+	return nil
+}
+
+const pathACMEHelpSyn = `
+ACME (RFC 8555) server endpoints.
+`
+
+const pathACMEHelpDesc = `
+These endpoints implement an ACME server, allowing standard ACME clients to
+obtain certificates from this mount without a Vault token. Clients should be
+configured with the directory URL, "<base_url>/acme/directory".
+
+Requests are authenticated by the JWS signature of the client's account key,
+and control of each requested DNS identifier is proven via the http-01 or
+dns-01 challenges. The identifiers which may be requested, and the
+certificates issued, are governed by the role set in config/acme.
+
+Accounts may revoke the certificates issued to them via revoke-cert; key
+rollover and revocation signed by the certificate's own key are not
+supported.
+`
@@ -0,0 +1,449 @@
+package pki
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+const testACMEBaseURL = "https://vault.example.com/v1/pki"
+
+type testACMEValidator struct {
+	keyAuthorizations []string
+}
+
+func (v *testACMEValidator) SupportsWildcard() bool {
+	return true
+}
+
+func (v *testACMEValidator) Validate(ctx context.Context, config *acmeConfigEntry, domain, token, keyAuthorization string) error {
+	v.keyAuthorizations = append(v.keyAuthorizations, keyAuthorization)
+	return nil
+}
+
+// testACMEClient signs requests to the backend as an ACME client would
+type testACMEClient struct {
+	t          *testing.T
+	b          *backend
+	storage    logical.Storage
+	key        *ecdsa.PrivateKey
+	accountURL string
+	nonce      string
+}
+
+func (c *testACMEClient) Nonce() (string, error) {
+	return c.nonce, nil
+}
+
+// sign returns the flattened JWS of an ACME request to the given path
+func (c *testACMEClient) sign(path string, payload interface{}) map[string]interface{} {
+	c.t.Helper()
+
+	signingKey := jose.SigningKey{Algorithm: jose.ES256, Key: c.key}
+	options := &jose.SignerOptions{
+		NonceSource: c,
+		EmbedJWK:    c.accountURL == "",
+		ExtraHeaders: map[jose.HeaderKey]interface{}{
+			"url": testACMEBaseURL + "/" + path,
+		},
+	}
+	if c.accountURL != "" {
+		signingKey.Key = jose.JSONWebKey{Key: c.key, KeyID: c.accountURL}
+	}
+	signer, err := jose.NewSigner(signingKey, options)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+
+	var payloadBytes []byte
+	if payload != nil {
+		payloadBytes, err = json.Marshal(payload)
+		if err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	jws, err := signer.Sign(payloadBytes)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(jws.FullSerialize()), &data); err != nil {
+		c.t.Fatal(err)
+	}
+	return data
+}
+
+func (c *testACMEClient) post(path string, payload interface{}) *logical.Response {
+	c.t.Helper()
+
+	resp, err := c.b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      path,
+		Storage:   c.storage,
+		Data:      c.sign(path, payload),
+	})
+	if err != nil || resp == nil {
+		c.t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+	}
+	c.nonce = resp.Headers["Replay-Nonce"][0]
+	return resp
+}
+
+func (c *testACMEClient) postOK(path string, payload interface{}) map[string]interface{} {
+	c.t.Helper()
+
+	resp := c.post(path, payload)
+	status := resp.Data[logical.HTTPStatusCode].(int)
+	if status != http.StatusOK && status != http.StatusCreated {
+		c.t.Fatalf("bad: path: %s status: %d body: %s", path, status, resp.Data[logical.HTTPRawBody])
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &body); err != nil {
+		c.t.Fatal(err)
+	}
+	return body
+}
+
+func (c *testACMEClient) expectProblem(resp *logical.Response, errType string) {
+	c.t.Helper()
+
+	var problem acmeProblem
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &problem); err != nil {
+		c.t.Fatal(err)
+	}
+	if problem.Type != acmeErrorPrefix+errType {
+		c.t.Fatalf("expected problem %s, got %#v", errType, problem)
+	}
+}
+
+func TestPki_ACME(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+	validator := &testACMEValidator{}
+	b.acmeValidators[acmeChallengeHTTP01] = validator
+
+	write := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	read := func(path string) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+			Storage:   storage,
+		})
+		if err != nil || resp == nil {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	write("root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "8760h",
+		"key_type":    "ec",
+		"key_bits":    256,
+	})
+	write("roles/acme", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+		"key_type":         "ec",
+		"key_bits":         256,
+	})
+
+	if status := read("acme/directory").Data[logical.HTTPStatusCode]; status != http.StatusForbidden {
+		t.Fatalf("expected directory to be unavailable while disabled, got %v", status)
+	}
+	write("config/acme", map[string]interface{}{
+		"enabled":         true,
+		"base_url":        testACMEBaseURL + "/",
+		"role":            "acme",
+		"challenge_types": "http-01",
+	})
+
+	var directory map[string]interface{}
+	resp := read("acme/directory")
+	if err := json.Unmarshal(resp.Data[logical.HTTPRawBody].([]byte), &directory); err != nil {
+		t.Fatal(err)
+	}
+	if directory["newAccount"] != testACMEBaseURL+"/acme/new-account" {
+		t.Fatalf("unexpected directory: %#v", directory)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &testACMEClient{
+		t:       t,
+		b:       b,
+		storage: storage,
+		key:     key,
+	}
+
+	// Requests must carry a nonce issued by the server
+	client.nonce = "bogus"
+	client.expectProblem(client.post("acme/new-account", map[string]interface{}{}), "badNonce")
+
+	resp = read("acme/new-nonce")
+	if resp.Data[logical.HTTPStatusCode] != http.StatusNoContent {
+		t.Fatalf("unexpected new-nonce response: %#v", resp)
+	}
+	client.nonce = resp.Headers["Replay-Nonce"][0]
+
+	resp = client.post("acme/new-account", map[string]interface{}{
+		"contact":              []string{"mailto:admin@example.com"},
+		"termsOfServiceAgreed": true,
+	})
+	if resp.Data[logical.HTTPStatusCode] != http.StatusCreated {
+		t.Fatalf("unexpected new-account response: %s", resp.Data[logical.HTTPRawBody])
+	}
+	client.accountURL = resp.Headers["Location"][0]
+
+	// A request with a forged signature doesn't consume the nonce it carries
+	forgedKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forger := &testACMEClient{
+		t:          t,
+		b:          b,
+		storage:    storage,
+		key:        forgedKey,
+		accountURL: client.accountURL,
+		nonce:      client.nonce,
+	}
+	forger.expectProblem(forger.post("acme/new-order", map[string]interface{}{
+		"identifiers": []map[string]string{{"type": "dns", "value": "www.example.com"}},
+	}), "malformed")
+
+	// The client's own request with that nonce is still accepted
+	client.expectProblem(client.post("acme/new-order", map[string]interface{}{
+		"identifiers": []map[string]string{{"type": "dns", "value": "www.example.org"}},
+	}), "rejectedIdentifier")
+
+	order := client.postOK("acme/new-order", map[string]interface{}{
+		"identifiers": []map[string]string{{"type": "dns", "value": "www.example.com"}},
+	})
+	if order["status"] != acmeStatusPending {
+		t.Fatalf("unexpected order: %#v", order)
+	}
+	orderPath := strings.TrimPrefix(order["finalize"].(string), testACMEBaseURL+"/")
+	orderPath = strings.TrimSuffix(orderPath, "/finalize")
+
+	authzPath := strings.TrimPrefix(order["authorizations"].([]interface{})[0].(string), testACMEBaseURL+"/")
+	authz := client.postOK(authzPath, nil)
+	challenge := authz["challenges"].([]interface{})[0].(map[string]interface{})
+	if challenge["type"] != acmeChallengeHTTP01 {
+		t.Fatalf("unexpected challenge: %#v", challenge)
+	}
+
+	// Finalizing before the challenge is complete must fail
+	client.expectProblem(client.post(orderPath+"/finalize", map[string]interface{}{"csr": ""}), "orderNotReady")
+
+	challenge = client.postOK(strings.TrimPrefix(challenge["url"].(string), testACMEBaseURL+"/"), map[string]interface{}{})
+	if challenge["status"] != acmeStatusValid {
+		t.Fatalf("unexpected challenge: %#v", challenge)
+	}
+	jwk := jose.JSONWebKey{Key: key.Public()}
+	thumbprint, err := jwk.Thumbprint(crypto.SHA256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := challenge["token"].(string) + "." + base64.RawURLEncoding.EncodeToString(thumbprint)
+	if len(validator.keyAuthorizations) != 1 || validator.keyAuthorizations[0] != expected {
+		t.Fatalf("unexpected key authorizations %v", validator.keyAuthorizations)
+	}
+
+	if order = client.postOK(orderPath, nil); order["status"] != acmeStatusReady {
+		t.Fatalf("unexpected order: %#v", order)
+	}
+
+	csrKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newCSR := func(names ...string) string {
+		csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+			Subject:  pkix.Name{CommonName: names[0]},
+			DNSNames: names,
+		}, csrKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(csr)
+	}
+
+	client.expectProblem(client.post(orderPath+"/finalize", map[string]interface{}{
+		"csr": newCSR("www.example.com", "other.example.com"),
+	}), "badCSR")
+
+	order = client.postOK(orderPath+"/finalize", map[string]interface{}{
+		"csr": newCSR("www.example.com"),
+	})
+	if order["status"] != acmeStatusValid {
+		t.Fatalf("unexpected order: %#v", order)
+	}
+
+	resp = client.post(strings.TrimPrefix(order["certificate"].(string), testACMEBaseURL+"/"), nil)
+	if resp.Data[logical.HTTPContentType] != "application/pem-certificate-chain" {
+		t.Fatalf("unexpected certificate response: %#v", resp)
+	}
+	block, _ := pem.Decode(resp.Data[logical.HTTPRawBody].([]byte))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "www.example.com" || cert.Issuer.CommonName != "root.example.com" {
+		t.Fatalf("unexpected certificate %s issued by %s", cert.Subject.CommonName, cert.Issuer.CommonName)
+	}
+
+	resp = client.post("acme/revoke-cert", map[string]interface{}{
+		"certificate": base64.RawURLEncoding.EncodeToString(cert.Raw),
+	})
+	if resp.Data[logical.HTTPStatusCode] != http.StatusOK {
+		t.Fatalf("unexpected revoke-cert response: %s", resp.Data[logical.HTTPRawBody])
+	}
+	entry, err := fetchCertBySerial(context.Background(), &logical.Request{Storage: storage}, "revoked/", certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":"))
+	if err != nil || entry == nil {
+		t.Fatalf("expected certificate to be revoked, err: %v", err)
+	}
+}
+
+func TestPki_ACMEUnauthenticated(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"pki": Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	client := cluster.Cores[0].Client
+	if err := client.Sys().Mount("pki", &api.MountInput{
+		Type: "pki",
+		Config: api.MountConfigInput{
+			AllowedResponseHeaders: []string{"Replay-Nonce", "Location", "Link"},
+		},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("pki/root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "8760h",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("pki/roles/acme", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Logical().Write("pki/config/acme", map[string]interface{}{
+		"enabled":  true,
+		"base_url": testACMEBaseURL,
+		"role":     "acme",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// ACME clients don't have a token, so every request goes through the
+	// router unauthenticated
+	client.SetToken("")
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	acmeClient := &testACMEClient{
+		t:   t,
+		key: key,
+	}
+	do := func(method, path string, payload interface{}, status int) (*api.Response, map[string]interface{}) {
+		t.Helper()
+		req := client.NewRequest(method, "/v1/pki/"+path)
+		if method == "POST" {
+			if err := req.SetJSONBody(acmeClient.sign(path, payload)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		resp, err := client.RawRequest(req)
+		if err != nil {
+			t.Fatalf("path: %s err: %v", path, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != status {
+			t.Fatalf("path: %s expected status %d, got %d", path, status, resp.StatusCode)
+		}
+		acmeClient.nonce = resp.Header.Get("Replay-Nonce")
+		if acmeClient.nonce == "" {
+			t.Fatalf("path: %s missing Replay-Nonce header", path)
+		}
+
+		var body map[string]interface{}
+		raw, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &body); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp, body
+	}
+
+	_, directory := do("GET", "acme/directory", nil, http.StatusOK)
+	if directory["newAccount"] != testACMEBaseURL+"/acme/new-account" {
+		t.Fatalf("unexpected directory: %#v", directory)
+	}
+	do("GET", "acme/new-nonce", nil, http.StatusNoContent)
+
+	resp, _ := do("POST", "acme/new-account", map[string]interface{}{
+		"termsOfServiceAgreed": true,
+	}, http.StatusCreated)
+	acmeClient.accountURL = resp.Header.Get("Location")
+
+	resp, order := do("POST", "acme/new-order", map[string]interface{}{
+		"identifiers": []map[string]string{{"type": "dns", "value": "www.example.com"}},
+	}, http.StatusCreated)
+	orderPath := strings.TrimPrefix(resp.Header.Get("Location"), testACMEBaseURL+"/")
+	if _, order = do("POST", orderPath, nil, http.StatusOK); order["status"] != acmeStatusPending {
+		t.Fatalf("unexpected order: %#v", order)
+	}
+
+	authzPath := strings.TrimPrefix(order["authorizations"].([]interface{})[0].(string), testACMEBaseURL+"/")
+	do("POST", authzPath, nil, http.StatusOK)
+}
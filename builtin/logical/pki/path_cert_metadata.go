@@ -0,0 +1,468 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/ryanuber/go-glob"
+)
+
+const (
+	certMetadataPrefix = "cert-metadata/"
+
+	// certIndexPrefix holds the secondary indexes of the certificate
+	// metadata, as empty entries named <index>/<value>/<serial>
+	certIndexPrefix = "cert-index/"
+
+	certIndexRole   = "role/"
+	certIndexCN     = "cn/"
+	certIndexExpiry = "expiry/"
+
+	// Certificates are indexed by the day they expire on
+	certIndexExpiryLayout = "2006-01-02"
+)
+
+// certMetadata records how a certificate was issued. The entries under
+// cert-metadata/ form an index of the certificate store which can be
+// searched without parsing every stored certificate; tidy keeps it in step
+// with certs/. Searches find candidate certificates by role, common name and
+// expiry through the secondary indexes under cert-index/.
+type certMetadata struct {
+	SerialNumber string    `json:"serial_number"`
+	IssuerID     string    `json:"issuer_id"`
+	Role         string    `json:"role"`
+	CommonName   string    `json:"common_name"`
+	AltNames     []string  `json:"alt_names"`
+	IPSANs       []string  `json:"ip_sans"`
+	URISANs      []string  `json:"uri_sans"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	EntityID     string    `json:"entity_id"`
+}
+
+func newCertMetadata(cert *x509.Certificate, issuerID, role, entityID string) *certMetadata {
+	md := &certMetadata{
+		SerialNumber: certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":"),
+		IssuerID:     issuerID,
+		Role:         role,
+		CommonName:   cert.Subject.CommonName,
+		NotBefore:    cert.NotBefore.UTC(),
+		NotAfter:     cert.NotAfter.UTC(),
+		EntityID:     entityID,
+	}
+	md.AltNames = append(md.AltNames, cert.DNSNames...)
+	md.AltNames = append(md.AltNames, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		md.IPSANs = append(md.IPSANs, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		md.URISANs = append(md.URISANs, uri.String())
+	}
+	return md
+}
+
+// indexKeys returns the keys of the secondary index entries of the
+// certificate
+func (md *certMetadata) indexKeys() []string {
+	serial := normalizeSerial(md.SerialNumber)
+	keys := []string{
+		certIndexPrefix + certIndexExpiry + md.NotAfter.UTC().Format(certIndexExpiryLayout) + "/" + serial,
+	}
+	if md.Role != "" {
+		keys = append(keys, certIndexPrefix+certIndexRole+url.PathEscape(md.Role)+"/"+serial)
+	}
+	if md.CommonName != "" {
+		keys = append(keys, certIndexPrefix+certIndexCN+url.PathEscape(strings.ToLower(md.CommonName))+"/"+serial)
+	}
+	return keys
+}
+
+// storeCertMetadata stores the metadata of a certificate and its index
+// entries. The index entries are written first so that metadata is always
+// indexed.
+func storeCertMetadata(ctx context.Context, s logical.Storage, md *certMetadata) error {
+	for _, key := range md.indexKeys() {
+		if err := s.Put(ctx, &logical.StorageEntry{
+			Key:   key,
+			Value: []byte{},
+		}); err != nil {
+			return err
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(certMetadataPrefix+normalizeSerial(md.SerialNumber), md)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, entry)
+}
+
+// deleteCertMetadata removes the metadata of a certificate and its index
+// entries
+func deleteCertMetadata(ctx context.Context, s logical.Storage, serial string) error {
+	md, err := fetchCertMetadata(ctx, s, serial)
+	if err != nil {
+		return err
+	}
+	if md != nil {
+		for _, key := range md.indexKeys() {
+			if err := s.Delete(ctx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return s.Delete(ctx, certMetadataPrefix+normalizeSerial(serial))
+}
+
+func fetchCertMetadata(ctx context.Context, s logical.Storage, serial string) (*certMetadata, error) {
+	entry, err := s.Get(ctx, certMetadataPrefix+normalizeSerial(serial))
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching metadata of certificate %s: %s", serial, err)}
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var md certMetadata
+	if err := entry.DecodeJSON(&md); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error decoding metadata of certificate %s: %s", serial, err)}
+	}
+	return &md, nil
+}
+
+func (md *certMetadata) toResponseData() map[string]interface{} {
+	return map[string]interface{}{
+		"serial_number": md.SerialNumber,
+		"issuer_id":     md.IssuerID,
+		"role":          md.Role,
+		"common_name":   md.CommonName,
+		"alt_names":     md.AltNames,
+		"ip_sans":       md.IPSANs,
+		"uri_sans":      md.URISANs,
+		"not_before":    md.NotBefore.Format(time.RFC3339),
+		"not_after":     md.NotAfter.Format(time.RFC3339),
+		"entity_id":     md.EntityID,
+	}
+}
+
+// matchesName reports whether the common name or any of the subject
+// alternative names of the certificate match the glob pattern
+func (md *certMetadata) matchesName(pattern string) bool {
+	pattern = strings.ToLower(pattern)
+	names := []string{md.CommonName}
+	names = append(names, md.AltNames...)
+	names = append(names, md.IPSANs...)
+	names = append(names, md.URISANs...)
+	for _, name := range names {
+		if glob.Glob(pattern, strings.ToLower(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+func pathCertMetadata(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `cert-metadata/(?P<serial>[0-9A-Fa-f-:]+)`,
+		Fields: map[string]*framework.FieldSchema{
+			"serial": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Certificate serial number, in colon- or
+hyphen-separated octal`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathCertMetadataRead,
+		},
+
+		HelpSynopsis:    pathCertMetadataHelpSyn,
+		HelpDescription: pathCertMetadataHelpDesc,
+	}
+}
+
+func pathSearchCerts(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `certs/search`,
+		Fields: map[string]*framework.FieldSchema{
+			"role": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Only return certificates issued by this role.`,
+			},
+			"common_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Only return certificates whose common name
+matches this value, which may contain globs.`,
+			},
+			"alt_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Only return certificates whose common name or
+any subject alternative name matches this value,
+which may contain globs.`,
+			},
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Only return certificates signed by this issuer.`,
+			},
+			"entity_id": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Only return certificates requested by this identity entity.`,
+			},
+			"expiring_within": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `Only return certificates expiring within this
+amount of time.`,
+			},
+			"include_expired": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `If set to true, expired certificates are returned as well.`,
+			},
+			"exclude_revoked": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `If set to true, revoked certificates are not returned.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathSearchCertsRead,
+			logical.UpdateOperation: b.pathSearchCertsRead,
+		},
+
+		HelpSynopsis:    pathSearchCertsHelpSyn,
+		HelpDescription: pathSearchCertsHelpDesc,
+	}
+}
+
+func (b *backend) pathCertMetadataRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	serial := data.Get("serial").(string)
+
+	md, err := fetchCertMetadata(ctx, req.Storage, serial)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: md.toResponseData(),
+	}, nil
+}
+
+func (b *backend) pathSearchCertsRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	role := data.Get("role").(string)
+	commonName := strings.ToLower(data.Get("common_name").(string))
+	altName := data.Get("alt_name").(string)
+	entityID := data.Get("entity_id").(string)
+	expiringWithin := time.Duration(data.Get("expiring_within").(int)) * time.Second
+	includeExpired := data.Get("include_expired").(bool)
+	excludeRevoked := data.Get("exclude_revoked").(bool)
+
+	var issuerID string
+	if issuerRef := data.Get("issuer_ref").(string); issuerRef != "" {
+		var err error
+		issuerID, err = resolveIssuerReference(ctx, req.Storage, issuerRef)
+		if err != nil {
+			return errorResponseOrErr(err)
+		}
+	}
+
+	now := time.Now()
+	serials, err := searchCertIndex(ctx, req.Storage, role, commonName, now, includeExpired, expiringWithin)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []*certMetadata
+	for _, serial := range serials {
+		md, err := fetchCertMetadata(ctx, req.Storage, serial)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case md == nil:
+			continue
+		case role != "" && md.Role != role:
+			continue
+		case issuerID != "" && md.IssuerID != issuerID:
+			continue
+		case entityID != "" && md.EntityID != entityID:
+			continue
+		case commonName != "" && !glob.Glob(commonName, strings.ToLower(md.CommonName)):
+			continue
+		case altName != "" && !md.matchesName(altName):
+			continue
+		case !includeExpired && md.NotAfter.Before(now):
+			continue
+		case expiringWithin > 0 && md.NotAfter.After(now.Add(expiringWithin)):
+			continue
+		}
+		matches = append(matches, md)
+	}
+
+	// Certificates expiring soonest, and so most in need of attention, come
+	// first
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].NotAfter.Before(matches[j].NotAfter)
+	})
+
+	keys := []string{}
+	keyInfo := make(map[string]interface{})
+	for _, md := range matches {
+		revokedEntry, err := req.Storage.Get(ctx, "revoked/"+normalizeSerial(md.SerialNumber))
+		if err != nil {
+			return nil, err
+		}
+		if excludeRevoked && revokedEntry != nil {
+			continue
+		}
+
+		info := md.toResponseData()
+		info["revoked"] = revokedEntry != nil
+		key := normalizeSerial(md.SerialNumber)
+		keys = append(keys, key)
+		keyInfo[key] = info
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+// searchCertIndex returns the serials of the candidate certificates of a
+// search, using the secondary indexes for the role, common name and expiry
+// filters. The candidates must still be checked against all of the filters.
+// Without any of these filters, the serials of all certificates with metadata
+// are returned.
+func searchCertIndex(ctx context.Context, s logical.Storage, role, commonName string, now time.Time, includeExpired bool, expiringWithin time.Duration) ([]string, error) {
+	// candidates is nil until narrowed down by an index
+	var candidates map[string]bool
+	narrow := func(serials map[string]bool) {
+		if candidates == nil {
+			candidates = serials
+			return
+		}
+		for serial := range candidates {
+			if !serials[serial] {
+				delete(candidates, serial)
+			}
+		}
+	}
+	listIndex := func(prefix string, serials map[string]bool) error {
+		keys, err := s.List(ctx, prefix)
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			serials[key] = true
+		}
+		return nil
+	}
+
+	if role != "" {
+		serials := make(map[string]bool)
+		if err := listIndex(certIndexPrefix+certIndexRole+url.PathEscape(role)+"/", serials); err != nil {
+			return nil, err
+		}
+		narrow(serials)
+	}
+
+	if commonName != "" {
+		serials := make(map[string]bool)
+		if !strings.Contains(commonName, "*") {
+			if err := listIndex(certIndexPrefix+certIndexCN+url.PathEscape(commonName)+"/", serials); err != nil {
+				return nil, err
+			}
+		} else {
+			// Match the pattern against the distinct common names
+			names, err := s.List(ctx, certIndexPrefix+certIndexCN)
+			if err != nil {
+				return nil, err
+			}
+			for _, name := range names {
+				unescaped, err := url.PathUnescape(strings.TrimSuffix(name, "/"))
+				if err != nil || !glob.Glob(commonName, unescaped) {
+					continue
+				}
+				if err := listIndex(certIndexPrefix+certIndexCN+name, serials); err != nil {
+					return nil, err
+				}
+			}
+		}
+		narrow(serials)
+	}
+
+	if !includeExpired || expiringWithin > 0 {
+		var from, to string
+		if !includeExpired {
+			from = now.UTC().Format(certIndexExpiryLayout)
+		}
+		if expiringWithin > 0 {
+			to = now.Add(expiringWithin).UTC().Format(certIndexExpiryLayout)
+		}
+
+		days, err := s.List(ctx, certIndexPrefix+certIndexExpiry)
+		if err != nil {
+			return nil, err
+		}
+		serials := make(map[string]bool)
+		for _, day := range days {
+			date := strings.TrimSuffix(day, "/")
+			if (from != "" && date < from) || (to != "" && date > to) {
+				continue
+			}
+			if err := listIndex(certIndexPrefix+certIndexExpiry+day, serials); err != nil {
+				return nil, err
+			}
+		}
+		narrow(serials)
+	}
+
+	if candidates == nil {
+		return s.List(ctx, certMetadataPrefix)
+	}
+
+	serials := make([]string, 0, len(candidates))
+	for serial := range candidates {
+		serials = append(serials, serial)
+	}
+	sort.Strings(serials)
+	return serials, nil
+}
+
+const pathCertMetadataHelpSyn = `
+Fetch the issuance metadata of a certificate.
+`
+
+const pathCertMetadataHelpDesc = `
+This returns the metadata recorded when the certificate with the given serial
+number was issued: the role and issuer used, the subject names, the validity
+period and the ID of the identity entity which requested it.
+`
+
+const pathSearchCertsHelpSyn = `
+Search the stored certificates by their issuance metadata.
+`
+
+const pathSearchCertsHelpDesc = `
+This returns the serial numbers and metadata of stored certificates matching
+all of the given filters, ordered by expiration. By default expired
+certificates are omitted; use "expiring_within" to find certificates which
+need renewing soon.
+
+The role, common name and expiration filters are answered from an index, so
+searches using them only read the metadata of matching certificates;
+searching all certificates including expired ones reads all of the metadata.
+
+Metadata is recorded for certificates issued from roles or signed as
+intermediates. Running tidy with "tidy_cert_store" adds metadata for any
+stored certificates issued before metadata was recorded, although their role
+and requester are unknown, and removes metadata of tidied certificates.
+`
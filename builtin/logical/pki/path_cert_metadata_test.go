@@ -0,0 +1,243 @@
+package pki
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestPki_CertMetadataSearch(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+			EntityID:  "entity-1",
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	search := func(data map[string]interface{}) []string {
+		t.Helper()
+		resp := request(logical.UpdateOperation, "certs/search", data)
+		keys, _ := resp.Data["keys"].([]string)
+		return keys
+	}
+
+	request(logical.UpdateOperation, "root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "8760h",
+	})
+	request(logical.UpdateOperation, "roles/web", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"max_ttl":          "40h",
+	})
+	request(logical.UpdateOperation, "roles/mail", map[string]interface{}{
+		"allowed_domains":  "example.org",
+		"allow_subdomains": true,
+		"max_ttl":          "40h",
+	})
+
+	issue := func(role, commonName, ttl string) string {
+		t.Helper()
+		resp := request(logical.UpdateOperation, "issue/"+role, map[string]interface{}{
+			"common_name": commonName,
+			"alt_names":   "alt." + commonName,
+			"ttl":         ttl,
+		})
+		return normalizeSerial(resp.Data["serial_number"].(string))
+	}
+	www := issue("web", "www.example.com", "1h")
+	api := issue("web", "api.example.com", "30h")
+	mx := issue("mail", "mx.example.org", "35h")
+
+	resp := request(logical.ReadOperation, "cert-metadata/"+www, nil)
+	if resp.Data["role"] != "web" || resp.Data["common_name"] != "www.example.com" || resp.Data["entity_id"] != "entity-1" {
+		t.Fatalf("unexpected metadata: %#v", resp.Data)
+	}
+	if altNames := resp.Data["alt_names"].([]string); !strutil.EquivalentSlices(altNames, []string{"www.example.com", "alt.www.example.com"}) {
+		t.Fatalf("unexpected alt names: %v", altNames)
+	}
+
+	expectKeys := func(name string, keys []string, expected ...string) {
+		t.Helper()
+		if len(keys) != len(expected) {
+			t.Fatalf("%s: expected %v, got %v", name, expected, keys)
+		}
+		for i := range keys {
+			if keys[i] != expected[i] {
+				t.Fatalf("%s: expected %v, got %v", name, expected, keys)
+			}
+		}
+	}
+
+	// The root certificate itself is stored in certs/ but has no metadata
+	// until tidy indexes it
+	expectKeys("all", search(nil), www, api, mx)
+	expectKeys("role", search(map[string]interface{}{"role": "web"}), www, api)
+	expectKeys("common_name", search(map[string]interface{}{"common_name": "*.EXAMPLE.org"}), mx)
+	expectKeys("alt_name", search(map[string]interface{}{"alt_name": "alt.api.*"}), api)
+	expectKeys("entity_id", search(map[string]interface{}{"entity_id": "entity-2"}))
+	expectKeys("expiring_within", search(map[string]interface{}{"expiring_within": "10h"}), www)
+
+	request(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": api,
+	})
+	resp = request(logical.UpdateOperation, "certs/search", map[string]interface{}{"role": "web"})
+	if info := resp.Data["key_info"].(map[string]interface{})[api].(map[string]interface{}); info["revoked"] != true {
+		t.Fatalf("expected certificate to be marked revoked: %#v", info)
+	}
+	expectKeys("exclude_revoked", search(map[string]interface{}{"exclude_revoked": true}), www, mx)
+
+	// Tidy indexes certificates without metadata and drops metadata of
+	// certificates no longer stored
+	if err := storage.Delete(context.Background(), certMetadataPrefix+mx); err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Delete(context.Background(), "certs/"+www); err != nil {
+		t.Fatal(err)
+	}
+	request(logical.UpdateOperation, "tidy", map[string]interface{}{
+		"tidy_cert_store": true,
+	})
+	for start := time.Now(); atomic.LoadUint32(b.tidyCASGuard) != 0; {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("timed out waiting for tidy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The root certificate expires last
+	root := search(map[string]interface{}{"common_name": "root.example.com"})
+	if len(root) != 1 {
+		t.Fatal("expected root certificate to be indexed by tidy")
+	}
+	expectKeys("tidied", search(nil), api, mx, root[0])
+
+	resp = request(logical.ReadOperation, "cert-metadata/"+mx, nil)
+	if resp.Data["role"] != "" || resp.Data["common_name"] != "mx.example.org" || resp.Data["issuer_id"] == "" {
+		t.Fatalf("unexpected backfilled metadata: %#v", resp.Data)
+	}
+}
+
+// metadataReadCounter counts the reads of certificate metadata
+type metadataReadCounter struct {
+	logical.Storage
+	reads uint32
+}
+
+func (s *metadataReadCounter) Get(ctx context.Context, key string) (*logical.StorageEntry, error) {
+	if strings.HasPrefix(key, certMetadataPrefix) {
+		atomic.AddUint32(&s.reads, 1)
+	}
+	return s.Storage.Get(ctx, key)
+}
+
+func TestPki_CertMetadataSearchIndex(t *testing.T) {
+	storage := &metadataReadCounter{Storage: &logical.InmemStorage{}}
+	config := logical.TestBackendConfig()
+	config.StorageView = storage
+	b := Backend(config)
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	request("root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "8760h",
+	})
+	request("roles/web", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"max_ttl":          "40h",
+	})
+	request("roles/mail", map[string]interface{}{
+		"allowed_domains":  "example.org",
+		"allow_subdomains": true,
+		"max_ttl":          "40h",
+	})
+	var web []string
+	for i := 0; i < 10; i++ {
+		resp := request("issue/web", map[string]interface{}{
+			"common_name": fmt.Sprintf("www%d.example.com", i),
+			"ttl":         "40h",
+		})
+		web = append(web, normalizeSerial(resp.Data["serial_number"].(string)))
+	}
+	request("issue/mail", map[string]interface{}{
+		"common_name": "mx.example.org",
+		"ttl":         "1h",
+	})
+
+	cases := []struct {
+		name string
+		data map[string]interface{}
+	}{
+		{"role", map[string]interface{}{"role": "mail", "include_expired": true}},
+		{"common_name", map[string]interface{}{"common_name": "MX.example.org", "include_expired": true}},
+		{"common_name_glob", map[string]interface{}{"common_name": "mx.*", "include_expired": true}},
+		{"expiring_within", map[string]interface{}{"expiring_within": "2h"}},
+	}
+	for _, tc := range cases {
+		atomic.StoreUint32(&storage.reads, 0)
+		resp := request("certs/search", tc.data)
+		if keys := resp.Data["keys"].([]string); len(keys) != 1 {
+			t.Fatalf("%s: expected a single match, got %v", tc.name, keys)
+		}
+		if reads := atomic.LoadUint32(&storage.reads); reads != 1 {
+			t.Fatalf("%s: expected the metadata of the match to be read once, got %d reads", tc.name, reads)
+		}
+	}
+
+	// Tidy removes the index entries along with the metadata
+	for _, serial := range web {
+		if err := storage.Delete(context.Background(), "certs/"+serial); err != nil {
+			t.Fatal(err)
+		}
+	}
+	request("tidy", map[string]interface{}{
+		"tidy_cert_store": true,
+	})
+	for start := time.Now(); atomic.LoadUint32(b.tidyCASGuard) != 0; {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("timed out waiting for tidy")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for _, prefix := range []string{certIndexPrefix + certIndexRole + "web/", certIndexPrefix + certIndexCN + "www0.example.com/"} {
+		keys, err := storage.List(context.Background(), prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(keys) != 0 {
+			t.Fatalf("expected index %s to be tidied, got %v", prefix, keys)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package pki
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/strutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const acmeConfigPath = "config/acme"
+
+// acmeConfigEntry holds the configuration of the mount's ACME server
+type acmeConfigEntry struct {
+	Enabled        bool     `json:"enabled"`
+	BaseURL        string   `json:"base_url"`
+	Role           string   `json:"role"`
+	ChallengeTypes []string `json:"challenge_types"`
+	DNSResolver    string   `json:"dns_resolver"`
+}
+
+func pathConfigACME(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: acmeConfigPath,
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `If set to true, enables the ACME server on this mount.`,
+			},
+			"base_url": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The URL of this mount as seen by ACME clients,
+for example https://vault.example.com/v1/pki. Required
+when enabling the ACME server.`,
+			},
+			"role": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The role controlling which identifiers may be
+requested, and how certificates are issued, via ACME.
+Required when enabling the ACME server.`,
+			},
+			"challenge_types": &framework.FieldSchema{
+				Type:        framework.TypeCommaStringSlice,
+				Description: `Comma-separated list of the challenge types offered to ACME clients.`,
+				Default:     []string{acmeChallengeHTTP01, acmeChallengeDNS01},
+			},
+			"dns_resolver": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The address (host:port) of the DNS server used
+to validate dns-01 challenges. Defaults to the
+system resolver.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathACMEConfigRead,
+			logical.UpdateOperation: b.pathACMEConfigWrite,
+		},
+
+		HelpSynopsis:    pathConfigACMEHelpSyn,
+		HelpDescription: pathConfigACMEHelpDesc,
+	}
+}
+
+func getACMEConfig(ctx context.Context, s logical.Storage) (*acmeConfigEntry, error) {
+	entry, err := s.Get(ctx, acmeConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &acmeConfigEntry{
+		ChallengeTypes: []string{acmeChallengeHTTP01, acmeChallengeDNS01},
+	}
+	if entry == nil {
+		return config, nil
+	}
+
+	if err := entry.DecodeJSON(config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (b *backend) pathACMEConfigRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getACMEConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":         config.Enabled,
+			"base_url":        config.BaseURL,
+			"role":            config.Role,
+			"challenge_types": config.ChallengeTypes,
+			"dns_resolver":    config.DNSResolver,
+		},
+	}, nil
+}
+
+func (b *backend) pathACMEConfigWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getACMEConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if enabledRaw, ok := data.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+	if baseURLRaw, ok := data.GetOk("base_url"); ok {
+		config.BaseURL = strings.TrimSuffix(baseURLRaw.(string), "/")
+		if config.BaseURL != "" && !govalidator.IsURL(config.BaseURL) {
+			return logical.ErrorResponse(fmt.Sprintf("invalid base_url: %s", config.BaseURL)), nil
+		}
+	}
+	if roleRaw, ok := data.GetOk("role"); ok {
+		config.Role = roleRaw.(string)
+	}
+	if challengeTypesRaw, ok := data.GetOk("challenge_types"); ok {
+		config.ChallengeTypes = strutil.RemoveDuplicates(challengeTypesRaw.([]string), true)
+		for _, challengeType := range config.ChallengeTypes {
+			if _, ok := b.acmeValidators[challengeType]; !ok {
+				return logical.ErrorResponse(fmt.Sprintf("unsupported challenge type: %s", challengeType)), nil
+			}
+		}
+	}
+	if dnsResolverRaw, ok := data.GetOk("dns_resolver"); ok {
+		config.DNSResolver = dnsResolverRaw.(string)
+		if config.DNSResolver != "" {
+			if _, _, err := net.SplitHostPort(config.DNSResolver); err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("invalid dns_resolver: %s", err)), nil
+			}
+		}
+	}
+
+	if config.Enabled {
+		if config.BaseURL == "" {
+			return logical.ErrorResponse("base_url is required to enable the ACME server"), nil
+		}
+		if len(config.ChallengeTypes) == 0 {
+			return logical.ErrorResponse("at least one challenge type is required to enable the ACME server"), nil
+		}
+		if config.Role == "" {
+			return logical.ErrorResponse("role is required to enable the ACME server"), nil
+		}
+		role, err := b.getRole(ctx, req.Storage, config.Role)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("unknown role: %s", config.Role)), nil
+		}
+	}
+
+	entry, err := logical.StorageEntryJSON(acmeConfigPath, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	return b.pathACMEConfigRead(ctx, req, data)
+}
+
+const pathConfigACMEHelpSyn = `
+Configure the ACME server of this mount.
+`
+
+const pathConfigACMEHelpDesc = `
+This endpoint configures the ACME (RFC 8555) server, which allows ACME
+clients to obtain certificates from this mount without a Vault token.
+
+Clients are pointed at the directory at "<base_url>/acme/directory". The
+identifiers they may request, and the issuer, TTL and other parameters of the
+certificates they receive, are controlled by the configured role; the role's
+lease generation setting is ignored.
+
+ACME clients rely on the Replay-Nonce, Location and Link response headers, so
+these must be allowed on the mount, for example with:
+
+  vault secrets tune -allowed-response-headers=Replay-Nonce \
+      -allowed-response-headers=Location -allowed-response-headers=Link pki
+`
@@ -13,8 +13,14 @@ import (
 
 // CRLConfig holds basic CRL configuration information
 type crlConfig struct {
-	Expiry  string `json:"expiry" mapstructure:"expiry"`
-	Disable bool   `json:"disable"`
+	Expiry                 string `json:"expiry" mapstructure:"expiry"`
+	Disable                bool   `json:"disable"`
+	OCSPDisable            bool   `json:"ocsp_disable"`
+	OCSPExpiry             string `json:"ocsp_expiry"`
+	AutoRebuild            bool   `json:"auto_rebuild"`
+	AutoRebuildGracePeriod string `json:"auto_rebuild_grace_period"`
+	EnableDelta            bool   `json:"enable_delta"`
+	DeltaRebuildInterval   string `json:"delta_rebuild_interval"`
 }
 
 func pathConfigCRL(b *backend) *framework.Path {
@@ -31,6 +37,40 @@ valid; defaults to 72 hours`,
 				Type:        framework.TypeBool,
 				Description: `If set to true, disables generating the CRL entirely.`,
 			},
+			"ocsp_disable": &framework.FieldSchema{
+				Type:        framework.TypeBool,
+				Description: `If set to true, the OCSP responder answers every request as unauthorized.`,
+			},
+			"ocsp_expiry": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `The amount of time an OCSP response should be
+valid; defaults to 12 hours`,
+				Default: "12h",
+			},
+			"auto_rebuild": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set to true, the CRL is no longer rebuilt on
+every revocation, and is instead rebuilt periodically
+before it expires.`,
+			},
+			"auto_rebuild_grace_period": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How long before the CRL expires it should be
+rebuilt when auto_rebuild is set; defaults to 12 hours`,
+				Default: "12h",
+			},
+			"enable_delta": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `If set to true, delta CRLs listing the
+certificates revoked since the last complete CRL are
+built. Requires auto_rebuild.`,
+			},
+			"delta_rebuild_interval": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `How often the delta CRL is rebuilt when new
+certificates have been revoked; defaults to 15 minutes`,
+				Default: "15m",
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -71,8 +111,15 @@ func (b *backend) pathCRLRead(ctx context.Context, req *logical.Request, data *f
 
 	return &logical.Response{
 		Data: map[string]interface{}{
-			"expiry":  config.Expiry,
-			"disable": config.Disable,
+			"expiry":       config.Expiry,
+			"disable":      config.Disable,
+			"ocsp_disable": config.OCSPDisable,
+			"ocsp_expiry":  config.OCSPExpiry,
+
+			"auto_rebuild":              config.AutoRebuild,
+			"auto_rebuild_grace_period": config.AutoRebuildGracePeriod,
+			"enable_delta":              config.EnableDelta,
+			"delta_rebuild_interval":    config.DeltaRebuildInterval,
 		},
 	}, nil
 }
@@ -95,12 +142,70 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 		config.Expiry = expiry
 	}
 
+	if ocspDisableRaw, ok := d.GetOk("ocsp_disable"); ok {
+		config.OCSPDisable = ocspDisableRaw.(bool)
+	}
+
+	if ocspExpiryRaw, ok := d.GetOk("ocsp_expiry"); ok {
+		ocspExpiry := ocspExpiryRaw.(string)
+		_, err := time.ParseDuration(ocspExpiry)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("given ocsp_expiry could not be decoded: %s", err)), nil
+		}
+		config.OCSPExpiry = ocspExpiry
+	}
+
 	var oldDisable bool
 	if disableRaw, ok := d.GetOk("disable"); ok {
 		oldDisable = config.Disable
 		config.Disable = disableRaw.(bool)
 	}
 
+	if autoRebuildRaw, ok := d.GetOk("auto_rebuild"); ok {
+		config.AutoRebuild = autoRebuildRaw.(bool)
+	}
+
+	if _, ok := d.GetOk("auto_rebuild_grace_period"); ok || config.AutoRebuildGracePeriod == "" {
+		gracePeriod := d.Get("auto_rebuild_grace_period").(string)
+		_, err := time.ParseDuration(gracePeriod)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("given auto_rebuild_grace_period could not be decoded: %s", err)), nil
+		}
+		config.AutoRebuildGracePeriod = gracePeriod
+	}
+
+	oldEnableDelta := config.EnableDelta
+	if enableDeltaRaw, ok := d.GetOk("enable_delta"); ok {
+		config.EnableDelta = enableDeltaRaw.(bool)
+	}
+
+	if _, ok := d.GetOk("delta_rebuild_interval"); ok || config.DeltaRebuildInterval == "" {
+		deltaInterval := d.Get("delta_rebuild_interval").(string)
+		interval, err := time.ParseDuration(deltaInterval)
+		if err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("given delta_rebuild_interval could not be decoded: %s", err)), nil
+		}
+		if interval <= 0 {
+			return logical.ErrorResponse("delta_rebuild_interval must be positive"), nil
+		}
+		config.DeltaRebuildInterval = deltaInterval
+	}
+
+	if config.EnableDelta && !config.AutoRebuild {
+		return logical.ErrorResponse("enable_delta requires auto_rebuild to be set"), nil
+	}
+
+	if config.AutoRebuild {
+		expiry := b.crlLifetime
+		if config.Expiry != "" {
+			expiry, _ = time.ParseDuration(config.Expiry)
+		}
+		gracePeriod, _ := time.ParseDuration(config.AutoRebuildGracePeriod)
+		if gracePeriod >= expiry {
+			return logical.ErrorResponse("auto_rebuild_grace_period must be less than the CRL expiry"), nil
+		}
+	}
+
 	entry, err := logical.StorageEntryJSON("config/crl", config)
 	if err != nil {
 		return nil, err
@@ -110,8 +215,9 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 		return nil, err
 	}
 
-	if oldDisable != config.Disable {
-		// It wasn't disabled but now it is, rotate
+	if oldDisable != config.Disable || oldEnableDelta != config.EnableDelta {
+		// It wasn't disabled but now it is, or delta CRLs were toggled,
+		// rotate
 		crlErr := buildCRL(ctx, b, req, true)
 		switch crlErr.(type) {
 		case errutil.UserError:
@@ -125,9 +231,16 @@ func (b *backend) pathCRLWrite(ctx context.Context, req *logical.Request, d *fra
 }
 
 const pathConfigCRLHelpSyn = `
-Configure the CRL expiration.
+Configure the CRL expiration and rebuilding, and OCSP response expiration.
 `
 
 const pathConfigCRLHelpDesc = `
-This endpoint allows configuration of the CRL lifetime.
+This endpoint allows configuration of the CRL lifetime, and of the lifetime
+of responses from the OCSP responder.
+
+By default the CRL is rebuilt on every revocation. With auto_rebuild set, it
+is instead rebuilt periodically, once the remaining lifetime of the CRL is
+less than the grace period. Setting enable_delta additionally publishes delta
+CRLs at "crl/delta", rebuilt every delta_rebuild_interval, which list the
+certificates revoked since the last complete CRL.
 `
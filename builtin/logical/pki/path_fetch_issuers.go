@@ -0,0 +1,375 @@
+package pki
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// Returns the list of issuers held by the mount
+func pathListIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathListIssuersHandler,
+		},
+
+		HelpSynopsis:    pathListIssuersHelpSyn,
+		HelpDescription: pathListIssuersHelpDesc,
+	}
+}
+
+// Manages a single issuer
+func pathIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex("issuer_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Reference to an existing issuer; either
+"default" for the mount's default issuer, an
+issuer ID, or an issuer name.`,
+			},
+			"issuer_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Name to assign to the issuer, which can be
+used in place of its ID. Must be unique within the
+mount and may not be "default". Set to an empty
+string to remove the name.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathIssuerRead,
+			logical.UpdateOperation: b.pathIssuerWrite,
+			logical.DeleteOperation: b.pathIssuerDelete,
+		},
+
+		HelpSynopsis:    pathIssuerHelpSyn,
+		HelpDescription: pathIssuerHelpDesc,
+	}
+}
+
+// Returns an issuer's certificate and chain, unauthenticated
+func pathFetchIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `cert/issuer/` + framework.GenericNameRegex("issuer_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to an existing issuer.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchIssuerRead,
+		},
+
+		HelpSynopsis:    pathFetchIssuerHelpSyn,
+		HelpDescription: pathFetchIssuerHelpDesc,
+	}
+}
+
+// Returns an issuer's certificate or CRL in raw format, unauthenticated
+func pathFetchIssuerRaw(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `(?P<type>ca|crl)/issuer/` + framework.GenericNameRegex("issuer_ref") + `(/pem)?`,
+		Fields: map[string]*framework.FieldSchema{
+			"type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Either "ca" or "crl".`,
+			},
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to an existing issuer.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchIssuerRead,
+		},
+
+		HelpSynopsis:    pathFetchIssuerHelpSyn,
+		HelpDescription: pathFetchIssuerHelpDesc,
+	}
+}
+
+// Returns an issuer's delta CRL in raw format, unauthenticated
+func pathFetchIssuerDeltaCRL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `(?P<type>crl)/issuer/` + framework.GenericNameRegex("issuer_ref") + `(?P<delta>/delta)(/pem)?`,
+		Fields: map[string]*framework.FieldSchema{
+			"type": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Always "crl".`,
+			},
+			"issuer_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to an existing issuer.`,
+			},
+			"delta": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Set when fetching the delta CRL.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchIssuerRead,
+		},
+
+		HelpSynopsis:    pathFetchIssuerHelpSyn,
+		HelpDescription: pathFetchIssuerHelpDesc,
+	}
+}
+
+func (b *backend) pathListIssuersHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ids, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := make(map[string]interface{}, len(ids))
+	for _, id := range ids {
+		issuer, err := fetchIssuerById(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer == nil {
+			continue
+		}
+		keyInfo[id] = map[string]interface{}{
+			"issuer_name": issuer.Name,
+			"is_default":  id == config.DefaultIssuerID,
+		}
+	}
+
+	return logical.ListResponseWithInfo(ids, keyInfo), nil
+}
+
+func (b *backend) pathIssuerRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, resp, err := b.fetchIssuerByRef(ctx, req, data.Get("issuer_ref").(string))
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id":     issuer.ID,
+			"issuer_name":   issuer.Name,
+			"key_id":        issuer.KeyID,
+			"certificate":   issuer.Certificate,
+			"ca_chain":      issuer.CAChain,
+			"serial_number": issuer.SerialNumber,
+			"is_default":    issuer.ID == config.DefaultIssuerID,
+		},
+	}, nil
+}
+
+func (b *backend) pathIssuerWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, resp, err := b.fetchIssuerByRef(ctx, req, data.Get("issuer_ref").(string))
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	nameRaw, ok := data.GetOk("issuer_name")
+	if !ok {
+		return b.pathIssuerRead(ctx, req, data)
+	}
+	name := nameRaw.(string)
+	if err := validateIssuerName(ctx, req.Storage, name, issuer.ID); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	issuer.Name = name
+	if err := writeIssuer(ctx, req.Storage, issuer); err != nil {
+		return nil, err
+	}
+
+	// The reference in the request may have been the old name
+	data.Raw["issuer_ref"] = issuer.ID
+	return b.pathIssuerRead(ctx, req, data)
+}
+
+func (b *backend) pathIssuerDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, resp, err := b.fetchIssuerByRef(ctx, req, data.Get("issuer_ref").(string))
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
+
+	if err := req.Storage.Delete(ctx, issuerPrefix+issuer.ID); err != nil {
+		return nil, err
+	}
+	if err := deleteIssuerCRLs(ctx, req.Storage, issuer.ID); err != nil {
+		return nil, err
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config.DefaultIssuerID != issuer.ID {
+		return nil, nil
+	}
+
+	config.DefaultIssuerID = ""
+	if err := setIssuersConfig(ctx, req.Storage, config); err != nil {
+		return nil, err
+	}
+
+	resp = &logical.Response{}
+	resp.AddWarning("Deleted the mount's default issuer; set a new default via config/issuers before issuing further certificates.")
+	return resp, nil
+}
+
+func (b *backend) pathFetchIssuerRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	issuer, resp, err := b.fetchIssuerByRef(ctx, req, data.Get("issuer_ref").(string))
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	fetchType := "cert"
+	if raw, ok := data.GetOk("type"); ok {
+		fetchType = raw.(string)
+	}
+	isPEM := strings.HasSuffix(req.Path, "/pem")
+	var isDelta bool
+	if raw, ok := data.GetOk("delta"); ok {
+		isDelta = raw.(string) != ""
+	}
+
+	switch fetchType {
+	case "ca":
+		if isPEM {
+			return rawResponse("application/pkix-cert", []byte(issuer.Certificate)), nil
+		}
+		block, _ := pem.Decode([]byte(issuer.Certificate))
+		if block == nil {
+			return nil, fmt.Errorf("unable to decode certificate of issuer %q", issuer.ID)
+		}
+		return rawResponse("application/pkix-cert", block.Bytes), nil
+
+	case "crl":
+		crlEntry, err := fetchIssuerCRLEntry(ctx, req.Storage, issuer.ID, isDelta)
+		if err != nil {
+			return nil, err
+		}
+		var crl []byte
+		if crlEntry != nil {
+			crl = crlEntry.Value
+			if isPEM {
+				crl = []byte(strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{
+					Type:  "X509 CRL",
+					Bytes: crlEntry.Value,
+				}))))
+			}
+		}
+		return rawResponse("application/pkix-crl", crl), nil
+	}
+
+	caChain := []string{issuer.Certificate}
+	caChain = append(caChain, issuer.CAChain...)
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id":   issuer.ID,
+			"issuer_name": issuer.Name,
+			"certificate": issuer.Certificate,
+			"ca_chain":    caChain,
+		},
+	}, nil
+}
+
+// fetchIssuerByRef resolves an issuer reference from a request, returning
+// an error response if it could not be found.
+func (b *backend) fetchIssuerByRef(ctx context.Context, req *logical.Request, ref string) (*issuerEntry, *logical.Response, error) {
+	id, err := resolveIssuerReference(ctx, req.Storage, ref)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return nil, logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, nil, err
+		}
+	}
+
+	issuer, err := fetchIssuerById(ctx, req.Storage, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if issuer == nil {
+		return nil, logical.ErrorResponse(fmt.Sprintf("unable to find issuer for reference: %s", ref)), nil
+	}
+
+	return issuer, nil, nil
+}
+
+func rawResponse(contentType string, body []byte) *logical.Response {
+	statusCode := 200
+	if len(body) == 0 {
+		statusCode = 204
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: contentType,
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  statusCode,
+		},
+	}
+}
+
+const pathListIssuersHelpSyn = `
+List the issuers held by this mount.
+`
+
+const pathListIssuersHelpDesc = `
+This path lists the IDs of all issuers held by this mount, along with each
+issuer's name and whether it is the mount's default issuer.
+`
+
+const pathIssuerHelpSyn = `
+Read, rename or delete a single issuer.
+`
+
+const pathIssuerHelpDesc = `
+This path allows an issuer, referenced by its ID, its name, or "default" for
+the mount's default issuer, to be read, renamed or deleted.
+
+Deleting an issuer does not delete its key, nor does it revoke certificates
+it has issued; those remain in the certificate store but will no longer
+appear on any CRL.
+`
+
+const pathFetchIssuerHelpSyn = `
+Fetch an issuer's CA certificate or CRL.
+`
+
+const pathFetchIssuerHelpDesc = `
+This allows an issuer's certificate and CRL to be fetched without
+authentication.
+
+Using "cert/issuer/<issuer_ref>" returns the issuer's certificate and CA chain
+in PEM encoding. Using "ca/issuer/<issuer_ref>" or "crl/issuer/<issuer_ref>"
+returns the certificate or CRL in DER encoding; add "/pem" to either to get
+PEM encoding. If delta CRLs are enabled, "crl/issuer/<issuer_ref>/delta"
+returns the issuer's delta CRL.
+`
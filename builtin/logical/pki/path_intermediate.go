@@ -13,8 +13,16 @@ import (
 )
 
 func pathGenerateIntermediate(b *backend) *framework.Path {
+	return buildPathGenerateIntermediate(b, "intermediate/generate/"+framework.GenericNameRegex("exported"))
+}
+
+func pathGenerateIssuerIntermediate(b *backend) *framework.Path {
+	return buildPathGenerateIntermediate(b, "issuers/generate/intermediate/"+framework.GenericNameRegex("exported"))
+}
+
+func buildPathGenerateIntermediate(b *backend, pattern string) *framework.Path {
 	ret := &framework.Path{
-		Pattern: "intermediate/generate/" + framework.GenericNameRegex("exported"),
+		Pattern: pattern,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.UpdateOperation: b.pathGenerateIntermediate,
@@ -49,6 +57,12 @@ certificate with a public key matching the
 previously-generated key from the generation
 endpoint.`,
 			},
+			"issuer_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Optional name to assign to the new issuer,
+which can be used in place of its ID. Must be
+unique within the mount and may not be "default".`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -70,6 +84,11 @@ func (b *backend) pathGenerateIntermediate(ctx context.Context, req *logical.Req
 		return errorResp, nil
 	}
 
+	keyName := data.Get("key_name").(string)
+	if err := validateKeyName(ctx, req.Storage, keyName, ""); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	var resp *logical.Response
 	input := &inputBundle{
 		role:    role,
@@ -126,18 +145,13 @@ func (b *backend) pathGenerateIntermediate(ctx context.Context, req *logical.Req
 		}
 	}
 
-	cb := &certutil.CertBundle{}
-	cb.PrivateKey = csrb.PrivateKey
-	cb.PrivateKeyType = csrb.PrivateKeyType
-
-	entry, err := logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(ctx, entry)
+	// Store the key on its own; the issuer is created once the signed
+	// certificate is provided via intermediate/set-signed
+	key, _, err := importKey(ctx, req.Storage, csrb.PrivateKey, csrb.PrivateKeyType, keyName)
 	if err != nil {
 		return nil, err
 	}
+	resp.Data["key_id"] = key.ID
 
 	return resp, nil
 }
@@ -163,70 +177,75 @@ func (b *backend) pathSetSignedIntermediate(ctx context.Context, req *logical.Re
 		return logical.ErrorResponse("supplied certificate could not be successfully parsed"), nil
 	}
 
-	cb := &certutil.CertBundle{}
-	entry, err := req.Storage.Get(ctx, "config/ca_bundle")
-	if err != nil {
-		return nil, err
-	}
-	if entry == nil {
-		return logical.ErrorResponse("could not find any existing entry with a private key"), nil
+	if !inputBundle.Certificate.IsCA {
+		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
 	}
 
-	err = entry.DecodeJSON(cb)
+	// Find the previously generated key matching the certificate
+	keyIDs, err := listKeys(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
-
-	if len(cb.PrivateKey) == 0 || cb.PrivateKeyType == "" {
-		return logical.ErrorResponse("could not find an existing private key"), nil
+	var key *keyEntry
+	for _, id := range keyIDs {
+		candidate, err := fetchKeyById(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == nil {
+			continue
+		}
+		signer, err := candidate.GetSigner()
+		if err != nil {
+			return nil, err
+		}
+		if publicKeysEqual(signer.Public(), inputBundle.Certificate.PublicKey) {
+			key = candidate
+			break
+		}
+	}
+	if key == nil {
+		return logical.ErrorResponse("could not find an existing private key matching the certificate"), nil
 	}
 
-	parsedCB, err := cb.ToParsedCertBundle()
+	signer, err := key.GetSigner()
 	if err != nil {
 		return nil, err
 	}
-	if parsedCB.PrivateKey == nil {
-		return nil, fmt.Errorf("saved key could not be parsed successfully")
-	}
-
-	inputBundle.PrivateKey = parsedCB.PrivateKey
-	inputBundle.PrivateKeyType = parsedCB.PrivateKeyType
-	inputBundle.PrivateKeyBytes = parsedCB.PrivateKeyBytes
-
-	if !inputBundle.Certificate.IsCA {
-		return logical.ErrorResponse("the given certificate is not marked for CA use and cannot be used with this backend"), nil
-	}
+	inputBundle.PrivateKey = signer
+	inputBundle.PrivateKeyType = key.PrivateKeyType
 
 	if err := inputBundle.Verify(); err != nil {
 		return nil, errwrap.Wrapf("verification of parsed bundle failed: {{err}}", err)
 	}
 
-	cb, err = inputBundle.ToCertBundle()
+	cb, err := inputBundle.ToCertBundle()
 	if err != nil {
 		return nil, errwrap.Wrapf("error converting raw values into cert bundle: {{err}}", err)
 	}
 
-	entry, err = logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
+	// As before multiple issuers were supported, setting the signed
+	// intermediate makes it the mount's default issuer. The key is already
+	// stored so only the certificate needs to be imported.
+	issuerName := data.Get("issuer_name").(string)
+	if err := validateIssuerName(ctx, req.Storage, issuerName, ""); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
-	err = req.Storage.Put(ctx, entry)
+	cb.PrivateKey = ""
+	_, err = writeCABundle(ctx, req.Storage, cb, issuerName, "", true)
 	if err != nil {
-		return nil, err
-	}
-
-	entry.Key = "certs/" + normalizeSerial(cb.SerialNumber)
-	entry.Value = inputBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		return nil, err
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location
-	entry.Key = "ca"
-	entry.Value = inputBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
+	err = req.Storage.Put(ctx, &logical.StorageEntry{
+		Key:   "certs/" + normalizeSerial(cb.SerialNumber),
+		Value: inputBundle.CertificateBytes,
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -15,8 +15,18 @@ import (
 )
 
 func pathIssue(b *backend) *framework.Path {
+	return buildPathIssue(b, "issue/"+framework.GenericNameRegex("role"))
+}
+
+func pathIssuerIssue(b *backend) *framework.Path {
+	ret := buildPathIssue(b, "issuer/"+framework.GenericNameRegex("issuer_ref")+"/issue/"+framework.GenericNameRegex("role"))
+	ret.Fields = addIssuerRefField(ret.Fields)
+	return ret
+}
+
+func buildPathIssue(b *backend, pattern string) *framework.Path {
 	ret := &framework.Path{
-		Pattern: "issue/" + framework.GenericNameRegex("role"),
+		Pattern: pattern,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.UpdateOperation: b.pathIssue,
@@ -31,8 +41,18 @@ func pathIssue(b *backend) *framework.Path {
 }
 
 func pathSign(b *backend) *framework.Path {
+	return buildPathSign(b, "sign/"+framework.GenericNameRegex("role"))
+}
+
+func pathIssuerSign(b *backend) *framework.Path {
+	ret := buildPathSign(b, "issuer/"+framework.GenericNameRegex("issuer_ref")+"/sign/"+framework.GenericNameRegex("role"))
+	ret.Fields = addIssuerRefField(ret.Fields)
+	return ret
+}
+
+func buildPathSign(b *backend, pattern string) *framework.Path {
 	ret := &framework.Path{
-		Pattern: "sign/" + framework.GenericNameRegex("role"),
+		Pattern: pattern,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.UpdateOperation: b.pathSign,
@@ -54,8 +74,18 @@ func pathSign(b *backend) *framework.Path {
 }
 
 func pathSignVerbatim(b *backend) *framework.Path {
+	return buildPathSignVerbatim(b, "sign-verbatim"+framework.OptionalParamRegex("role"))
+}
+
+func pathIssuerSignVerbatim(b *backend) *framework.Path {
+	ret := buildPathSignVerbatim(b, "issuer/"+framework.GenericNameRegex("issuer_ref")+"/sign-verbatim"+framework.OptionalParamRegex("role"))
+	ret.Fields = addIssuerRefField(ret.Fields)
+	return ret
+}
+
+func buildPathSignVerbatim(b *backend, pattern string) *framework.Path {
 	ret := &framework.Path{
-		Pattern: "sign-verbatim" + framework.OptionalParamRegex("role"),
+		Pattern: pattern,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.UpdateOperation: b.pathSignVerbatim,
@@ -183,6 +213,7 @@ func (b *backend) pathSignVerbatim(ctx context.Context, req *logical.Request, da
 			*entry.GenerateLease = *role.GenerateLease
 		}
 		entry.NoStore = role.NoStore
+		entry.Issuer = role.Issuer
 	}
 
 	return b.pathIssueSignCert(ctx, req, data, entry, true, true)
@@ -200,8 +231,14 @@ func (b *backend) pathIssueSignCert(ctx context.Context, req *logical.Request, d
 			`the "format" path parameter must be "pem", "der", or "pem_bundle"`), nil
 	}
 
+	// An issuer in the request path takes precedence over the role's
+	issuerRef := role.Issuer
+	if _, ok := data.Schema["issuer_ref"]; ok || issuerRef == "" {
+		issuerRef = getIssuerRef(data)
+	}
+
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfoByIssuer(ctx, req, issuerRef)
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -324,6 +361,15 @@ func (b *backend) pathIssueSignCert(ctx context.Context, req *logical.Request, d
 		if err != nil {
 			return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
 		}
+
+		issuerID, err := resolveIssuerReference(ctx, req.Storage, issuerRef)
+		if err != nil {
+			return nil, err
+		}
+		md := newCertMetadata(parsedBundle.Certificate, issuerID, data.Get("role").(string), req.EntityID)
+		if err := storeCertMetadata(ctx, req.Storage, md); err != nil {
+			return nil, errwrap.Wrapf("unable to store certificate metadata: {{err}}", err)
+		}
 	}
 
 	if useCSR {
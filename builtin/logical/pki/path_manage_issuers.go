@@ -0,0 +1,221 @@
+package pki
+
+import (
+	"context"
+	"encoding/pem"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathImportIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/import/bundle",
+		Fields: map[string]*framework.FieldSchema{
+			"pem_bundle": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `PEM-format, concatenated unencrypted
+secret keys and CA certificates. Each certificate
+becomes an issuer and each key is stored for use by
+issuers with a matching public key.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportIssuers,
+		},
+
+		HelpSynopsis:    pathImportIssuersHelpSyn,
+		HelpDescription: pathImportIssuersHelpDesc,
+	}
+}
+
+func pathConfigIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/issuers",
+		Fields: map[string]*framework.FieldSchema{
+			"default": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Reference (name or ID) of the issuer to use
+as the mount's default issuer.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathConfigIssuersRead,
+			logical.UpdateOperation: b.pathConfigIssuersWrite,
+		},
+
+		HelpSynopsis:    pathConfigIssuersHelpSyn,
+		HelpDescription: pathConfigIssuersHelpDesc,
+	}
+}
+
+func (b *backend) pathImportIssuers(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	pemBundle := data.Get("pem_bundle").(string)
+	if pemBundle == "" {
+		return logical.ErrorResponse("'pem_bundle' was empty"), nil
+	}
+
+	// Split the bundle into its individual blocks; unlike config/ca, any
+	// number of certificates and keys may be provided
+	var certs []string
+	var keys []*certutil.ParsedCertBundle
+	rest := []byte(pemBundle)
+	for len(strings.TrimSpace(string(rest))) > 0 {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return logical.ErrorResponse("no data found in PEM block"), nil
+		}
+		encoded := string(pem.EncodeToMemory(block))
+
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, encoded)
+			continue
+		}
+
+		parsed, err := certutil.ParsePEMBundle(encoded)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		if parsed.PrivateKey == nil {
+			return logical.ErrorResponse("unknown PEM block of type " + block.Type), nil
+		}
+		keys = append(keys, parsed)
+	}
+
+	if len(certs) == 0 && len(keys) == 0 {
+		return logical.ErrorResponse("no certificates or keys found in the PEM bundle"), nil
+	}
+
+	var importedKeys, importedIssuers []string
+	for _, parsed := range keys {
+		cb, err := parsed.ToCertBundle()
+		if err != nil {
+			return nil, err
+		}
+		key, existing, err := importKey(ctx, req.Storage, cb.PrivateKey, cb.PrivateKeyType, "")
+		if err != nil {
+			return errorResponseOrErr(err)
+		}
+		if !existing {
+			importedKeys = append(importedKeys, key.ID)
+		}
+	}
+
+	for _, cert := range certs {
+		issuer, existing, err := importIssuer(ctx, req.Storage, cert, nil, "")
+		if err != nil {
+			return errorResponseOrErr(err)
+		}
+		if !existing {
+			importedIssuers = append(importedIssuers, issuer.ID)
+		}
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config.DefaultIssuerID == "" && len(importedIssuers) > 0 {
+		config.DefaultIssuerID = importedIssuers[0]
+		if err := setIssuersConfig(ctx, req.Storage, config); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(importedIssuers) > 0 {
+		b.revokeStorageLock.Lock()
+		defer b.revokeStorageLock.Unlock()
+
+		if err := buildCRL(ctx, b, req, true); err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				// None of the imported issuers have a key
+			default:
+				return nil, err
+			}
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"imported_issuers": importedIssuers,
+			"imported_keys":    importedKeys,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigIssuersRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"default": config.DefaultIssuerID,
+		},
+	}, nil
+}
+
+func (b *backend) pathConfigIssuersWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ref := data.Get("default").(string)
+	if ref == "" || ref == defaultRef {
+		return logical.ErrorResponse("a reference to an existing issuer must be provided in 'default'"), nil
+	}
+
+	id, err := resolveIssuerReference(ctx, req.Storage, ref)
+	if err != nil {
+		return errorResponseOrErr(err)
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	config.DefaultIssuerID = id
+	if err := setIssuersConfig(ctx, req.Storage, config); err != nil {
+		return nil, err
+	}
+
+	return b.pathConfigIssuersRead(ctx, req, data)
+}
+
+// errorResponseOrErr converts user errors into error responses, passing
+// through any other error.
+func errorResponseOrErr(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), nil
+	default:
+		return nil, err
+	}
+}
+
+const pathImportIssuersHelpSyn = `
+Import CA certificates and keys as new issuers.
+`
+
+const pathImportIssuersHelpDesc = `
+This imports the CA certificates and private keys in the given PEM bundle.
+Unlike config/ca, existing issuers are left in place, allowing a CA to be
+rotated without creating a new mount. Certificates and keys already held by
+the mount are ignored.
+
+If the mount has no default issuer, the first imported issuer becomes the
+default.
+`
+
+const pathConfigIssuersHelpSyn = `
+Read and set the mount's default issuer.
+`
+
+const pathConfigIssuersHelpDesc = `
+The default issuer is used by the legacy ca, ca_chain and crl fetch paths and
+by any issue or sign request that does not specify another issuer.
+`
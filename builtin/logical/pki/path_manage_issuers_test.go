@@ -0,0 +1,263 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault"
+)
+
+func TestPki_MultipleIssuers(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	write := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	read := func(path string) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      path,
+			Storage:   storage,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	resp := write("root/generate/internal", map[string]interface{}{
+		"common_name": "root-old.example.com",
+		"issuer_name": "old",
+		"ttl":         "8760h",
+		"key_type":    "ec",
+		"key_bits":    256,
+	})
+	oldID := resp.Data["issuer_id"].(string)
+
+	// The legacy path refuses to replace the existing root
+	resp = write("root/generate/internal", map[string]interface{}{
+		"common_name": "root-new.example.com",
+	})
+	if len(resp.Warnings) == 0 {
+		t.Fatal("expected a warning when generating over an existing root")
+	}
+
+	resp = write("issuers/generate/root/internal", map[string]interface{}{
+		"common_name": "root-new.example.com",
+		"issuer_name": "new",
+		"ttl":         "8760h",
+		"key_type":    "ec",
+		"key_bits":    256,
+	})
+	newID := resp.Data["issuer_id"].(string)
+	if newID == oldID {
+		t.Fatal("expected a distinct issuer")
+	}
+
+	// The first issuer remains the default until changed
+	resp = read("config/issuers")
+	if resp.Data["default"] != oldID {
+		t.Fatalf("expected default issuer %s, got %v", oldID, resp.Data["default"])
+	}
+
+	write("roles/example", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"issuer_ref":       "new",
+		"ttl":              "1h",
+		"key_type":         "ec",
+		"key_bits":         256,
+	})
+
+	issuedBy := func(resp *logical.Response) string {
+		t.Helper()
+		block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.Issuer.CommonName
+	}
+
+	resp = write("issue/example", map[string]interface{}{
+		"common_name": "a.example.com",
+	})
+	if cn := issuedBy(resp); cn != "root-new.example.com" {
+		t.Fatalf("expected role issuer to be used, got %s", cn)
+	}
+	newSerial := resp.Data["serial_number"].(string)
+
+	resp = write("issuer/old/issue/example", map[string]interface{}{
+		"common_name": "b.example.com",
+	})
+	if cn := issuedBy(resp); cn != "root-old.example.com" {
+		t.Fatalf("expected path issuer to be used, got %s", cn)
+	}
+
+	// Revocations only appear on the CRL of the issuing CA
+	write("revoke", map[string]interface{}{
+		"serial_number": newSerial,
+	})
+	crlLength := func(ref string) int {
+		t.Helper()
+		resp := read("crl/issuer/" + ref)
+		crl, err := x509.ParseCRL(resp.Data[logical.HTTPRawBody].([]byte))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(crl.TBSCertList.RevokedCertificates)
+	}
+	if l := crlLength("new"); l != 1 {
+		t.Fatalf("expected 1 revoked cert on new CRL, got %d", l)
+	}
+	if l := crlLength("old"); l != 0 {
+		t.Fatalf("expected no revoked certs on old CRL, got %d", l)
+	}
+
+	write("config/issuers", map[string]interface{}{
+		"default": "new",
+	})
+	resp = read("cert/ca")
+	block, _ := pem.Decode([]byte(resp.Data["certificate"].(string)))
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cert.Subject.CommonName != "root-new.example.com" {
+		t.Fatalf("expected legacy fetch to return the new default, got %s", cert.Subject.CommonName)
+	}
+
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "key/" + read("issuer/new").Data["key_id"].(string),
+		Storage:   storage,
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected error deleting a key in use, got err: %v resp: %#v", err, resp)
+	}
+}
+
+func TestPki_MigrateLegacyBundle(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.UpdateOperation,
+		Path:      "root/generate/exported",
+		Storage:   storage,
+		Data: map[string]interface{}{
+			"common_name": "legacy.example.com",
+			"key_type":    "ec",
+			"key_bits":    256,
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+
+	// Rewrite storage in the layout used before multiple issuers
+	cb := &certutil.CertBundle{
+		PrivateKeyType: certutil.ECPrivateKey,
+		PrivateKey:     resp.Data["private_key"].(string),
+		Certificate:    resp.Data["certificate"].(string),
+		SerialNumber:   resp.Data["serial_number"].(string),
+	}
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "root",
+		Storage:   storage,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	entry, err := logical.StorageEntryJSON(legacyCABundlePath, cb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := storage.Put(context.Background(), entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.initialize(context.Background(), &logical.InitializationRequest{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err = storage.Get(context.Background(), legacyCABundlePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry != nil {
+		t.Fatal("expected legacy bundle to be removed")
+	}
+
+	caInfo, err := fetchCAInfo(context.Background(), &logical.Request{Storage: storage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if caInfo.Certificate.Subject.CommonName != "legacy.example.com" {
+		t.Fatalf("unexpected default issuer %s", caInfo.Certificate.Subject.CommonName)
+	}
+}
+
+func TestPki_IssuerFetchUnauthenticated(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"pki": Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	client := cluster.Cores[0].Client
+	if err := client.Sys().Mount("pki", &api.MountInput{
+		Type: "pki",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Logical().Write("pki/issuers/generate/root/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "8760h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerID := resp.Data["issuer_id"].(string)
+
+	// The issuer's certificate and CRLs are fetched without a token, through
+	// the router rather than the backend
+	client.SetToken("")
+	for _, path := range []string{
+		"ca/issuer/" + issuerID,
+		"ca/issuer/" + issuerID + "/pem",
+		"crl/issuer/" + issuerID,
+		"crl/issuer/" + issuerID + "/pem",
+		"crl/issuer/" + issuerID + "/delta",
+		"crl/issuer/" + issuerID + "/delta/pem",
+	} {
+		req := client.NewRequest("GET", "/v1/pki/"+path)
+		resp, err := client.RawRequest(req)
+		if err != nil {
+			t.Fatalf("path: %s err: %v", path, err)
+		}
+		resp.Body.Close()
+	}
+}
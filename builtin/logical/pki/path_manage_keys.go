@@ -0,0 +1,167 @@
+package pki
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathListKeys(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/?$",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ListOperation: b.pathListKeysHandler,
+		},
+
+		HelpSynopsis:    pathListKeysHelpSyn,
+		HelpDescription: pathListKeysHelpDesc,
+	}
+}
+
+func pathKey(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "key/" + framework.GenericNameRegex("key_ref"),
+		Fields: map[string]*framework.FieldSchema{
+			"key_ref": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Reference to an existing key; either a key ID or a key name.`,
+			},
+			"key_name": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Name to assign to the key, which can be used
+in place of its ID. Must be unique within the mount.
+Set to an empty string to remove the name.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation:   b.pathKeyRead,
+			logical.UpdateOperation: b.pathKeyWrite,
+			logical.DeleteOperation: b.pathKeyDelete,
+		},
+
+		HelpSynopsis:    pathKeyHelpSyn,
+		HelpDescription: pathKeyHelpDesc,
+	}
+}
+
+func (b *backend) pathListKeysHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	ids, err := listKeys(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	keyInfo := make(map[string]interface{}, len(ids))
+	for _, id := range ids {
+		key, err := fetchKeyById(ctx, req.Storage, id)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			continue
+		}
+		keyInfo[id] = map[string]interface{}{
+			"key_name": key.Name,
+		}
+	}
+
+	return logical.ListResponseWithInfo(ids, keyInfo), nil
+}
+
+func (b *backend) pathKeyRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := resolveKeyReference(ctx, req.Storage, data.Get("key_ref").(string))
+	if err != nil {
+		return errorResponseOrErr(err)
+	}
+
+	key, err := fetchKeyById(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, nil
+	}
+
+	// The private key itself is never returned
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"key_id":   key.ID,
+			"key_name": key.Name,
+			"key_type": string(key.PrivateKeyType),
+		},
+	}, nil
+}
+
+func (b *backend) pathKeyWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := resolveKeyReference(ctx, req.Storage, data.Get("key_ref").(string))
+	if err != nil {
+		return errorResponseOrErr(err)
+	}
+
+	key, err := fetchKeyById(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, nil
+	}
+
+	if nameRaw, ok := data.GetOk("key_name"); ok {
+		name := nameRaw.(string)
+		if err := validateKeyName(ctx, req.Storage, name, key.ID); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		key.Name = name
+		if err := writeKey(ctx, req.Storage, key); err != nil {
+			return nil, err
+		}
+	}
+
+	data.Raw["key_ref"] = key.ID
+	return b.pathKeyRead(ctx, req, data)
+}
+
+func (b *backend) pathKeyDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	id, err := resolveKeyReference(ctx, req.Storage, data.Get("key_ref").(string))
+	if err != nil {
+		return errorResponseOrErr(err)
+	}
+
+	issuerIDs, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	for _, issuerID := range issuerIDs {
+		issuer, err := fetchIssuerById(ctx, req.Storage, issuerID)
+		if err != nil {
+			return nil, err
+		}
+		if issuer != nil && issuer.KeyID == id {
+			return logical.ErrorResponse(fmt.Sprintf("key is in use by issuer %q; delete the issuer first", issuerID)), nil
+		}
+	}
+
+	return nil, req.Storage.Delete(ctx, keyPrefix+id)
+}
+
+const pathListKeysHelpSyn = `
+List the keys held by this mount.
+`
+
+const pathListKeysHelpDesc = `
+This path lists the IDs of all private keys held by this mount, along with
+each key's name.
+`
+
+const pathKeyHelpSyn = `
+Read, rename or delete a single key.
+`
+
+const pathKeyHelpDesc = `
+This path allows a key, referenced by its ID or name, to be read, renamed or
+deleted. The private key material itself can never be read. Keys which are
+in use by an issuer cannot be deleted.
+`
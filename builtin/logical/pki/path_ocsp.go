@@ -0,0 +1,262 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ocsp"
+)
+
+const ocspResponseContentType = "application/ocsp-response"
+
+// Answers DER-encoded OCSP requests sent as a POST body, unauthenticated
+func pathOCSP(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "ocsp",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathOCSPPost,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+// Answers base64-encoded OCSP requests sent as part of the URL, unauthenticated
+func pathOCSPGet(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "ocsp/" + framework.MatchAllRegex("req"),
+		Fields: map[string]*framework.FieldSchema{
+			"req": &framework.FieldSchema{
+				Type:        framework.TypeString,
+				Description: `Base64-encoded DER OCSP request.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathOCSPGetHandler,
+		},
+
+		HelpSynopsis:    pathOCSPHelpSyn,
+		HelpDescription: pathOCSPHelpDesc,
+	}
+}
+
+func (b *backend) pathOCSPGetHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	der, err := base64.StdEncoding.DecodeString(data.Get("req").(string))
+	if err != nil {
+		return ocspResponse(ocsp.MalformedRequestErrorResponse), nil
+	}
+
+	return b.respondOCSP(ctx, req, der)
+}
+
+func (b *backend) pathOCSPPost(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// The HTTP layer places the unparsed body of OCSP requests here
+	der, ok := data.Raw[logical.HTTPRawBody].([]byte)
+	if !ok || len(der) == 0 {
+		return ocspResponse(ocsp.MalformedRequestErrorResponse), nil
+	}
+
+	return b.respondOCSP(ctx, req, der)
+}
+
+// respondOCSP answers a single DER-encoded OCSP request. Failures caused by
+// the request are reported to the client as OCSP error responses rather than
+// as Vault errors, as required by RFC 6960.
+func (b *backend) respondOCSP(ctx context.Context, req *logical.Request, der []byte) (*logical.Response, error) {
+	config, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	ocspLifetime := b.ocspLifetime
+	if config != nil {
+		if config.OCSPDisable {
+			return ocspResponse(ocsp.UnauthorizedErrorResponse), nil
+		}
+		if config.OCSPExpiry != "" {
+			ocspLifetime, err = time.ParseDuration(config.OCSPExpiry)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing OCSP duration of %s", config.OCSPExpiry)
+			}
+		}
+	}
+
+	ocspReq, err := ocsp.ParseRequest(der)
+	if err != nil {
+		return ocspResponse(ocsp.MalformedRequestErrorResponse), nil
+	}
+	if !ocspReq.HashAlgorithm.Available() {
+		return ocspResponse(ocsp.MalformedRequestErrorResponse), nil
+	}
+
+	issuerID, err := findIssuerForOCSPRequest(ctx, req.Storage, ocspReq)
+	if err != nil {
+		return nil, err
+	}
+	if issuerID == "" {
+		// We are not authoritative for certificates of an unknown issuer
+		return ocspResponse(ocsp.UnauthorizedErrorResponse), nil
+	}
+
+	caInfo, err := fetchCAInfoByIssuer(ctx, req, issuerID)
+	if err != nil {
+		return ocspResponse(ocsp.UnauthorizedErrorResponse), nil
+	}
+
+	template, err := ocspTemplateForSerial(ctx, req, caInfo.Certificate, ocspReq.SerialNumber)
+	if err != nil {
+		return nil, err
+	}
+	template.IssuerHash = ocspReq.HashAlgorithm
+	template.ThisUpdate = time.Now()
+	template.NextUpdate = template.ThisUpdate.Add(ocspLifetime)
+
+	// The issuing CA signs its own responses, so no responder certificate
+	// needs to be embedded
+	resp, err := ocsp.CreateResponse(caInfo.Certificate, caInfo.Certificate, template, caInfo.PrivateKey)
+	if err != nil {
+		return nil, errwrap.Wrapf("error creating OCSP response: {{err}}", err)
+	}
+
+	return ocspResponse(resp), nil
+}
+
+// ocspTemplateForSerial determines the status of the certificate with the
+// given serial, as issued by the given issuer.
+func ocspTemplateForSerial(ctx context.Context, req *logical.Request, issuerCert *x509.Certificate, serial *big.Int) (ocsp.Response, error) {
+	template := ocsp.Response{
+		Status:       ocsp.Unknown,
+		SerialNumber: serial,
+	}
+	colonSerial := certutil.GetHexFormatted(serial.Bytes(), ":")
+
+	revokedEntry, err := fetchCertBySerial(ctx, req, "revoked/", colonSerial)
+	if err != nil {
+		return template, err
+	}
+	if revokedEntry != nil {
+		var revInfo revocationInfo
+		if err := revokedEntry.DecodeJSON(&revInfo); err != nil {
+			return template, errwrap.Wrapf("error decoding revocation entry: {{err}}", err)
+		}
+		revokedCert, err := x509.ParseCertificate(revInfo.CertificateBytes)
+		if err != nil {
+			return template, errwrap.Wrapf("error parsing revoked certificate: {{err}}", err)
+		}
+		if issuedBy(revokedCert, issuerCert) {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = time.Unix(revInfo.RevocationTime, 0)
+			if !revInfo.RevocationTimeUTC.IsZero() {
+				template.RevokedAt = revInfo.RevocationTimeUTC
+			}
+			template.RevocationReason = ocsp.Unspecified
+		}
+		return template, nil
+	}
+
+	certEntry, err := fetchCertBySerial(ctx, req, "certs/", colonSerial)
+	if err != nil {
+		return template, err
+	}
+	if certEntry == nil {
+		return template, nil
+	}
+	cert, err := x509.ParseCertificate(certEntry.Value)
+	if err != nil {
+		return template, errwrap.Wrapf("error parsing stored certificate: {{err}}", err)
+	}
+	if issuedBy(cert, issuerCert) {
+		template.Status = ocsp.Good
+	}
+
+	return template, nil
+}
+
+// findIssuerForOCSPRequest returns the ID of the stored issuer whose name
+// and key hashes match those in the request, or an empty string if there is
+// none.
+func findIssuerForOCSPRequest(ctx context.Context, s logical.Storage, ocspReq *ocsp.Request) (string, error) {
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return "", err
+	}
+	for _, id := range ids {
+		issuer, err := fetchIssuerById(ctx, s, id)
+		if err != nil {
+			return "", err
+		}
+		if issuer == nil || issuer.KeyID == "" {
+			continue
+		}
+		issuerCert, err := issuer.GetCertificate()
+		if err != nil {
+			return "", err
+		}
+
+		var publicKeyInfo struct {
+			Algorithm pkix.AlgorithmIdentifier
+			PublicKey asn1.BitString
+		}
+		if _, err := asn1.Unmarshal(issuerCert.RawSubjectPublicKeyInfo, &publicKeyInfo); err != nil {
+			return "", errwrap.Wrapf("error parsing issuer public key: {{err}}", err)
+		}
+
+		h := ocspReq.HashAlgorithm.New()
+		h.Write(issuerCert.RawSubject)
+		if !bytes.Equal(h.Sum(nil), ocspReq.IssuerNameHash) {
+			continue
+		}
+		h.Reset()
+		h.Write(publicKeyInfo.PublicKey.RightAlign())
+		if bytes.Equal(h.Sum(nil), ocspReq.IssuerKeyHash) {
+			return issuer.ID, nil
+		}
+	}
+
+	return "", nil
+}
+
+// issuedBy reports whether cert was signed by issuerCert.
+func issuedBy(cert, issuerCert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, issuerCert.RawSubject) && cert.CheckSignatureFrom(issuerCert) == nil
+}
+
+func ocspResponse(body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: ocspResponseContentType,
+			logical.HTTPRawBody:     body,
+			logical.HTTPStatusCode:  200,
+		},
+	}
+}
+
+const pathOCSPHelpSyn = `
+Query the revocation status of a certificate via OCSP.
+`
+
+const pathOCSPHelpDesc = `
+This is an OCSP responder as described in RFC 6960, and does not require
+authentication. Requests may either be POSTed to "ocsp" as a DER-encoded body
+with a Content-Type of "application/ocsp-request", or sent via GET to
+"ocsp/<request>" with the DER-encoded request base64-encoded in the path.
+
+Responses are signed by the issuer named in the request. Certificates which
+were not issued by one of the mount's issuers are answered as unauthorized,
+and unknown serial numbers are answered with a status of unknown. The
+lifetime of responses and whether the responder is enabled at all are
+configured via config/crl.
+`
@@ -0,0 +1,228 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault"
+	"golang.org/x/crypto/ocsp"
+)
+
+func TestPki_OCSP(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	write := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	parseCert := func(certPem string) *x509.Certificate {
+		t.Helper()
+		block, _ := pem.Decode([]byte(certPem))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+
+	resp := write("root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "8760h",
+		"key_type":    "ec",
+		"key_bits":    256,
+	})
+	issuer := parseCert(resp.Data["certificate"].(string))
+
+	write("roles/example", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+		"key_type":         "ec",
+		"key_bits":         256,
+	})
+	resp = write("issue/example", map[string]interface{}{
+		"common_name": "a.example.com",
+	})
+	leaf := parseCert(resp.Data["certificate"].(string))
+
+	query := func(op logical.Operation, cert *x509.Certificate) *ocsp.Response {
+		t.Helper()
+		der, err := ocsp.CreateRequest(cert, issuer, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := &logical.Request{
+			Operation: op,
+			Storage:   storage,
+		}
+		if op == logical.ReadOperation {
+			req.Path = "ocsp/" + base64.StdEncoding.EncodeToString(der)
+		} else {
+			req.Path = "ocsp"
+			req.Data = map[string]interface{}{
+				logical.HTTPRawBody: der,
+			}
+		}
+		resp, err := b.HandleRequest(context.Background(), req)
+		if err != nil || resp == nil {
+			t.Fatalf("bad: err: %v resp: %#v", err, resp)
+		}
+		if resp.Data[logical.HTTPContentType] != "application/ocsp-response" {
+			t.Fatalf("unexpected content type %v", resp.Data[logical.HTTPContentType])
+		}
+
+		ocspResp, err := ocsp.ParseResponseForCert(resp.Data[logical.HTTPRawBody].([]byte), cert, issuer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ocspResp
+	}
+
+	for _, op := range []logical.Operation{logical.ReadOperation, logical.UpdateOperation} {
+		if status := query(op, leaf).Status; status != ocsp.Good {
+			t.Fatalf("expected good status via %s, got %d", op, status)
+		}
+	}
+
+	write("revoke", map[string]interface{}{
+		"serial_number": resp.Data["serial_number"],
+	})
+	ocspResp := query(logical.UpdateOperation, leaf)
+	if ocspResp.Status != ocsp.Revoked {
+		t.Fatalf("expected revoked status, got %d", ocspResp.Status)
+	}
+	if ocspResp.RevokedAt.IsZero() {
+		t.Fatal("expected a revocation time")
+	}
+
+	// A certificate with a serial the mount has never issued is unknown
+	unknown := *leaf
+	unknown.SerialNumber = new(big.Int).Add(leaf.SerialNumber, big.NewInt(1))
+	if status := query(logical.ReadOperation, &unknown).Status; status != ocsp.Unknown {
+		t.Fatalf("expected unknown status, got %d", status)
+	}
+
+	write("config/crl", map[string]interface{}{
+		"ocsp_disable": true,
+	})
+	der, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "ocsp/" + base64.StdEncoding.EncodeToString(der),
+		Storage:   storage,
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	_, err = ocsp.ParseResponse(resp.Data[logical.HTTPRawBody].([]byte), issuer)
+	if err != (ocsp.ResponseError{Status: ocsp.Unauthorized}) {
+		t.Fatalf("expected unauthorized response when disabled, got %v", err)
+	}
+}
+
+func TestPki_OCSPUnauthenticated(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"pki": Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	client := cluster.Cores[0].Client
+	if err := client.Sys().Mount("pki", &api.MountInput{
+		Type: "pki",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Logical().Write("pki/root/generate/internal", map[string]interface{}{
+		"common_name": "root.example.com",
+		"ttl":         "8760h",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuerPEM := resp.Data["certificate"].(string)
+	if _, err := client.Logical().Write("pki/roles/example", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = client.Logical().Write("pki/issue/example", map[string]interface{}{
+		"common_name": "a.example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafPEM := resp.Data["certificate"].(string)
+
+	parseCert := func(certPem string) *x509.Certificate {
+		t.Helper()
+		block, _ := pem.Decode([]byte(certPem))
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert
+	}
+	issuer, leaf := parseCert(issuerPEM), parseCert(leafPEM)
+	der, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// OCSP clients don't have a token, so both forms of request go through
+	// the router unauthenticated
+	client.SetToken("")
+	getReq := client.NewRequest("GET", "/v1/pki/ocsp/"+base64.StdEncoding.EncodeToString(der))
+	postReq := client.NewRequest("POST", "/v1/pki/ocsp")
+	postReq.Headers = http.Header{"Content-Type": []string{"application/ocsp-request"}}
+	postReq.BodyBytes = der
+
+	for _, req := range []*api.Request{getReq, postReq} {
+		httpResp, err := client.RawRequest(req)
+		if err != nil {
+			t.Fatalf("%s: %v", req.Method, err)
+		}
+		body, err := ioutil.ReadAll(httpResp.Body)
+		httpResp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ocspResp, err := ocsp.ParseResponseForCert(body, leaf, issuer)
+		if err != nil {
+			t.Fatalf("%s: %v", req.Method, err)
+		}
+		if ocspResp.Status != ocsp.Good {
+			t.Fatalf("%s: expected good status, got %d", req.Method, ocspResp.Status)
+		}
+	}
+}
@@ -45,6 +45,19 @@ func pathRotateCRL(b *backend) *framework.Path {
 	}
 }
 
+func pathRotateDeltaCRL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `crl/rotate-delta`,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathRotateDeltaCRLRead,
+		},
+
+		HelpSynopsis:    pathRotateDeltaCRLHelpSyn,
+		HelpDescription: pathRotateDeltaCRLHelpDesc,
+	}
+}
+
 func (b *backend) pathRevokeWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	serial := data.Get("serial_number").(string)
 	if len(serial) == 0 {
@@ -84,6 +97,33 @@ func (b *backend) pathRotateCRLRead(ctx context.Context, req *logical.Request, d
 	}
 }
 
+func (b *backend) pathRotateDeltaCRLRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	crlInfo, err := b.CRL(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if crlInfo == nil || !crlInfo.EnableDelta || crlInfo.Disable {
+		return logical.ErrorResponse("delta CRLs are not enabled"), nil
+	}
+
+	b.revokeStorageLock.Lock()
+	defer b.revokeStorageLock.Unlock()
+
+	crlErr := buildDeltaCRL(ctx, b, req)
+	switch crlErr.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(fmt.Sprintf("Error during delta CRL building: %s", crlErr)), nil
+	case errutil.InternalError:
+		return nil, errwrap.Wrapf("error encountered during delta CRL building: {{err}}", crlErr)
+	default:
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"success": true,
+			},
+		}, nil
+	}
+}
+
 const pathRevokeHelpSyn = `
 Revoke a certificate by serial number.
 `
@@ -99,3 +139,12 @@ Force a rebuild of the CRL.
 const pathRotateCRLHelpDesc = `
 Force a rebuild of the CRL. This can be used to remove expired certificates from it if no certificates have been revoked. A root token is required.
 `
+
+const pathRotateDeltaCRLHelpSyn = `
+Force a rebuild of the delta CRL.
+`
+
+const pathRotateDeltaCRLHelpDesc = `
+Force a rebuild of the delta CRL. This can be used to publish revocations
+before the next scheduled delta CRL rebuild.
+`
@@ -370,6 +370,19 @@ for "generate_lease".`,
 					Value: 30,
 				},
 			},
+
+			"issuer_ref": &framework.FieldSchema{
+				Type:    framework.TypeString,
+				Default: defaultRef,
+				Description: `Reference to the issuer used to sign requests
+serviced by this role; either "default" for the
+mount's default issuer, an issuer ID, or an
+issuer name.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:  "Issuer Reference",
+					Value: defaultRef,
+				},
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -579,6 +592,7 @@ func (b *backend) pathRoleCreate(ctx context.Context, req *logical.Request, data
 		PolicyIdentifiers:             data.Get("policy_identifiers").([]string),
 		BasicConstraintsValidForNonCA: data.Get("basic_constraints_valid_for_non_ca").(bool),
 		NotBeforeDuration:             time.Duration(data.Get("not_before_duration").(int)) * time.Second,
+		Issuer:                        data.Get("issuer_ref").(string),
 	}
 
 	allowedOtherSANs := data.Get("allowed_other_sans").([]string)
@@ -632,6 +646,17 @@ func (b *backend) pathRoleCreate(ctx context.Context, req *logical.Request, data
 		}
 	}
 
+	// The default issuer may legitimately not exist yet, but any other
+	// reference must resolve
+	if entry.Issuer == "" {
+		entry.Issuer = defaultRef
+	}
+	if entry.Issuer != defaultRef {
+		if _, err := resolveIssuerReference(ctx, req.Storage, entry.Issuer); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("error resolving issuer_ref: %s", err)), nil
+		}
+	}
+
 	// Store it
 	jsonEntry, err := logical.StorageEntryJSON("role/"+name, entry)
 	if err != nil {
@@ -774,6 +799,7 @@ type roleEntry struct {
 	ExtKeyUsageOIDs               []string      `json:"ext_key_usage_oids" mapstructure:"ext_key_usage_oids"`
 	BasicConstraintsValidForNonCA bool          `json:"basic_constraints_valid_for_non_ca" mapstructure:"basic_constraints_valid_for_non_ca"`
 	NotBeforeDuration             time.Duration `json:"not_before_duration" mapstructure:"not_before_duration"`
+	Issuer                        string        `json:"issuer_ref" mapstructure:"issuer_ref"`
 
 	// Used internally for signing intermediates
 	AllowExpirationPastCA bool
@@ -826,6 +852,10 @@ func (r *roleEntry) ToResponseData() map[string]interface{} {
 	if r.GenerateLease != nil {
 		responseData["generate_lease"] = r.GenerateLease
 	}
+	responseData["issuer_ref"] = r.Issuer
+	if r.Issuer == "" {
+		responseData["issuer_ref"] = defaultRef
+	}
 	return responseData
 }
 
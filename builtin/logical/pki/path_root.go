@@ -20,8 +20,16 @@ import (
 )
 
 func pathGenerateRoot(b *backend) *framework.Path {
+	return buildPathGenerateRoot(b, "root/generate/"+framework.GenericNameRegex("exported"))
+}
+
+func pathGenerateIssuerRoot(b *backend) *framework.Path {
+	return buildPathGenerateRoot(b, "issuers/generate/root/"+framework.GenericNameRegex("exported"))
+}
+
+func buildPathGenerateRoot(b *backend, pattern string) *framework.Path {
 	ret := &framework.Path{
-		Pattern: "root/generate/" + framework.GenericNameRegex("exported"),
+		Pattern: pattern,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.UpdateOperation: b.pathCAGenerateRoot,
@@ -34,6 +42,7 @@ func pathGenerateRoot(b *backend) *framework.Path {
 	ret.Fields = addCACommonFields(map[string]*framework.FieldSchema{})
 	ret.Fields = addCAKeyGenerationFields(ret.Fields)
 	ret.Fields = addCAIssueFields(ret.Fields)
+	ret.Fields = addIssuerNameField(ret.Fields)
 
 	return ret
 }
@@ -54,8 +63,18 @@ func pathDeleteRoot(b *backend) *framework.Path {
 }
 
 func pathSignIntermediate(b *backend) *framework.Path {
+	return buildPathSignIntermediate(b, "root/sign-intermediate")
+}
+
+func pathIssuerSignIntermediate(b *backend) *framework.Path {
+	ret := buildPathSignIntermediate(b, "issuer/"+framework.GenericNameRegex("issuer_ref")+"/sign-intermediate")
+	ret.Fields = addIssuerRefField(ret.Fields)
+	return ret
+}
+
+func buildPathSignIntermediate(b *backend, pattern string) *framework.Path {
 	ret := &framework.Path{
-		Pattern: "root/sign-intermediate",
+		Pattern: pattern,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.UpdateOperation: b.pathCASignIntermediate,
@@ -92,8 +111,18 @@ the non-repudiation flag.`,
 }
 
 func pathSignSelfIssued(b *backend) *framework.Path {
+	return buildPathSignSelfIssued(b, "root/sign-self-issued")
+}
+
+func pathIssuerSignSelfIssued(b *backend) *framework.Path {
+	ret := buildPathSignSelfIssued(b, "issuer/"+framework.GenericNameRegex("issuer_ref")+"/sign-self-issued")
+	ret.Fields = addIssuerRefField(ret.Fields)
+	return ret
+}
+
+func buildPathSignSelfIssued(b *backend, pattern string) *framework.Path {
 	ret := &framework.Path{
-		Pattern: "root/sign-self-issued",
+		Pattern: pattern,
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
 			logical.UpdateOperation: b.pathCASignSelfIssued,
@@ -114,20 +143,58 @@ func pathSignSelfIssued(b *backend) *framework.Path {
 }
 
 func (b *backend) pathCADeleteRoot(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	return nil, req.Storage.Delete(ctx, "config/ca_bundle")
+	issuerIDs, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range issuerIDs {
+		if err := req.Storage.Delete(ctx, issuerPrefix+id); err != nil {
+			return nil, err
+		}
+		if err := deleteIssuerCRLs(ctx, req.Storage, id); err != nil {
+			return nil, err
+		}
+	}
+
+	keyIDs, err := listKeys(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range keyIDs {
+		if err := req.Storage.Delete(ctx, keyPrefix+id); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, req.Storage.Delete(ctx, issuersConfigPath)
 }
 
 func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	var err error
 
-	entry, err := req.Storage.Get(ctx, "config/ca_bundle")
-	if err != nil {
-		return nil, err
+	// The legacy root/generate path refuses to replace the mount's CA;
+	// issuers/generate/root instead adds a new issuer alongside it.
+	isLegacy := !strings.HasPrefix(req.Path, "issuers/")
+	if isLegacy {
+		_, err := resolveIssuerReference(ctx, req.Storage, defaultRef)
+		switch err.(type) {
+		case nil:
+			resp := &logical.Response{}
+			resp.AddWarning(fmt.Sprintf("Refusing to generate a root certificate over an existing root certificate. If you really want to destroy the original root certificate, please issue a delete against %sroot.", req.MountPoint))
+			return resp, nil
+		case errutil.UserError:
+		default:
+			return nil, err
+		}
+	}
+
+	issuerName := data.Get("issuer_name").(string)
+	if err := validateIssuerName(ctx, req.Storage, issuerName, ""); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
-	if entry != nil {
-		resp := &logical.Response{}
-		resp.AddWarning(fmt.Sprintf("Refusing to generate a root certificate over an existing root certificate. If you really want to destroy the original root certificate, please issue a delete against %sroot.", req.MountPoint))
-		return resp, nil
+	keyName := data.Get("key_name").(string)
+	if err := validateKeyName(ctx, req.Storage, keyName, ""); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
 	}
 
 	exported, format, role, errorResp := b.getGenerationParams(data)
@@ -204,15 +271,13 @@ func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request,
 		}
 	}
 
-	// Store it as the CA bundle
-	entry, err = logical.StorageEntryJSON("config/ca_bundle", cb)
-	if err != nil {
-		return nil, err
-	}
-	err = req.Storage.Put(ctx, entry)
+	// Store it as a new issuer
+	issuer, err := writeCABundle(ctx, req.Storage, cb, issuerName, keyName, isLegacy)
 	if err != nil {
 		return nil, err
 	}
+	resp.Data["issuer_id"] = issuer.ID
+	resp.Data["key_id"] = issuer.KeyID
 
 	// Also store it as just the certificate identified by serial number, so it
 	// can be revoked
@@ -224,15 +289,6 @@ func (b *backend) pathCAGenerateRoot(ctx context.Context, req *logical.Request,
 		return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
 	}
 
-	// For ease of later use, also store just the certificate at a known
-	// location
-	entry.Key = "ca"
-	entry.Value = parsedBundle.CertificateBytes
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		return nil, err
-	}
-
 	// Build a fresh CRL
 	err = buildCRL(ctx, b, req, true)
 	if err != nil {
@@ -280,7 +336,7 @@ func (b *backend) pathCASignIntermediate(ctx context.Context, req *logical.Reque
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfoByIssuer(ctx, req, getIssuerRef(data))
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
@@ -374,6 +430,15 @@ func (b *backend) pathCASignIntermediate(ctx context.Context, req *logical.Reque
 		return nil, errwrap.Wrapf("unable to store certificate locally: {{err}}", err)
 	}
 
+	issuerID, err := resolveIssuerReference(ctx, req.Storage, getIssuerRef(data))
+	if err != nil {
+		return nil, err
+	}
+	md := newCertMetadata(parsedBundle.Certificate, issuerID, "", req.EntityID)
+	if err := storeCertMetadata(ctx, req.Storage, md); err != nil {
+		return nil, errwrap.Wrapf("unable to store certificate metadata: {{err}}", err)
+	}
+
 	if parsedBundle.Certificate.MaxPathLen == 0 {
 		resp.AddWarning("Max path length of the signed certificate is zero. This certificate cannot be used to issue intermediate CA certificates.")
 	}
@@ -406,7 +471,7 @@ func (b *backend) pathCASignSelfIssued(ctx context.Context, req *logical.Request
 	}
 
 	var caErr error
-	signingBundle, caErr := fetchCAInfo(ctx, req)
+	signingBundle, caErr := fetchCAInfoByIssuer(ctx, req, getIssuerRef(data))
 	switch caErr.(type) {
 	case errutil.UserError:
 		return nil, errutil.UserError{Err: fmt.Sprintf(
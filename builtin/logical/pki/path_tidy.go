@@ -130,6 +130,41 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 						if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
 							return errwrap.Wrapf(fmt.Sprintf("error deleting serial %q from storage: {{err}}", serial), err)
 						}
+						continue
+					}
+
+					// Index certificates stored before issuance metadata
+					// was recorded, so they can be found by searching
+					md, err := fetchCertMetadata(ctx, req.Storage, serial)
+					if err != nil {
+						return err
+					}
+					if md == nil {
+						issuerID, err := findIssuerForCert(ctx, req.Storage, cert)
+						if err != nil {
+							return errwrap.Wrapf(fmt.Sprintf("error finding issuer of certificate %q: {{err}}", serial), err)
+						}
+						if err := storeCertMetadata(ctx, req.Storage, newCertMetadata(cert, issuerID, "", "")); err != nil {
+							return errwrap.Wrapf(fmt.Sprintf("error storing metadata of certificate %q: {{err}}", serial), err)
+						}
+					}
+				}
+
+				// Remove the metadata of any certificates no longer stored
+				mdSerials, err := req.Storage.List(ctx, certMetadataPrefix)
+				if err != nil {
+					return errwrap.Wrapf("error fetching list of certificate metadata: {{err}}", err)
+				}
+				for _, serial := range mdSerials {
+					certEntry, err := req.Storage.Get(ctx, "certs/"+serial)
+					if err != nil {
+						return errwrap.Wrapf(fmt.Sprintf("error fetching certificate %q: {{err}}", serial), err)
+					}
+					if certEntry != nil {
+						continue
+					}
+					if err := deleteCertMetadata(ctx, req.Storage, serial); err != nil {
+						return errwrap.Wrapf(fmt.Sprintf("error deleting metadata of serial %q: {{err}}", serial), err)
 					}
 				}
 			}
@@ -191,6 +226,9 @@ func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *fr
 						if err := req.Storage.Delete(ctx, "certs/"+serial); err != nil {
 							return errwrap.Wrapf(fmt.Sprintf("error deleting serial %q from store when tidying revoked: {{err}}", serial), err)
 						}
+						if err := deleteCertMetadata(ctx, req.Storage, serial); err != nil {
+							return errwrap.Wrapf(fmt.Sprintf("error deleting metadata of serial %q when tidying revoked: {{err}}", serial), err)
+						}
 						tidiedRevoked = true
 					}
 				}
@@ -229,6 +267,10 @@ For safety, this function is a noop if called without parameters; cleanup from
 normal certificate storage must be enabled with 'tidy_cert_store' and cleanup
 from revocation information must be enabled with 'tidy_revocation_list'.
 
+Tidying the certificate store also keeps the index of certificate metadata
+used by "certs/search" in step with it: metadata of removed certificates is
+removed, and metadata is added for stored certificates which lack it.
+
 The 'safety_buffer' parameter is useful to ensure that clock skew amongst your
 hosts cannot lead to a certificate being removed from the CRL while it is still
 considered valid by other hosts (for instance, if their clocks are a few
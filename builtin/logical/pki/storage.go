@@ -0,0 +1,691 @@
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	issuerPrefix       = "issuer/"
+	keyPrefix          = "key/"
+	crlPrefix          = "crls/"
+	deltaCRLSuffix     = "/delta"
+	crlStatePath       = "crls/state"
+	deltaWALPrefix     = "delta-wal/"
+	issuersConfigPath  = "config/issuers"
+	legacyCABundlePath = "config/ca_bundle"
+	legacyCAPath       = "ca"
+	legacyCRLPath      = "crl"
+
+	// defaultRef is the reserved reference which resolves to the issuer
+	// currently configured as the mount's default.
+	defaultRef = "default"
+)
+
+var nameRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,127}$`)
+
+// issuerEntry is a single CA certificate held by the mount. Issuers that
+// reference a key may sign certificates and CRLs.
+type issuerEntry struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	KeyID        string   `json:"key_id"`
+	Certificate  string   `json:"certificate"`
+	CAChain      []string `json:"ca_chain"`
+	SerialNumber string   `json:"serial_number"`
+}
+
+// keyEntry is a single private key held by the mount. Keys are stored
+// separately from issuers so that a key may back more than one issuer, e.g.
+// when a CA certificate is reissued with the same key.
+type keyEntry struct {
+	ID             string                  `json:"id"`
+	Name           string                  `json:"name"`
+	PrivateKeyType certutil.PrivateKeyType `json:"private_key_type"`
+	PrivateKey     string                  `json:"private_key"`
+}
+
+type issuersConfigEntry struct {
+	DefaultIssuerID string `json:"default"`
+}
+
+func (i *issuerEntry) GetCertificate() (*x509.Certificate, error) {
+	block, _ := pem.Decode([]byte(i.Certificate))
+	if block == nil {
+		return nil, fmt.Errorf("unable to decode certificate of issuer %q", i.ID)
+	}
+
+	return x509.ParseCertificate(block.Bytes)
+}
+
+func (k *keyEntry) GetSigner() (crypto.Signer, error) {
+	bundle := &certutil.CertBundle{
+		PrivateKeyType: k.PrivateKeyType,
+		PrivateKey:     k.PrivateKey,
+	}
+	parsed, err := bundle.ToParsedCertBundle()
+	if err != nil {
+		return nil, err
+	}
+	if parsed.PrivateKey == nil {
+		return nil, fmt.Errorf("unable to parse private key %q", k.ID)
+	}
+
+	return parsed.PrivateKey, nil
+}
+
+func listIssuers(ctx context.Context, s logical.Storage) ([]string, error) {
+	return s.List(ctx, issuerPrefix)
+}
+
+func fetchIssuerById(ctx context.Context, s logical.Storage, id string) (*issuerEntry, error) {
+	entry, err := s.Get(ctx, issuerPrefix+id)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch issuer %q: %v", id, err)}
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var issuer issuerEntry
+	if err := entry.DecodeJSON(&issuer); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode issuer %q: %v", id, err)}
+	}
+
+	return &issuer, nil
+}
+
+func writeIssuer(ctx context.Context, s logical.Storage, issuer *issuerEntry) error {
+	entry, err := logical.StorageEntryJSON(issuerPrefix+issuer.ID, issuer)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func listKeys(ctx context.Context, s logical.Storage) ([]string, error) {
+	return s.List(ctx, keyPrefix)
+}
+
+func fetchKeyById(ctx context.Context, s logical.Storage, id string) (*keyEntry, error) {
+	entry, err := s.Get(ctx, keyPrefix+id)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch key %q: %v", id, err)}
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var key keyEntry
+	if err := entry.DecodeJSON(&key); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode key %q: %v", id, err)}
+	}
+
+	return &key, nil
+}
+
+func writeKey(ctx context.Context, s logical.Storage, key *keyEntry) error {
+	entry, err := logical.StorageEntryJSON(keyPrefix+key.ID, key)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func getIssuersConfig(ctx context.Context, s logical.Storage) (*issuersConfigEntry, error) {
+	entry, err := s.Get(ctx, issuersConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &issuersConfigEntry{}
+	if entry != nil {
+		if err := entry.DecodeJSON(config); err != nil {
+			return nil, err
+		}
+	}
+
+	return config, nil
+}
+
+func setIssuersConfig(ctx context.Context, s logical.Storage, config *issuersConfigEntry) error {
+	entry, err := logical.StorageEntryJSON(issuersConfigPath, config)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+// resolveIssuerReference maps a reference, which may be the literal
+// "default", an issuer ID or an issuer name, to an issuer ID.
+func resolveIssuerReference(ctx context.Context, s logical.Storage, ref string) (string, error) {
+	if ref == "" || ref == defaultRef {
+		config, err := getIssuersConfig(ctx, s)
+		if err != nil {
+			return "", errutil.InternalError{Err: fmt.Sprintf("unable to fetch issuers configuration: %v", err)}
+		}
+		if config.DefaultIssuerID == "" {
+			return "", errutil.UserError{Err: "backend must be configured with a CA certificate/key"}
+		}
+		return config.DefaultIssuerID, nil
+	}
+
+	issuer, err := fetchIssuerById(ctx, s, ref)
+	if err != nil {
+		return "", err
+	}
+	if issuer != nil {
+		return issuer.ID, nil
+	}
+
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return "", errutil.InternalError{Err: fmt.Sprintf("unable to list issuers: %v", err)}
+	}
+	for _, id := range ids {
+		issuer, err := fetchIssuerById(ctx, s, id)
+		if err != nil {
+			return "", err
+		}
+		if issuer != nil && issuer.Name == ref {
+			return issuer.ID, nil
+		}
+	}
+
+	return "", errutil.UserError{Err: fmt.Sprintf("unable to find issuer for reference: %s", ref)}
+}
+
+// resolveKeyReference maps a key ID or key name to a key ID.
+func resolveKeyReference(ctx context.Context, s logical.Storage, ref string) (string, error) {
+	key, err := fetchKeyById(ctx, s, ref)
+	if err != nil {
+		return "", err
+	}
+	if key != nil {
+		return key.ID, nil
+	}
+
+	ids, err := listKeys(ctx, s)
+	if err != nil {
+		return "", errutil.InternalError{Err: fmt.Sprintf("unable to list keys: %v", err)}
+	}
+	for _, id := range ids {
+		key, err := fetchKeyById(ctx, s, id)
+		if err != nil {
+			return "", err
+		}
+		if key != nil && key.Name == ref {
+			return key.ID, nil
+		}
+	}
+
+	return "", errutil.UserError{Err: fmt.Sprintf("unable to find key for reference: %s", ref)}
+}
+
+// validateIssuerName ensures a name is well formed, does not shadow the
+// reserved default reference and is not in use by a different issuer.
+func validateIssuerName(ctx context.Context, s logical.Storage, name, ownID string) error {
+	if name == "" {
+		return nil
+	}
+	if name == defaultRef || !nameRegex.MatchString(name) {
+		return errutil.UserError{Err: fmt.Sprintf("invalid issuer name %q", name)}
+	}
+
+	id, err := resolveIssuerReference(ctx, s, name)
+	switch err.(type) {
+	case nil:
+		if id != ownID {
+			return errutil.UserError{Err: fmt.Sprintf("issuer name %q is already in use", name)}
+		}
+	case errutil.UserError:
+	default:
+		return err
+	}
+
+	return nil
+}
+
+// validateKeyName is the key equivalent of validateIssuerName.
+func validateKeyName(ctx context.Context, s logical.Storage, name, ownID string) error {
+	if name == "" {
+		return nil
+	}
+	if name == defaultRef || !nameRegex.MatchString(name) {
+		return errutil.UserError{Err: fmt.Sprintf("invalid key name %q", name)}
+	}
+
+	id, err := resolveKeyReference(ctx, s, name)
+	switch err.(type) {
+	case nil:
+		if id != ownID {
+			return errutil.UserError{Err: fmt.Sprintf("key name %q is already in use", name)}
+		}
+	case errutil.UserError:
+	default:
+		return err
+	}
+
+	return nil
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	aBytes, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+	bBytes, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(aBytes, bBytes)
+}
+
+// importKey stores the given PEM private key, returning the existing entry
+// if an identical key is already held by the mount.
+func importKey(ctx context.Context, s logical.Storage, keyPem string, keyType certutil.PrivateKeyType, name string) (*keyEntry, bool, error) {
+	key := &keyEntry{
+		Name:           name,
+		PrivateKeyType: keyType,
+		PrivateKey:     strings.TrimSpace(keyPem),
+	}
+	signer, err := key.GetSigner()
+	if err != nil {
+		return nil, false, errutil.UserError{Err: err.Error()}
+	}
+
+	ids, err := listKeys(ctx, s)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, id := range ids {
+		existing, err := fetchKeyById(ctx, s, id)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing == nil {
+			continue
+		}
+		existingSigner, err := existing.GetSigner()
+		if err != nil {
+			return nil, false, err
+		}
+		if publicKeysEqual(signer.Public(), existingSigner.Public()) {
+			return existing, true, nil
+		}
+	}
+
+	if err := validateKeyName(ctx, s, name, ""); err != nil {
+		return nil, false, err
+	}
+
+	key.ID, err = uuid.GenerateUUID()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := writeKey(ctx, s, key); err != nil {
+		return nil, false, err
+	}
+
+	// Link any existing issuers which were imported without their key
+	issuerIDs, err := listIssuers(ctx, s)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, id := range issuerIDs {
+		issuer, err := fetchIssuerById(ctx, s, id)
+		if err != nil {
+			return nil, false, err
+		}
+		if issuer == nil || issuer.KeyID != "" {
+			continue
+		}
+		cert, err := issuer.GetCertificate()
+		if err != nil {
+			return nil, false, err
+		}
+		if publicKeysEqual(cert.PublicKey, signer.Public()) {
+			issuer.KeyID = key.ID
+			if err := writeIssuer(ctx, s, issuer); err != nil {
+				return nil, false, err
+			}
+		}
+	}
+
+	return key, false, nil
+}
+
+// importIssuer stores the given PEM CA certificate, linking it to a stored
+// key with a matching public key when one exists. If the certificate is
+// already held by the mount the existing entry is returned.
+func importIssuer(ctx context.Context, s logical.Storage, certPem string, caChain []string, name string) (*issuerEntry, bool, error) {
+	issuer := &issuerEntry{
+		Name:        name,
+		Certificate: strings.TrimSpace(certPem),
+		CAChain:     caChain,
+	}
+	cert, err := issuer.GetCertificate()
+	if err != nil {
+		return nil, false, errutil.UserError{Err: err.Error()}
+	}
+	if !cert.IsCA {
+		return nil, false, errutil.UserError{Err: "the given certificate is not marked for CA use and cannot be used with this backend"}
+	}
+	issuer.SerialNumber = certutil.GetHexFormatted(cert.SerialNumber.Bytes(), ":")
+
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, id := range ids {
+		existing, err := fetchIssuerById(ctx, s, id)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing != nil && existing.Certificate == issuer.Certificate {
+			return existing, true, nil
+		}
+	}
+
+	if err := validateIssuerName(ctx, s, name, ""); err != nil {
+		return nil, false, err
+	}
+
+	keyIDs, err := listKeys(ctx, s)
+	if err != nil {
+		return nil, false, err
+	}
+	for _, id := range keyIDs {
+		key, err := fetchKeyById(ctx, s, id)
+		if err != nil {
+			return nil, false, err
+		}
+		if key == nil {
+			continue
+		}
+		signer, err := key.GetSigner()
+		if err != nil {
+			return nil, false, err
+		}
+		if publicKeysEqual(cert.PublicKey, signer.Public()) {
+			issuer.KeyID = key.ID
+			break
+		}
+	}
+
+	issuer.ID, err = uuid.GenerateUUID()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := writeIssuer(ctx, s, issuer); err != nil {
+		return nil, false, err
+	}
+
+	return issuer, false, nil
+}
+
+// writeCABundle imports the key and certificate of the given bundle as a
+// new issuer. If makeDefault is set, or no default issuer exists yet, the
+// issuer becomes the mount's default.
+func writeCABundle(ctx context.Context, s logical.Storage, cb *certutil.CertBundle, issuerName, keyName string, makeDefault bool) (*issuerEntry, error) {
+	if cb.PrivateKey != "" {
+		if _, _, err := importKey(ctx, s, cb.PrivateKey, cb.PrivateKeyType, keyName); err != nil {
+			return nil, err
+		}
+	}
+
+	var caChain []string
+	for _, chainCert := range cb.CAChain {
+		if strings.TrimSpace(chainCert) != strings.TrimSpace(cb.Certificate) {
+			caChain = append(caChain, chainCert)
+		}
+	}
+
+	issuer, _, err := importIssuer(ctx, s, cb.Certificate, caChain, issuerName)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if makeDefault || config.DefaultIssuerID == "" {
+		config.DefaultIssuerID = issuer.ID
+		if err := setIssuersConfig(ctx, s, config); err != nil {
+			return nil, err
+		}
+	}
+
+	return issuer, nil
+}
+
+// fetchCAInfoByIssuer returns the signing bundle for the referenced issuer.
+// The issuer must have an associated key.
+func fetchCAInfoByIssuer(ctx context.Context, req *logical.Request, ref string) (*certutil.CAInfoBundle, error) {
+	id, err := resolveIssuerReference(ctx, req.Storage, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := fetchIssuerById(ctx, req.Storage, id)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("unable to find issuer for reference: %s", ref)}
+	}
+	if issuer.KeyID == "" {
+		return nil, errutil.UserError{Err: fmt.Sprintf("issuer %q has no associated private key and cannot be used for signing", issuer.ID)}
+	}
+
+	key, err := fetchKeyById(ctx, req.Storage, issuer.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("key %q of issuer %q is missing", issuer.KeyID, issuer.ID)}
+	}
+
+	bundle := &certutil.CertBundle{
+		Certificate:    issuer.Certificate,
+		CAChain:        issuer.CAChain,
+		PrivateKeyType: key.PrivateKeyType,
+		PrivateKey:     key.PrivateKey,
+		SerialNumber:   issuer.SerialNumber,
+	}
+	parsedBundle, err := bundle.ToParsedCertBundle()
+	if err != nil {
+		return nil, errutil.InternalError{Err: err.Error()}
+	}
+	if parsedBundle.Certificate == nil {
+		return nil, errutil.InternalError{Err: "stored CA information not able to be parsed"}
+	}
+
+	caInfo := &certutil.CAInfoBundle{ParsedCertBundle: *parsedBundle}
+
+	entries, err := getURLs(ctx, req)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to fetch URL information: %v", err)}
+	}
+	if entries == nil {
+		entries = &certutil.URLEntries{
+			IssuingCertificates:   []string{},
+			CRLDistributionPoints: []string{},
+			OCSPServers:           []string{},
+		}
+	}
+	caInfo.URLs = entries
+
+	return caInfo, nil
+}
+
+// fetchIssuerCertEntry returns the DER certificate of the referenced issuer
+// as a storage entry, or nil if no such issuer exists.
+func fetchIssuerCertEntry(ctx context.Context, s logical.Storage, ref string) (*logical.StorageEntry, error) {
+	id, err := resolveIssuerReference(ctx, s, ref)
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	issuer, err := fetchIssuerById(ctx, s, id)
+	if err != nil || issuer == nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode([]byte(issuer.Certificate))
+	if block == nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode certificate of issuer %q", id)}
+	}
+
+	return &logical.StorageEntry{
+		Key:   issuerPrefix + id,
+		Value: block.Bytes,
+	}, nil
+}
+
+// fetchIssuerCRLEntry returns the DER complete or delta CRL of the
+// referenced issuer, or nil if no such issuer or CRL exists.
+func fetchIssuerCRLEntry(ctx context.Context, s logical.Storage, ref string, delta bool) (*logical.StorageEntry, error) {
+	id, err := resolveIssuerReference(ctx, s, ref)
+	switch err.(type) {
+	case nil:
+	case errutil.UserError:
+		return nil, nil
+	default:
+		return nil, err
+	}
+
+	key := crlPrefix + id
+	if delta {
+		key += deltaCRLSuffix
+	}
+	entry, err := s.Get(ctx, key)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error fetching CRL of issuer %q: %s", id, err)}
+	}
+	if entry != nil && len(entry.Value) == 0 {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("CRL of issuer %q was empty", id)}
+	}
+
+	return entry, nil
+}
+
+// deleteIssuerCRLs removes the complete and delta CRLs of an issuer
+func deleteIssuerCRLs(ctx context.Context, s logical.Storage, id string) error {
+	if err := s.Delete(ctx, crlPrefix+id); err != nil {
+		return err
+	}
+	return s.Delete(ctx, crlPrefix+id+deltaCRLSuffix)
+}
+
+// findIssuerForCert returns the ID of the stored issuer which signed the
+// given certificate, or an empty string if there is none.
+func findIssuerForCert(ctx context.Context, s logical.Storage, cert *x509.Certificate) (string, error) {
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return "", err
+	}
+	for _, id := range ids {
+		issuer, err := fetchIssuerById(ctx, s, id)
+		if err != nil {
+			return "", err
+		}
+		if issuer == nil {
+			continue
+		}
+		issuerCert, err := issuer.GetCertificate()
+		if err != nil {
+			return "", err
+		}
+		if issuedBy(cert, issuerCert) {
+			return issuer.ID, nil
+		}
+	}
+
+	return "", nil
+}
+
+// isIssuerSerial reports whether the given colon-separated serial belongs
+// to one of the mount's issuers.
+func isIssuerSerial(ctx context.Context, s logical.Storage, colonSerial string) (bool, error) {
+	ids, err := listIssuers(ctx, s)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range ids {
+		issuer, err := fetchIssuerById(ctx, s, id)
+		if err != nil {
+			return false, err
+		}
+		if issuer != nil && issuer.SerialNumber == colonSerial {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// migrateLegacyBundle converts the single CA bundle stored by earlier
+// versions of this backend into an issuer and key, making it the default.
+func (b *backend) migrateLegacyBundle(ctx context.Context, s logical.Storage) error {
+	entry, err := s.Get(ctx, legacyCABundlePath)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		return nil
+	}
+
+	var cb certutil.CertBundle
+	if err := entry.DecodeJSON(&cb); err != nil {
+		return errwrap.Wrapf("unable to decode legacy CA bundle: {{err}}", err)
+	}
+
+	switch {
+	case cb.Certificate != "":
+		if _, err := writeCABundle(ctx, s, &cb, "", "", true); err != nil {
+			return err
+		}
+	case cb.PrivateKey != "":
+		// A pending intermediate; only the key exists so far
+		if _, _, err := importKey(ctx, s, cb.PrivateKey, cb.PrivateKeyType, ""); err != nil {
+			return err
+		}
+	}
+
+	for _, path := range []string{legacyCAPath, legacyCRLPath, legacyCABundlePath} {
+		if err := s.Delete(ctx, path); err != nil {
+			return err
+		}
+	}
+
+	if cb.Certificate != "" {
+		if err := buildCRL(ctx, b, &logical.Request{Storage: s}, true); err != nil {
+			return err
+		}
+	}
+
+	b.Logger().Info("migrated legacy CA bundle to issuer storage")
+	return nil
+}
@@ -1,7 +1,25 @@
 package pki
 
-import "strings"
+import (
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/framework"
+)
 
 func normalizeSerial(serial string) string {
 	return strings.Replace(strings.ToLower(serial), ":", "-", -1)
 }
+
+// getIssuerRef returns the issuer referenced by the request, falling back
+// to the mount's default issuer on paths without an issuer_ref field.
+func getIssuerRef(data *framework.FieldData) string {
+	if _, ok := data.Schema["issuer_ref"]; !ok {
+		return defaultRef
+	}
+
+	ref := data.Get("issuer_ref").(string)
+	if ref == "" {
+		return defaultRef
+	}
+	return ref
+}
@@ -32,6 +32,7 @@ type sshRole struct {
 	KeyBits                int               `mapstructure:"key_bits" json:"key_bits"`
 	AdminUser              string            `mapstructure:"admin_user" json:"admin_user"`
 	DefaultUser            string            `mapstructure:"default_user" json:"default_user"`
+	DefaultUserTemplate    bool              `mapstructure:"default_user_template" json:"default_user_template"`
 	CIDRList               string            `mapstructure:"cidr_list" json:"cidr_list"`
 	ExcludeCIDRList        string            `mapstructure:"exclude_cidr_list" json:"exclude_cidr_list"`
 	Port                   int               `mapstructure:"port" json:"port"`
@@ -39,6 +40,7 @@ type sshRole struct {
 	AllowedUsers           string            `mapstructure:"allowed_users" json:"allowed_users"`
 	AllowedUsersTemplate   bool              `mapstructure:"allowed_users_template" json:"allowed_users_template"`
 	AllowedDomains         string            `mapstructure:"allowed_domains" json:"allowed_domains"`
+	AllowedDomainsTemplate bool              `mapstructure:"allowed_domains_template" json:"allowed_domains_template"`
 	KeyOptionSpecs         string            `mapstructure:"key_option_specs" json:"key_option_specs"`
 	MaxTTL                 string            `mapstructure:"max_ttl" json:"max_ttl"`
 	TTL                    string            `mapstructure:"ttl" json:"ttl"`
@@ -110,6 +112,17 @@ func pathRoles(b *backend) *framework.Path {
 					Name: "Default Username",
 				},
 			},
+			"default_user_template": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				If set, Default user can be specified using identity template policies,
+				such as "{{identity.entity.aliases.<mount accessor>.name}}". The template
+				must be the whole default user, and renders to a single user. Signing fails
+				if the template cannot be rendered for the requesting entity.
+				`,
+				Default: false,
+			},
 			"cidr_list": &framework.FieldSchema{
 				Type: framework.TypeString,
 				Description: `
@@ -201,6 +214,17 @@ func pathRoles(b *backend) *framework.Path {
 				valid host. If only certain domains are allowed, then this list enforces it.
 				`,
 			},
+			"allowed_domains_template": &framework.FieldSchema{
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for Dynamic type] [Not applicable for OTP type] [Optional for CA type]
+				If set, Allowed domains can be specified using identity template policies,
+				such as "{{identity.entity.metadata.hostnames}}". A template may render to a
+				comma separated list of domains. Host certificates must then be requested with
+				explicit valid principals.
+				`,
+				Default: false,
+			},
 			"key_option_specs": &framework.FieldSchema{
 				Type: framework.TypeString,
 				Description: `
@@ -522,7 +546,9 @@ func (b *backend) createCARole(allowedUsers, defaultUser, signer string, data *f
 		AllowedUsers:           allowedUsers,
 		AllowedUsersTemplate:   data.Get("allowed_users_template").(bool),
 		AllowedDomains:         data.Get("allowed_domains").(string),
+		AllowedDomainsTemplate: data.Get("allowed_domains_template").(bool),
 		DefaultUser:            defaultUser,
+		DefaultUserTemplate:    data.Get("default_user_template").(bool),
 		AllowBareDomains:       data.Get("allow_bare_domains").(bool),
 		AllowSubdomains:        data.Get("allow_subdomains").(bool),
 		AllowUserKeyIDs:        data.Get("allow_user_key_ids").(bool),
@@ -604,7 +630,9 @@ func (b *backend) parseRole(role *sshRole) (map[string]interface{}, error) {
 			"allowed_users":            role.AllowedUsers,
 			"allowed_users_template":   role.AllowedUsersTemplate,
 			"allowed_domains":          role.AllowedDomains,
+			"allowed_domains_template": role.AllowedDomainsTemplate,
 			"default_user":             role.DefaultUser,
+			"default_user_template":    role.DefaultUserTemplate,
 			"ttl":                      int64(ttl.Seconds()),
 			"max_ttl":                  int64(maxTTL.Seconds()),
 			"allowed_critical_options": role.AllowedCriticalOptions,
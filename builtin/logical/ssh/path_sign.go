@@ -135,12 +135,17 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 
 	var parsedPrincipals []string
 	if certificateType == ssh.HostCert {
-		parsedPrincipals, err = b.calculateValidPrincipals(data, req, role, "", role.AllowedDomains, validateValidPrincipalForHosts(role))
+		parsedPrincipals, err = b.calculateValidPrincipals(data, req, "", false, role.AllowedDomains, role.AllowedDomainsTemplate, true, validateValidPrincipalForHosts(role))
 		if err != nil {
 			return logical.ErrorResponse(err.Error()), nil
 		}
+		// A host certificate without principals is valid for any host, which
+		// would defeat restricting hosts to those of the requesting entity
+		if role.AllowedDomainsTemplate && len(parsedPrincipals) == 0 {
+			return logical.ErrorResponse("valid_principals must be specified when the role uses allowed_domains_template"), nil
+		}
 	} else {
-		parsedPrincipals, err = b.calculateValidPrincipals(data, req, role, role.DefaultUser, role.AllowedUsers, strutil.StrListContains)
+		parsedPrincipals, err = b.calculateValidPrincipals(data, req, role.DefaultUser, role.DefaultUserTemplate, role.AllowedUsers, role.AllowedUsersTemplate, false, strutil.StrListContains)
 		if err != nil {
 			return logical.ErrorResponse(err.Error()), nil
 		}
@@ -206,37 +211,52 @@ func (b *backend) pathSignCertificate(ctx context.Context, req *logical.Request,
 	return response, nil
 }
 
-func (b *backend) calculateValidPrincipals(data *framework.FieldData, req *logical.Request, role *sshRole, defaultPrincipal, principalsAllowedByRole string, validatePrincipal func([]string, string) bool) ([]string, error) {
-	validPrincipals := ""
+var (
+	// identityTemplateRegex matches principals consisting of an identity
+	// template
+	identityTemplateRegex = regexp.MustCompile(`^{{.+?}}$`)
+
+	// embeddedIdentityTemplateRegex matches principals containing identity
+	// templates
+	embeddedIdentityTemplateRegex = regexp.MustCompile(`{{.+?}}`)
+)
+
+// calculateValidPrincipals returns the principals of the certificate, checking
+// they are allowed by the role. If templateLists is set, the templates of the
+// allowed principals may be embedded in them and render to comma separated
+// lists, as for allowed domains. Otherwise a template is a whole principal,
+// and renders to a single principal whatever its value.
+func (b *backend) calculateValidPrincipals(data *framework.FieldData, req *logical.Request, defaultPrincipal string, defaultPrincipalTemplate bool, principalsAllowedByRole string, principalsAllowedTemplate bool, templateLists bool, validatePrincipal func([]string, string) bool) ([]string, error) {
+	var parsedPrincipals []string
 	validPrincipalsRaw, ok := data.GetOk("valid_principals")
-	if ok {
-		validPrincipals = validPrincipalsRaw.(string)
-	} else {
-		validPrincipals = defaultPrincipal
+	switch {
+	case ok:
+		parsedPrincipals = strutil.ParseStringSlice(validPrincipalsRaw.(string), ",")
+	case defaultPrincipalTemplate:
+		rendered, err := b.renderPrincipals(defaultPrincipal, req, false)
+		if err != nil {
+			return nil, err
+		}
+		// Signing without principals would produce a certificate valid for
+		// any principal
+		if len(rendered) == 0 && defaultPrincipal != "" {
+			return nil, fmt.Errorf("template '%s' could not be rendered -> request has no identity entity", defaultPrincipal)
+		}
+		parsedPrincipals = rendered
+	default:
+		parsedPrincipals = strutil.ParseStringSlice(defaultPrincipal, ",")
 	}
+	parsedPrincipals = strutil.RemoveDuplicates(parsedPrincipals, false)
 
-	parsedPrincipals := strutil.RemoveDuplicates(strutil.ParseStringSlice(validPrincipals, ","), false)
 	// Build list of allowed Principals from template and static principalsAllowedByRole
 	var allowedPrincipals []string
 	for _, principal := range strutil.RemoveDuplicates(strutil.ParseStringSlice(principalsAllowedByRole, ","), false) {
-		if role.AllowedUsersTemplate {
-			// Look for templating markers {{ .* }}
-			matched, _ := regexp.MatchString(`^{{.+?}}$`, principal)
-			if matched {
-				if req.EntityID != "" {
-					// Retrieve principal based on template + entityID from request.
-					templatePrincipal, err := framework.PopulateIdentityTemplate(principal, req.EntityID, b.System())
-					if err == nil {
-						// Template returned a principal
-						allowedPrincipals = append(allowedPrincipals, templatePrincipal)
-					} else {
-						return nil, fmt.Errorf("template '%s' could not be rendered -> %s", principal, err)
-					}
-				}
-			} else {
-				// Static principal or err template
-				allowedPrincipals = append(allowedPrincipals, principal)
+		if principalsAllowedTemplate {
+			rendered, err := b.renderPrincipals(principal, req, templateLists)
+			if err != nil {
+				return nil, err
 			}
+			allowedPrincipals = append(allowedPrincipals, rendered...)
 		} else {
 			// Static principal
 			allowedPrincipals = append(allowedPrincipals, principal)
@@ -266,6 +286,37 @@ func (b *backend) calculateValidPrincipals(data *framework.FieldData, req *logic
 	}
 }
 
+// renderPrincipals resolves the identity templates in principal for the
+// entity making the request. Nothing is returned for templates when the
+// request has no entity.
+//
+// Unless list is set, only a principal consisting of a template is rendered,
+// and to a single principal: alias names and metadata may be influenced by
+// the user, so a value such as "alice,root" must not grant "root". If list is
+// set, templates may be embedded in the principal and the rendered value is
+// split on commas, as it may refer to metadata holding several principals.
+func (b *backend) renderPrincipals(principal string, req *logical.Request, list bool) ([]string, error) {
+	templateRegex := identityTemplateRegex
+	if list {
+		templateRegex = embeddedIdentityTemplateRegex
+	}
+	if !templateRegex.MatchString(principal) {
+		return []string{principal}, nil
+	}
+	if req.EntityID == "" {
+		return nil, nil
+	}
+
+	rendered, err := framework.PopulateIdentityTemplate(principal, req.EntityID, b.System())
+	if err != nil {
+		return nil, fmt.Errorf("template '%s' could not be rendered -> %s", principal, err)
+	}
+	if list {
+		return strutil.ParseStringSlice(rendered, ","), nil
+	}
+	return []string{rendered}, nil
+}
+
 func validateValidPrincipalForHosts(role *sshRole) func([]string, string) bool {
 	return func(allowedPrincipals []string, validPrincipal string) bool {
 		for _, allowedPrincipal := range allowedPrincipals {
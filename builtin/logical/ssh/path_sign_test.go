@@ -0,0 +1,251 @@
+package ssh
+
+import (
+	"context"
+	"crypto/rand"
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ed25519"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSH_SignTemplatedPrincipals(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = logical.StaticSystemView{
+		DefaultLeaseTTLVal: 24 * 3600e9,
+		MaxLeaseTTLVal:     24 * 3600e9,
+		EntityVal: &logical.Entity{
+			ID:   "entity-id",
+			Name: "entity",
+			Aliases: []*logical.Alias{
+				{
+					MountAccessor: "userpass_accessor",
+					Name:          "alice",
+				},
+			},
+			Metadata: map[string]string{
+				"hostnames": "web1.example.com,web2.example.com",
+			},
+		},
+	}
+
+	b, err := Backend(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(path, entityID string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   config.StorageView,
+			EntityID:  entityID,
+			Data:      data,
+		})
+	}
+	mustRequest := func(path, entityID string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(path, entityID, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	mustFail := func(path, entityID string, data map[string]interface{}) {
+		t.Helper()
+		resp, err := request(path, entityID, data)
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected request to %s to fail, got %#v", path, resp)
+		}
+	}
+	principals := func(resp *logical.Response) []string {
+		t.Helper()
+		parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(resp.Data["signed_key"].(string)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed.(*ssh.Certificate).ValidPrincipals
+	}
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := string(ssh.MarshalAuthorizedKey(sshPub))
+
+	mustRequest("config/ca", "", map[string]interface{}{
+		"generate_signing_key": true,
+	})
+
+	mustRequest("roles/user", "", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"default_user":            "{{identity.entity.aliases.userpass_accessor.name}}",
+		"default_user_template":   true,
+		"allowed_users":           "{{identity.entity.aliases.userpass_accessor.name}},{{identity.entity.name}},ops",
+		"allowed_users_template":  true,
+	})
+
+	// The default user is rendered for the requesting entity
+	resp := mustRequest("sign/user", "entity-id", map[string]interface{}{
+		"public_key": publicKey,
+	})
+	if p := principals(resp); !reflect.DeepEqual(p, []string{"alice"}) {
+		t.Fatalf("unexpected principals: %v", p)
+	}
+
+	// Templated and static allowed users may be combined
+	resp = mustRequest("sign/user", "entity-id", map[string]interface{}{
+		"public_key":       publicKey,
+		"valid_principals": "alice,entity,ops",
+	})
+	if p := principals(resp); !reflect.DeepEqual(p, []string{"alice", "entity", "ops"}) {
+		t.Fatalf("unexpected principals: %v", p)
+	}
+	mustFail("sign/user", "entity-id", map[string]interface{}{
+		"public_key":       publicKey,
+		"valid_principals": "bob",
+	})
+
+	// Without an entity the default user cannot be rendered, which must not
+	// result in a certificate valid for any user
+	mustFail("sign/user", "", map[string]interface{}{
+		"public_key": publicKey,
+	})
+
+	mustRequest("roles/host", "", map[string]interface{}{
+		"key_type":                 "ca",
+		"allow_host_certificates":  true,
+		"allowed_domains":          "{{identity.entity.metadata.hostnames}}",
+		"allowed_domains_template": true,
+		"allow_bare_domains":       true,
+	})
+
+	resp = mustRequest("sign/host", "entity-id", map[string]interface{}{
+		"public_key":       publicKey,
+		"cert_type":        "host",
+		"valid_principals": "web1.example.com,web2.example.com",
+	})
+	if p := principals(resp); !reflect.DeepEqual(p, []string{"web1.example.com", "web2.example.com"}) {
+		t.Fatalf("unexpected principals: %v", p)
+	}
+	mustFail("sign/host", "entity-id", map[string]interface{}{
+		"public_key":       publicKey,
+		"cert_type":        "host",
+		"valid_principals": "db1.example.com",
+	})
+	mustFail("sign/host", "entity-id", map[string]interface{}{
+		"public_key": publicKey,
+		"cert_type":  "host",
+	})
+	mustFail("sign/host", "", map[string]interface{}{
+		"public_key":       publicKey,
+		"cert_type":        "host",
+		"valid_principals": "web1.example.com",
+	})
+}
+
+func TestSSH_SignTemplatedPrincipals_Comma(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	config.System = logical.StaticSystemView{
+		DefaultLeaseTTLVal: 24 * 3600e9,
+		MaxLeaseTTLVal:     24 * 3600e9,
+		EntityVal: &logical.Entity{
+			ID:   "entity-id",
+			Name: "entity",
+			Aliases: []*logical.Alias{
+				{
+					MountAccessor: "userpass_accessor",
+					Name:          "alice,root",
+				},
+			},
+			Metadata: map[string]string{
+				"user": "bob,root",
+			},
+		},
+	}
+
+	b, err := Backend(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Setup(context.Background(), config); err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   config.StorageView,
+			EntityID:  "entity-id",
+			Data:      data,
+		})
+	}
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshPub, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey := string(ssh.MarshalAuthorizedKey(sshPub))
+
+	for path, data := range map[string]map[string]interface{}{
+		"config/ca": {
+			"generate_signing_key": true,
+		},
+		"roles/user": {
+			"key_type":                "ca",
+			"allow_user_certificates": true,
+			"default_user":            "{{identity.entity.aliases.userpass_accessor.name}}",
+			"default_user_template":   true,
+			"allowed_users":           "{{identity.entity.aliases.userpass_accessor.name}},{{identity.entity.metadata.user}}",
+			"allowed_users_template":  true,
+		},
+	} {
+		resp, err := request(path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+	}
+
+	// A value containing a comma renders to a single principal
+	resp, err := request("sign/user", map[string]interface{}{
+		"public_key": publicKey,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v resp: %#v", err, resp)
+	}
+	parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(resp.Data["signed_key"].(string)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := parsed.(*ssh.Certificate).ValidPrincipals; !reflect.DeepEqual(p, []string{"alice,root"}) {
+		t.Fatalf("unexpected principals: %v", p)
+	}
+
+	// Neither the alias name nor the metadata grant any other principal
+	for _, principal := range []string{"root", "alice", "bob"} {
+		resp, err := request("sign/user", map[string]interface{}{
+			"public_key":       publicKey,
+			"valid_principals": principal,
+		})
+		if err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("expected %q not to be allowed", principal)
+		}
+	}
+}
@@ -3,9 +3,12 @@ package transit
 import (
 	"context"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
@@ -29,6 +32,7 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 			SealWrapStorage: []string{
 				"archive/",
 				"policy/",
+				"import/",
 			},
 		},
 
@@ -38,6 +42,9 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 			b.pathConfig(),
 			b.pathRotate(),
 			b.pathRewrap(),
+			b.pathWrappingKey(),
+			b.pathImport(),
+			b.pathImportVersion(),
 			b.pathKeys(),
 			b.pathListKeys(),
 			b.pathExportKeys(),
@@ -47,6 +54,7 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 			b.pathRandom(),
 			b.pathHash(),
 			b.pathHMAC(),
+			b.pathCMAC(),
 			b.pathSign(),
 			b.pathVerify(),
 			b.pathBackup(),
@@ -55,9 +63,10 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 			b.pathCacheConfig(),
 		},
 
-		Secrets:     []*framework.Secret{},
-		Invalidate:  b.invalidate,
-		BackendType: logical.TypeLogical,
+		Secrets:      []*framework.Secret{},
+		Invalidate:   b.invalidate,
+		BackendType:  logical.TypeLogical,
+		PeriodicFunc: b.periodicFunc,
 	}
 
 	// determine cacheSize to use. Defaults to 0 which means unlimited
@@ -83,6 +92,14 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 type backend struct {
 	*framework.Backend
 	lm *keysutil.LockManager
+
+	// wrappingKeyLock serializes generation of the key import wrapping key
+	wrappingKeyLock sync.Mutex
+
+	// autoRotateLock guards autoRotateCheckAfter, the earliest time at which
+	// keys are next checked for automatic rotation
+	autoRotateLock       sync.Mutex
+	autoRotateCheckAfter time.Time
 }
 
 func GetCacheSizeFromStorage(ctx context.Context, s logical.Storage) (int, error) {
@@ -101,6 +118,19 @@ func GetCacheSizeFromStorage(ctx context.Context, s logical.Storage) (int, error
 	return size, nil
 }
 
+func (b *backend) periodicFunc(ctx context.Context, req *logical.Request) error {
+	// Keys are rotated on the primary only, unless the mount is local
+	replicationState := b.System().ReplicationState()
+	if replicationState.HasState(consts.ReplicationPerformanceStandby | consts.ReplicationDRSecondary) {
+		return nil
+	}
+	if !b.System().LocalMount() && replicationState.HasState(consts.ReplicationPerformanceSecondary) {
+		return nil
+	}
+
+	return b.autoRotateKeys(ctx, req)
+}
+
 func (b *backend) invalidate(_ context.Context, key string) {
 	if b.Logger().IsDebug() {
 		b.Logger().Debug("invalidating key", "key", key)
@@ -0,0 +1,61 @@
+package transit
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// kwpIV is the alternative initial value of RFC 5649
+var kwpIV = []byte{0xa6, 0x59, 0x59, 0xa6}
+
+var errKWPInvalidCiphertext = errors.New("invalid wrapped key")
+
+// unwrapKWP unwraps a key wrapped using AES key wrap with padding (RFC 5649)
+func unwrapKWP(kek, wrapped []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < 16 || len(wrapped)%8 != 0 {
+		return nil, errKWPInvalidCiphertext
+	}
+
+	out := make([]byte, len(wrapped))
+	copy(out, wrapped)
+
+	n := len(out)/8 - 1
+	if n == 1 {
+		block.Decrypt(out, out)
+	} else {
+		buf := make([]byte, 16)
+		a := out[:8]
+		for j := 5; j >= 0; j-- {
+			for i := n; i >= 1; i-- {
+				t := uint64(n*j + i)
+				binary.BigEndian.PutUint64(buf, binary.BigEndian.Uint64(a)^t)
+				copy(buf[8:], out[i*8:(i+1)*8])
+				block.Decrypt(buf, buf)
+
+				copy(a, buf[:8])
+				copy(out[i*8:], buf[8:])
+			}
+		}
+	}
+
+	if subtle.ConstantTimeCompare(out[:4], kwpIV) != 1 {
+		return nil, errKWPInvalidCiphertext
+	}
+	keyLen := int(binary.BigEndian.Uint32(out[4:8]))
+	if keyLen <= 8*(n-1) || keyLen > 8*n {
+		return nil, errKWPInvalidCiphertext
+	}
+	for _, pad := range out[8+keyLen:] {
+		if pad != 0 {
+			return nil, errKWPInvalidCiphertext
+		}
+	}
+
+	return out[8 : 8+keyLen], nil
+}
@@ -0,0 +1,92 @@
+package transit
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+// wrapKWP wraps key with kek using AES key wrap with padding (RFC 5649)
+func wrapKWP(kek, key []byte) ([]byte, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, errors.New("no key to wrap")
+	}
+
+	padded := make([]byte, 8+(len(key)+7)/8*8)
+	copy(padded, kwpIV)
+	binary.BigEndian.PutUint32(padded[4:8], uint32(len(key)))
+	copy(padded[8:], key)
+
+	if len(padded) == 16 {
+		block.Encrypt(padded, padded)
+		return padded, nil
+	}
+
+	n := len(padded)/8 - 1
+	buf := make([]byte, 16)
+	a := padded[:8]
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(buf, a)
+			copy(buf[8:], padded[i*8:(i+1)*8])
+			block.Encrypt(buf, buf)
+
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(a, binary.BigEndian.Uint64(buf[:8])^t)
+			copy(padded[i*8:], buf[8:])
+		}
+	}
+
+	return padded, nil
+}
+
+func TestTransit_KWP(t *testing.T) {
+	// Test vectors from RFC 5649 Section 6
+	kek, _ := hex.DecodeString("5840df6e29b02af1ab493b705bf16ea1ae8338f4dcc176a8")
+	tests := []struct {
+		key     string
+		wrapped string
+	}{
+		{
+			key:     "c37b7e6492584340bed12207808941155068f738",
+			wrapped: "138bdeaa9b8fa7fc61f97742e72248ee5ae6ae5360d1ae6a5f54f373fa543b6a",
+		},
+		{
+			key:     "466f7250617369",
+			wrapped: "afbeb0f07dfbf5419200f2ccb50bb24f",
+		},
+	}
+
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.key)
+		expected, _ := hex.DecodeString(test.wrapped)
+
+		wrapped, err := wrapKWP(kek, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(wrapped, expected) {
+			t.Fatalf("expected wrapped key %x, got %x", expected, wrapped)
+		}
+
+		unwrapped, err := unwrapKWP(kek, wrapped)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(unwrapped, key) {
+			t.Fatalf("expected key %x, got %x", key, unwrapped)
+		}
+
+		wrapped[len(wrapped)-1] ^= 1
+		if _, err := unwrapKWP(kek, wrapped); err == nil {
+			t.Fatal("expected tampered wrapped key to fail to unwrap")
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package transit
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_CMAC(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+	verify := func(input, cmac string) bool {
+		t.Helper()
+		resp := mustRequest(logical.UpdateOperation, "verify/foo", map[string]interface{}{
+			"input": input,
+			"cmac":  cmac,
+		})
+		return resp.Data["valid"].(bool)
+	}
+
+	input := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+
+	mustRequest(logical.UpdateOperation, "keys/foo", map[string]interface{}{
+		"type": "aes256-cmac",
+	})
+	resp := mustRequest(logical.ReadOperation, "keys/foo", nil)
+	if resp.Data["type"] != "aes256-cmac" || resp.Data["supports_encryption"] != false {
+		t.Fatalf("unexpected key: %#v", resp.Data)
+	}
+
+	resp = mustRequest(logical.UpdateOperation, "cmac/foo", map[string]interface{}{
+		"input": input,
+	})
+	cmac := resp.Data["cmac"].(string)
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(cmac, "vault:v1:"))
+	if err != nil || len(raw) != 16 {
+		t.Fatalf("unexpected CMAC %q: %v", cmac, err)
+	}
+	if !verify(input, cmac) {
+		t.Fatal("expected CMAC to verify")
+	}
+	if verify(base64.StdEncoding.EncodeToString([]byte("the quick brown cat")), cmac) {
+		t.Fatal("expected CMAC of other input not to verify")
+	}
+
+	// Truncated CMACs are a prefix of the full CMAC
+	resp = mustRequest(logical.UpdateOperation, "cmac/foo", map[string]interface{}{
+		"input":      input,
+		"mac_length": 8,
+	})
+	truncated := resp.Data["cmac"].(string)
+	if truncated != "vault:v1:"+base64.StdEncoding.EncodeToString(raw[:8]) {
+		t.Fatalf("unexpected truncated CMAC %q", truncated)
+	}
+	if !verify(input, truncated) {
+		t.Fatal("expected truncated CMAC to verify")
+	}
+	resp, err = request(logical.UpdateOperation, "cmac/foo", map[string]interface{}{
+		"input":      input,
+		"mac_length": 2,
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected too short a mac_length to fail, got err: %v resp: %#v", err, resp)
+	}
+
+	// Batch requests
+	resp = mustRequest(logical.UpdateOperation, "verify/foo", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"input": input, "cmac": cmac},
+			map[string]interface{}{"input": input, "cmac": "vault:v1:" + base64.StdEncoding.EncodeToString(make([]byte, 16))},
+		},
+	})
+	results := resp.Data["batch_results"].([]batchResponseCMACItem)
+	if !results[0].Valid || results[1].Valid {
+		t.Fatalf("unexpected batch results: %#v", results)
+	}
+
+	// Encryption keys cannot be used for CMACs, nor CMAC keys for encryption
+	mustRequest(logical.UpdateOperation, "keys/enc", nil)
+	resp, err = request(logical.UpdateOperation, "cmac/enc", map[string]interface{}{
+		"input": input,
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected CMAC with an encryption key to fail, got err: %v resp: %#v", err, resp)
+	}
+	resp, err = request(logical.UpdateOperation, "encrypt/foo", map[string]interface{}{
+		"plaintext": input,
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected encryption with a CMAC key to fail, got err: %v resp: %#v", err, resp)
+	}
+}
+
+func TestTransit_HMACKeyType(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	resp, err := request(logical.UpdateOperation, "keys/small", map[string]interface{}{
+		"type":     "hmac",
+		"key_size": 16,
+	})
+	if err == nil && (resp == nil || !resp.IsError()) {
+		t.Fatal("expected too small a key size to fail")
+	}
+
+	mustRequest(logical.UpdateOperation, "keys/foo", map[string]interface{}{
+		"type":       "hmac",
+		"key_size":   64,
+		"exportable": true,
+	})
+	resp = mustRequest(logical.ReadOperation, "keys/foo", nil)
+	if resp.Data["type"] != "hmac" || resp.Data["key_size"] != 64 || resp.Data["supports_signing"] != false {
+		t.Fatalf("unexpected key: %#v", resp.Data)
+	}
+
+	resp = mustRequest(logical.ReadOperation, "export/hmac-key/foo/1", nil)
+	key, err := base64.StdEncoding.DecodeString(resp.Data["keys"].(map[string]string)["1"])
+	if err != nil || len(key) != 64 {
+		t.Fatalf("unexpected exported key: %v", err)
+	}
+
+	resp = mustRequest(logical.UpdateOperation, "hmac/foo", map[string]interface{}{
+		"input": base64.StdEncoding.EncodeToString([]byte("message")),
+	})
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("message"))
+	if resp.Data["hmac"] != "vault:v1:"+base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Fatalf("expected HMAC to be computed with the key material, got %#v", resp.Data)
+	}
+
+	resp, err = request(logical.UpdateOperation, "encrypt/foo", map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString([]byte("message")),
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected encryption with an HMAC key to fail, got err: %v resp: %#v", err, resp)
+	}
+
+	// Rotation keeps the configured key size
+	mustRequest(logical.UpdateOperation, "keys/foo/rotate", nil)
+	resp = mustRequest(logical.ReadOperation, "export/hmac-key/foo/2", nil)
+	key, err = base64.StdEncoding.DecodeString(resp.Data["keys"].(map[string]string)["2"])
+	if err != nil || len(key) != 64 {
+		t.Fatalf("unexpected rotated key: %v", err)
+	}
+}
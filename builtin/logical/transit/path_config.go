@@ -3,6 +3,7 @@ package transit
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
@@ -47,6 +48,21 @@ the latest version of the key is allowed.`,
 				Type:        framework.TypeBool,
 				Description: `Enables taking a backup of the named key in plaintext format. Once set, this cannot be disabled.`,
 			},
+
+			"auto_rotate_period": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `If set, the key is rotated automatically once
+the latest version is this old. Must be at least
+an hour. Set to 0 to disable automatic rotation.`,
+			},
+
+			"min_decryption_age": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `If set, versions of the key can no longer be
+used for decryption once they have been superseded
+for this long: min_decryption_version is raised
+automatically. Set to 0 to disable.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -84,6 +100,8 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 	originalDeletionAllowed := p.DeletionAllowed
 	originalExportable := p.Exportable
 	originalAllowPlaintextBackup := p.AllowPlaintextBackup
+	originalAutoRotatePeriod := p.AutoRotatePeriod
+	originalMinDecryptionAge := p.MinDecryptionAge
 
 	defer func() {
 		if retErr != nil || (resp != nil && resp.IsError()) {
@@ -92,6 +110,8 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 			p.DeletionAllowed = originalDeletionAllowed
 			p.Exportable = originalExportable
 			p.AllowPlaintextBackup = originalAllowPlaintextBackup
+			p.AutoRotatePeriod = originalAutoRotatePeriod
+			p.MinDecryptionAge = originalMinDecryptionAge
 		}
 	}()
 
@@ -185,6 +205,30 @@ func (b *backend) pathConfigWrite(ctx context.Context, req *logical.Request, d *
 		}
 	}
 
+	autoRotatePeriodRaw, ok := d.GetOk("auto_rotate_period")
+	if ok {
+		autoRotatePeriod := time.Duration(autoRotatePeriodRaw.(int)) * time.Second
+		if autoRotatePeriod != 0 && autoRotatePeriod < minAutoRotatePeriod {
+			return logical.ErrorResponse(fmt.Sprintf("auto rotate period must be 0 or at least %s", minAutoRotatePeriod)), nil
+		}
+		if autoRotatePeriod != p.AutoRotatePeriod {
+			p.AutoRotatePeriod = autoRotatePeriod
+			persistNeeded = true
+		}
+	}
+
+	minDecryptionAgeRaw, ok := d.GetOk("min_decryption_age")
+	if ok {
+		minDecryptionAge := time.Duration(minDecryptionAgeRaw.(int)) * time.Second
+		if minDecryptionAge < 0 {
+			return logical.ErrorResponse("min decryption age cannot be negative"), nil
+		}
+		if minDecryptionAge != p.MinDecryptionAge {
+			p.MinDecryptionAge = minDecryptionAge
+			persistNeeded = true
+		}
+	}
+
 	if !persistNeeded {
 		return nil, nil
 	}
@@ -208,5 +252,10 @@ const pathConfigHelpSyn = `Configure a named encryption key`
 const pathConfigHelpDesc = `
 This path is used to configure the named key. Currently, this
 supports adjusting the minimum version of the key allowed to
-be used for decryption via the min_decryption_version parameter.
+be used for decryption via the min_decryption_version parameter,
+and automatic rotation of the key via auto_rotate_period.
+
+Automatically rotated keys are checked every few minutes, so a
+rotation may happen a little after the period has elapsed. Set
+min_decryption_age to also retire old versions automatically.
 `
@@ -50,6 +50,13 @@ Base64 encoded nonce value used during encryption. Must be provided if
 convergent encryption is enabled for this key and the key was generated with
 Vault 0.6.1. Not required for keys created in 0.6.2+.`,
 			},
+
+			"associated_data": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `
+Base64 encoded associated data given during encryption. Must be provided if
+the ciphertext was encrypted with associated data.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -82,9 +89,10 @@ func (b *backend) pathDecryptWrite(ctx context.Context, req *logical.Request, d
 
 		batchInputItems = make([]BatchRequestItem, 1)
 		batchInputItems[0] = BatchRequestItem{
-			Ciphertext: ciphertext,
-			Context:    d.Get("context").(string),
-			Nonce:      d.Get("nonce").(string),
+			Ciphertext:     ciphertext,
+			Context:        d.Get("context").(string),
+			Nonce:          d.Get("nonce").(string),
+			AssociatedData: d.Get("associated_data").(string),
 		}
 	}
 
@@ -118,6 +126,15 @@ func (b *backend) pathDecryptWrite(ctx context.Context, req *logical.Request, d
 				continue
 			}
 		}
+
+		// Decode the associated data
+		if len(item.AssociatedData) != 0 {
+			batchInputItems[i].DecodedAssociatedData, err = base64.StdEncoding.DecodeString(item.AssociatedData)
+			if err != nil {
+				batchResponseItems[i].Error = err.Error()
+				continue
+			}
+		}
 	}
 
 	// Get the policy
@@ -140,7 +157,7 @@ func (b *backend) pathDecryptWrite(ctx context.Context, req *logical.Request, d
 			continue
 		}
 
-		plaintext, err := p.Decrypt(item.DecodedContext, item.DecodedNonce, item.Ciphertext)
+		plaintext, err := p.DecryptWithAssociatedData(item.DecodedContext, item.DecodedNonce, item.Ciphertext, item.DecodedAssociatedData)
 		if err != nil {
 			switch err.(type) {
 			case errutil.UserError:
@@ -35,6 +35,12 @@ type BatchRequestItem struct {
 
 	// DecodedNonce is the base64 decoded version of Nonce
 	DecodedNonce []byte
+
+	// Associated data to authenticate along with the plaintext
+	AssociatedData string `json:"associated_data" structs:"associated_data" mapstructure:"associated_data"`
+
+	// DecodedAssociatedData is the base64 decoded version of AssociatedData
+	DecodedAssociatedData []byte
 }
 
 // EncryptBatchResponseItem represents a response item for batch processing
@@ -81,6 +87,14 @@ encryption key) this nonce value is **never reused**.
 `,
 			},
 
+			"associated_data": {
+				Type: framework.TypeString,
+				Description: `
+Base64 encoded associated data which is authenticated, but not encrypted,
+along with the plaintext. The same associated data must be given to decrypt
+the ciphertext. Only supported by AEAD key types.`,
+			},
+
 			"type": {
 				Type:    framework.TypeString,
 				Default: "aes256-gcm96",
@@ -192,6 +206,14 @@ func decodeBatchRequestItems(src interface{}, dst *[]BatchRequestItem) error {
 				errs.Errors = append(errs.Errors, fmt.Sprintf("'[%d].key_version' expected type 'int', got unconvertible type '%T'", i, item["key_version"]))
 			}
 		}
+
+		if v, has := item["associated_data"]; has {
+			if casted, ok := v.(string); ok {
+				(*dst)[i].AssociatedData = casted
+			} else {
+				errs.Errors = append(errs.Errors, fmt.Sprintf("'[%d].associated_data' expected type 'string', got unconvertible type '%T'", i, item["associated_data"]))
+			}
+		}
 	}
 
 	if len(errs.Errors) > 0 {
@@ -239,10 +261,11 @@ func (b *backend) pathEncryptWrite(ctx context.Context, req *logical.Request, d
 
 		batchInputItems = make([]BatchRequestItem, 1)
 		batchInputItems[0] = BatchRequestItem{
-			Plaintext:  valueRaw.(string),
-			Context:    d.Get("context").(string),
-			Nonce:      d.Get("nonce").(string),
-			KeyVersion: d.Get("key_version").(int),
+			Plaintext:      valueRaw.(string),
+			Context:        d.Get("context").(string),
+			Nonce:          d.Get("nonce").(string),
+			KeyVersion:     d.Get("key_version").(int),
+			AssociatedData: d.Get("associated_data").(string),
 		}
 	}
 
@@ -281,6 +304,15 @@ func (b *backend) pathEncryptWrite(ctx context.Context, req *logical.Request, d
 				continue
 			}
 		}
+
+		// Decode the associated data
+		if len(item.AssociatedData) != 0 {
+			batchInputItems[i].DecodedAssociatedData, err = base64.StdEncoding.DecodeString(item.AssociatedData)
+			if err != nil {
+				batchResponseItems[i].Error = err.Error()
+				continue
+			}
+		}
 	}
 
 	// Get the policy
@@ -341,7 +373,7 @@ func (b *backend) pathEncryptWrite(ctx context.Context, req *logical.Request, d
 			continue
 		}
 
-		ciphertext, err := p.Encrypt(item.KeyVersion, item.DecodedContext, item.DecodedNonce, item.Plaintext)
+		ciphertext, err := p.EncryptWithAssociatedData(item.KeyVersion, item.DecodedContext, item.DecodedNonce, item.Plaintext, item.DecodedAssociatedData)
 		if err != nil {
 			switch err.(type) {
 			case errutil.UserError:
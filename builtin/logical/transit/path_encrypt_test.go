@@ -3,6 +3,7 @@ package transit
 import (
 	"context"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/hashicorp/vault/sdk/logical"
@@ -654,6 +655,16 @@ func TestTransit_decodeBatchRequestItems(t *testing.T) {
 			src:  []interface{}{map[string]interface{}{"context": 666}},
 			dest: []BatchRequestItem{},
 		},
+		{
+			name: "src_associated_data-dest",
+			src:  []interface{}{map[string]interface{}{"associated_data": "dGVzdGRhdGE="}},
+			dest: []BatchRequestItem{},
+		},
+		{
+			name: "src_associated_data_invalid-dest",
+			src:  []interface{}{map[string]interface{}{"associated_data": 666}},
+			dest: []BatchRequestItem{},
+		},
 		{
 			name: "src_multi_order-dest",
 			src: []interface{}{
@@ -700,3 +711,95 @@ func TestTransit_decodeBatchRequestItems(t *testing.T) {
 		})
 	}
 }
+
+func TestTransit_AssociatedData(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	request := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Storage:   s,
+			Data:      data,
+		})
+	}
+	mustRequest := func(path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	plaintext := "dGhlIHF1aWNrIGJyb3duIGZveA==" // "the quick brown fox"
+	recordID := "cmVjb3JkLTE="                  // "record-1"
+
+	for _, keyType := range []string{"aes128-gcm96", "aes256-gcm96", "chacha20-poly1305"} {
+		mustRequest("keys/"+keyType, map[string]interface{}{
+			"type": keyType,
+		})
+		resp := mustRequest("encrypt/"+keyType, map[string]interface{}{
+			"plaintext":       plaintext,
+			"associated_data": recordID,
+		})
+		ciphertext := resp.Data["ciphertext"].(string)
+
+		resp = mustRequest("decrypt/"+keyType, map[string]interface{}{
+			"ciphertext":      ciphertext,
+			"associated_data": recordID,
+		})
+		if resp.Data["plaintext"] != plaintext {
+			t.Fatalf("%s: bad plaintext: %#v", keyType, resp.Data)
+		}
+
+		// The ciphertext is bound to the associated data
+		for _, ad := range []string{"", "cmVjb3JkLTI="} {
+			resp, err := request("decrypt/"+keyType, map[string]interface{}{
+				"ciphertext":      ciphertext,
+				"associated_data": ad,
+			})
+			if err == nil && (resp == nil || !resp.IsError()) {
+				t.Fatalf("%s: expected decryption with associated data %q to fail", keyType, ad)
+			}
+		}
+
+		// Rewrapping keeps the binding
+		mustRequest("keys/"+keyType+"/rotate", nil)
+		resp = mustRequest("rewrap/"+keyType, map[string]interface{}{
+			"ciphertext":      ciphertext,
+			"associated_data": recordID,
+		})
+		ciphertext = resp.Data["ciphertext"].(string)
+		if !strings.HasPrefix(ciphertext, "vault:v2:") {
+			t.Fatalf("%s: expected rewrapped ciphertext, got %q", keyType, ciphertext)
+		}
+		if resp, err := request("decrypt/"+keyType, map[string]interface{}{"ciphertext": ciphertext}); err == nil && (resp == nil || !resp.IsError()) {
+			t.Fatalf("%s: expected decryption of rewrapped ciphertext without associated data to fail", keyType)
+		}
+
+		// Batch items carry their own associated data
+		resp = mustRequest("decrypt/"+keyType, map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"ciphertext": ciphertext, "associated_data": recordID},
+				map[string]interface{}{"ciphertext": ciphertext, "associated_data": "not base64"},
+			},
+		})
+		results := resp.Data["batch_results"].([]DecryptBatchResponseItem)
+		if results[0].Plaintext != plaintext || results[1].Error == "" {
+			t.Fatalf("%s: unexpected batch results: %#v", keyType, results)
+		}
+	}
+
+	// Associated data cannot be used with RSA keys
+	mustRequest("keys/rsa", map[string]interface{}{
+		"type": "rsa-2048",
+	})
+	resp, err := request("encrypt/rsa", map[string]interface{}{
+		"plaintext":       plaintext,
+		"associated_data": recordID,
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected associated data with an RSA key to fail, got err: %v resp: %#v", err, resp)
+	}
+}
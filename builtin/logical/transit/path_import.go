@@ -0,0 +1,330 @@
+package transit
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// The wrapping key is stored as a policy outside of policy/ so it cannot
+	// be used or listed as a regular key
+	wrappingKeyName          = "wrapping-key"
+	wrappingKeyStoragePrefix = "import/"
+)
+
+var importHashFuncs = map[string]crypto.Hash{
+	"SHA1":   crypto.SHA1,
+	"SHA224": crypto.SHA224,
+	"SHA256": crypto.SHA256,
+	"SHA384": crypto.SHA384,
+	"SHA512": crypto.SHA512,
+}
+
+func (b *backend) pathWrappingKey() *framework.Path {
+	return &framework.Path{
+		Pattern: "wrapping_key",
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathWrappingKeyRead,
+		},
+
+		HelpSynopsis:    pathWrappingKeyHelpSyn,
+		HelpDescription: pathWrappingKeyHelpDesc,
+	}
+}
+
+func importFields() map[string]*framework.FieldSchema {
+	return map[string]*framework.FieldSchema{
+		"name": &framework.FieldSchema{
+			Type:        framework.TypeString,
+			Description: "Name of the key",
+		},
+
+		"ciphertext": &framework.FieldSchema{
+			Type: framework.TypeString,
+			Description: `The base64-encoded key material, wrapped as
+described in the help of this path.`,
+		},
+
+		"hash_function": &framework.FieldSchema{
+			Type:    framework.TypeString,
+			Default: "SHA256",
+			Description: `The hash function used for the RSA-OAEP
+encryption of the ephemeral key. One of "SHA1",
+"SHA224", "SHA256", "SHA384" or "SHA512". Defaults
+to "SHA256".`,
+		},
+	}
+}
+
+func (b *backend) pathImport() *framework.Path {
+	fields := importFields()
+	fields["type"] = &framework.FieldSchema{
+		Type:    framework.TypeString,
+		Default: "aes256-gcm96",
+		Description: `The type of the imported key. Any of the key
+types supported by "keys/<name>" may be imported.
+Defaults to "aes256-gcm96".`,
+	}
+	fields["allow_rotation"] = &framework.FieldSchema{
+		Type: framework.TypeBool,
+		Description: `If set, the imported key may be rotated within
+Vault, generating new versions which are not
+known outside of Vault.`,
+	}
+	fields["derived"] = &framework.FieldSchema{
+		Type: framework.TypeBool,
+		Description: `Enables key derivation mode. This
+allows for per-transaction unique
+keys for encryption operations.`,
+	}
+	fields["exportable"] = &framework.FieldSchema{
+		Type: framework.TypeBool,
+		Description: `Enables keys to be exportable.
+This allows for all the valid keys
+in the key ring to be exported.`,
+	}
+	fields["allow_plaintext_backup"] = &framework.FieldSchema{
+		Type: framework.TypeBool,
+		Description: `Enables taking a backup of the named
+key in plaintext format. Once set,
+this cannot be disabled.`,
+	}
+
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import",
+		Fields:  fields,
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportWrite,
+		},
+
+		HelpSynopsis:    pathImportHelpSyn,
+		HelpDescription: pathImportHelpDesc,
+	}
+}
+
+func (b *backend) pathImportVersion() *framework.Path {
+	return &framework.Path{
+		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import_version",
+		Fields:  importFields(),
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathImportVersionWrite,
+		},
+
+		HelpSynopsis:    pathImportVersionHelpSyn,
+		HelpDescription: pathImportHelpDesc,
+	}
+}
+
+// getWrappingKey returns the RSA key used to wrap imported key material,
+// generating it on first use
+func (b *backend) getWrappingKey(ctx context.Context, storage logical.Storage) (*rsa.PrivateKey, error) {
+	b.wrappingKeyLock.Lock()
+	defer b.wrappingKeyLock.Unlock()
+
+	p, err := keysutil.LoadPolicy(ctx, storage, wrappingKeyStoragePrefix+"policy/"+wrappingKeyName)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		p = keysutil.NewPolicy(keysutil.PolicyConfig{
+			Name:          wrappingKeyName,
+			Type:          keysutil.KeyType_RSA4096,
+			StoragePrefix: wrappingKeyStoragePrefix,
+		})
+		if err := p.Rotate(ctx, storage, b.GetRandomReader()); err != nil {
+			return nil, errwrap.Wrapf("error generating wrapping key: {{err}}", err)
+		}
+	}
+
+	entry, ok := p.Keys[strconv.Itoa(p.LatestVersion)]
+	if !ok || entry.RSAKey == nil {
+		return nil, errors.New("wrapping key not found")
+	}
+	return entry.RSAKey, nil
+}
+
+func (b *backend) pathWrappingKeyRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	key, err := b.getWrappingKey(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	derBytes, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, errwrap.Wrapf("error marshaling wrapping key: {{err}}", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"public_key": string(pem.EncodeToMemory(&pem.Block{
+				Type:  "PUBLIC KEY",
+				Bytes: derBytes,
+			})),
+		},
+	}, nil
+}
+
+// unwrapImportedKey decrypts key material wrapped for import: the ciphertext
+// is an ephemeral AES-256 key encrypted to the wrapping key with RSA-OAEP,
+// followed by the key material wrapped with the ephemeral key using AES key
+// wrap with padding (RFC 5649).
+func (b *backend) unwrapImportedKey(ctx context.Context, req *logical.Request, d *framework.FieldData) ([]byte, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(d.Get("ciphertext").(string))
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("error decoding ciphertext: %s", err)}
+	}
+
+	hashFuncName := strings.ToUpper(d.Get("hash_function").(string))
+	hashFunc, ok := importHashFuncs[hashFuncName]
+	if !ok {
+		return nil, errutil.UserError{Err: fmt.Sprintf("unsupported hash function %q", hashFuncName)}
+	}
+
+	wrappingKey, err := b.getWrappingKey(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	ephemeralLen := wrappingKey.Size()
+	if len(ciphertext) <= ephemeralLen {
+		return nil, errutil.UserError{Err: "ciphertext is too short"}
+	}
+
+	ephemeralKey, err := rsa.DecryptOAEP(hashFunc.New(), b.GetRandomReader(), wrappingKey, ciphertext[:ephemeralLen], nil)
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("error decrypting ephemeral key: %s", err)}
+	}
+	if len(ephemeralKey) != 32 {
+		return nil, errutil.UserError{Err: "ephemeral key must be an AES-256 key"}
+	}
+
+	key, err := unwrapKWP(ephemeralKey, ciphertext[ephemeralLen:])
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("error unwrapping key material: %s", err)}
+	}
+
+	return key, nil
+}
+
+func (b *backend) pathImportWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	keyType := d.Get("type").(string)
+
+	polReq := keysutil.PolicyRequest{
+		Storage:                  req.Storage,
+		Name:                     name,
+		Derived:                  d.Get("derived").(bool),
+		Exportable:               d.Get("exportable").(bool),
+		AllowPlaintextBackup:     d.Get("allow_plaintext_backup").(bool),
+		AllowImportedKeyRotation: d.Get("allow_rotation").(bool),
+	}
+	var ok bool
+	polReq.KeyType, ok = parseKeyType(keyType)
+	if !ok {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
+	}
+
+	key, err := b.unwrapImportedKey(ctx, req, d)
+	if err != nil {
+		return errorResponseOrErr(err)
+	}
+
+	if err := b.lm.ImportPolicy(ctx, polReq, key, b.GetRandomReader()); err != nil {
+		return errorResponseOrErr(err)
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathImportVersionWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse("key not found"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(true)
+	}
+	defer p.Unlock()
+
+	if !p.Imported {
+		return logical.ErrorResponse("versions may only be imported into imported keys"), logical.ErrInvalidRequest
+	}
+
+	key, err := b.unwrapImportedKey(ctx, req, d)
+	if err != nil {
+		return errorResponseOrErr(err)
+	}
+
+	if err := p.Import(ctx, req.Storage, key, b.GetRandomReader()); err != nil {
+		return errorResponseOrErr(err)
+	}
+
+	return nil, nil
+}
+
+func errorResponseOrErr(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	default:
+		return nil, err
+	}
+}
+
+const pathWrappingKeyHelpSyn = `Returns the public key used to wrap imported keys`
+
+const pathWrappingKeyHelpDesc = `
+This path returns the public half of the RSA-4096 wrapping key, in PEM
+format, which is used to wrap key material sent to the import endpoints.
+The wrapping key is generated the first time it is read.
+`
+
+const pathImportHelpSyn = `Import an externally generated key`
+
+const pathImportVersionHelpSyn = `Import a new version of an imported key`
+
+const pathImportHelpDesc = `
+This path imports externally generated key material, either as a new named
+key or, using "import_version", as a new version of an existing imported key.
+
+AES, ChaCha20 and HMAC key material is given as the raw key bytes; the private
+keys of all other key types are given as PKCS #8 DER. HMAC keys must be between
+32 and 512 bytes long.
+
+The key material must be wrapped before sending it to Vault:
+
+1. Generate an ephemeral 256-bit AES key.
+2. Wrap the key material with the ephemeral key using AES key wrap with
+   padding (RFC 5649).
+3. Encrypt the ephemeral key with RSA-OAEP, using the public key from
+   "wrapping_key" and the hash function given in "hash_function".
+4. Send the base64 encoding of the encrypted ephemeral key followed by the
+   wrapped key material as "ciphertext".
+
+Imported keys cannot be rotated within Vault unless "allow_rotation" is set.
+`
@@ -0,0 +1,197 @@
+package transit
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ed25519"
+)
+
+func TestTransit_Import(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	resp := mustRequest(logical.ReadOperation, "wrapping_key", nil)
+	block, _ := pem.Decode([]byte(resp.Data["public_key"].(string)))
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrappingKey := parsed.(*rsa.PublicKey)
+	if wrappingKey.N.BitLen() != 4096 {
+		t.Fatalf("expected a 4096 bit wrapping key, got %d bits", wrappingKey.N.BitLen())
+	}
+
+	wrap := func(key []byte) string {
+		t.Helper()
+		ephemeralKey := make([]byte, 32)
+		if _, err := rand.Read(ephemeralKey); err != nil {
+			t.Fatal(err)
+		}
+		encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, wrappingKey, ephemeralKey, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wrappedKey, err := wrapKWP(ephemeralKey, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(append(encryptedKey, wrappedKey...))
+	}
+
+	// An AES key is imported as raw bytes
+	aesKey := make([]byte, 32)
+	if _, err := rand.Read(aesKey); err != nil {
+		t.Fatal(err)
+	}
+	mustRequest(logical.UpdateOperation, "keys/aes/import", map[string]interface{}{
+		"ciphertext": wrap(aesKey),
+	})
+	resp = mustRequest(logical.UpdateOperation, "encrypt/aes", map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString([]byte("the quick brown fox")),
+	})
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(resp.Data["ciphertext"].(string), "vault:v1:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aesBlock, err := aes.NewCipher(aesKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(aesBlock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext, err := gcm.Open(nil, ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():], nil)
+	if err != nil || string(plaintext) != "the quick brown fox" {
+		t.Fatalf("expected ciphertext to be encrypted with the imported key, err: %v", err)
+	}
+
+	// As are HMAC keys
+	hmacKey := make([]byte, 48)
+	if _, err := rand.Read(hmacKey); err != nil {
+		t.Fatal(err)
+	}
+	mustRequest(logical.UpdateOperation, "keys/hmac/import", map[string]interface{}{
+		"ciphertext": wrap(hmacKey),
+		"type":       "hmac",
+	})
+	resp = mustRequest(logical.UpdateOperation, "hmac/hmac", map[string]interface{}{
+		"input": base64.StdEncoding.EncodeToString([]byte("message")),
+	})
+	mac := hmac.New(sha256.New, hmacKey)
+	mac.Write([]byte("message"))
+	if resp.Data["hmac"] != "vault:v1:"+base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+		t.Fatalf("expected HMAC to be computed with the imported key, got %#v", resp.Data)
+	}
+
+	// Other key types are imported as PKCS #8
+	edPub, edPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edDER, err := x509.MarshalPKCS8PrivateKey(edPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err = request(logical.UpdateOperation, "keys/ed/import", map[string]interface{}{
+		"ciphertext": wrap(edDER),
+		"type":       "ecdsa-p256",
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected importing a key of the wrong type to fail, got err: %v resp: %#v", err, resp)
+	}
+	mustRequest(logical.UpdateOperation, "keys/ed/import", map[string]interface{}{
+		"ciphertext": wrap(edDER),
+		"type":       "ed25519",
+	})
+	resp = mustRequest(logical.UpdateOperation, "sign/ed", map[string]interface{}{
+		"input": base64.StdEncoding.EncodeToString([]byte("message")),
+	})
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(resp.Data["signature"].(string), "vault:v1:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(edPub, []byte("message"), signature) {
+		t.Fatal("expected signature to be made with the imported key")
+	}
+
+	// Importing over an existing key is not allowed
+	resp, err = request(logical.UpdateOperation, "keys/ed/import", map[string]interface{}{
+		"ciphertext": wrap(edDER),
+		"type":       "ed25519",
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected importing over an existing key to fail, got err: %v resp: %#v", err, resp)
+	}
+
+	newRSAKey := func() []byte {
+		t.Helper()
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der
+	}
+	mustRequest(logical.UpdateOperation, "keys/rsa/import", map[string]interface{}{
+		"ciphertext":    wrap(newRSAKey()),
+		"type":          "rsa-2048",
+		"hash_function": "SHA256",
+	})
+	resp = mustRequest(logical.ReadOperation, "keys/rsa", nil)
+	if resp.Data["imported_key"] != true || resp.Data["allow_imported_key_rotation"] != false {
+		t.Fatalf("unexpected key: %#v", resp.Data)
+	}
+
+	resp, err = request(logical.UpdateOperation, "keys/rsa/rotate", nil)
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected rotating an imported key to fail, got err: %v resp: %#v", err, resp)
+	}
+	mustRequest(logical.UpdateOperation, "keys/rsa/import_version", map[string]interface{}{
+		"ciphertext": wrap(newRSAKey()),
+	})
+	resp = mustRequest(logical.ReadOperation, "keys/rsa", nil)
+	if resp.Data["latest_version"] != 2 {
+		t.Fatalf("expected imported version, got %#v", resp.Data)
+	}
+
+	// Versions can only be imported into imported keys
+	mustRequest(logical.UpdateOperation, "keys/generated", nil)
+	resp, err = request(logical.UpdateOperation, "keys/generated/import_version", map[string]interface{}{
+		"ciphertext": wrap(aesKey),
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected importing a version into a generated key to fail, got err: %v resp: %#v", err, resp)
+	}
+}
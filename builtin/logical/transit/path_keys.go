@@ -47,10 +47,25 @@ func (b *backend) pathKeys() *framework.Path {
 				Description: `
 The type of key to create. Currently, "aes128-gcm96" (symmetric), "aes256-gcm96" (symmetric), "ecdsa-p256"
 (asymmetric), "ecdsa-p384" (asymmetric), "ecdsa-p521" (asymmetric), "ed25519" (asymmetric), "rsa-2048" (asymmetric), "rsa-3072"
-(asymmetric), "rsa-4096" (asymmetric) are supported.  Defaults to "aes256-gcm96".
+(asymmetric), "rsa-4096" (asymmetric), "hmac" (HMAC), "aes128-cmac" (CMAC) and "aes256-cmac" (CMAC) are supported.
+Defaults to "aes256-gcm96".
 `,
 			},
 
+			"auto_rotate_period": &framework.FieldSchema{
+				Type: framework.TypeDurationSecond,
+				Description: `If set, the key is rotated automatically once
+the latest version is this old. Must be at least
+an hour. Defaults to 0, disabling automatic
+rotation.`,
+			},
+
+			"key_size": &framework.FieldSchema{
+				Type: framework.TypeInt,
+				Description: `The key size in bytes for keys of type "hmac".
+Must be between 32 and 512. Defaults to 32.`,
+			},
+
 			"derived": &framework.FieldSchema{
 				Type: framework.TypeBool,
 				Description: `Enables key derivation mode. This
@@ -137,29 +152,15 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 		Convergent:           convergent,
 		Exportable:           exportable,
 		AllowPlaintextBackup: allowPlaintextBackup,
+		KeySize:              d.Get("key_size").(int),
+		AutoRotatePeriod:     time.Duration(d.Get("auto_rotate_period").(int)) * time.Second,
 	}
-	switch keyType {
-	case "aes128-gcm96":
-		polReq.KeyType = keysutil.KeyType_AES128_GCM96
-	case "aes256-gcm96":
-		polReq.KeyType = keysutil.KeyType_AES256_GCM96
-	case "chacha20-poly1305":
-		polReq.KeyType = keysutil.KeyType_ChaCha20_Poly1305
-	case "ecdsa-p256":
-		polReq.KeyType = keysutil.KeyType_ECDSA_P256
-	case "ecdsa-p384":
-		polReq.KeyType = keysutil.KeyType_ECDSA_P384
-	case "ecdsa-p521":
-		polReq.KeyType = keysutil.KeyType_ECDSA_P521
-	case "ed25519":
-		polReq.KeyType = keysutil.KeyType_ED25519
-	case "rsa-2048":
-		polReq.KeyType = keysutil.KeyType_RSA2048
-	case "rsa-3072":
-		polReq.KeyType = keysutil.KeyType_RSA3072
-	case "rsa-4096":
-		polReq.KeyType = keysutil.KeyType_RSA4096
-	default:
+	if polReq.AutoRotatePeriod != 0 && polReq.AutoRotatePeriod < minAutoRotatePeriod {
+		return logical.ErrorResponse(fmt.Sprintf("auto rotate period must be 0 or at least %s", minAutoRotatePeriod)), logical.ErrInvalidRequest
+	}
+	var ok bool
+	polReq.KeyType, ok = parseKeyType(keyType)
+	if !ok {
 		return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
 	}
 
@@ -182,6 +183,39 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 	return nil, nil
 }
 
+// parseKeyType returns the key type with the given name
+func parseKeyType(keyType string) (keysutil.KeyType, bool) {
+	switch keyType {
+	case "aes128-gcm96":
+		return keysutil.KeyType_AES128_GCM96, true
+	case "aes256-gcm96":
+		return keysutil.KeyType_AES256_GCM96, true
+	case "chacha20-poly1305":
+		return keysutil.KeyType_ChaCha20_Poly1305, true
+	case "ecdsa-p256":
+		return keysutil.KeyType_ECDSA_P256, true
+	case "ecdsa-p384":
+		return keysutil.KeyType_ECDSA_P384, true
+	case "ecdsa-p521":
+		return keysutil.KeyType_ECDSA_P521, true
+	case "ed25519":
+		return keysutil.KeyType_ED25519, true
+	case "rsa-2048":
+		return keysutil.KeyType_RSA2048, true
+	case "rsa-3072":
+		return keysutil.KeyType_RSA3072, true
+	case "rsa-4096":
+		return keysutil.KeyType_RSA4096, true
+	case "hmac":
+		return keysutil.KeyType_HMAC, true
+	case "aes128-cmac":
+		return keysutil.KeyType_AES128_CMAC, true
+	case "aes256-cmac":
+		return keysutil.KeyType_AES256_CMAC, true
+	}
+	return 0, false
+}
+
 // Built-in helper type for returning asymmetric keys
 type asymKey struct {
 	Name         string    `json:"name" structs:"name" mapstructure:"name"`
@@ -224,9 +258,20 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 			"supports_decryption":    p.Type.DecryptionSupported(),
 			"supports_signing":       p.Type.SigningSupported(),
 			"supports_derivation":    p.Type.DerivationSupported(),
+			"imported_key":           p.Imported,
+			"auto_rotate_period":     int64(p.AutoRotatePeriod.Seconds()),
+			"min_decryption_age":     int64(p.MinDecryptionAge.Seconds()),
 		},
 	}
 
+	if p.Type == keysutil.KeyType_HMAC {
+		resp.Data["key_size"] = p.KeySize
+	}
+
+	if p.Imported {
+		resp.Data["allow_imported_key_rotation"] = p.AllowImportedKeyRotation
+	}
+
 	if p.BackupInfo != nil {
 		resp.Data["backup_info"] = map[string]interface{}{
 			"time":    p.BackupInfo.Time,
@@ -264,7 +309,7 @@ func (b *backend) pathPolicyRead(ctx context.Context, req *logical.Request, d *f
 	}
 
 	switch p.Type {
-	case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305:
+	case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305, keysutil.KeyType_HMAC, keysutil.KeyType_AES128_CMAC, keysutil.KeyType_AES256_CMAC:
 		retKeys := map[string]int64{}
 		for k, v := range p.Keys {
 			retKeys[k] = v.DeprecatedCreationTime
@@ -37,6 +37,13 @@ func (b *backend) pathRewrap() *framework.Path {
 				Description: "Nonce for when convergent encryption is used",
 			},
 
+			"associated_data": &framework.FieldSchema{
+				Type: framework.TypeString,
+				Description: `Base64 encoded associated data given during
+encryption, which is also bound to the rewrapped
+ciphertext.`,
+			},
+
 			"key_version": &framework.FieldSchema{
 				Type: framework.TypeInt,
 				Description: `The version of the key to use for encryption.
@@ -75,10 +82,11 @@ func (b *backend) pathRewrapWrite(ctx context.Context, req *logical.Request, d *
 
 		batchInputItems = make([]BatchRequestItem, 1)
 		batchInputItems[0] = BatchRequestItem{
-			Ciphertext: ciphertext,
-			Context:    d.Get("context").(string),
-			Nonce:      d.Get("nonce").(string),
-			KeyVersion: d.Get("key_version").(int),
+			Ciphertext:     ciphertext,
+			Context:        d.Get("context").(string),
+			Nonce:          d.Get("nonce").(string),
+			KeyVersion:     d.Get("key_version").(int),
+			AssociatedData: d.Get("associated_data").(string),
 		}
 	}
 
@@ -112,6 +120,15 @@ func (b *backend) pathRewrapWrite(ctx context.Context, req *logical.Request, d *
 				continue
 			}
 		}
+
+		// Decode the associated data
+		if len(item.AssociatedData) != 0 {
+			batchInputItems[i].DecodedAssociatedData, err = base64.StdEncoding.DecodeString(item.AssociatedData)
+			if err != nil {
+				batchResponseItems[i].Error = err.Error()
+				continue
+			}
+		}
 	}
 
 	// Get the policy
@@ -134,7 +151,7 @@ func (b *backend) pathRewrapWrite(ctx context.Context, req *logical.Request, d *
 			continue
 		}
 
-		plaintext, err := p.Decrypt(item.DecodedContext, item.DecodedNonce, item.Ciphertext)
+		plaintext, err := p.DecryptWithAssociatedData(item.DecodedContext, item.DecodedNonce, item.Ciphertext, item.DecodedAssociatedData)
 		if err != nil {
 			switch err.(type) {
 			case errutil.UserError:
@@ -146,7 +163,7 @@ func (b *backend) pathRewrapWrite(ctx context.Context, req *logical.Request, d *
 			}
 		}
 
-		ciphertext, err := p.Encrypt(item.KeyVersion, item.DecodedContext, item.DecodedNonce, plaintext)
+		ciphertext, err := p.EncryptWithAssociatedData(item.KeyVersion, item.DecodedContext, item.DecodedNonce, plaintext, item.DecodedAssociatedData)
 		if err != nil {
 			switch err.(type) {
 			case errutil.UserError:
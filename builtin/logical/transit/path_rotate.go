@@ -2,12 +2,25 @@ package transit
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
+const (
+	// autoRotateCheckInterval is how often keys are checked for automatic
+	// rotation
+	autoRotateCheckInterval = 10 * time.Minute
+
+	// minAutoRotatePeriod is the shortest allowed automatic rotation period
+	minAutoRotatePeriod = time.Hour
+)
+
 func (b *backend) pathRotate() *framework.Path {
 	return &framework.Path{
 		Pattern: "keys/" + framework.GenericNameRegex("name") + "/rotate",
@@ -45,6 +58,11 @@ func (b *backend) pathRotateWrite(ctx context.Context, req *logical.Request, d *
 		p.Lock(true)
 	}
 
+	if p.Imported && !p.AllowImportedKeyRotation {
+		p.Unlock()
+		return logical.ErrorResponse("imported key does not allow rotation; use import_version to add new versions"), logical.ErrInvalidRequest
+	}
+
 	// Rotate the policy
 	err = p.Rotate(ctx, req.Storage, b.GetRandomReader())
 
@@ -52,6 +70,68 @@ func (b *backend) pathRotateWrite(ctx context.Context, req *logical.Request, d *
 	return nil, err
 }
 
+// autoRotateKeys rotates the keys which are due for automatic rotation and
+// retires versions older than their minimum decryption age. Keys are checked
+// at most once per autoRotateCheckInterval.
+func (b *backend) autoRotateKeys(ctx context.Context, req *logical.Request) error {
+	b.autoRotateLock.Lock()
+	defer b.autoRotateLock.Unlock()
+
+	now := time.Now()
+	if now.Before(b.autoRotateCheckAfter) {
+		return nil
+	}
+	b.autoRotateCheckAfter = now.Add(autoRotateCheckInterval)
+
+	names, err := req.Storage.List(ctx, "policy/")
+	if err != nil {
+		return err
+	}
+
+	var errs *multierror.Error
+	for _, name := range names {
+		if err := b.autoRotateKey(ctx, req.Storage, name, now); err != nil {
+			errs = multierror.Append(errs, errwrap.Wrapf(fmt.Sprintf("error automatically rotating key %q: {{err}}", name), err))
+		}
+	}
+	return errs.ErrorOrNil()
+}
+
+func (b *backend) autoRotateKey(ctx context.Context, storage logical.Storage, name string, now time.Time) error {
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		return nil
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(true)
+	}
+	defer p.Unlock()
+
+	if p.RotationDue(now) && (!p.Imported || p.AllowImportedKeyRotation) {
+		b.Logger().Info("automatically rotating key", "name", name)
+		if err := p.Rotate(ctx, storage, b.GetRandomReader()); err != nil {
+			return err
+		}
+	}
+
+	priorMinDecryptionVersion := p.MinDecryptionVersion
+	if p.RatchetMinDecryptionVersion(now) {
+		b.Logger().Info("raising min decryption version of key", "name", name, "min_decryption_version", p.MinDecryptionVersion)
+		if err := p.Persist(ctx, storage); err != nil {
+			p.MinDecryptionVersion = priorMinDecryptionVersion
+			return err
+		}
+	}
+
+	return nil
+}
+
 const pathRotateHelpSyn = `Rotate named encryption key`
 
 const pathRotateHelpDesc = `
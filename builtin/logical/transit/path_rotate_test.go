@@ -0,0 +1,137 @@
+package transit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestTransit_AutoRotate(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+	ctx := context.Background()
+
+	request := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(ctx, &logical.Request{
+			Operation: op,
+			Path:      path,
+			Storage:   storage,
+			Data:      data,
+		})
+	}
+	mustRequest := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := request(op, path, data)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %s err: %v resp: %#v", path, err, resp)
+		}
+		return resp
+	}
+
+	// ageKey moves the creation time of every version of the key back
+	ageKey := func(name string, age time.Duration) {
+		t.Helper()
+		p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+			Storage: storage,
+			Name:    name,
+		}, b.GetRandomReader())
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Lock(true)
+		defer p.Unlock()
+		for ver, entry := range p.Keys {
+			entry.CreationTime = entry.CreationTime.Add(-age)
+			p.Keys[ver] = entry
+		}
+		if err := p.Persist(ctx, storage); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runPeriodic := func() {
+		t.Helper()
+		b.autoRotateCheckAfter = time.Time{}
+		if err := b.periodicFunc(ctx, &logical.Request{Storage: storage}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectVersions := func(name string, latest, minDecryption int) {
+		t.Helper()
+		resp := mustRequest(logical.ReadOperation, "keys/"+name, nil)
+		if resp.Data["latest_version"] != latest || resp.Data["min_decryption_version"] != minDecryption {
+			t.Fatalf("expected latest version %d and min decryption version %d, got %#v", latest, minDecryption, resp.Data)
+		}
+	}
+
+	resp, err := request(logical.UpdateOperation, "keys/short", map[string]interface{}{
+		"auto_rotate_period": "10m",
+	})
+	if err == nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected too short a rotation period to fail, got err: %v resp: %#v", err, resp)
+	}
+
+	mustRequest(logical.UpdateOperation, "keys/auto", map[string]interface{}{
+		"auto_rotate_period": "24h",
+	})
+	mustRequest(logical.UpdateOperation, "keys/manual", nil)
+	resp = mustRequest(logical.ReadOperation, "keys/auto", nil)
+	if resp.Data["auto_rotate_period"] != int64(24*60*60) {
+		t.Fatalf("unexpected auto rotate period: %#v", resp.Data)
+	}
+
+	runPeriodic()
+	expectVersions("auto", 1, 1)
+
+	ageKey("auto", 25*time.Hour)
+	ageKey("manual", 25*time.Hour)
+	runPeriodic()
+	expectVersions("auto", 2, 1)
+	expectVersions("manual", 1, 1)
+
+	// Checks are throttled
+	ageKey("auto", 25*time.Hour)
+	if err := b.periodicFunc(ctx, &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	expectVersions("auto", 2, 1)
+
+	// Version 1 was superseded 25 hours ago
+	mustRequest(logical.UpdateOperation, "keys/auto/config", map[string]interface{}{
+		"min_decryption_age": "48h",
+	})
+	runPeriodic()
+	expectVersions("auto", 3, 1)
+
+	ageKey("auto", 24*time.Hour)
+	runPeriodic()
+	expectVersions("auto", 4, 2)
+
+	// Disabling automatic rotation
+	mustRequest(logical.UpdateOperation, "keys/auto/config", map[string]interface{}{
+		"auto_rotate_period": 0,
+		"min_decryption_age": 0,
+	})
+	ageKey("auto", 1000*time.Hour)
+	runPeriodic()
+	expectVersions("auto", 4, 2)
+
+	// Imported keys are only rotated if allowed
+	p, _, err := b.lm.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: storage,
+		Name:    "manual",
+	}, b.GetRandomReader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Lock(true)
+	p.Imported = true
+	p.AutoRotatePeriod = time.Hour
+	if err := p.Persist(ctx, storage); err != nil {
+		t.Fatal(err)
+	}
+	p.Unlock()
+	runPeriodic()
+	expectVersions("manual", 1, 1)
+}
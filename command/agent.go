@@ -30,14 +30,20 @@ import (
 	"github.com/hashicorp/vault/command/agent/auth/kerberos"
 	"github.com/hashicorp/vault/command/agent/auth/kubernetes"
 	"github.com/hashicorp/vault/command/agent/cache"
+	"github.com/hashicorp/vault/command/agent/cache/cacheboltdb"
 	agentConfig "github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/command/agent/exec"
 	"github.com/hashicorp/vault/command/agent/sink"
 	"github.com/hashicorp/vault/command/agent/sink/file"
 	"github.com/hashicorp/vault/command/agent/sink/inmem"
+	"github.com/hashicorp/vault/command/agent/sink/socket"
 	"github.com/hashicorp/vault/command/agent/template"
+	"github.com/hashicorp/vault/helper/metricsutil"
+	"github.com/hashicorp/vault/internalshared/configutil"
 	"github.com/hashicorp/vault/internalshared/gatedwriter"
 	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/hashicorp/vault/sdk/helper/useragent"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/hashicorp/vault/sdk/version"
 	"github.com/kr/pretty"
@@ -321,6 +327,19 @@ func (c *AgentCommand) Run(args []string) int {
 		return 0
 	}
 
+	inmemMetrics, _, prometheusEnabled, err := configutil.SetupTelemetry(&configutil.SetupTelemetryOpts{
+		Config:      config.Telemetry,
+		Ui:          c.UI,
+		ServiceName: "vault",
+		DisplayName: "Vault",
+		UserAgent:   useragent.String(),
+	})
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error initializing telemetry: %s", err))
+		return 1
+	}
+	metricsHelper := metricsutil.NewMetricsHelper(inmemMetrics, prometheusEnabled)
+
 	// Ignore any setting of agent's address. This client is used by the agent
 	// to reach out to Vault. This should never loop back to agent.
 	c.flagAgentAddress = ""
@@ -337,7 +356,8 @@ func (c *AgentCommand) Run(args []string) int {
 	// down accordingly.
 	ctx, cancelFunc := context.WithCancel(context.Background())
 
-	var method auth.AuthMethod
+	var methods []*auth.FailoverMethod
+	var authStatus *auth.StatusTracker
 	var sinks []*sink.SinkConfig
 	var namespace string
 	if config.AutoAuth != nil {
@@ -361,6 +381,24 @@ func (c *AgentCommand) Run(args []string) int {
 				}
 				config.Sink = s
 				sinks = append(sinks, config)
+			case "unix":
+				config := &sink.SinkConfig{
+					Logger:    c.logger.Named("sink.unix"),
+					Config:    sc.Config,
+					Client:    client,
+					WrapTTL:   sc.WrapTTL,
+					DHType:    sc.DHType,
+					DeriveKey: sc.DeriveKey,
+					DHPath:    sc.DHPath,
+					AAD:       sc.AAD,
+				}
+				s, err := socket.NewSocketSink(ctx, config)
+				if err != nil {
+					c.UI.Error(errwrap.Wrapf("Error creating unix socket sink: {{err}}", err).Error())
+					return 1
+				}
+				config.Sink = s
+				sinks = append(sinks, config)
 			default:
 				c.UI.Error(fmt.Sprintf("Unknown sink type %q", sc.Type))
 				return 1
@@ -368,48 +406,75 @@ func (c *AgentCommand) Run(args []string) int {
 		}
 
 		// Check if a default namespace has been set
-		mountPath := config.AutoAuth.Method.MountPath
 		if config.AutoAuth.Method.Namespace != "" {
 			namespace = config.AutoAuth.Method.Namespace
-			mountPath = path.Join(namespace, mountPath)
 		}
 
-		authConfig := &auth.AuthConfig{
-			Logger:    c.logger.Named(fmt.Sprintf("auth.%s", config.AutoAuth.Method.Type)),
-			MountPath: mountPath,
-			Config:    config.AutoAuth.Method.Config,
-		}
-		switch config.AutoAuth.Method.Type {
-		case "alicloud":
-			method, err = alicloud.NewAliCloudAuthMethod(authConfig)
-		case "aws":
-			method, err = aws.NewAWSAuthMethod(authConfig)
-		case "azure":
-			method, err = azure.NewAzureAuthMethod(authConfig)
-		case "cert":
-			method, err = cert.NewCertAuthMethod(authConfig)
-		case "cf":
-			method, err = cf.NewCFAuthMethod(authConfig)
-		case "gcp":
-			method, err = gcp.NewGCPAuthMethod(authConfig)
-		case "jwt":
-			method, err = jwt.NewJWTAuthMethod(authConfig)
-		case "kerberos":
-			method, err = kerberos.NewKerberosAuthMethod(authConfig)
-		case "kubernetes":
-			method, err = kubernetes.NewKubernetesAuthMethod(authConfig)
-		case "approle":
-			method, err = approle.NewApproleAuthMethod(authConfig)
-		case "pcf": // Deprecated.
-			method, err = cf.NewCFAuthMethod(authConfig)
-		default:
-			c.UI.Error(fmt.Sprintf("Unknown auth method %q", config.AutoAuth.Method.Type))
-			return 1
-		}
-		if err != nil {
-			c.UI.Error(errwrap.Wrapf(fmt.Sprintf("Error creating %s auth method: {{err}}", config.AutoAuth.Method.Type), err).Error())
-			return 1
+		names := make([]string, 0, len(config.AutoAuth.Methods))
+		seen := make(map[string]bool, len(config.AutoAuth.Methods))
+		for _, mc := range config.AutoAuth.Methods {
+			mountPath := mc.MountPath
+			if mc.Namespace != "" {
+				mountPath = path.Join(mc.Namespace, mountPath)
+			}
+
+			authConfig := &auth.AuthConfig{
+				Logger:    c.logger.Named(fmt.Sprintf("auth.%s", mc.Type)),
+				MountPath: mountPath,
+				Config:    mc.Config,
+			}
+
+			var method auth.AuthMethod
+			switch mc.Type {
+			case "alicloud":
+				method, err = alicloud.NewAliCloudAuthMethod(authConfig)
+			case "aws":
+				method, err = aws.NewAWSAuthMethod(authConfig)
+			case "azure":
+				method, err = azure.NewAzureAuthMethod(authConfig)
+			case "cert":
+				method, err = cert.NewCertAuthMethod(authConfig)
+			case "cf":
+				method, err = cf.NewCFAuthMethod(authConfig)
+			case "gcp":
+				method, err = gcp.NewGCPAuthMethod(authConfig)
+			case "jwt":
+				method, err = jwt.NewJWTAuthMethod(authConfig)
+			case "kerberos":
+				method, err = kerberos.NewKerberosAuthMethod(authConfig)
+			case "kubernetes":
+				method, err = kubernetes.NewKubernetesAuthMethod(authConfig)
+			case "approle":
+				method, err = approle.NewApproleAuthMethod(authConfig)
+			case "pcf": // Deprecated.
+				method, err = cf.NewCFAuthMethod(authConfig)
+			default:
+				c.UI.Error(fmt.Sprintf("Unknown auth method %q", mc.Type))
+				return 1
+			}
+			if err != nil {
+				c.UI.Error(errwrap.Wrapf(fmt.Sprintf("Error creating %s auth method: {{err}}", mc.Type), err).Error())
+				return 1
+			}
+
+			// Methods are named after their type, or their mount path if
+			// several methods of the same type are configured
+			name := mc.Type
+			if seen[name] {
+				name = mountPath
+			}
+			seen[name] = true
+			names = append(names, name)
+
+			methods = append(methods, &auth.FailoverMethod{
+				Name:       name,
+				Method:     method,
+				MaxRetries: mc.MaxRetries,
+				MinBackoff: mc.MinBackoff,
+				MaxBackoff: mc.MaxBackoff,
+			})
 		}
+		authStatus = auth.NewStatusTracker(names)
 	}
 
 	// Warn if cache _and_ cert auto-auth is enabled but certificates were not
@@ -439,10 +504,25 @@ func (c *AgentCommand) Run(args []string) int {
 	default:
 	}
 
+	// restoredToken is the auto-auth token restored from the persistent
+	// cache, if any
+	var restoredToken string
+
 	// Parse agent listener configurations
 	if config.Cache != nil && len(config.Listeners) != 0 {
 		cacheLogger := c.logger.Named("cache")
 
+		var ps *cacheboltdb.BoltStorage
+		var restore bool
+		if config.Cache.Persist != nil {
+			ps, restore, err = openPersistentCache(client, config.Cache.Persist, cacheLogger)
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error opening persistent cache: %v", err))
+				return 1
+			}
+			defer ps.Close()
+		}
+
 		// Create the API proxier
 		apiProxy, err := cache.NewAPIProxy(&cache.APIProxyConfig{
 			Client: client,
@@ -455,20 +535,48 @@ func (c *AgentCommand) Run(args []string) int {
 
 		// Create the lease cache proxier and set its underlying proxier to
 		// the API proxier.
-		leaseCache, err := cache.NewLeaseCache(&cache.LeaseCacheConfig{
+		leaseCacheConfig := &cache.LeaseCacheConfig{
 			Client:      client,
 			BaseContext: ctx,
 			Proxier:     apiProxy,
 			Logger:      cacheLogger.Named("leasecache"),
-		})
+			Storage:     ps,
+		}
+		if config.Cache.Persist != nil {
+			leaseCacheConfig.RetrievalTokenTTL = config.Cache.Persist.RetrievalTokenTTL
+		}
+		for _, ss := range config.Cache.StaticSecrets {
+			leaseCacheConfig.StaticSecrets = append(leaseCacheConfig.StaticSecrets, &cache.StaticSecretConfig{
+				PathPrefix:      ss.PathPrefix,
+				RefreshInterval: ss.RefreshInterval,
+			})
+		}
+		leaseCache, err := cache.NewLeaseCache(leaseCacheConfig)
 		if err != nil {
 			c.UI.Error(fmt.Sprintf("Error creating lease cache: %v", err))
 			return 1
 		}
 
+		if restore {
+			cacheLogger.Info("restoring persistent cache")
+			restoredToken, err = leaseCache.Restore(ctx)
+			if err != nil {
+				if config.Cache.Persist.ExitOnErr {
+					c.UI.Error(fmt.Sprintf("Error restoring persistent cache: %v", err))
+					return 1
+				}
+				cacheLogger.Warn("failed to restore persistent cache, starting with an empty cache", "error", err)
+				restoredToken = ""
+				if err := ps.Clear(); err != nil {
+					c.UI.Error(fmt.Sprintf("Error clearing persistent cache: %v", err))
+					return 1
+				}
+			}
+		}
+
 		var inmemSink sink.Sink
-		if config.Cache.UseAutoAuthToken {
-			cacheLogger.Debug("auto-auth token is allowed to be used; configuring inmem sink")
+		if config.Cache.UseAutoAuthToken || ps != nil {
+			cacheLogger.Debug("configuring inmem sink")
 			inmemSink, err = inmem.New(&sink.SinkConfig{
 				Logger: cacheLogger,
 			}, leaseCache)
@@ -482,6 +590,13 @@ func (c *AgentCommand) Run(args []string) int {
 			})
 		}
 
+		// The inmem sink is also needed to register the auto-auth token with
+		// the persistent cache, but it is only used to authenticate requests
+		// if use_auto_auth_token is set
+		if !config.Cache.UseAutoAuthToken {
+			inmemSink = nil
+		}
+
 		var proxyVaultToken = !config.Cache.ForceAutoAuthToken
 
 		// Create the request handler
@@ -507,6 +622,11 @@ func (c *AgentCommand) Run(args []string) int {
 			// Create a muxer and add paths relevant for the lease cache layer
 			mux := http.NewServeMux()
 			mux.Handle(consts.AgentPathCacheClear, leaseCache.HandleCacheClear(ctx))
+			if authStatus != nil {
+				mux.Handle(consts.AgentPathAuthStatus, authStatus.HandleStatus())
+			}
+			mux.Handle(consts.AgentPathMetrics, cache.HandleMetrics(metricsHelper))
+			mux.Handle(consts.AgentPathHealth, cache.HandleHealth(authStatus))
 			mux.Handle("/", muxHandler)
 
 			scheme := "https://"
@@ -563,7 +683,7 @@ func (c *AgentCommand) Run(args []string) int {
 	}, func(error) {})
 
 	// Start auto-auth and sink servers
-	if method != nil {
+	if len(methods) > 0 {
 		enableTokenCh := len(config.Templates) > 0
 		ah := auth.NewAuthHandler(&auth.AuthHandlerConfig{
 			Logger:                       c.logger.Named("auth.handler"),
@@ -571,6 +691,9 @@ func (c *AgentCommand) Run(args []string) int {
 			WrapTTL:                      config.AutoAuth.Method.WrapTTL,
 			EnableReauthOnNewCredentials: config.AutoAuth.EnableReauthOnNewCredentials,
 			EnableTemplateTokenCh:        enableTokenCh,
+			EnableExecTokenCh:            config.Exec != nil,
+			Token:                        restoredToken,
+			Status:                       authStatus,
 		})
 
 		ss := sink.NewSinkServer(&sink.SinkServerConfig{
@@ -589,7 +712,7 @@ func (c *AgentCommand) Run(args []string) int {
 		})
 
 		g.Add(func() error {
-			return ah.Run(ctx, method)
+			return ah.RunMethods(ctx, methods)
 		}, func(error) {
 			cancelFunc()
 		})
@@ -627,6 +750,23 @@ func (c *AgentCommand) Run(args []string) int {
 			ts.Stop()
 		})
 
+		if config.Exec != nil {
+			es := exec.NewServer(&exec.ServerConfig{
+				Logger:       c.logger.Named("exec.server"),
+				LogLevel:     level,
+				LogWriter:    c.logWriter,
+				VaultConf:    config.Vault,
+				Namespace:    namespace,
+				EnvTemplates: config.EnvTemplates,
+				Exec:         config.Exec,
+			})
+
+			g.Add(func() error {
+				return es.Run(ctx, ah.ExecTokenCh)
+			}, func(error) {
+				cancelFunc()
+			})
+		}
 	}
 
 	// Server configuration output
@@ -666,6 +806,64 @@ func (c *AgentCommand) Run(args []string) int {
 	return 0
 }
 
+// openPersistentCache opens the persistent cache. The key of an existing cache
+// is retrieved from Vault using the retrieval token stored alongside it, and
+// the returned bool indicates whether the cache should be restored. If the
+// key can't be retrieved, the cache is discarded unless exit_on_err is set.
+func openPersistentCache(client *api.Client, persist *agentConfig.Persist, logger log.Logger) (*cacheboltdb.BoltStorage, bool, error) {
+	if err := os.MkdirAll(persist.Path, 0700); err != nil {
+		return nil, false, err
+	}
+
+	exists, err := cacheboltdb.DBFileExists(persist.Path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var key []byte
+	if exists {
+		key, err = retrievePersistentKey(client, persist.Path)
+		if err != nil {
+			if persist.ExitOnErr {
+				return nil, false, err
+			}
+			logger.Warn("unable to retrieve the key of the persistent cache, starting with an empty cache", "error", err)
+			if err := cacheboltdb.RemoveDBFile(persist.Path); err != nil {
+				return nil, false, err
+			}
+			key = nil
+		}
+	}
+
+	restore := key != nil
+	if !restore {
+		key, err = cacheboltdb.GenerateKey()
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	ps, err := cacheboltdb.NewBoltStorage(&cacheboltdb.BoltStorageConfig{
+		Path: persist.Path,
+		Key:  key,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return ps, restore, nil
+}
+
+func retrievePersistentKey(client *api.Client, path string) ([]byte, error) {
+	token, err := cacheboltdb.GetRetrievalToken(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(token) == 0 {
+		return nil, errors.New("no retrieval token found in the persistent cache")
+	}
+	return cache.RetrievePersistentKey(client, string(token))
+}
+
 // verifyRequestHeader wraps an http.Handler inside a Handler that checks for
 // the request header that is used for SSRF protection.
 func verifyRequestHeader(handler http.Handler) http.Handler {
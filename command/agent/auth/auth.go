@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
 	"math/rand"
 	"net/http"
 	"time"
@@ -42,12 +43,16 @@ type AuthConfig struct {
 type AuthHandler struct {
 	OutputCh                     chan string
 	TemplateTokenCh              chan string
+	ExecTokenCh                  chan string
 	logger                       hclog.Logger
 	client                       *api.Client
 	random                       *rand.Rand
 	wrapTTL                      time.Duration
 	enableReauthOnNewCredentials bool
 	enableTemplateTokenCh        bool
+	enableExecTokenCh            bool
+	status                       *StatusTracker
+	token                        string
 }
 
 type AuthHandlerConfig struct {
@@ -56,6 +61,16 @@ type AuthHandlerConfig struct {
 	WrapTTL                      time.Duration
 	EnableReauthOnNewCredentials bool
 	EnableTemplateTokenCh        bool
+	EnableExecTokenCh            bool
+
+	// Status tracks the state of the auth methods. If not set, a tracker is
+	// created when running.
+	Status *StatusTracker
+
+	// Token is a previously obtained token, such as one restored from the
+	// persistent cache, which is used for as long as it can be renewed before
+	// authenticating
+	Token string
 }
 
 func NewAuthHandler(conf *AuthHandlerConfig) *AuthHandler {
@@ -64,12 +79,16 @@ func NewAuthHandler(conf *AuthHandlerConfig) *AuthHandler {
 		// has been shut down, during agent shutdown, we won't block
 		OutputCh:                     make(chan string, 1),
 		TemplateTokenCh:              make(chan string, 1),
+		ExecTokenCh:                  make(chan string, 1),
 		logger:                       conf.Logger,
 		client:                       conf.Client,
 		random:                       rand.New(rand.NewSource(int64(time.Now().Nanosecond()))),
 		wrapTTL:                      conf.WrapTTL,
 		enableReauthOnNewCredentials: conf.EnableReauthOnNewCredentials,
 		enableTemplateTokenCh:        conf.EnableTemplateTokenCh,
+		enableExecTokenCh:            conf.EnableExecTokenCh,
+		status:                       conf.Status,
+		token:                        conf.Token,
 	}
 
 	return ah
@@ -82,37 +101,79 @@ func backoffOrQuit(ctx context.Context, backoff time.Duration) {
 	}
 }
 
+// Run authenticates with the given method and keeps the resulting token
+// renewed, retrying forever if authentication fails
 func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 	if am == nil {
 		return errors.New("auth handler: nil auth method")
 	}
+	return ah.RunMethods(ctx, []*FailoverMethod{{
+		Name:   "method",
+		Method: am,
+	}})
+}
+
+// RunMethods authenticates with the first of the given methods and keeps the
+// resulting token renewed. Once a method has exhausted its retries, the
+// handler fails over to the next one, wrapping around to the first.
+func (ah *AuthHandler) RunMethods(ctx context.Context, methods []*FailoverMethod) error {
+	if len(methods) == 0 {
+		return errors.New("auth handler: no auth methods")
+	}
+	names := make([]string, len(methods))
+	for i, fm := range methods {
+		if fm == nil || fm.Method == nil {
+			return errors.New("auth handler: nil auth method")
+		}
+		names[i] = fm.Name
+	}
+	if ah.status == nil {
+		ah.status = NewStatusTracker(names)
+	}
+	if len(ah.status.Methods()) != len(methods) {
+		return errors.New("auth handler: status tracker does not match the auth methods")
+	}
 
 	ah.logger.Info("starting auth handler")
 	defer func() {
-		am.Shutdown()
+		for _, fm := range methods {
+			fm.Method.Shutdown()
+		}
 		close(ah.OutputCh)
 		close(ah.TemplateTokenCh)
+		close(ah.ExecTokenCh)
 		ah.logger.Info("auth handler stopped")
 	}()
 
-	credCh := am.NewCreds()
-	if !ah.enableReauthOnNewCredentials {
-		realCredCh := credCh
-		credCh = nil
-		if realCredCh != nil {
-			go func() {
-				for {
+	// New credentials are only acted upon when they are found by the active
+	// method
+	credCh := make(chan struct{}, 1)
+	for i, fm := range methods {
+		realCredCh := fm.Method.NewCreds()
+		if realCredCh == nil {
+			continue
+		}
+		go func(i int) {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-realCredCh:
+					if !ah.enableReauthOnNewCredentials || ah.status.activeIndex() != i {
+						continue
+					}
 					select {
-					case <-ctx.Done():
-						return
-					case <-realCredCh:
+					case credCh <- struct{}{}:
+					default:
 					}
 				}
-			}()
-		}
+			}
+		}(i)
 	}
-	if credCh == nil {
-		credCh = make(chan struct{})
+
+	if ah.token != "" {
+		ah.resumeToken(ctx, ah.token, credCh)
+		ah.token = ""
 	}
 
 	var watcher *api.LifetimeWatcher
@@ -125,15 +186,17 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		default:
 		}
 
-		// Create a fresh backoff value
-		backoff := 2*time.Second + time.Duration(ah.random.Int63()%int64(time.Second*2)-int64(time.Second))
+		fm := methods[ah.status.activeIndex()]
+		am := fm.Method
+
+		// The backoff in case this attempt fails
+		backoff := fm.backoff(ah.status.failures()+1, ah.random)
 
-		ah.logger.Info("authenticating")
+		ah.logger.Info("authenticating", "method", fm.Name)
 
 		path, header, data, err := am.Authenticate(ctx, ah.client)
 		if err != nil {
-			ah.logger.Error("error getting path or data from method", "error", err, "backoff", backoff.Seconds())
-			backoffOrQuit(ctx, backoff)
+			ah.authFailed(ctx, methods, backoff, "error getting path or data from method", err)
 			continue
 		}
 
@@ -143,8 +206,7 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		case AuthMethodWithClient:
 			clientToUse, err = am.(AuthMethodWithClient).AuthClient(ah.client)
 			if err != nil {
-				ah.logger.Error("error creating client for authentication call", "error", err, "backoff", backoff.Seconds())
-				backoffOrQuit(ctx, backoff)
+				ah.authFailed(ctx, methods, backoff, "error creating client for authentication call", err)
 				continue
 			}
 		default:
@@ -154,8 +216,7 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		if ah.wrapTTL > 0 {
 			wrapClient, err := clientToUse.Clone()
 			if err != nil {
-				ah.logger.Error("error creating client for wrapped call", "error", err, "backoff", backoff.Seconds())
-				backoffOrQuit(ctx, backoff)
+				ah.authFailed(ctx, methods, backoff, "error creating client for wrapped call", err)
 				continue
 			}
 			wrapClient.SetWrappingLookupFunc(func(string, string) string {
@@ -172,34 +233,34 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		secret, err := clientToUse.Logical().Write(path, data)
 		// Check errors/sanity
 		if err != nil {
-			ah.logger.Error("error authenticating", "error", err, "backoff", backoff.Seconds())
-			backoffOrQuit(ctx, backoff)
+			ah.authFailed(ctx, methods, backoff, "error authenticating", err)
 			continue
 		}
 
 		switch {
 		case ah.wrapTTL > 0:
 			if secret.WrapInfo == nil {
-				ah.logger.Error("authentication returned nil wrap info", "backoff", backoff.Seconds())
-				backoffOrQuit(ctx, backoff)
+				ah.authFailed(ctx, methods, backoff, "authentication returned nil wrap info", nil)
 				continue
 			}
 			if secret.WrapInfo.Token == "" {
-				ah.logger.Error("authentication returned empty wrapped client token", "backoff", backoff.Seconds())
-				backoffOrQuit(ctx, backoff)
+				ah.authFailed(ctx, methods, backoff, "authentication returned empty wrapped client token", nil)
 				continue
 			}
 			wrappedResp, err := jsonutil.EncodeJSON(secret.WrapInfo)
 			if err != nil {
-				ah.logger.Error("failed to encode wrapinfo", "error", err, "backoff", backoff.Seconds())
-				backoffOrQuit(ctx, backoff)
+				ah.authFailed(ctx, methods, backoff, "failed to encode wrapinfo", err)
 				continue
 			}
+			ah.status.success()
 			ah.logger.Info("authentication successful, sending wrapped token to sinks and pausing")
 			ah.OutputCh <- string(wrappedResp)
 			if ah.enableTemplateTokenCh {
 				ah.TemplateTokenCh <- string(wrappedResp)
 			}
+			if ah.enableExecTokenCh {
+				ah.ExecTokenCh <- string(wrappedResp)
+			}
 
 			am.CredSuccess()
 
@@ -215,20 +276,22 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 
 		default:
 			if secret == nil || secret.Auth == nil {
-				ah.logger.Error("authentication returned nil auth info", "backoff", backoff.Seconds())
-				backoffOrQuit(ctx, backoff)
+				ah.authFailed(ctx, methods, backoff, "authentication returned nil auth info", nil)
 				continue
 			}
 			if secret.Auth.ClientToken == "" {
-				ah.logger.Error("authentication returned empty client token", "backoff", backoff.Seconds())
-				backoffOrQuit(ctx, backoff)
+				ah.authFailed(ctx, methods, backoff, "authentication returned empty client token", nil)
 				continue
 			}
+			ah.status.success()
 			ah.logger.Info("authentication successful, sending token to sinks")
 			ah.OutputCh <- secret.Auth.ClientToken
 			if ah.enableTemplateTokenCh {
 				ah.TemplateTokenCh <- secret.Auth.ClientToken
 			}
+			if ah.enableExecTokenCh {
+				ah.ExecTokenCh <- secret.Auth.ClientToken
+			}
 
 			am.CredSuccess()
 		}
@@ -275,3 +338,101 @@ func (ah *AuthHandler) Run(ctx context.Context, am AuthMethod) error {
 		}
 	}
 }
+
+// authFailed records a failed authentication attempt with the active method
+// and backs off, failing over to the next method once the active one has
+// exhausted its retries
+func (ah *AuthHandler) authFailed(ctx context.Context, methods []*FailoverMethod, backoff time.Duration, msg string, err error) {
+	fm := methods[ah.status.activeIndex()]
+	if err != nil {
+		ah.logger.Error(msg, "method", fm.Name, "error", err, "backoff", backoff.Seconds())
+		err = fmt.Errorf("%s: %w", msg, err)
+	} else {
+		ah.logger.Error(msg, "method", fm.Name, "backoff", backoff.Seconds())
+		err = errors.New(msg)
+	}
+
+	failures := ah.status.failure(err)
+	backoffOrQuit(ctx, backoff)
+
+	if len(methods) > 1 && fm.MaxRetries > 0 && failures > fm.MaxRetries {
+		next := methods[ah.status.failover()]
+		ah.logger.Warn("auth method exhausted its retries, failing over", "method", fm.Name, "next", next.Name)
+	}
+}
+
+// resumeToken sends a previously obtained token to the sinks and keeps it
+// renewed, returning once it can no longer be renewed so that the agent
+// authenticates again.
+func (ah *AuthHandler) resumeToken(ctx context.Context, token string, credCh chan struct{}) {
+	client, err := ah.client.Clone()
+	if err != nil {
+		ah.logger.Error("error creating client to look up restored token", "error", err)
+		return
+	}
+	client.SetToken(token)
+
+	lookup, err := client.Auth().Token().LookupSelf()
+	if err != nil {
+		ah.logger.Info("restored token is no longer valid, authenticating", "error", err)
+		return
+	}
+	renewable, err := lookup.TokenIsRenewable()
+	if err != nil || !renewable {
+		ah.logger.Info("restored token is not renewable, authenticating")
+		return
+	}
+	ttl, err := lookup.TokenTTL()
+	if err != nil {
+		ah.logger.Error("error reading TTL of restored token", "error", err)
+		return
+	}
+
+	watcher, err := client.NewLifetimeWatcher(&api.LifetimeWatcherInput{
+		Secret: &api.Secret{
+			Auth: &api.SecretAuth{
+				ClientToken:   token,
+				Renewable:     renewable,
+				LeaseDuration: int(ttl.Seconds()),
+			},
+		},
+	})
+	if err != nil {
+		ah.logger.Error("error creating lifetime watcher for restored token", "error", err)
+		return
+	}
+
+	ah.status.success()
+	ah.logger.Info("using restored token, sending token to sinks")
+	ah.OutputCh <- token
+	if ah.enableTemplateTokenCh {
+		ah.TemplateTokenCh <- token
+	}
+	if ah.enableExecTokenCh {
+		ah.ExecTokenCh <- token
+	}
+
+	go watcher.Renew()
+	defer watcher.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case err := <-watcher.DoneCh():
+			ah.logger.Info("lifetime watcher of restored token done, authenticating")
+			if err != nil {
+				ah.logger.Error("error renewing restored token", "error", err)
+			}
+			return
+
+		case <-watcher.RenewCh():
+			ah.logger.Info("renewed restored token")
+
+		case <-credCh:
+			ah.logger.Info("auth method found new credentials, re-authenticating")
+			return
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+)
+
+const (
+	defaultMinBackoff = 1 * time.Second
+	defaultMaxBackoff = 5 * time.Minute
+)
+
+// FailoverMethod is an auth method along with its retry and backoff settings.
+// The auth handler fails over between an ordered list of them.
+type FailoverMethod struct {
+	// Name identifies the method in logs, metrics and status reports
+	Name   string
+	Method AuthMethod
+
+	// MaxRetries is the number of consecutive failed attempts after which
+	// the handler fails over to the next method. Zero retries forever.
+	MaxRetries int
+
+	// MinBackoff is the backoff after a first failed attempt, doubling with
+	// every consecutive failure up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// backoff returns the time to wait after the given number of consecutive
+// failures. It is jittered by up to a quarter so that agents failing at the
+// same time don't retry in lockstep.
+func (m *FailoverMethod) backoff(failures int, random *rand.Rand) time.Duration {
+	minBackoff, maxBackoff := m.MinBackoff, m.MaxBackoff
+	if minBackoff <= 0 {
+		minBackoff = defaultMinBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+
+	backoff := minBackoff
+	for i := 1; i < failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff - time.Duration(random.Int63n(int64(backoff/4)+1))
+}
+
+// MethodStatus is the state of an auth method as reported by the agent
+type MethodStatus struct {
+	Name                string    `json:"name"`
+	Active              bool      `json:"active"`
+	Authenticated       bool      `json:"authenticated"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastAttempt         time.Time `json:"last_attempt,omitempty"`
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+}
+
+// StatusTracker keeps track of the state of the auth methods the handler
+// fails over between. It's created separately from the handler so that the
+// agent's listeners can report it before authentication starts.
+type StatusTracker struct {
+	l       sync.RWMutex
+	methods []*MethodStatus
+	active  int
+}
+
+// NewStatusTracker returns a tracker for the methods with the given names, in
+// failover order
+func NewStatusTracker(names []string) *StatusTracker {
+	s := &StatusTracker{
+		methods: make([]*MethodStatus, len(names)),
+	}
+	for i, name := range names {
+		s.methods[i] = &MethodStatus{
+			Name:   name,
+			Active: i == 0,
+		}
+	}
+	if len(names) > 0 {
+		s.emitActive()
+	}
+	return s
+}
+
+// Methods returns a copy of the state of each method, in failover order
+func (s *StatusTracker) Methods() []MethodStatus {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	methods := make([]MethodStatus, len(s.methods))
+	for i, m := range s.methods {
+		methods[i] = *m
+	}
+	return methods
+}
+
+// HandleStatus returns a handler reporting the state of the auth methods
+func (s *StatusTracker) HandleStatus() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"methods": s.Methods(),
+		})
+	})
+}
+
+func (s *StatusTracker) activeIndex() int {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	return s.active
+}
+
+func (s *StatusTracker) failures() int {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	return s.methods[s.active].ConsecutiveFailures
+}
+
+// success records a successful authentication with the active method
+func (s *StatusTracker) success() {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	m := s.methods[s.active]
+	now := time.Now()
+	m.Authenticated = true
+	m.ConsecutiveFailures = 0
+	m.LastError = ""
+	m.LastAttempt = now
+	m.LastSuccess = now
+
+	labels := []metrics.Label{{Name: "method", Value: m.Name}}
+	metrics.IncrCounterWithLabels([]string{"agent", "auth", "success"}, 1, labels)
+	metrics.SetGaugeWithLabels([]string{"agent", "auth", "method", "consecutive_failures"}, 0, labels)
+}
+
+// failure records a failed authentication with the active method, returning
+// its number of consecutive failures
+func (s *StatusTracker) failure(err error) int {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	m := s.methods[s.active]
+	m.Authenticated = false
+	m.ConsecutiveFailures++
+	m.LastAttempt = time.Now()
+	if err != nil {
+		m.LastError = err.Error()
+	}
+
+	labels := []metrics.Label{{Name: "method", Value: m.Name}}
+	metrics.IncrCounterWithLabels([]string{"agent", "auth", "failure"}, 1, labels)
+	metrics.SetGaugeWithLabels([]string{"agent", "auth", "method", "consecutive_failures"}, float32(m.ConsecutiveFailures), labels)
+	return m.ConsecutiveFailures
+}
+
+// failover makes the next method active, wrapping around to the first one,
+// and returns its index
+func (s *StatusTracker) failover() int {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.methods[s.active].Active = false
+	s.methods[s.active].Authenticated = false
+	s.active = (s.active + 1) % len(s.methods)
+	s.methods[s.active].Active = true
+	s.methods[s.active].ConsecutiveFailures = 0
+	s.emitActive()
+	return s.active
+}
+
+// emitActive sets the active gauge of each method. Must be called with the
+// lock held.
+func (s *StatusTracker) emitActive() {
+	for i, m := range s.methods {
+		var active float32
+		if i == s.active {
+			active = 1
+		}
+		metrics.SetGaugeWithLabels([]string{"agent", "auth", "method", "active"}, active, []metrics.Label{{Name: "method", Value: m.Name}})
+	}
+}
@@ -0,0 +1,201 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+)
+
+// failoverTestMethod fails to authenticate if broken, and otherwise logs in
+// at the given path
+type failoverTestMethod struct {
+	path   string
+	broken bool
+}
+
+func (m *failoverTestMethod) Authenticate(context.Context, *api.Client) (string, http.Header, map[string]interface{}, error) {
+	if m.broken {
+		return "", nil, nil, errors.New("method is broken")
+	}
+	return m.path, nil, nil, nil
+}
+
+func (m *failoverTestMethod) NewCreds() chan struct{} {
+	return nil
+}
+
+func (m *failoverTestMethod) CredSuccess() {
+}
+
+func (m *failoverTestMethod) Shutdown() {
+}
+
+func TestFailoverMethod_backoff(t *testing.T) {
+	fm := &FailoverMethod{
+		MinBackoff: time.Second,
+		MaxBackoff: 10 * time.Second,
+	}
+	random := rand.New(rand.NewSource(1))
+
+	for failures, expected := range map[int]time.Duration{
+		1: time.Second,
+		2: 2 * time.Second,
+		3: 4 * time.Second,
+		4: 8 * time.Second,
+		5: 10 * time.Second,
+		9: 10 * time.Second,
+	} {
+		for i := 0; i < 10; i++ {
+			backoff := fm.backoff(failures, random)
+			if backoff > expected || backoff < expected*3/4 {
+				t.Fatalf("backoff after %d failures should be within a quarter of %s, got %s", failures, expected, backoff)
+			}
+		}
+	}
+}
+
+func TestAuthHandler_Failover(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/auth/working/login" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintln(w, `{"auth": {"client_token": "working-token", "renewable": false, "lease_duration": 3600}}`)
+	}))
+	defer vault.Close()
+
+	client, err := api.NewClient(&api.Config{Address: vault.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := NewStatusTracker([]string{"broken", "working"})
+	ah := NewAuthHandler(&AuthHandlerConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace),
+		Client: client,
+		Status: status,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ah.RunMethods(ctx, []*FailoverMethod{
+			{
+				Name:       "broken",
+				Method:     &failoverTestMethod{broken: true},
+				MaxRetries: 1,
+				MinBackoff: time.Millisecond,
+				MaxBackoff: time.Millisecond,
+			},
+			{
+				Name:   "working",
+				Method: &failoverTestMethod{path: "auth/working/login"},
+			},
+		})
+	}()
+
+	select {
+	case token := <-ah.OutputCh:
+		if token != "working-token" {
+			t.Fatalf("unexpected token %q", token)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for a token")
+	}
+
+	methods := status.Methods()
+	if methods[0].Active || methods[0].LastError == "" {
+		t.Fatalf("expected broken method to be inactive with an error: %#v", methods[0])
+	}
+	if !methods[1].Active || !methods[1].Authenticated || methods[1].LastSuccess.IsZero() {
+		t.Fatalf("expected working method to be active and authenticated: %#v", methods[1])
+	}
+
+	// The status is reported through the agent's listeners
+	rec := httptest.NewRecorder()
+	status.HandleStatus().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/agent/v1/auth-status", nil))
+	var resp struct {
+		Methods []MethodStatus `json:"methods"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Methods) != 2 || resp.Methods[1].Name != "working" || !resp.Methods[1].Active {
+		t.Fatalf("unexpected status response: %#v", resp)
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuthHandler_RestoredTokenStatus(t *testing.T) {
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/auth/token/lookup-self":
+			fmt.Fprintln(w, `{"data": {"id": "restored-token", "renewable": true, "ttl": 3600}}`)
+		case "/v1/auth/token/renew-self":
+			fmt.Fprintln(w, `{"auth": {"client_token": "restored-token", "renewable": true, "lease_duration": 3600}}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer vault.Close()
+
+	client, err := api.NewClient(&api.Config{Address: vault.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status := NewStatusTracker([]string{"broken"})
+	ah := NewAuthHandler(&AuthHandlerConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace),
+		Client: client,
+		Status: status,
+		Token:  "restored-token",
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ah.RunMethods(ctx, []*FailoverMethod{{
+			Name:   "broken",
+			Method: &failoverTestMethod{broken: true},
+		}})
+	}()
+
+	select {
+	case token := <-ah.OutputCh:
+		if token != "restored-token" {
+			t.Fatalf("unexpected token %q", token)
+		}
+	case <-ctx.Done():
+		t.Fatal("timed out waiting for a token")
+	}
+
+	// The restored token is reported as authenticated without logging in
+	methods := status.Methods()
+	if !methods[0].Authenticated || methods[0].LastSuccess.IsZero() || methods[0].ConsecutiveFailures != 0 {
+		t.Fatalf("expected method to be authenticated with the restored token: %#v", methods[0])
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,283 @@
+package cacheboltdb
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hashicorp/errwrap"
+	wrapping "github.com/hashicorp/go-kms-wrapping"
+	"github.com/hashicorp/go-kms-wrapping/wrappers/aead"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	// DatabaseFileName is the name of the cache file within the configured
+	// persistence directory
+	DatabaseFileName = "vault-agent-cache.db"
+
+	// TokenType is the index type of the auto-auth token. Only the latest
+	// auto-auth token is kept.
+	TokenType = "token"
+
+	// AuthLeaseType is the index type of tokens created through the agent
+	AuthLeaseType = "auth-lease"
+
+	// SecretLeaseType is the index type of leased secrets
+	SecretLeaseType = "secret-lease"
+
+	// KeySize is the size of the key the cache is encrypted with
+	KeySize = 32
+
+	metaBucketName    = "meta"
+	retrievalTokenKey = "retrieval-token"
+
+	dbFileMode  = 0600
+	openTimeout = 5 * time.Second
+)
+
+var indexTypes = []string{TokenType, AuthLeaseType, SecretLeaseType}
+
+// BoltStorage is a persistent cache of the agent's cached indexes, stored
+// encrypted in a BoltDB file. The key the indexes are encrypted with is
+// itself stored in Vault, behind a response-wrapping token referred to as
+// the retrieval token.
+type BoltStorage struct {
+	db      *bolt.DB
+	wrapper wrapping.Wrapper
+	key     []byte
+}
+
+// BoltStorageConfig is the configuration for NewBoltStorage
+type BoltStorageConfig struct {
+	// Path is the directory the cache file is stored in
+	Path string
+
+	// Key is the key used to encrypt the cached indexes
+	Key []byte
+}
+
+// NewBoltStorage opens or creates the cache file in the configured directory
+func NewBoltStorage(config *BoltStorageConfig) (*BoltStorage, error) {
+	if config == nil {
+		return nil, errors.New("nil configuration provided")
+	}
+	if config.Path == "" {
+		return nil, errors.New("cache path not provided")
+	}
+	if len(config.Key) != KeySize {
+		return nil, fmt.Errorf("cache encryption key must be %d bytes", KeySize)
+	}
+
+	wrapper := aead.NewWrapper(nil)
+	if err := wrapper.SetAESGCMKeyBytes(config.Key); err != nil {
+		return nil, err
+	}
+
+	db, err := open(config.Path, false)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range append(indexTypes, metaBucketName) {
+			if _, err := tx.CreateBucketIfNotExists([]byte(bucket)); err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("failed to create bucket %q: {{err}}", bucket), err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &BoltStorage{
+		db:      db,
+		wrapper: wrapper,
+		key:     config.Key,
+	}, nil
+}
+
+func open(path string, readOnly bool) (*bolt.DB, error) {
+	db, err := bolt.Open(filepath.Join(path, DatabaseFileName), dbFileMode, &bolt.Options{
+		Timeout:  openTimeout,
+		ReadOnly: readOnly,
+	})
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to open cache file: {{err}}", err)
+	}
+	return db, nil
+}
+
+// GenerateKey returns a new random key to encrypt the cache with
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Key returns the key the cache is encrypted with
+func (b *BoltStorage) Key() []byte {
+	return b.key
+}
+
+// Set encrypts and stores an index of the given type
+func (b *BoltStorage) Set(ctx context.Context, id string, plaintext []byte, indexType string) error {
+	blob, err := b.wrapper.Encrypt(ctx, plaintext, []byte(id))
+	if err != nil {
+		return errwrap.Wrapf("error encrypting index: {{err}}", err)
+	}
+	protoBlob, err := proto.Marshal(blob)
+	if err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(indexType))
+		if bucket == nil {
+			return fmt.Errorf("unknown index type %q", indexType)
+		}
+		if indexType == TokenType {
+			// Replace the previous auto-auth token
+			if err := clearBucket(tx, indexType); err != nil {
+				return err
+			}
+			bucket = tx.Bucket([]byte(indexType))
+		}
+		return bucket.Put([]byte(id), protoBlob)
+	})
+}
+
+// Delete removes an index, whichever its type
+func (b *BoltStorage) Delete(id string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, indexType := range indexTypes {
+			if err := tx.Bucket([]byte(indexType)).Delete([]byte(id)); err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("failed to delete %q from %q: {{err}}", id, indexType), err)
+			}
+		}
+		return nil
+	})
+}
+
+// GetByType returns the decrypted indexes of the given type
+func (b *BoltStorage) GetByType(ctx context.Context, indexType string) ([][]byte, error) {
+	var blobs []*wrapping.EncryptedBlobInfo
+	var ids []string
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(indexType))
+		if bucket == nil {
+			return fmt.Errorf("unknown index type %q", indexType)
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			blob := new(wrapping.EncryptedBlobInfo)
+			if err := proto.Unmarshal(v, blob); err != nil {
+				return errwrap.Wrapf(fmt.Sprintf("failed to decode %q: {{err}}", k), err)
+			}
+			ids = append(ids, string(k))
+			blobs = append(blobs, blob)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make([][]byte, 0, len(blobs))
+	for i, blob := range blobs {
+		plaintext, err := b.wrapper.Decrypt(ctx, blob, []byte(ids[i]))
+		if err != nil {
+			return nil, errwrap.Wrapf(fmt.Sprintf("failed to decrypt %q: {{err}}", ids[i]), err)
+		}
+		indexes = append(indexes, plaintext)
+	}
+	return indexes, nil
+}
+
+// StoreRetrievalToken stores the response-wrapping token holding the key
+// the cache is encrypted with. The token is not encrypted, it is needed to
+// obtain the key after a restart.
+func (b *BoltStorage) StoreRetrievalToken(token []byte) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket([]byte(metaBucketName)).Put([]byte(retrievalTokenKey), token)
+	})
+}
+
+// Clear removes all the stored indexes, keeping the retrieval token
+func (b *BoltStorage) Clear() error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		for _, indexType := range indexTypes {
+			if err := clearBucket(tx, indexType); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func clearBucket(tx *bolt.Tx, name string) error {
+	if err := tx.DeleteBucket([]byte(name)); err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("failed to clear %q: {{err}}", name), err)
+	}
+	if _, err := tx.CreateBucket([]byte(name)); err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("failed to create bucket %q: {{err}}", name), err)
+	}
+	return nil
+}
+
+// Close closes the cache file
+func (b *BoltStorage) Close() error {
+	return b.db.Close()
+}
+
+// GetRetrievalToken reads the retrieval token from the cache file in the
+// given directory, without needing the key the cache is encrypted with. An
+// empty token is returned if none was stored.
+func GetRetrievalToken(path string) ([]byte, error) {
+	db, err := open(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var token []byte
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(metaBucketName))
+		if bucket == nil {
+			return nil
+		}
+		token = append(token, bucket.Get([]byte(retrievalTokenKey))...)
+		return nil
+	})
+	return token, err
+}
+
+// DBFileExists returns whether a non-empty cache file exists in the given
+// directory
+func DBFileExists(path string) (bool, error) {
+	info, err := os.Stat(filepath.Join(path, DatabaseFileName))
+	switch {
+	case os.IsNotExist(err):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return info.Size() > 0, nil
+}
+
+// RemoveDBFile deletes the cache file in the given directory
+func RemoveDBFile(path string) error {
+	err := os.Remove(filepath.Join(path, DatabaseFileName))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,148 @@
+package cacheboltdb
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func testBoltStorage(t *testing.T, path string, key []byte) *BoltStorage {
+	t.Helper()
+	b, err := NewBoltStorage(&BoltStorageConfig{
+		Path: path,
+		Key:  key,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBoltStorage(t *testing.T) {
+	ctx := context.Background()
+
+	path, err := ioutil.TempDir("", "vault-agent-cache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := testBoltStorage(t, path, key)
+
+	if err := b.Set(ctx, "token1", []byte("auto-auth 1"), TokenType); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set(ctx, "token2", []byte("auto-auth 2"), TokenType); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set(ctx, "lease1", []byte("lease 1"), SecretLeaseType); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set(ctx, "lease2", []byte("lease 2"), SecretLeaseType); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set(ctx, "foo", []byte("foo"), "foo"); err == nil {
+		t.Fatal("expected error for unknown index type")
+	}
+	if err := b.Delete("lease1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.StoreRetrievalToken([]byte("retrieval")); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	exists, err := DBFileExists(path)
+	if err != nil || !exists {
+		t.Fatalf("expected cache file to exist: %v", err)
+	}
+	token, err := GetRetrievalToken(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(token) != "retrieval" {
+		t.Fatalf("unexpected retrieval token %q", token)
+	}
+
+	// Only the latest auto-auth token is kept
+	b = testBoltStorage(t, path, key)
+	tokens, err := b.GetByType(ctx, TokenType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 1 || string(tokens[0]) != "auto-auth 2" {
+		t.Fatalf("unexpected tokens %q", tokens)
+	}
+	leases, err := b.GetByType(ctx, SecretLeaseType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 || string(leases[0]) != "lease 2" {
+		t.Fatalf("unexpected leases %q", leases)
+	}
+
+	if err := b.Clear(); err != nil {
+		t.Fatal(err)
+	}
+	leases, err = b.GetByType(ctx, SecretLeaseType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 0 {
+		t.Fatalf("expected no leases after clear, got %q", leases)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The retrieval token survives clearing the cache
+	token, err = GetRetrievalToken(path)
+	if err != nil || string(token) != "retrieval" {
+		t.Fatalf("unexpected retrieval token %q: %v", token, err)
+	}
+
+	if err := RemoveDBFile(path); err != nil {
+		t.Fatal(err)
+	}
+	exists, err = DBFileExists(path)
+	if err != nil || exists {
+		t.Fatalf("expected cache file to be removed: %v", err)
+	}
+}
+
+func TestBoltStorage_WrongKey(t *testing.T) {
+	ctx := context.Background()
+
+	path, err := ioutil.TempDir("", "vault-agent-cache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := testBoltStorage(t, path, key)
+	if err := b.Set(ctx, "lease", []byte("lease"), SecretLeaseType); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	otherKey, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = testBoltStorage(t, path, otherKey)
+	defer b.Close()
+	if _, err := b.GetByType(ctx, SecretLeaseType); err == nil {
+		t.Fatal("expected decryption to fail with the wrong key")
+	}
+}
@@ -1,6 +1,12 @@
 package cachememdb
 
-import "context"
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+)
 
 // Index holds the response to be cached along with multiple other values that
 // serve as pointers to refer back to this index.
@@ -47,7 +53,38 @@ type Index struct {
 	// RenewCtxInfo holds the context and the corresponding cancel func for the
 	// goroutine that manages the renewal of the secret belonging to the
 	// response in this index.
-	RenewCtxInfo *ContextInfo
+	RenewCtxInfo *ContextInfo `json:"-"`
+
+	// Type is the kind of the cached entry, used to restore it from
+	// persistent storage
+	// Required: false, Unique: false
+	Type string
+
+	// RequestMethod, RequestToken and RequestHeader are the parts of the
+	// request that resulted in the response held by this index, which are
+	// needed to resume renewing the secret after a restore.
+	// Required: false, Unique: false
+	RequestMethod string
+	RequestToken  string
+	RequestHeader http.Header
+
+	// LastRenewed is the time the secret held by this index was last renewed
+	// Required: false, Unique: false
+	LastRenewed time.Time
+}
+
+// Serialize returns the index encoded for persistent storage
+func (i *Index) Serialize() ([]byte, error) {
+	return jsonutil.EncodeJSON(i)
+}
+
+// Deserialize decodes an index read from persistent storage
+func Deserialize(indexBytes []byte) (*Index, error) {
+	index := new(Index)
+	if err := jsonutil.DecodeJSON(indexBytes, index); err != nil {
+		return nil, err
+	}
+	return index, nil
 }
 
 type IndexName uint32
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/hashicorp/vault/command/agent/auth"
+	"github.com/hashicorp/vault/helper/metricsutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/sdk/version"
+)
+
+// HandleMetrics returns a handler reporting the agent's metrics, in the
+// Prometheus format if requested through the format parameter or the Accept
+// header, as JSON otherwise
+func HandleMetrics(metricsHelper *metricsutil.MetricsHelper) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			logical.RespondError(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			logical.RespondError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		format := r.Form.Get("format")
+		if format == "" {
+			format = metricsutil.FormatFromRequest(&logical.Request{Headers: r.Header})
+		}
+
+		resp := metricsHelper.ResponseForFormat(format)
+
+		w.Header().Set("Content-Type", resp.Data[logical.HTTPContentType].(string))
+		switch v := resp.Data[logical.HTTPRawBody].(type) {
+		case string:
+			w.WriteHeader(resp.Data[logical.HTTPStatusCode].(int))
+			w.Write([]byte(v))
+		case []byte:
+			w.WriteHeader(resp.Data[logical.HTTPStatusCode].(int))
+			w.Write(v)
+		default:
+			logical.RespondError(w, http.StatusInternalServerError, fmt.Errorf("wrong response returned"))
+		}
+	})
+}
+
+// HealthResponse is the body returned by the agent's health endpoint
+type HealthResponse struct {
+	Healthy  bool                `json:"healthy"`
+	Version  string              `json:"version"`
+	AutoAuth *HealthAutoAuthInfo `json:"auto_auth,omitempty"`
+}
+
+// HealthAutoAuthInfo reports the state of the agent's auto-auth
+type HealthAutoAuthInfo struct {
+	ActiveMethod  string `json:"active_method"`
+	Authenticated bool   `json:"authenticated"`
+}
+
+// HandleHealth returns a handler reporting whether the agent is healthy. The
+// agent is unhealthy if auto-auth is configured and the active method isn't
+// authenticated, in which case the status code is 503 unless overridden with
+// the unauthenticatedcode parameter. The status code of a healthy agent can
+// be overridden with the okcode parameter. authStatus is nil if auto-auth
+// isn't configured.
+func HandleHealth(authStatus *auth.StatusTracker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		default:
+			logical.RespondError(w, http.StatusMethodNotAllowed, nil)
+			return
+		}
+
+		okCode, err := healthStatusCode(r, "okcode", http.StatusOK)
+		if err != nil {
+			logical.RespondError(w, http.StatusBadRequest, err)
+			return
+		}
+		unauthenticatedCode, err := healthStatusCode(r, "unauthenticatedcode", http.StatusServiceUnavailable)
+		if err != nil {
+			logical.RespondError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		body := &HealthResponse{
+			Healthy: true,
+			Version: version.GetVersion().VersionNumber(),
+		}
+		if authStatus != nil {
+			body.AutoAuth = &HealthAutoAuthInfo{}
+			for _, m := range authStatus.Methods() {
+				if m.Active {
+					body.AutoAuth.ActiveMethod = m.Name
+					body.AutoAuth.Authenticated = m.Authenticated
+				}
+			}
+			body.Healthy = body.AutoAuth.Authenticated
+		}
+
+		code := okCode
+		if !body.Healthy {
+			code = unauthenticatedCode
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		if r.Method == http.MethodHead {
+			return
+		}
+		json.NewEncoder(w).Encode(body)
+	})
+}
+
+// healthStatusCode returns the status code given in the field of the request
+// query, or the default one
+func healthStatusCode(r *http.Request, field string, defaultCode int) (int, error) {
+	value := r.URL.Query().Get(field)
+	if value == "" {
+		return defaultCode, nil
+	}
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 100 || code > 599 {
+		return 0, fmt.Errorf("invalid status code %q for %q", value, field)
+	}
+	return code, nil
+}
@@ -0,0 +1,92 @@
+package cache
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/vault/command/agent/auth"
+	"github.com/hashicorp/vault/helper/metricsutil"
+)
+
+func TestHandleMetrics(t *testing.T) {
+	inm := metrics.NewInmemSink(10*time.Second, time.Minute)
+	inm.IncrCounter([]string{"agent", "cache", "hit"}, 1)
+	handler := HandleMetrics(metricsutil.NewMetricsHelper(inm, false))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/agent/v1/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	var summary metrics.MetricsSummary
+	if err := json.Unmarshal(w.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Counters) != 1 || summary.Counters[0].Name != "agent.cache.hit" {
+		t.Fatalf("unexpected counters %#v", summary.Counters)
+	}
+
+	// Prometheus isn't enabled without telemetry configuration
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/agent/v1/metrics?format=prometheus", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/agent/v1/metrics", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected status %d", w.Code)
+	}
+}
+
+func TestHandleHealth(t *testing.T) {
+	get := func(handler http.Handler, url string) (int, *HealthResponse) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		if w.Code == http.StatusBadRequest {
+			return w.Code, nil
+		}
+		var body HealthResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, &body
+	}
+
+	// Without auto-auth the agent is healthy
+	code, body := get(HandleHealth(nil), "/agent/v1/health")
+	if code != http.StatusOK || !body.Healthy || body.AutoAuth != nil {
+		t.Fatalf("unexpected response %d %#v", code, body)
+	}
+	code, _ = get(HandleHealth(nil), "/agent/v1/health?okcode=204")
+	if code != http.StatusNoContent {
+		t.Fatalf("unexpected status %d", code)
+	}
+
+	// Until auto-auth succeeds the agent is unhealthy
+	handler := HandleHealth(auth.NewStatusTracker([]string{"approle", "kubernetes"}))
+	code, body = get(handler, "/agent/v1/health")
+	if code != http.StatusServiceUnavailable || body.Healthy {
+		t.Fatalf("unexpected response %d %#v", code, body)
+	}
+	if body.AutoAuth == nil || body.AutoAuth.ActiveMethod != "approle" || body.AutoAuth.Authenticated {
+		t.Fatalf("unexpected auto-auth info %#v", body.AutoAuth)
+	}
+	code, _ = get(handler, "/agent/v1/health?unauthenticatedcode=200")
+	if code != http.StatusOK {
+		t.Fatalf("unexpected status %d", code)
+	}
+	code, _ = get(handler, "/agent/v1/health?unauthenticatedcode=foo")
+	if code != http.StatusBadRequest {
+		t.Fatalf("unexpected status %d", code)
+	}
+}
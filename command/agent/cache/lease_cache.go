@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"errors"
@@ -15,9 +16,11 @@ import (
 	"sync"
 	"time"
 
+	metrics "github.com/armon/go-metrics"
 	"github.com/hashicorp/errwrap"
 	hclog "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/command/agent/cache/cacheboltdb"
 	cachememdb "github.com/hashicorp/vault/command/agent/cache/cachememdb"
 	"github.com/hashicorp/vault/helper/namespace"
 	nshelper "github.com/hashicorp/vault/helper/namespace"
@@ -40,6 +43,10 @@ const (
 	vaultPathLeaseRevoke         = "/v1/sys/leases/revoke"
 	vaultPathLeaseRevokeForce    = "/v1/sys/leases/revoke-force"
 	vaultPathLeaseRevokePrefix   = "/v1/sys/leases/revoke-prefix"
+
+	// defaultRetrievalTokenTTL is the default TTL of the response-wrapping
+	// token holding the key of the persistent cache
+	defaultRetrievalTokenTTL = 24 * time.Hour
 )
 
 var (
@@ -78,6 +85,14 @@ type LeaseCache struct {
 	// idLocks is used during cache lookup to ensure that identical requests made
 	// in parallel won't trigger multiple renewal goroutines.
 	idLocks []*locksutil.LockEntry
+
+	// ps is the optional persistent storage the cached indexes are also
+	// written to, so that they can be restored after a restart.
+	ps                *cacheboltdb.BoltStorage
+	retrievalTokenTTL time.Duration
+
+	// staticSecrets is the optional cache of secrets without leases
+	staticSecrets *staticSecretCache
 }
 
 // LeaseCacheConfig is the configuration for initializing a new
@@ -87,6 +102,16 @@ type LeaseCacheConfig struct {
 	BaseContext context.Context
 	Proxier     Proxier
 	Logger      hclog.Logger
+
+	// Storage, if set, persists the cached indexes. RetrievalTokenTTL is the
+	// TTL of the response-wrapping token the key of the storage is stored
+	// in, which must cover the time until the agent is next restarted.
+	Storage           *cacheboltdb.BoltStorage
+	RetrievalTokenTTL time.Duration
+
+	// StaticSecrets enables caching of secrets without leases under the
+	// given path prefixes
+	StaticSecrets []*StaticSecretConfig
 }
 
 // NewLeaseCache creates a new instance of a LeaseCache.
@@ -111,14 +136,27 @@ func NewLeaseCache(conf *LeaseCacheConfig) (*LeaseCache, error) {
 	// Create a base context for the lease cache layer
 	baseCtxInfo := cachememdb.NewContextInfo(conf.BaseContext)
 
+	retrievalTokenTTL := conf.RetrievalTokenTTL
+	if retrievalTokenTTL == 0 {
+		retrievalTokenTTL = defaultRetrievalTokenTTL
+	}
+
+	var staticSecrets *staticSecretCache
+	if len(conf.StaticSecrets) > 0 {
+		staticSecrets = newStaticSecretCache(conf.StaticSecrets)
+	}
+
 	return &LeaseCache{
-		client:      conf.Client,
-		proxier:     conf.Proxier,
-		logger:      conf.Logger,
-		db:          db,
-		baseCtxInfo: baseCtxInfo,
-		l:           &sync.RWMutex{},
-		idLocks:     locksutil.CreateLocks(),
+		client:            conf.Client,
+		proxier:           conf.Proxier,
+		logger:            conf.Logger,
+		db:                db,
+		baseCtxInfo:       baseCtxInfo,
+		l:                 &sync.RWMutex{},
+		idLocks:           locksutil.CreateLocks(),
+		ps:                conf.Storage,
+		retrievalTokenTTL: retrievalTokenTTL,
+		staticSecrets:     staticSecrets,
 	}, nil
 }
 
@@ -134,15 +172,21 @@ func (c *LeaseCache) checkCacheForRequest(id string) (*SendResponse, error) {
 		return nil, nil
 	}
 
-	// Cached request is found, deserialize the response
-	reader := bufio.NewReader(bytes.NewReader(index.Response))
+	return c.cachedResponse(index.Response)
+}
+
+// cachedResponse deserializes a cached response
+func (c *LeaseCache) cachedResponse(response []byte) (*SendResponse, error) {
+	reader := bufio.NewReader(bytes.NewReader(response))
 	resp, err := http.ReadResponse(reader, nil)
 	if err != nil {
 		c.logger.Error("failed to deserialize response", "error", err)
 		return nil, err
 	}
 
-	sendResp, err := NewSendResponse(&api.Response{Response: resp}, index.Response)
+	// The body is read from the deserialized response, since the cached bytes
+	// include the status line and headers
+	sendResp, err := NewSendResponse(&api.Response{Response: resp}, nil)
 	if err != nil {
 		c.logger.Error("failed to create new send response", "error", err)
 		return nil, err
@@ -163,6 +207,17 @@ func (c *LeaseCache) checkCacheForRequest(id string) (*SendResponse, error) {
 // it will return the cached response, otherwise it will delegate to the
 // underlying Proxier and cache the received response.
 func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse, error) {
+	// Writes evict the cached static secret at their path once they are done
+	if c.staticSecrets != nil && isWriteMethod(req.Request.Method) {
+		defer c.staticSecrets.invalidate(requestNamespace(req), staticSecretPath(req.Request))
+	}
+
+	if sendResp := c.checkStaticSecretCache(ctx, req); sendResp != nil {
+		c.logger.Debug("returning cached static secret", "path", req.Request.URL.Path)
+		metrics.IncrCounter([]string{"agent", "cache", "hit"}, 1)
+		return sendResp, nil
+	}
+
 	// Compute the index ID
 	id, err := computeIndexID(req)
 	if err != nil {
@@ -184,6 +239,7 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 	}
 	if sendResp != nil {
 		c.logger.Debug("returning cached response", "path", req.Request.URL.Path)
+		metrics.IncrCounter([]string{"agent", "cache", "hit"}, 1)
 		return sendResp, nil
 	}
 
@@ -203,10 +259,17 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 	// will be the one performing the cache write.
 	if sendResp != nil {
 		c.logger.Debug("returning cached response", "method", req.Request.Method, "path", req.Request.URL.Path)
+		metrics.IncrCounter([]string{"agent", "cache", "hit"}, 1)
 		return sendResp, nil
 	}
 
 	c.logger.Debug("forwarding request", "method", req.Request.Method, "path", req.Request.URL.Path)
+	metrics.IncrCounter([]string{"agent", "cache", "miss"}, 1)
+
+	var staticGeneration uint64
+	if c.staticSecrets != nil {
+		staticGeneration = c.staticSecrets.currentGeneration()
+	}
 
 	// Pass the request down and get a response
 	resp, err := c.proxier.Send(ctx, req)
@@ -220,19 +283,17 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 		return resp, err
 	}
 
-	// Get the namespace from the request header
-	namespace := req.Request.Header.Get(consts.NamespaceHeaderName)
-	// We need to populate an empty value since go-memdb will skip over indexes
-	// that contain empty values.
-	if namespace == "" {
-		namespace = "root/"
-	}
+	namespace := requestNamespace(req)
 
 	// Build the index to cache based on the response received
 	index := &cachememdb.Index{
-		ID:          id,
-		Namespace:   namespace,
-		RequestPath: req.Request.URL.Path,
+		ID:            id,
+		Namespace:     namespace,
+		RequestPath:   req.Request.URL.Path,
+		RequestMethod: req.Request.Method,
+		RequestToken:  req.Token,
+		RequestHeader: req.Request.Header,
+		LastRenewed:   time.Now().UTC(),
 	}
 
 	secret, err := api.ParseSecret(bytes.NewReader(resp.ResponseBody))
@@ -265,6 +326,9 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 		return nil, err
 	}
 	if !secret.Renewable && !tokenRenewable {
+		if c.cacheStaticSecret(req, resp, secret, staticGeneration) {
+			return resp, nil
+		}
 		c.logger.Debug("pass-through response; secret not renewable", "method", req.Request.Method, "path", req.Request.URL.Path)
 		return resp, nil
 	}
@@ -289,6 +353,7 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 
 		index.Lease = secret.LeaseID
 		index.LeaseToken = req.Token
+		index.Type = cacheboltdb.SecretLeaseType
 
 	case secret.Auth != nil:
 		c.logger.Debug("processing auth response", "method", req.Request.Method, "path", req.Request.URL.Path)
@@ -317,6 +382,7 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 		renewCtxInfo = c.createCtxInfo(parentCtx)
 		index.Token = secret.Auth.ClientToken
 		index.TokenAccessor = secret.Auth.Accessor
+		index.Type = cacheboltdb.AuthLeaseType
 
 	default:
 		// We shouldn't be hitting this, but will err on the side of caution and
@@ -359,6 +425,10 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 		c.logger.Error("failed to cache the proxied response", "error", err)
 		return nil, err
 	}
+	if err := c.persist(ctx, index); err != nil {
+		c.logger.Error("failed to persist the proxied response", "error", err)
+		return nil, err
+	}
 
 	// Start renewing the secret in the response
 	go c.startRenewing(renewCtx, index, req, secret)
@@ -366,6 +436,125 @@ func (c *LeaseCache) Send(ctx context.Context, req *SendRequest) (*SendResponse,
 	return resp, nil
 }
 
+// requestNamespace returns the namespace of a request from its header, with
+// exactly one trailing slash
+func requestNamespace(req *SendRequest) string {
+	namespace := canonicalNamespace(req.Request.Header.Get(consts.NamespaceHeaderName))
+	// We need to populate an empty value since go-memdb will skip over indexes
+	// that contain empty values.
+	if namespace == "" {
+		namespace = "root/"
+	}
+	return namespace
+}
+
+// checkStaticSecretCache returns the cached response of a read of a secret
+// without lease, if the requesting token is allowed to read it. Tokens are
+// checked against Vault once per refresh interval; if the check fails the
+// request is forwarded to Vault, which denies it.
+func (c *LeaseCache) checkStaticSecretCache(ctx context.Context, req *SendRequest) *SendResponse {
+	if c.staticSecrets == nil || req.Request.Method != http.MethodGet || req.Token == "" {
+		return nil
+	}
+
+	path := staticSecretPath(req.Request)
+	key, prefix := c.staticSecrets.key(requestNamespace(req), path)
+	if prefix == nil {
+		return nil
+	}
+
+	entry := c.staticSecrets.get(key, req.Request.URL.RawQuery)
+	if entry == nil {
+		return nil
+	}
+
+	tokenID := staticSecretTokenID(req.Token)
+	if !c.staticSecrets.allowed(entry, tokenID, prefix.RefreshInterval) {
+		allowed, err := c.checkStaticSecretCapability(req, path)
+		if err != nil {
+			c.logger.Warn("failed to check permissions for cached static secret, forwarding request", "path", req.Request.URL.Path, "error", err)
+			return nil
+		}
+		if !allowed {
+			c.logger.Debug("token not allowed to read cached static secret, forwarding request", "path", req.Request.URL.Path)
+			return nil
+		}
+		c.staticSecrets.allow(entry, tokenID)
+	}
+
+	sendResp, err := c.cachedResponse(entry.response)
+	if err != nil {
+		return nil
+	}
+	return sendResp
+}
+
+// checkStaticSecretCapability asks Vault whether the requesting token may
+// read or list the given path
+func (c *LeaseCache) checkStaticSecretCapability(req *SendRequest, path string) (bool, error) {
+	client, err := c.client.Clone()
+	if err != nil {
+		return false, err
+	}
+	client.SetToken(req.Token)
+	if namespace := req.Request.Header.Get(consts.NamespaceHeaderName); namespace != "" {
+		client.SetNamespace(namespace)
+	}
+
+	required := "read"
+	if list := req.Request.URL.Query().Get("list"); list == "true" {
+		required = "list"
+	}
+
+	capabilities, err := client.Sys().CapabilitiesSelf(path)
+	if err != nil {
+		return false, err
+	}
+	for _, capability := range capabilities {
+		if capability == required || capability == "root" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// cacheStaticSecret caches the response of a read of a secret without lease
+// under one of the configured prefixes, returning whether it was cached
+func (c *LeaseCache) cacheStaticSecret(req *SendRequest, resp *SendResponse, secret *api.Secret, generation uint64) bool {
+	if c.staticSecrets == nil || req.Request.Method != http.MethodGet || req.Token == "" {
+		return false
+	}
+	// Responses which are wrapped, carry a lease or a token are never shared
+	if secret.LeaseID != "" || secret.Auth != nil || secret.WrapInfo != nil {
+		return false
+	}
+
+	key, prefix := c.staticSecrets.key(requestNamespace(req), staticSecretPath(req.Request))
+	if prefix == nil {
+		return false
+	}
+
+	var respBytes bytes.Buffer
+	if err := resp.Response.Write(&respBytes); err != nil {
+		c.logger.Error("failed to serialize static secret response", "error", err)
+		return false
+	}
+
+	// Reset the response body for upper layers to read
+	if resp.Response.Body != nil {
+		resp.Response.Body.Close()
+	}
+	resp.Response.Body = ioutil.NopCloser(bytes.NewReader(resp.ResponseBody))
+
+	if !c.staticSecrets.set(generation, key, req.Request.URL.RawQuery, staticSecretTokenID(req.Token), respBytes.Bytes(), prefix.RefreshInterval) {
+		c.logger.Debug("not caching static secret written while it was read", "path", req.Request.URL.Path)
+		return false
+	}
+
+	c.logger.Debug("storing static secret response into the cache", "path", req.Request.URL.Path)
+	return true
+}
+
 func (c *LeaseCache) createCtxInfo(ctx context.Context) *cachememdb.ContextInfo {
 	if ctx == nil {
 		c.l.RLock()
@@ -384,6 +573,11 @@ func (c *LeaseCache) startRenewing(ctx context.Context, index *cachememdb.Index,
 			c.logger.Error("failed to evict index", "id", id, "error", err)
 			return
 		}
+		if c.ps != nil {
+			if err := c.ps.Delete(id); err != nil {
+				c.logger.Error("failed to delete index from persistent storage", "id", id, "error", err)
+			}
+		}
 	}()
 
 	client, err := c.client.Clone()
@@ -424,6 +618,12 @@ func (c *LeaseCache) startRenewing(ctx context.Context, index *cachememdb.Index,
 			return
 		case <-watcher.RenewCh():
 			c.logger.Debug("secret renewed", "path", req.Request.URL.Path)
+			if c.ps != nil {
+				index.LastRenewed = time.Now().UTC()
+				if err := c.persist(ctx, index); err != nil {
+					c.logger.Error("failed to persist renewed index", "error", err)
+				}
+			}
 		case <-index.RenewCtxInfo.DoneCh:
 			// This case indicates the renewal process to shutdown and evict
 			// the cache entry. This is triggered when a specific secret
@@ -516,6 +716,7 @@ func (c *LeaseCache) handleCacheClear(ctx context.Context, in *cacheClearInput)
 		// The first value provided for this case will be the namespace, but if it's
 		// an empty value we need to overwrite it with "root/" to ensure proper
 		// cache lookup.
+		in.Namespace = canonicalNamespace(in.Namespace)
 		if in.Namespace == "" {
 			in.Namespace = "root/"
 		}
@@ -529,6 +730,9 @@ func (c *LeaseCache) handleCacheClear(ctx context.Context, in *cacheClearInput)
 		for _, index := range indexes {
 			index.RenewCtxInfo.CancelFunc()
 		}
+		if c.staticSecrets != nil {
+			c.staticSecrets.invalidatePrefix(in.Namespace, in.RequestPath)
+		}
 
 	case "token":
 		if in.Token == "" {
@@ -604,6 +808,14 @@ func (c *LeaseCache) handleCacheClear(ctx context.Context, in *cacheClearInput)
 		if err := c.db.Flush(); err != nil {
 			return err
 		}
+		if c.ps != nil {
+			if err := c.ps.Clear(); err != nil {
+				return err
+			}
+		}
+		if c.staticSecrets != nil {
+			c.staticSecrets.clear()
+		}
 
 	default:
 		return errInvalidType
@@ -870,11 +1082,28 @@ func (c *LeaseCache) RegisterAutoAuthToken(token string) error {
 		return err
 	}
 
-	// If the index is found, defer its cancelFunc
-	if oldIndex != nil {
-		defer oldIndex.RenewCtxInfo.CancelFunc()
+	// If the token is already registered, such as when it was restored from
+	// persistent storage, keep the existing index so that the secrets derived
+	// from it are not evicted
+	if oldIndex == nil {
+		if err := c.registerAutoAuthToken(token); err != nil {
+			return err
+		}
 	}
 
+	// Wrap the key of the persistent storage with the new token, so that it
+	// can be retrieved after the next restart
+	if c.ps != nil {
+		if err := c.storeRetrievalToken(token); err != nil {
+			c.logger.Error("failed to store the persistent cache retrieval token", "error", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *LeaseCache) registerAutoAuthToken(token string) error {
 	// The following randomly generated values are required for index stored by
 	// the cache, but are not actually used. We use random values to prevent
 	// accidental access.
@@ -896,6 +1125,7 @@ func (c *LeaseCache) RegisterAutoAuthToken(token string) error {
 		Token:       token,
 		Namespace:   namespace,
 		RequestPath: requestPath,
+		Type:        cacheboltdb.TokenType,
 	}
 
 	// Derive a context off of the lease cache's base context
@@ -914,10 +1144,251 @@ func (c *LeaseCache) RegisterAutoAuthToken(token string) error {
 		c.logger.Error("failed to cache the auto-auth token", "error", err)
 		return err
 	}
+	if err := c.persist(context.Background(), index); err != nil {
+		c.logger.Error("failed to persist the auto-auth token", "error", err)
+		return err
+	}
 
 	return nil
 }
 
+// persist writes the index to the persistent storage, if configured
+func (c *LeaseCache) persist(ctx context.Context, index *cachememdb.Index) error {
+	if c.ps == nil {
+		return nil
+	}
+
+	indexBytes, err := index.Serialize()
+	if err != nil {
+		return err
+	}
+	return c.ps.Set(ctx, index.ID, indexBytes, index.Type)
+}
+
+// storeRetrievalToken response-wraps the key of the persistent storage using
+// the given token, and stores the resulting wrapping token alongside the
+// cache
+func (c *LeaseCache) storeRetrievalToken(token string) error {
+	client, err := c.client.Clone()
+	if err != nil {
+		return err
+	}
+	client.SetToken(token)
+	client.SetWrappingLookupFunc(func(string, string) string {
+		return c.retrievalTokenTTL.String()
+	})
+
+	secret, err := client.Logical().Write("sys/wrapping/wrap", map[string]interface{}{
+		"key": base64.StdEncoding.EncodeToString(c.ps.Key()),
+	})
+	if err != nil {
+		return err
+	}
+	if secret == nil || secret.WrapInfo == nil || secret.WrapInfo.Token == "" {
+		return errors.New("no wrapping token returned")
+	}
+
+	return c.ps.StoreRetrievalToken([]byte(secret.WrapInfo.Token))
+}
+
+// RetrievePersistentKey unwraps the retrieval token stored alongside a
+// persistent cache, returning the key the cache is encrypted with. The
+// retrieval token can only be used once.
+func RetrievePersistentKey(client *api.Client, retrievalToken string) ([]byte, error) {
+	client, err := client.Clone()
+	if err != nil {
+		return nil, err
+	}
+	client.SetToken("")
+
+	secret, err := client.Logical().Unwrap(retrievalToken)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to unwrap the retrieval token: {{err}}", err)
+	}
+	if secret == nil || secret.Data == nil {
+		return nil, errors.New("no key found behind the retrieval token")
+	}
+	keyRaw, ok := secret.Data["key"].(string)
+	if !ok {
+		return nil, errors.New("no key found behind the retrieval token")
+	}
+	return base64.StdEncoding.DecodeString(keyRaw)
+}
+
+// Restore loads the indexes held by the persistent storage into the cache and
+// resumes renewing their secrets. Indexes whose secrets have expired, or
+// whose parent token is no longer cached, are removed from the storage. The
+// auto-auth token that was in use is returned, if any, so that the agent can
+// keep using it.
+func (c *LeaseCache) Restore(ctx context.Context) (string, error) {
+	if c.ps == nil {
+		return "", errors.New("no persistent storage configured")
+	}
+
+	var autoAuthToken string
+	tokens, err := c.ps.GetByType(ctx, cacheboltdb.TokenType)
+	if err != nil {
+		return "", err
+	}
+	for _, indexBytes := range tokens {
+		index, err := cachememdb.Deserialize(indexBytes)
+		if err != nil {
+			return "", err
+		}
+		index.RenewCtxInfo = c.createCtxInfo(nil)
+		if err := c.db.Set(index); err != nil {
+			return "", err
+		}
+		autoAuthToken = index.Token
+	}
+
+	// Tokens are restored before the leases belonging to them, and parent
+	// tokens before their children
+	authLeases, err := c.restorableIndexes(ctx, cacheboltdb.AuthLeaseType)
+	if err != nil {
+		return "", err
+	}
+	for len(authLeases) > 0 {
+		var pending []*restorableIndex
+		for _, entry := range authLeases {
+			if entry.secret.Auth.Orphan {
+				c.restoreIndex(entry, nil)
+				continue
+			}
+			parent, err := c.db.Get(cachememdb.IndexNameToken, entry.index.RequestToken)
+			if err != nil {
+				return "", err
+			}
+			if parent != nil {
+				c.restoreIndex(entry, parent.RenewCtxInfo.Ctx)
+				continue
+			}
+			pending = append(pending, entry)
+		}
+
+		// The parents of the remaining tokens are not cached
+		if len(pending) == len(authLeases) {
+			for _, entry := range pending {
+				c.dropIndex(entry.index, "parent token not cached")
+			}
+			break
+		}
+		authLeases = pending
+	}
+
+	secretLeases, err := c.restorableIndexes(ctx, cacheboltdb.SecretLeaseType)
+	if err != nil {
+		return "", err
+	}
+	for _, entry := range secretLeases {
+		parent, err := c.db.Get(cachememdb.IndexNameToken, entry.index.RequestToken)
+		if err != nil {
+			return "", err
+		}
+		if parent == nil {
+			c.dropIndex(entry.index, "token not cached")
+			continue
+		}
+		c.restoreIndex(entry, parent.RenewCtxInfo.Ctx)
+	}
+
+	return autoAuthToken, nil
+}
+
+type restorableIndex struct {
+	index  *cachememdb.Index
+	secret *api.Secret
+}
+
+// restorableIndexes returns the stored indexes of the given type whose
+// secrets have not expired
+func (c *LeaseCache) restorableIndexes(ctx context.Context, indexType string) ([]*restorableIndex, error) {
+	indexes, err := c.ps.GetByType(ctx, indexType)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*restorableIndex
+	for _, indexBytes := range indexes {
+		index, err := cachememdb.Deserialize(indexBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(index.Response)), nil)
+		if err != nil {
+			c.dropIndex(index, "failed to deserialize response")
+			continue
+		}
+		secret, err := api.ParseSecret(resp.Body)
+		resp.Body.Close()
+		if err != nil || secret == nil {
+			c.dropIndex(index, "failed to parse secret")
+			continue
+		}
+
+		ttl := time.Duration(secret.LeaseDuration) * time.Second
+		if secret.Auth != nil {
+			ttl = time.Duration(secret.Auth.LeaseDuration) * time.Second
+		}
+		if ttl > 0 && time.Now().After(index.LastRenewed.Add(ttl)) {
+			c.dropIndex(index, "secret expired")
+			continue
+		}
+
+		entries = append(entries, &restorableIndex{
+			index:  index,
+			secret: secret,
+		})
+	}
+	return entries, nil
+}
+
+// restoreIndex caches a restored index and resumes renewing its secret,
+// deriving its context from the given parent context
+func (c *LeaseCache) restoreIndex(entry *restorableIndex, parentCtx context.Context) {
+	index := entry.index
+
+	req, err := http.NewRequest(index.RequestMethod, index.RequestPath, nil)
+	if err != nil {
+		c.dropIndex(index, "failed to build request")
+		return
+	}
+	req.Header = index.RequestHeader
+	if req.Header == nil {
+		req.Header = make(http.Header)
+	}
+	sendReq := &SendRequest{
+		Token:   index.RequestToken,
+		Request: req,
+	}
+
+	renewCtxInfo := c.createCtxInfo(parentCtx)
+	renewCtx := context.WithValue(renewCtxInfo.Ctx, contextIndexID, index.ID)
+	index.RenewCtxInfo = &cachememdb.ContextInfo{
+		Ctx:        renewCtx,
+		CancelFunc: renewCtxInfo.CancelFunc,
+		DoneCh:     renewCtxInfo.DoneCh,
+	}
+
+	if err := c.db.Set(index); err != nil {
+		c.logger.Error("failed to cache restored index", "id", index.ID, "error", err)
+		return
+	}
+
+	c.logger.Debug("restored index from persistent storage", "method", index.RequestMethod, "path", index.RequestPath)
+	go c.startRenewing(renewCtx, index, sendReq, entry.secret)
+}
+
+// dropIndex removes an index which can't be restored from the persistent
+// storage
+func (c *LeaseCache) dropIndex(index *cachememdb.Index, reason string) {
+	c.logger.Debug("not restoring index", "id", index.ID, "path", index.RequestPath, "reason", reason)
+	if err := c.ps.Delete(index.ID); err != nil {
+		c.logger.Error("failed to delete index from persistent storage", "id", index.ID, "error", err)
+	}
+}
+
 type cacheClearInput struct {
 	Type string
 
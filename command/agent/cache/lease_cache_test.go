@@ -1,15 +1,22 @@
 package cache
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"os"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/hashicorp/vault/command/agent/cache/cacheboltdb"
 	"github.com/hashicorp/vault/command/agent/cache/cachememdb"
 
 	"github.com/go-test/deep"
@@ -509,3 +516,363 @@ func TestCache_DeriveNamespaceAndRevocationPath(t *testing.T) {
 		})
 	}
 }
+
+// testPersistVault emulates the Vault endpoints used to wrap the key of the
+// persistent cache and to renew restored secrets
+type testPersistVault struct {
+	sync.Mutex
+	key     string
+	wrapTTL string
+}
+
+func (v *testPersistVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.Lock()
+	defer v.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/v1/sys/wrapping/wrap":
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		v.key = body["key"]
+		v.wrapTTL = r.Header.Get("X-Vault-Wrap-TTL")
+		fmt.Fprint(w, `{"wrap_info": {"token": "retrieval-token", "ttl": 86400}}`)
+	case "/v1/sys/wrapping/unwrap":
+		if r.Header.Get(consts.AuthHeaderName) != "retrieval-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"data": {"key": %q}}`, v.key)
+	case "/v1/sys/leases/renew":
+		fmt.Fprint(w, `{"lease_id": "foo", "renewable": true, "lease_duration": 3600}`)
+	case "/v1/auth/token/renew-self":
+		fmt.Fprint(w, `{"auth": {"client_token": "testtoken", "renewable": true, "lease_duration": 3600}}`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func testPersistentLeaseCache(t *testing.T, ctx context.Context, client *api.Client, ps *cacheboltdb.BoltStorage, responses []*SendResponse) *LeaseCache {
+	t.Helper()
+
+	lc, err := NewLeaseCache(&LeaseCacheConfig{
+		Client:      client,
+		BaseContext: ctx,
+		Proxier:     newMockProxier(responses),
+		Logger:      logging.NewVaultLogger(hclog.Trace).Named("cache.leasecache"),
+		Storage:     ps,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return lc
+}
+
+func TestLeaseCache_PersistAndRestore(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	vault := &testPersistVault{}
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := ioutil.TempDir("", "vault-agent-cache-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(path)
+
+	key, err := cacheboltdb.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps, err := cacheboltdb.NewBoltStorage(&cacheboltdb.BoltStorageConfig{
+		Path: path,
+		Key:  key,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responses := []*SendResponse{
+		newTestSendResponse(http.StatusCreated, `{"auth": {"client_token": "testtoken", "renewable": true, "lease_duration": 3600}}`),
+		newTestSendResponse(http.StatusOK, `{"lease_id": "foo", "renewable": true, "lease_duration": 3600, "data": {"value": "foo"}}`),
+	}
+	lc := testPersistentLeaseCache(t, ctx, client, ps, responses)
+
+	// Registering the auto-auth token wraps the key of the cache
+	if err := lc.RegisterAutoAuthToken("autoauthtoken"); err != nil {
+		t.Fatal(err)
+	}
+	if vault.wrapTTL != "24h0m0s" {
+		t.Fatalf("unexpected wrap TTL %q", vault.wrapTTL)
+	}
+
+	// Create a child token of the auto-auth token, and a lease belonging to
+	// the child token
+	_, err = lc.Send(ctx, &SendRequest{
+		Token:   "autoauthtoken",
+		Request: httptest.NewRequest("POST", "http://example.com/v1/auth/token/create", strings.NewReader(`{"policies": ["default"]}`)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaseReq := func() *SendRequest {
+		return &SendRequest{
+			Token:   "testtoken",
+			Request: httptest.NewRequest("GET", "http://example.com/v1/database/creds/foo", nil),
+		}
+	}
+	if _, err := lc.Send(ctx, leaseReq()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Store an expired lease, which should not be restored
+	var expired bytes.Buffer
+	expiredResp := &http.Response{
+		StatusCode: http.StatusOK,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(strings.NewReader(`{"lease_id": "bar", "renewable": true, "lease_duration": 60}`)),
+	}
+	if err := expiredResp.Write(&expired); err != nil {
+		t.Fatal(err)
+	}
+	expiredIndex := &cachememdb.Index{
+		ID:            "expired",
+		Namespace:     "root/",
+		RequestPath:   "/v1/database/creds/bar",
+		RequestMethod: "GET",
+		RequestToken:  "testtoken",
+		Lease:         "bar",
+		LeaseToken:    "testtoken",
+		Response:      expired.Bytes(),
+		Type:          cacheboltdb.SecretLeaseType,
+		LastRenewed:   time.Now().Add(-time.Hour),
+	}
+	expiredBytes, err := expiredIndex.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Set(ctx, expiredIndex.ID, expiredBytes, expiredIndex.Type); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a restart
+	cancel()
+	if err := ps.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	retrievalToken, err := cacheboltdb.GetRetrievalToken(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoredKey, err := RetrievePersistentKey(client, string(retrievalToken))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(restoredKey, key) {
+		t.Fatal("retrieved key does not match")
+	}
+
+	ps, err = cacheboltdb.NewBoltStorage(&cacheboltdb.BoltStorageConfig{
+		Path: path,
+		Key:  restoredKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Close()
+
+	// The restored cache must not forward requests for cached secrets
+	lc = testPersistentLeaseCache(t, ctx, client, ps, nil)
+	token, err := lc.Restore(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "autoauthtoken" {
+		t.Fatalf("unexpected restored auto-auth token %q", token)
+	}
+
+	for _, lookup := range []struct {
+		indexName string
+		value     string
+	}{
+		{cachememdb.IndexNameToken, "autoauthtoken"},
+		{cachememdb.IndexNameToken, "testtoken"},
+		{cachememdb.IndexNameLease, "foo"},
+	} {
+		index, err := lc.db.Get(lookup.indexName, lookup.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if index == nil {
+			t.Fatalf("expected %s %q to be restored", lookup.indexName, lookup.value)
+		}
+	}
+
+	index, err := lc.db.Get(cachememdb.IndexNameLease, "bar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index != nil {
+		t.Fatal("expected expired lease not to be restored")
+	}
+	leases, err := ps.GetByType(ctx, cacheboltdb.SecretLeaseType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leases) != 1 {
+		t.Fatalf("expected expired lease to be removed from storage, found %d leases", len(leases))
+	}
+
+	resp, err := lc.Send(ctx, leaseReq())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.CacheMeta.Hit {
+		t.Fatal("expected restored response to be served from the cache")
+	}
+}
+
+// testCapabilitiesVault emulates the capabilities-self endpoint, granting
+// read on every path to the tokens in allowed
+type testCapabilitiesVault struct {
+	sync.Mutex
+	allowed map[string]bool
+	checks  int
+}
+
+func (v *testCapabilitiesVault) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v.Lock()
+	defer v.Unlock()
+
+	if r.URL.Path != "/v1/sys/capabilities-self" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	v.checks++
+
+	capabilities := []string{"deny"}
+	if v.allowed[r.Header.Get(consts.AuthHeaderName)] {
+		capabilities = []string{"read"}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": map[string]interface{}{
+			"capabilities": capabilities,
+		},
+	})
+}
+
+func TestLeaseCache_StaticSecrets(t *testing.T) {
+	ctx := context.Background()
+
+	vault := &testCapabilitiesVault{
+		allowed: map[string]bool{"token1": true, "token2": true},
+	}
+	server := httptest.NewServer(vault)
+	defer server.Close()
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	responses := []*SendResponse{
+		newTestSendResponse(http.StatusOK, `{"data": {"value": "foo"}}`),
+		newTestSendResponse(http.StatusOK, `{"data": {"value": "other"}}`),
+		newTestSendResponse(http.StatusOK, `{"data": {"value": "other"}}`),
+		newTestSendResponse(http.StatusNoContent, ""),
+		newTestSendResponse(http.StatusOK, `{"data": {"value": "bar"}}`),
+	}
+	proxier := newMockProxier(responses)
+	lc, err := NewLeaseCache(&LeaseCacheConfig{
+		Client:      client,
+		BaseContext: ctx,
+		Proxier:     proxier,
+		Logger:      logging.NewVaultLogger(hclog.Trace).Named("cache.leasecache"),
+		StaticSecrets: []*StaticSecretConfig{
+			{PathPrefix: "secret/", RefreshInterval: time.Hour},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(token, method, path string) *SendResponse {
+		t.Helper()
+		resp, err := lc.Send(ctx, &SendRequest{
+			Token:   token,
+			Request: httptest.NewRequest(method, "http://example.com/v1/"+path, nil),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	expectBody := func(resp *SendResponse, body string) {
+		t.Helper()
+		if string(resp.ResponseBody) != body {
+			t.Fatalf("expected body %q, got %q", body, resp.ResponseBody)
+		}
+	}
+
+	// The first read is proxied and cached, the second is served from the
+	// cache without checking the permissions of the token that read it
+	expectBody(send("token1", "GET", "secret/foo"), `{"data": {"value": "foo"}}`)
+	resp := send("token1", "GET", "secret/foo")
+	expectBody(resp, `{"data": {"value": "foo"}}`)
+	if !resp.CacheMeta.Hit {
+		t.Fatal("expected a cache hit")
+	}
+	if vault.checks != 0 {
+		t.Fatalf("expected no capability checks, got %d", vault.checks)
+	}
+
+	// Another allowed token is served from the cache once checked
+	if resp := send("token2", "GET", "secret/foo"); !resp.CacheMeta.Hit {
+		t.Fatal("expected a cache hit")
+	}
+	if vault.checks != 1 {
+		t.Fatalf("expected 1 capability check, got %d", vault.checks)
+	}
+
+	// A token without permission is forwarded to Vault
+	if resp := send("token3", "GET", "secret/foo"); resp.CacheMeta != nil && resp.CacheMeta.Hit {
+		t.Fatal("expected the request of a denied token to be forwarded")
+	}
+
+	// Paths outside of the prefixes aren't cached
+	send("token1", "GET", "other/foo")
+	if proxier.ResponseIndex() != 3 {
+		t.Fatalf("expected 3 proxied requests, got %d", proxier.ResponseIndex())
+	}
+
+	// Writing the secret evicts it
+	send("token1", "PUT", "secret/foo")
+	resp = send("token1", "GET", "secret/foo")
+	expectBody(resp, `{"data": {"value": "bar"}}`)
+	if resp.CacheMeta != nil && resp.CacheMeta.Hit {
+		t.Fatal("expected the read after the write to be forwarded")
+	}
+	if resp := send("token1", "GET", "secret/foo"); !resp.CacheMeta.Hit {
+		t.Fatal("expected a cache hit")
+	}
+}
@@ -0,0 +1,274 @@
+package cache
+
+import (
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/cryptoutil"
+)
+
+const defaultStaticSecretRefreshInterval = 5 * time.Minute
+
+// kvV2Segments are the path segments of the KV version 2 endpoints which
+// affect the same secret, for invalidating cached reads on writes
+var kvV2Segments = map[string]bool{
+	"data":     true,
+	"metadata": true,
+	"delete":   true,
+	"undelete": true,
+	"destroy":  true,
+}
+
+// StaticSecretConfig enables caching of the secrets without leases, such as
+// KV secrets, read under a path prefix
+type StaticSecretConfig struct {
+	// PathPrefix is matched against request paths, without the leading /v1/,
+	// within any namespace
+	PathPrefix string
+
+	// RefreshInterval is how long a cached secret is served before being read
+	// from Vault again. The permissions of the tokens it is served to are
+	// re-checked at the same interval.
+	RefreshInterval time.Duration
+}
+
+// staticSecretCache holds the cached responses of secrets without leases.
+// Unlike leased secrets, they are shared between all the tokens allowed to
+// read them.
+type staticSecretCache struct {
+	l        sync.Mutex
+	prefixes []*StaticSecretConfig
+
+	// entries are keyed by secret, then by query string
+	entries map[staticSecretKey]map[string]*staticSecretEntry
+
+	// generation is incremented on every invalidation, so that responses read
+	// from Vault while a secret was being written aren't cached
+	generation uint64
+}
+
+type staticSecretEntry struct {
+	response []byte
+	expires  time.Time
+
+	// allowed holds the hashes of the tokens known to be allowed to read the
+	// secret, along with when they were checked
+	allowed map[string]time.Time
+}
+
+func newStaticSecretCache(configs []*StaticSecretConfig) *staticSecretCache {
+	prefixes := make([]*StaticSecretConfig, 0, len(configs))
+	for _, config := range configs {
+		prefix := &StaticSecretConfig{
+			PathPrefix:      strings.TrimPrefix(config.PathPrefix, "/"),
+			RefreshInterval: config.RefreshInterval,
+		}
+		if prefix.RefreshInterval <= 0 {
+			prefix.RefreshInterval = defaultStaticSecretRefreshInterval
+		}
+		prefixes = append(prefixes, prefix)
+	}
+
+	// Match the most specific prefix first
+	sort.SliceStable(prefixes, func(i, j int) bool {
+		return len(prefixes[i].PathPrefix) > len(prefixes[j].PathPrefix)
+	})
+
+	return &staticSecretCache{
+		prefixes: prefixes,
+		entries:  make(map[staticSecretKey]map[string]*staticSecretEntry),
+	}
+}
+
+// staticSecretPath returns the path of a request, without the leading /v1/
+func staticSecretPath(req *http.Request) string {
+	return strings.TrimPrefix(req.URL.Path, "/v1/")
+}
+
+// staticSecretKey identifies a cached secret by its namespace, which is
+// empty or ends with a single slash, and its path within the namespace
+type staticSecretKey struct {
+	namespace string
+	path      string
+}
+
+// canonicalNamespace returns the namespace with exactly one trailing slash, or
+// an empty string for the root namespace, including the "root/" placeholder
+// used in the cache indexes
+func canonicalNamespace(namespace string) string {
+	namespace = strings.Trim(namespace, "/")
+	if namespace == "" || namespace == "root" {
+		return ""
+	}
+	return namespace + "/"
+}
+
+// key returns the key of the secret at the given path within the namespace,
+// along with the configuration of the prefix it falls under. Requests may
+// give namespaces in the path as well as in the header, so the leading path
+// segments before a configured prefix are folded into the namespace: the same
+// secret has the same key however the request splits its namespace. If the
+// secret isn't under any prefix the returned configuration is nil.
+func (s *staticSecretCache) key(namespace, path string) (staticSecretKey, *StaticSecretConfig) {
+	fullPath := canonicalNamespace(namespace) + strings.TrimPrefix(path, "/")
+	for i := 0; i < len(fullPath); i++ {
+		if i > 0 && fullPath[i-1] != '/' {
+			continue
+		}
+		if prefix := s.match(fullPath[i:]); prefix != nil {
+			return staticSecretKey{namespace: fullPath[:i], path: fullPath[i:]}, prefix
+		}
+	}
+	return staticSecretKey{}, nil
+}
+
+// staticSecretTokenID returns the hash under which a token is recorded as
+// allowed to read a secret
+func staticSecretTokenID(token string) string {
+	return hex.EncodeToString(cryptoutil.Blake2b256Hash(token))
+}
+
+// isWriteMethod returns whether a request may modify the secret at its path
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// match returns the configuration of the prefix the path falls under, if any
+func (s *staticSecretCache) match(path string) *StaticSecretConfig {
+	for _, prefix := range s.prefixes {
+		if strings.HasPrefix(path, prefix.PathPrefix) {
+			return prefix
+		}
+	}
+	return nil
+}
+
+// currentGeneration returns the generation to pass to set when caching a
+// response read from Vault
+func (s *staticSecretCache) currentGeneration() uint64 {
+	s.l.Lock()
+	defer s.l.Unlock()
+	return s.generation
+}
+
+// get returns the cached entry for a request, if it hasn't expired
+func (s *staticSecretCache) get(key staticSecretKey, query string) *staticSecretEntry {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	entry := s.entries[key][query]
+	if entry == nil {
+		return nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(s.entries[key], query)
+		if len(s.entries[key]) == 0 {
+			delete(s.entries, key)
+		}
+		return nil
+	}
+	return entry
+}
+
+// set caches a response read by the given token, unless the secret may have
+// been written since the given generation
+func (s *staticSecretCache) set(generation uint64, key staticSecretKey, query, tokenID string, response []byte, refreshInterval time.Duration) bool {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	if generation != s.generation {
+		return false
+	}
+
+	now := time.Now()
+	if s.entries[key] == nil {
+		s.entries[key] = make(map[string]*staticSecretEntry)
+	}
+	s.entries[key][query] = &staticSecretEntry{
+		response: response,
+		expires:  now.Add(refreshInterval),
+		allowed: map[string]time.Time{
+			tokenID: now,
+		},
+	}
+	return true
+}
+
+// allowed returns whether the token was checked to be allowed to read the
+// secret within the refresh interval
+func (s *staticSecretCache) allowed(entry *staticSecretEntry, tokenID string, refreshInterval time.Duration) bool {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	checked, ok := entry.allowed[tokenID]
+	return ok && time.Since(checked) < refreshInterval
+}
+
+// allow records the token as allowed to read the secret
+func (s *staticSecretCache) allow(entry *staticSecretEntry, tokenID string) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	entry.allowed[tokenID] = time.Now()
+}
+
+// invalidate evicts the cached secret written at the given path. For KV
+// version 2 mounts, writing to any of the data, metadata, delete, undelete
+// and destroy endpoints evicts the cached data and metadata of the secret.
+func (s *staticSecretCache) invalidate(namespace, path string) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.generation++
+	key, prefix := s.key(namespace, path)
+	if prefix == nil {
+		return
+	}
+	delete(s.entries, key)
+
+	segments := strings.Split(key.path, "/")
+	for i := 1; i < len(segments); i++ {
+		if !kvV2Segments[segments[i]] {
+			continue
+		}
+		for _, variant := range []string{"data", "metadata"} {
+			variantSegments := append([]string(nil), segments...)
+			variantSegments[i] = variant
+			delete(s.entries, staticSecretKey{
+				namespace: key.namespace,
+				path:      strings.Join(variantSegments, "/"),
+			})
+		}
+	}
+}
+
+// invalidatePrefix evicts the cached secrets under the given path prefix
+func (s *staticSecretCache) invalidatePrefix(namespace, prefix string) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.generation++
+	fullPrefix := canonicalNamespace(namespace) + strings.TrimPrefix(prefix, "/v1/")
+	for key := range s.entries {
+		if strings.HasPrefix(key.namespace+key.path, fullPrefix) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// clear evicts all the cached secrets
+func (s *staticSecretCache) clear() {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	s.generation++
+	s.entries = make(map[staticSecretKey]map[string]*staticSecretEntry)
+}
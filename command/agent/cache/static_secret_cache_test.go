@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStaticSecretCache_Match(t *testing.T) {
+	s := newStaticSecretCache([]*StaticSecretConfig{
+		{PathPrefix: "/secret/"},
+		{PathPrefix: "secret/app/", RefreshInterval: time.Minute},
+	})
+
+	if prefix := s.match("secret/app/foo"); prefix == nil || prefix.RefreshInterval != time.Minute {
+		t.Fatalf("expected the most specific prefix to match, got %#v", prefix)
+	}
+	if prefix := s.match("secret/foo"); prefix == nil || prefix.RefreshInterval != defaultStaticSecretRefreshInterval {
+		t.Fatalf("expected the default refresh interval, got %#v", prefix)
+	}
+	if prefix := s.match("kv/foo"); prefix != nil {
+		t.Fatalf("expected no match, got %#v", prefix)
+	}
+}
+
+// testStaticSecretKey returns the key of a secret in the root namespace
+func testStaticSecretKey(s *staticSecretCache, path string) staticSecretKey {
+	key, _ := s.key("root/", path)
+	return key
+}
+
+func TestStaticSecretCache_Key(t *testing.T) {
+	s := newStaticSecretCache([]*StaticSecretConfig{{PathPrefix: "secret/"}})
+
+	cases := []struct {
+		namespace string
+		path      string
+		expected  staticSecretKey
+	}{
+		{"", "secret/foo", staticSecretKey{"", "secret/foo"}},
+		{"root/", "secret/foo", staticSecretKey{"", "secret/foo"}},
+		{"", "ns1/ns2/secret/foo", staticSecretKey{"ns1/ns2/", "secret/foo"}},
+		{"ro", "ot/secret/foo", staticSecretKey{"ro/ot/", "secret/foo"}},
+		{"eng", "secret/foo", staticSecretKey{"eng/", "secret/foo"}},
+		{"eng/", "secret/foo", staticSecretKey{"eng/", "secret/foo"}},
+		{"/eng//", "/secret/foo", staticSecretKey{"eng/", "secret/foo"}},
+		{"", "eng/secret/foo", staticSecretKey{"eng/", "secret/foo"}},
+		{"eng", "team/secret/foo", staticSecretKey{"eng/team/", "secret/foo"}},
+		{"eng/team", "secret/foo", staticSecretKey{"eng/team/", "secret/foo"}},
+	}
+	for _, tc := range cases {
+		key, prefix := s.key(tc.namespace, tc.path)
+		if prefix == nil {
+			t.Fatalf("%q %q: expected a prefix to match", tc.namespace, tc.path)
+		}
+		if key != tc.expected {
+			t.Fatalf("%q %q: expected %#v, got %#v", tc.namespace, tc.path, tc.expected, key)
+		}
+	}
+
+	for _, path := range []string{"other/foo", "eng/secretfoo", "eng/other/foo"} {
+		if _, prefix := s.key("", path); prefix != nil {
+			t.Fatalf("%q: expected no match, got %#v", path, prefix)
+		}
+	}
+
+	// A secret cached through the header namespace is evicted by a write
+	// giving the namespace in the path
+	key, _ := s.key("eng", "secret/foo")
+	s.set(s.currentGeneration(), key, "", "token", nil, time.Hour)
+	s.invalidate("root/", "eng/secret/foo")
+	if s.get(key, "") != nil {
+		t.Fatal("expected eng/secret/foo to be evicted")
+	}
+
+	s.set(s.currentGeneration(), key, "", "token", nil, time.Hour)
+	s.invalidatePrefix("eng", "/v1/secret/")
+	if s.get(key, "") != nil {
+		t.Fatal("expected eng/secret/foo to be evicted by its prefix")
+	}
+}
+
+func TestStaticSecretCache_Invalidate(t *testing.T) {
+	s := newStaticSecretCache([]*StaticSecretConfig{{PathPrefix: "kv/"}})
+
+	set := func(path string) {
+		t.Helper()
+		if !s.set(s.currentGeneration(), testStaticSecretKey(s, path), "", "token", []byte(path), time.Hour) {
+			t.Fatalf("expected %q to be cached", path)
+		}
+	}
+	set("kv/data/foo")
+	set("kv/metadata/foo")
+	set("kv/data/bar")
+
+	// Deleting a version of a KV v2 secret evicts its data and metadata
+	s.invalidate("root/", "kv/delete/foo")
+	if s.get(testStaticSecretKey(s, "kv/data/foo"), "") != nil {
+		t.Fatal("expected kv/data/foo to be evicted")
+	}
+	if s.get(testStaticSecretKey(s, "kv/metadata/foo"), "") != nil {
+		t.Fatal("expected kv/metadata/foo to be evicted")
+	}
+	if s.get(testStaticSecretKey(s, "kv/data/bar"), "") == nil {
+		t.Fatal("expected kv/data/bar to be kept")
+	}
+
+	// A response read before a write isn't cached
+	generation := s.currentGeneration()
+	s.invalidate("root/", "kv/data/bar")
+	if s.set(generation, testStaticSecretKey(s, "kv/data/bar"), "", "token", nil, time.Hour) {
+		t.Fatal("expected a response read before a write not to be cached")
+	}
+
+	set("kv/data/baz")
+	s.invalidatePrefix("root/", "/v1/kv/data/")
+	if s.get(testStaticSecretKey(s, "kv/data/baz"), "") != nil {
+		t.Fatal("expected kv/data/baz to be evicted")
+	}
+}
+
+func TestStaticSecretCache_Expiry(t *testing.T) {
+	s := newStaticSecretCache([]*StaticSecretConfig{{PathPrefix: "secret/"}})
+	key := testStaticSecretKey(s, "secret/foo")
+
+	s.set(s.currentGeneration(), key, "", "token1", nil, -time.Second)
+	if s.get(key, "") != nil {
+		t.Fatal("expected an expired entry not to be returned")
+	}
+
+	s.set(s.currentGeneration(), key, "", "token1", nil, time.Hour)
+	entry := s.get(key, "")
+	if entry == nil {
+		t.Fatal("expected an entry")
+	}
+	if !s.allowed(entry, "token1", time.Hour) {
+		t.Fatal("expected the token that read the secret to be allowed")
+	}
+	if s.allowed(entry, "token2", time.Hour) {
+		t.Fatal("expected an unchecked token not to be allowed")
+	}
+	s.allow(entry, "token2")
+	if !s.allowed(entry, "token2", time.Hour) {
+		t.Fatal("expected a checked token to be allowed")
+	}
+	if s.allowed(entry, "token2", 0) {
+		t.Fatal("expected the check to expire with the refresh interval")
+	}
+}
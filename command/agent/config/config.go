@@ -9,6 +9,7 @@ import (
 	"time"
 
 	ctconfig "github.com/hashicorp/consul-template/config"
+	"github.com/hashicorp/consul-template/signals"
 	"github.com/hashicorp/errwrap"
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/hcl"
@@ -28,6 +29,8 @@ type Config struct {
 	Cache         *Cache                     `hcl:"cache"`
 	Vault         *Vault                     `hcl:"vault"`
 	Templates     []*ctconfig.TemplateConfig `hcl:"templates"`
+	EnvTemplates  []*EnvTemplate             `hcl:"-"`
+	Exec          *Exec                      `hcl:"exec"`
 }
 
 // Vault contains configuration for connecting to Vault servers
@@ -47,12 +50,71 @@ type Cache struct {
 	UseAutoAuthTokenRaw interface{} `hcl:"use_auto_auth_token"`
 	UseAutoAuthToken    bool        `hcl:"-"`
 	ForceAutoAuthToken  bool        `hcl:"-"`
+	Persist             *Persist    `hcl:"persist"`
+
+	StaticSecrets []*StaticSecret `hcl:"-"`
+}
+
+// Persist contains the configuration for persisting the cache to disk, so
+// that it survives restarts of the agent
+type Persist struct {
+	Path                 string        `hcl:"path"`
+	ExitOnErr            bool          `hcl:"exit_on_err"`
+	RetrievalTokenTTLRaw interface{}   `hcl:"retrieval_token_ttl"`
+	RetrievalTokenTTL    time.Duration `hcl:"-"`
+}
+
+// StaticSecret enables caching of the secrets without leases read under a
+// path prefix, such as KV secrets
+type StaticSecret struct {
+	PathPrefix         string        `hcl:"path_prefix"`
+	RefreshIntervalRaw interface{}   `hcl:"refresh_interval"`
+	RefreshInterval    time.Duration `hcl:"-"`
 }
 
+// EnvTemplate is a template rendered into an environment variable of the
+// child process started in exec mode
+type EnvTemplate struct {
+	Name     string
+	Template *ctconfig.TemplateConfig
+}
+
+// Exec contains the configuration for running a child process with the env
+// templates as its environment
+type Exec struct {
+	Command                []string      `hcl:"command"`
+	RestartOnSecretChanges string        `hcl:"restart_on_secret_changes"`
+	RestartStopSignalRaw   string        `hcl:"restart_stop_signal"`
+	RestartStopSignal      os.Signal     `hcl:"-"`
+	ReloadSignalRaw        string        `hcl:"reload_signal"`
+	ReloadSignal           os.Signal     `hcl:"-"`
+	KillTimeoutRaw         interface{}   `hcl:"kill_timeout"`
+	KillTimeout            time.Duration `hcl:"-"`
+}
+
+const (
+	// ExecRestartAlways restarts the child process whenever the rendered
+	// environment changes
+	ExecRestartAlways = "always"
+
+	// ExecRestartNever leaves the child process running with the environment
+	// it was started with
+	ExecRestartNever = "never"
+
+	// defaultMethodMaxRetries is the number of retries of an auth method
+	// before failing over to the next one, when several are configured
+	defaultMethodMaxRetries = 3
+
+	defaultExecKillTimeout = 30 * time.Second
+)
+
 // AutoAuth is the configured authentication method and sinks
 type AutoAuth struct {
-	Method *Method `hcl:"-"`
-	Sinks  []*Sink `hcl:"sinks"`
+	// Method is the first of the configured methods, which is used until it
+	// exhausts its retries and the agent fails over to the next one
+	Method  *Method   `hcl:"-"`
+	Methods []*Method `hcl:"-"`
+	Sinks   []*Sink   `hcl:"sinks"`
 
 	// NOTE: This is unsupported outside of testing and may disappear at any
 	// time.
@@ -67,6 +129,15 @@ type Method struct {
 	WrapTTL    time.Duration `hcl:"-"`
 	Namespace  string        `hcl:"namespace"`
 	Config     map[string]interface{}
+
+	// MaxRetries is the number of consecutive failed attempts after which
+	// the agent fails over to the next method, zero retrying forever.
+	// MinBackoff doubles with every failed attempt, up to MaxBackoff.
+	MaxRetries    int           `hcl:"max_retries"`
+	MinBackoffRaw interface{}   `hcl:"min_backoff"`
+	MinBackoff    time.Duration `hcl:"-"`
+	MaxBackoffRaw interface{}   `hcl:"max_backoff"`
+	MaxBackoff    time.Duration `hcl:"-"`
 }
 
 // Sink defines a location to write the authenticated token
@@ -141,6 +212,31 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, errwrap.Wrapf("error parsing 'template': {{err}}", err)
 	}
 
+	if err := parseEnvTemplates(result, list); err != nil {
+		return nil, errwrap.Wrapf("error parsing 'env_template': {{err}}", err)
+	}
+
+	if err := parseExec(result, list); err != nil {
+		return nil, errwrap.Wrapf("error parsing 'exec': {{err}}", err)
+	}
+
+	if result.Exec != nil {
+		if result.AutoAuth == nil {
+			return nil, fmt.Errorf("exec is configured but auto_auth not configured")
+		}
+		if result.AutoAuth.Method.WrapTTL > 0 {
+			return nil, fmt.Errorf("exec is configured and auto_auth uses wrapping")
+		}
+		if result.ExitAfterAuth {
+			return nil, fmt.Errorf("exec is configured and exit_after_auth is true")
+		}
+		if len(result.EnvTemplates) == 0 {
+			return nil, fmt.Errorf("exec requires at least one env_template")
+		}
+	} else if len(result.EnvTemplates) > 0 {
+		return nil, fmt.Errorf("env_template requires exec to be configured")
+	}
+
 	if result.Cache != nil {
 		if len(result.Listeners) < 1 {
 			return nil, fmt.Errorf("at least one listener required when cache enabled")
@@ -154,13 +250,23 @@ func LoadConfig(path string) (*Config, error) {
 				return nil, fmt.Errorf("cache.use_auto_auth_token is true and auto_auth uses wrapping")
 			}
 		}
+
+		if result.Cache.Persist != nil {
+			if result.AutoAuth == nil {
+				return nil, fmt.Errorf("cache.persist is configured but auto_auth not configured")
+			}
+			if result.AutoAuth.Method.WrapTTL > 0 {
+				return nil, fmt.Errorf("cache.persist is configured and auto_auth uses wrapping")
+			}
+		}
 	}
 
 	if result.AutoAuth != nil {
 		if len(result.AutoAuth.Sinks) == 0 &&
 			(result.Cache == nil || !result.Cache.UseAutoAuthToken) &&
-			len(result.Templates) == 0 {
-			return nil, fmt.Errorf("auto_auth requires at least one sink or at least one template or cache.use_auto_auth_token=true")
+			len(result.Templates) == 0 &&
+			result.Exec == nil {
+			return nil, fmt.Errorf("auto_auth requires at least one sink, template or exec or cache.use_auto_auth_token=true")
 		}
 	}
 
@@ -244,10 +350,52 @@ func parseCache(result *Config, list *ast.ObjectList) error {
 		}
 	}
 
+	if c.Persist != nil {
+		if c.Persist.Path == "" {
+			return errors.New("'path' must be specified for 'persist'")
+		}
+		if c.Persist.RetrievalTokenTTLRaw != nil {
+			if c.Persist.RetrievalTokenTTL, err = parseutil.ParseDurationSecond(c.Persist.RetrievalTokenTTLRaw); err != nil {
+				return multierror.Prefix(err, "persist")
+			}
+			c.Persist.RetrievalTokenTTLRaw = nil
+		}
+	}
+
+	if o, ok := item.Val.(*ast.ObjectType); ok {
+		if c.StaticSecrets, err = parseStaticSecrets(o.List); err != nil {
+			return err
+		}
+	}
+
 	result.Cache = &c
 	return nil
 }
 
+func parseStaticSecrets(list *ast.ObjectList) ([]*StaticSecret, error) {
+	name := "static_secret"
+
+	var result []*StaticSecret
+	for _, item := range list.Filter(name).Items {
+		var ss StaticSecret
+		if err := hcl.DecodeObject(&ss, item.Val); err != nil {
+			return nil, multierror.Prefix(err, name)
+		}
+		if ss.PathPrefix == "" {
+			return nil, fmt.Errorf("'path_prefix' must be specified for %q", name)
+		}
+		if ss.RefreshIntervalRaw != nil {
+			var err error
+			if ss.RefreshInterval, err = parseutil.ParseDurationSecond(ss.RefreshIntervalRaw); err != nil {
+				return nil, multierror.Prefix(err, fmt.Sprintf("%s %q:", name, ss.PathPrefix))
+			}
+			ss.RefreshIntervalRaw = nil
+		}
+		result = append(result, &ss)
+	}
+	return result, nil
+}
+
 func parseAutoAuth(result *Config, list *ast.ObjectList) error {
 	name := "auto_auth"
 
@@ -275,7 +423,7 @@ func parseAutoAuth(result *Config, list *ast.ObjectList) error {
 	}
 	subList := subs.List
 
-	if err := parseMethod(result, subList); err != nil {
+	if err := parseMethods(result, subList); err != nil {
 		return errwrap.Wrapf("error parsing 'method': {{err}}", err)
 	}
 	if a.Method == nil {
@@ -286,6 +434,14 @@ func parseAutoAuth(result *Config, list *ast.ObjectList) error {
 		return errwrap.Wrapf("error parsing 'sink' stanzas: {{err}}", err)
 	}
 
+	if len(result.AutoAuth.Methods) > 1 {
+		for _, m := range result.AutoAuth.Methods {
+			if m.WrapTTL > 0 {
+				return fmt.Errorf("error parsing auto_auth: wrapping is not supported with multiple auth methods")
+			}
+		}
+	}
+
 	if result.AutoAuth.Method.WrapTTL > 0 {
 		if len(result.AutoAuth.Sinks) != 1 {
 			return fmt.Errorf("error parsing auto_auth: wrapping enabled on auth method and 0 or many sinks defined")
@@ -299,20 +455,35 @@ func parseAutoAuth(result *Config, list *ast.ObjectList) error {
 	return nil
 }
 
-func parseMethod(result *Config, list *ast.ObjectList) error {
+func parseMethods(result *Config, list *ast.ObjectList) error {
 	name := "method"
 
 	methodList := list.Filter(name)
-	if len(methodList.Items) != 1 {
-		return fmt.Errorf("one and only one %q block is required", name)
+	if len(methodList.Items) < 1 {
+		return fmt.Errorf("at least one %q block is required", name)
 	}
 
-	// Get our item
-	item := methodList.Items[0]
+	var ms []*Method
+	for _, item := range methodList.Items {
+		m, err := parseMethod(item)
+		if err != nil {
+			return err
+		}
+		if len(methodList.Items) > 1 && m.MaxRetries == 0 {
+			m.MaxRetries = defaultMethodMaxRetries
+		}
+		ms = append(ms, m)
+	}
 
+	result.AutoAuth.Methods = ms
+	result.AutoAuth.Method = ms[0]
+	return nil
+}
+
+func parseMethod(item *ast.ObjectItem) (*Method, error) {
 	var m Method
 	if err := hcl.DecodeObject(&m, item.Val); err != nil {
-		return err
+		return nil, err
 	}
 
 	if m.Type == "" {
@@ -320,7 +491,7 @@ func parseMethod(result *Config, list *ast.ObjectList) error {
 			m.Type = strings.ToLower(item.Keys[0].Token.Value().(string))
 		}
 		if m.Type == "" {
-			return errors.New("method type must be specified")
+			return nil, errors.New("method type must be specified")
 		}
 	}
 
@@ -334,16 +505,36 @@ func parseMethod(result *Config, list *ast.ObjectList) error {
 	if m.WrapTTLRaw != nil {
 		var err error
 		if m.WrapTTL, err = parseutil.ParseDurationSecond(m.WrapTTLRaw); err != nil {
-			return err
+			return nil, err
 		}
 		m.WrapTTLRaw = nil
 	}
 
+	if m.MaxRetries < 0 {
+		return nil, multierror.Prefix(errors.New("'max_retries' must not be negative"), fmt.Sprintf("method.%s", m.Type))
+	}
+	if m.MinBackoffRaw != nil {
+		var err error
+		if m.MinBackoff, err = parseutil.ParseDurationSecond(m.MinBackoffRaw); err != nil {
+			return nil, multierror.Prefix(err, fmt.Sprintf("method.%s", m.Type))
+		}
+		m.MinBackoffRaw = nil
+	}
+	if m.MaxBackoffRaw != nil {
+		var err error
+		if m.MaxBackoff, err = parseutil.ParseDurationSecond(m.MaxBackoffRaw); err != nil {
+			return nil, multierror.Prefix(err, fmt.Sprintf("method.%s", m.Type))
+		}
+		m.MaxBackoffRaw = nil
+	}
+	if m.MinBackoff > 0 && m.MaxBackoff > 0 && m.MinBackoff > m.MaxBackoff {
+		return nil, multierror.Prefix(errors.New("'min_backoff' must not be greater than 'max_backoff'"), fmt.Sprintf("method.%s", m.Type))
+	}
+
 	// Canonicalize namespace path if provided
 	m.Namespace = namespace.Canonicalize(m.Namespace)
 
-	result.AutoAuth.Method = &m
-	return nil
+	return &m, nil
 }
 
 func parseSinks(result *Config, list *ast.ObjectList) error {
@@ -427,48 +618,181 @@ func parseTemplates(result *Config, list *ast.ObjectList) error {
 			return fmt.Errorf("error decoding config: %s", err)
 		}
 
-		// Convert to a map and flatten the keys we want to flatten
-		parsed, ok := shadow.(map[string]interface{})
-		if !ok {
-			return errors.New("error converting config")
-		}
-
-		// flatten the wait field. The initial "wait" value, if given, is a
-		// []map[string]interface{}, but we need it to be map[string]interface{}.
-		// Consul Template has a method flattenKeys that walks all of parsed and
-		// flattens every key. For Vault Agent, we only care about the wait input.
-		// Only one wait stanza is supported, however Consul Template does not error
-		// with multiple instead it flattens them down, with last value winning.
-		// Here we take the last element of the parsed["wait"] slice to keep
-		// consistency with Consul Template behavior.
-		wait, ok := parsed["wait"].([]map[string]interface{})
-		if ok {
-			parsed["wait"] = wait[len(wait)-1]
-		}
-
-		var tc ctconfig.TemplateConfig
-
-		// Use mapstructure to populate the basic config fields
-		var md mapstructure.Metadata
-		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-			DecodeHook: mapstructure.ComposeDecodeHookFunc(
-				ctconfig.StringToFileModeFunc(),
-				ctconfig.StringToWaitDurationHookFunc(),
-				mapstructure.StringToSliceHookFunc(","),
-				mapstructure.StringToTimeDurationHookFunc(),
-			),
-			ErrorUnused: true,
-			Metadata:    &md,
-			Result:      &tc,
-		})
+		tc, err := decodeTemplateConfig(shadow)
 		if err != nil {
-			return errors.New("mapstructure decoder creation failed")
-		}
-		if err := decoder.Decode(parsed); err != nil {
 			return err
 		}
-		tcs = append(tcs, &tc)
+		tcs = append(tcs, tc)
 	}
 	result.Templates = tcs
 	return nil
 }
+
+// decodeTemplateConfig decodes a template stanza into a consul-template
+// template configuration
+func decodeTemplateConfig(shadow interface{}) (*ctconfig.TemplateConfig, error) {
+	// Convert to a map and flatten the keys we want to flatten
+	parsed, ok := shadow.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("error converting config")
+	}
+
+	// flatten the wait field. The initial "wait" value, if given, is a
+	// []map[string]interface{}, but we need it to be map[string]interface{}.
+	// Consul Template has a method flattenKeys that walks all of parsed and
+	// flattens every key. For Vault Agent, we only care about the wait input.
+	// Only one wait stanza is supported, however Consul Template does not error
+	// with multiple instead it flattens them down, with last value winning.
+	// Here we take the last element of the parsed["wait"] slice to keep
+	// consistency with Consul Template behavior.
+	wait, ok := parsed["wait"].([]map[string]interface{})
+	if ok {
+		parsed["wait"] = wait[len(wait)-1]
+	}
+
+	var tc ctconfig.TemplateConfig
+
+	// Use mapstructure to populate the basic config fields
+	var md mapstructure.Metadata
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			ctconfig.StringToFileModeFunc(),
+			ctconfig.StringToWaitDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+			mapstructure.StringToTimeDurationHookFunc(),
+		),
+		ErrorUnused: true,
+		Metadata:    &md,
+		Result:      &tc,
+	})
+	if err != nil {
+		return nil, errors.New("mapstructure decoder creation failed")
+	}
+	if err := decoder.Decode(parsed); err != nil {
+		return nil, err
+	}
+	return &tc, nil
+}
+
+// envTemplateKeys are the template options which make sense for a template
+// rendered into an environment variable
+var envTemplateKeys = map[string]bool{
+	"contents":             true,
+	"error_on_missing_key": true,
+	"left_delimiter":       true,
+	"right_delimiter":      true,
+}
+
+func parseEnvTemplates(result *Config, list *ast.ObjectList) error {
+	name := "env_template"
+
+	templateList := list.Filter(name)
+	if len(templateList.Items) < 1 {
+		return nil
+	}
+
+	var ets []*EnvTemplate
+	seen := make(map[string]bool, len(templateList.Items))
+
+	for _, item := range templateList.Items {
+		if len(item.Keys) != 1 {
+			return errors.New("env_template must be given the name of the environment variable")
+		}
+		envVar := item.Keys[0].Token.Value().(string)
+		if envVar == "" || strings.ContainsAny(envVar, "= \t\n") {
+			return fmt.Errorf("invalid environment variable name %q", envVar)
+		}
+		if seen[envVar] {
+			return fmt.Errorf("environment variable %q is templated more than once", envVar)
+		}
+		seen[envVar] = true
+
+		var shadow interface{}
+		if err := hcl.DecodeObject(&shadow, item.Val); err != nil {
+			return fmt.Errorf("error decoding config: %s", err)
+		}
+		if parsed, ok := shadow.(map[string]interface{}); ok {
+			for key := range parsed {
+				if !envTemplateKeys[key] {
+					return multierror.Prefix(fmt.Errorf("%q is not supported", key), fmt.Sprintf("env_template.%s", envVar))
+				}
+			}
+		}
+
+		tc, err := decodeTemplateConfig(shadow)
+		if err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("env_template.%s", envVar))
+		}
+		if tc.Contents == nil || *tc.Contents == "" {
+			return multierror.Prefix(errors.New("'contents' must be specified"), fmt.Sprintf("env_template.%s", envVar))
+		}
+
+		ets = append(ets, &EnvTemplate{
+			Name:     envVar,
+			Template: tc,
+		})
+	}
+
+	result.EnvTemplates = ets
+	return nil
+}
+
+func parseExec(result *Config, list *ast.ObjectList) error {
+	name := "exec"
+
+	execList := list.Filter(name)
+	if len(execList.Items) == 0 {
+		return nil
+	}
+
+	if len(execList.Items) > 1 {
+		return fmt.Errorf("one and only one %q block is required", name)
+	}
+
+	item := execList.Items[0]
+
+	var e Exec
+	err := hcl.DecodeObject(&e, item.Val)
+	if err != nil {
+		return err
+	}
+
+	if len(e.Command) == 0 || e.Command[0] == "" {
+		return errors.New("'command' must be specified")
+	}
+
+	switch e.RestartOnSecretChanges {
+	case "":
+		e.RestartOnSecretChanges = ExecRestartAlways
+	case ExecRestartAlways, ExecRestartNever:
+	default:
+		return fmt.Errorf("value of 'restart_on_secret_changes' can be either %q or %q, %q is an invalid option", ExecRestartAlways, ExecRestartNever, e.RestartOnSecretChanges)
+	}
+
+	if e.RestartStopSignalRaw == "" {
+		e.RestartStopSignalRaw = "SIGTERM"
+	}
+	if e.RestartStopSignal, err = signals.Parse(e.RestartStopSignalRaw); err != nil {
+		return multierror.Prefix(err, "restart_stop_signal")
+	}
+
+	if e.ReloadSignalRaw != "" {
+		if e.RestartOnSecretChanges == ExecRestartNever {
+			return errors.New("'reload_signal' can't be used when 'restart_on_secret_changes' is \"never\"")
+		}
+		if e.ReloadSignal, err = signals.Parse(e.ReloadSignalRaw); err != nil {
+			return multierror.Prefix(err, "reload_signal")
+		}
+	}
+
+	e.KillTimeout = defaultExecKillTimeout
+	if e.KillTimeoutRaw != nil {
+		if e.KillTimeout, err = parseutil.ParseDurationSecond(e.KillTimeoutRaw); err != nil {
+			return multierror.Prefix(err, "kill_timeout")
+		}
+		e.KillTimeoutRaw = nil
+	}
+
+	result.Exec = &e
+	return nil
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"syscall"
 	"testing"
 	"time"
 
@@ -81,6 +82,7 @@ func TestLoadConfigFile_AgentCache(t *testing.T) {
 	config.Listeners[0].RawConfig = nil
 	config.Listeners[1].RawConfig = nil
 	config.Listeners[2].RawConfig = nil
+	expected.AutoAuth.Methods = []*Method{expected.AutoAuth.Method}
 	if diff := deep.Equal(config, expected); diff != nil {
 		t.Fatal(diff)
 	}
@@ -152,6 +154,7 @@ func TestLoadConfigFile(t *testing.T) {
 		},
 	}
 
+	expected.AutoAuth.Methods = []*Method{expected.AutoAuth.Method}
 	if diff := deep.Equal(config, expected); diff != nil {
 		t.Fatal(diff)
 	}
@@ -196,11 +199,54 @@ func TestLoadConfigFile_Method_Wrapping(t *testing.T) {
 		},
 	}
 
+	expected.AutoAuth.Methods = []*Method{expected.AutoAuth.Method}
 	if diff := deep.Equal(config, expected); diff != nil {
 		t.Fatal(diff)
 	}
 }
 
+func TestLoadConfigFile_AutoAuth_MultipleMethods(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config-auto_auth-multiple-methods.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*Method{
+		{
+			Type:       "kubernetes",
+			MountPath:  "auth/kubernetes",
+			MaxRetries: 5,
+			MinBackoff: 2 * time.Second,
+			MaxBackoff: time.Minute,
+			Config: map[string]interface{}{
+				"role": "foobar",
+			},
+		},
+		{
+			Type:       "approle",
+			MountPath:  "auth/approle-fallback",
+			MaxRetries: 3,
+			Config: map[string]interface{}{
+				"role_id_file_path":   "/etc/vault/role-id",
+				"secret_id_file_path": "/etc/vault/secret-id",
+			},
+		},
+	}
+	if diff := deep.Equal(config.AutoAuth.Methods, expected); diff != nil {
+		t.Fatal(diff)
+	}
+	if config.AutoAuth.Method != config.AutoAuth.Methods[0] {
+		t.Fatal("expected the first method to be the primary one")
+	}
+}
+
+func TestLoadConfigFile_Bad_AutoAuth_MultipleMethods_Wrapping(t *testing.T) {
+	_, err := LoadConfig("./test-fixtures/bad-config-auto_auth-multiple-methods-wrapping.hcl")
+	if err == nil {
+		t.Fatal("LoadConfig should return an error when wrapping is enabled with multiple auth methods")
+	}
+}
+
 func TestLoadConfigFile_AgentCache_NoAutoAuth(t *testing.T) {
 	config, err := LoadConfig("./test-fixtures/config-cache-no-auto_auth.hcl")
 	if err != nil {
@@ -227,6 +273,73 @@ func TestLoadConfigFile_AgentCache_NoAutoAuth(t *testing.T) {
 	}
 }
 
+func TestLoadConfigFile_AgentCache_Persist(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config-cache-persist.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Persist{
+		Path:              "/vault/agent-cache",
+		ExitOnErr:         true,
+		RetrievalTokenTTL: 48 * time.Hour,
+	}
+	if diff := deep.Equal(config.Cache.Persist, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestLoadConfigFile_Bad_AgentCache_PersistNoPath(t *testing.T) {
+	_, err := LoadConfig("./test-fixtures/bad-config-cache-persist-no-path.hcl")
+	if err == nil {
+		t.Fatal("LoadConfig should return an error when cache.persist has no path")
+	}
+}
+
+func TestLoadConfigFile_AgentCache_StaticSecret(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config-cache-static-secret.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []*StaticSecret{
+		{
+			PathPrefix:      "secret/",
+			RefreshInterval: 10 * time.Minute,
+		},
+		{
+			PathPrefix: "kv/data/app/",
+		},
+	}
+	if diff := deep.Equal(config.Cache.StaticSecrets, expected); diff != nil {
+		t.Fatal(diff)
+	}
+}
+
+func TestLoadConfigFile_Bad_AgentCache_StaticSecretNoPathPrefix(t *testing.T) {
+	_, err := LoadConfig("./test-fixtures/bad-config-cache-static-secret-no-path-prefix.hcl")
+	if err == nil {
+		t.Fatal("LoadConfig should return an error when cache.static_secret has no path_prefix")
+	}
+}
+
+func TestLoadConfigFile_Telemetry(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config-telemetry.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Telemetry == nil {
+		t.Fatal("expected telemetry to be configured")
+	}
+	if config.Telemetry.PrometheusRetentionTime != 30*time.Second {
+		t.Fatalf("unexpected prometheus retention time %v", config.Telemetry.PrometheusRetentionTime)
+	}
+	if !config.Telemetry.DisableHostname {
+		t.Fatal("expected disable_hostname to be set")
+	}
+}
+
 func TestLoadConfigFile_Bad_AgentCache_InconsisentAutoAuth(t *testing.T) {
 	_, err := LoadConfig("./test-fixtures/bad-config-cache-inconsistent-auto_auth.hcl")
 	if err == nil {
@@ -310,6 +423,7 @@ func TestLoadConfigFile_AgentCache_AutoAuth_NoSink(t *testing.T) {
 	}
 
 	config.Listeners[0].RawConfig = nil
+	expected.AutoAuth.Methods = []*Method{expected.AutoAuth.Method}
 	if diff := deep.Equal(config, expected); diff != nil {
 		t.Fatal(diff)
 	}
@@ -349,6 +463,7 @@ func TestLoadConfigFile_AgentCache_AutoAuth_Force(t *testing.T) {
 	}
 
 	config.Listeners[0].RawConfig = nil
+	expected.AutoAuth.Methods = []*Method{expected.AutoAuth.Method}
 	if diff := deep.Equal(config, expected); diff != nil {
 		t.Fatal(diff)
 	}
@@ -388,6 +503,7 @@ func TestLoadConfigFile_AgentCache_AutoAuth_True(t *testing.T) {
 	}
 
 	config.Listeners[0].RawConfig = nil
+	expected.AutoAuth.Methods = []*Method{expected.AutoAuth.Method}
 	if diff := deep.Equal(config, expected); diff != nil {
 		t.Fatal(diff)
 	}
@@ -438,6 +554,7 @@ func TestLoadConfigFile_AgentCache_AutoAuth_False(t *testing.T) {
 	}
 
 	config.Listeners[0].RawConfig = nil
+	expected.AutoAuth.Methods = []*Method{expected.AutoAuth.Method}
 	if diff := deep.Equal(config, expected); diff != nil {
 		t.Fatal(diff)
 	}
@@ -541,6 +658,7 @@ func TestLoadConfigFile_Template(t *testing.T) {
 				Templates: tc.expectedTemplates,
 			}
 
+			expected.AutoAuth.Methods = []*Method{expected.AutoAuth.Method}
 			if diff := deep.Equal(config, expected); diff != nil {
 				t.Fatal(diff)
 			}
@@ -636,9 +754,67 @@ func TestLoadConfigFile_Template_NoSinks(t *testing.T) {
 				Templates: tc.expectedTemplates,
 			}
 
+			expected.AutoAuth.Methods = []*Method{expected.AutoAuth.Method}
 			if diff := deep.Equal(config, expected); diff != nil {
 				t.Fatal(diff)
 			}
 		})
 	}
 }
+
+func TestLoadConfigFile_Exec(t *testing.T) {
+	config, err := LoadConfig("./test-fixtures/config-exec.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedExec := &Exec{
+		Command:                []string{"/usr/bin/app", "-config", "/etc/app.conf"},
+		RestartOnSecretChanges: ExecRestartAlways,
+		RestartStopSignalRaw:   "SIGINT",
+		RestartStopSignal:      syscall.SIGINT,
+		ReloadSignalRaw:        "SIGHUP",
+		ReloadSignal:           syscall.SIGHUP,
+		KillTimeout:            10 * time.Second,
+	}
+	if diff := deep.Equal(config.Exec, expectedExec); diff != nil {
+		t.Fatal(diff)
+	}
+
+	if len(config.EnvTemplates) != 2 {
+		t.Fatalf("expected 2 env templates, got %d", len(config.EnvTemplates))
+	}
+	for i, expected := range []struct {
+		name     string
+		contents string
+		missing  bool
+	}{
+		{"DB_USERNAME", `{{ with secret "database/creds/app" }}{{ .Data.username }}{{ end }}`, true},
+		{"DB_PASSWORD", `{{ with secret "database/creds/app" }}{{ .Data.password }}{{ end }}`, false},
+	} {
+		et := config.EnvTemplates[i]
+		if et.Name != expected.name {
+			t.Fatalf("expected env template %q, got %q", expected.name, et.Name)
+		}
+		if et.Template.Contents == nil || *et.Template.Contents != expected.contents {
+			t.Fatalf("unexpected contents for %q: %v", et.Name, et.Template.Contents)
+		}
+		if (et.Template.ErrMissingKey != nil && *et.Template.ErrMissingKey) != expected.missing {
+			t.Fatalf("unexpected error_on_missing_key for %q", et.Name)
+		}
+	}
+}
+
+func TestLoadConfigFile_Bad_Exec_NoEnvTemplates(t *testing.T) {
+	_, err := LoadConfig("./test-fixtures/bad-config-exec-no-env-templates.hcl")
+	if err == nil {
+		t.Fatal("LoadConfig should return an error when exec is configured without env templates")
+	}
+}
+
+func TestLoadConfigFile_Bad_Exec_EnvTemplateDestination(t *testing.T) {
+	_, err := LoadConfig("./test-fixtures/bad-config-exec-env-template-destination.hcl")
+	if err == nil {
+		t.Fatal("LoadConfig should return an error when an env template has a destination")
+	}
+}
@@ -0,0 +1,24 @@
+pid_file = "./pidfile"
+
+auto_auth {
+  method "kubernetes" {
+    wrap_ttl = 300
+
+    config = {
+      role = "foobar"
+    }
+  }
+
+  method "approle" {
+    config = {
+      role_id_file_path   = "/etc/vault/role-id"
+      secret_id_file_path = "/etc/vault/secret-id"
+    }
+  }
+
+  sink "file" {
+    config = {
+      path = "/tmp/file-foo"
+    }
+  }
+}
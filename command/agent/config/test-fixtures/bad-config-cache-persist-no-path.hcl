@@ -0,0 +1,22 @@
+pid_file = "./pidfile"
+
+auto_auth {
+	method {
+		type = "kubernetes"
+		config = {
+			role = "foobar"
+		}
+	}
+}
+
+cache {
+	use_auto_auth_token = true
+	persist {
+		exit_on_err = true
+	}
+}
+
+listener "tcp" {
+	address = "127.0.0.1:8300"
+	tls_disable = true
+}
@@ -0,0 +1,22 @@
+pid_file = "./pidfile"
+
+auto_auth {
+	method {
+		type = "kubernetes"
+		config = {
+			role = "foobar"
+		}
+	}
+}
+
+cache {
+	use_auto_auth_token = true
+	static_secret {
+		refresh_interval = "10m"
+	}
+}
+
+listener "tcp" {
+	address = "127.0.0.1:8300"
+	tls_disable = true
+}
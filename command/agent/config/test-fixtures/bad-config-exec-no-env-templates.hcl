@@ -0,0 +1,15 @@
+pid_file = "./pidfile"
+
+auto_auth {
+  method {
+    type = "aws"
+
+    config = {
+      role = "foobar"
+    }
+  }
+}
+
+exec {
+  command = ["/usr/bin/app"]
+}
@@ -0,0 +1,28 @@
+pid_file = "./pidfile"
+
+auto_auth {
+  method "kubernetes" {
+    max_retries = 5
+    min_backoff = "2s"
+    max_backoff = "1m"
+
+    config = {
+      role = "foobar"
+    }
+  }
+
+  method "approle" {
+    mount_path = "auth/approle-fallback"
+
+    config = {
+      role_id_file_path   = "/etc/vault/role-id"
+      secret_id_file_path = "/etc/vault/secret-id"
+    }
+  }
+
+  sink "file" {
+    config = {
+      path = "/tmp/file-foo"
+    }
+  }
+}
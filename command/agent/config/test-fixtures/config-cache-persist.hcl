@@ -0,0 +1,24 @@
+pid_file = "./pidfile"
+
+auto_auth {
+	method {
+		type = "kubernetes"
+		config = {
+			role = "foobar"
+		}
+	}
+}
+
+cache {
+	use_auto_auth_token = true
+	persist {
+		path = "/vault/agent-cache"
+		exit_on_err = true
+		retrieval_token_ttl = "48h"
+	}
+}
+
+listener "tcp" {
+	address = "127.0.0.1:8300"
+	tls_disable = true
+}
@@ -0,0 +1,26 @@
+pid_file = "./pidfile"
+
+auto_auth {
+	method {
+		type = "kubernetes"
+		config = {
+			role = "foobar"
+		}
+	}
+}
+
+cache {
+	use_auto_auth_token = true
+	static_secret {
+		path_prefix = "secret/"
+		refresh_interval = "10m"
+	}
+	static_secret {
+		path_prefix = "kv/data/app/"
+	}
+}
+
+listener "tcp" {
+	address = "127.0.0.1:8300"
+	tls_disable = true
+}
@@ -0,0 +1,28 @@
+pid_file = "./pidfile"
+
+auto_auth {
+  method {
+    type      = "aws"
+    namespace = "/my-namespace"
+
+    config = {
+      role = "foobar"
+    }
+  }
+}
+
+env_template "DB_USERNAME" {
+  contents             = "{{ with secret \"database/creds/app\" }}{{ .Data.username }}{{ end }}"
+  error_on_missing_key = true
+}
+
+env_template "DB_PASSWORD" {
+  contents = "{{ with secret \"database/creds/app\" }}{{ .Data.password }}{{ end }}"
+}
+
+exec {
+  command             = ["/usr/bin/app", "-config", "/etc/app.conf"]
+  restart_stop_signal = "SIGINT"
+  reload_signal       = "SIGHUP"
+  kill_timeout        = "10s"
+}
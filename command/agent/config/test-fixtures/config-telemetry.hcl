@@ -0,0 +1,24 @@
+pid_file = "./pidfile"
+
+auto_auth {
+	method {
+		type = "kubernetes"
+		config = {
+			role = "foobar"
+		}
+	}
+}
+
+cache {
+	use_auto_auth_token = true
+}
+
+listener "tcp" {
+	address = "127.0.0.1:8300"
+	tls_disable = true
+}
+
+telemetry {
+	prometheus_retention_time = "30s"
+	disable_hostname = true
+}
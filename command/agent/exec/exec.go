@@ -0,0 +1,332 @@
+// Package exec is responsible for running a child process with secrets from
+// Vault injected as environment variables. The Server renders the configured
+// env templates with an internal Consul Template Runner, starts the child
+// process once all of them have been rendered, and restarts or signals it
+// whenever the rendered environment changes.
+package exec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/consul-template/child"
+	ctconfig "github.com/hashicorp/consul-template/config"
+	"github.com/hashicorp/consul-template/manager"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/command/agent/template"
+	"github.com/hashicorp/vault/sdk/helper/pointerutil"
+)
+
+// ServerConfig is a config struct for setting up the basic parts of the
+// Server
+type ServerConfig struct {
+	Logger    hclog.Logger
+	VaultConf *config.Vault
+	Namespace string
+
+	// LogLevel and LogWriter are used to set up the internal Consul Template
+	// Runner's logging, see template.ServerConfig
+	LogLevel  hclog.Level
+	LogWriter io.Writer
+
+	EnvTemplates []*config.EnvTemplate
+	Exec         *config.Exec
+
+	// Stdout and Stderr receive the output of the child process. They default
+	// to the agent's own.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Server manages the child process and the Consul Template Runner rendering
+// its environment
+type Server struct {
+	config *ServerConfig
+	logger hclog.Logger
+
+	// runner is the consul-template runner rendering the env templates
+	runner *manager.Runner
+
+	// envVars maps the destination given to each env template to the name of
+	// the environment variable it is rendered into
+	envVars map[string]string
+
+	// child is the running child process, and childEnv the rendered
+	// environment it was started with
+	child    *child.Child
+	childEnv map[string]string
+
+	// testingLimitRetry is used for tests to limit the number of retries
+	// performed by the runner
+	testingLimitRetry int
+}
+
+// NewServer returns a new configured server
+func NewServer(conf *ServerConfig) *Server {
+	return &Server{
+		config: conf,
+		logger: conf.Logger,
+	}
+}
+
+// Run kicks off the internal Consul Template runner, and listens for changes to
+// the token from the AuthHandler. The child process is started once all the
+// env templates have been rendered. If Done() is called on the context, the
+// child process is stopped and Run returns. If the child process exits on its
+// own, Run returns an error.
+func (s *Server) Run(ctx context.Context, incoming chan string) error {
+	if incoming == nil {
+		return errors.New("exec server: incoming channel is nil")
+	}
+	if s.config.Exec == nil || len(s.config.EnvTemplates) == 0 {
+		return errors.New("exec server: no command or env templates configured")
+	}
+
+	latestToken := new(string)
+	s.logger.Info("starting exec server")
+
+	defer func() {
+		s.stopChild()
+		s.logger.Info("exec server stopped")
+	}()
+
+	// The env templates are rendered in dry mode so nothing is written to disk.
+	// Each of them is given a destination which doesn't exist, so that every
+	// render is reported with its contents.
+	placeholderID, err := uuid.GenerateUUID()
+	if err != nil {
+		return fmt.Errorf("exec server failed to generate template destinations: %w", err)
+	}
+	placeholder := filepath.Join(os.TempDir(), "vault-agent-exec-"+placeholderID)
+
+	s.envVars = make(map[string]string, len(s.config.EnvTemplates))
+	templates := make(ctconfig.TemplateConfigs, 0, len(s.config.EnvTemplates))
+	for _, et := range s.config.EnvTemplates {
+		tc := et.Template.Copy()
+		tc.Destination = pointerutil.StringPtr(filepath.Join(placeholder, et.Name))
+		s.envVars[*tc.Destination] = et.Name
+		templates = append(templates, tc)
+	}
+
+	runnerConfig, err := template.NewRunnerConfig(&template.ServerConfig{
+		Logger:    s.logger,
+		VaultConf: s.config.VaultConf,
+		Namespace: s.config.Namespace,
+		LogLevel:  s.config.LogLevel,
+		LogWriter: s.config.LogWriter,
+	}, templates)
+	if err != nil {
+		return fmt.Errorf("exec server failed to generate runner config: %w", err)
+	}
+
+	if s.runner, err = s.newRunner(runnerConfig); err != nil {
+		return fmt.Errorf("exec server failed to create: %w", err)
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	if len(forwardedSignals) > 0 {
+		signal.Notify(sigCh, forwardedSignals...)
+		defer signal.Stop(sigCh)
+	}
+
+	for {
+		var exitCh <-chan int
+		if s.child != nil {
+			exitCh = s.child.ExitCh()
+		}
+
+		select {
+		case <-ctx.Done():
+			s.runner.Stop()
+			return nil
+
+		case token := <-incoming:
+			if token != *latestToken {
+				s.logger.Info("exec server received new token")
+
+				s.runner.Stop()
+				*latestToken = token
+				ctv := ctconfig.Config{
+					Vault: &ctconfig.VaultConfig{
+						Token: latestToken,
+					},
+				}
+
+				// If we're testing, limit retries to avoid long test runs
+				// from exponential back-offs
+				if s.testingLimitRetry != 0 {
+					ctv.Vault.Retry = &ctconfig.RetryConfig{Attempts: &s.testingLimitRetry}
+				}
+
+				runnerConfig = runnerConfig.Merge(&ctv)
+				var runnerErr error
+				s.runner, runnerErr = s.newRunner(runnerConfig)
+				if runnerErr != nil {
+					s.logger.Error("exec server failed with new Vault token", "error", runnerErr)
+					continue
+				}
+				go s.runner.Start()
+			}
+
+		case err := <-s.runner.ErrCh:
+			metrics.IncrCounter([]string{"agent", "template", "render", "error"}, 1)
+			s.runner.StopImmediately()
+			return fmt.Errorf("exec server: %w", err)
+
+		case <-s.runner.TemplateRenderedCh():
+			env, ok := s.renderedEnv()
+			if !ok {
+				// Not all env templates have been rendered yet
+				continue
+			}
+			if err := s.update(env); err != nil {
+				s.runner.StopImmediately()
+				return fmt.Errorf("exec server: %w", err)
+			}
+
+		case sig := <-sigCh:
+			if s.child != nil {
+				s.logger.Debug("forwarding signal to child process", "signal", sig)
+				if err := s.child.Signal(sig); err != nil {
+					s.logger.Error("error forwarding signal to child process", "signal", sig, "error", err)
+				}
+			}
+
+		case code := <-exitCh:
+			s.child = nil
+			s.runner.Stop()
+			return fmt.Errorf("exec server: child process exited with code %d", code)
+		}
+	}
+}
+
+func (s *Server) newRunner(runnerConfig *ctconfig.Config) (*manager.Runner, error) {
+	runner, err := manager.NewRunner(runnerConfig, true)
+	if err != nil {
+		return nil, err
+	}
+	runner.SetOutStream(ioutil.Discard)
+	return runner, nil
+}
+
+// renderedEnv returns the environment variables rendered by the latest run of
+// the runner, and whether all of them were rendered
+func (s *Server) renderedEnv() (map[string]string, bool) {
+	env := make(map[string]string, len(s.envVars))
+	for _, event := range s.runner.RenderEvents() {
+		if !event.DidRender {
+			return nil, false
+		}
+		for _, tc := range event.TemplateConfigs {
+			if name, ok := s.envVars[ctconfig.StringVal(tc.Destination)]; ok {
+				env[name] = string(event.Contents)
+			}
+		}
+	}
+	return env, len(env) == len(s.envVars)
+}
+
+// update starts the child process with the rendered environment, or applies
+// the configured policy if the child is running and the environment changed
+func (s *Server) update(env map[string]string) error {
+	if s.child == nil {
+		return s.startChild(env)
+	}
+
+	if envEqual(env, s.childEnv) {
+		return nil
+	}
+
+	switch {
+	case s.config.Exec.RestartOnSecretChanges == config.ExecRestartNever:
+		s.logger.Info("rendered environment changed, leaving child process running")
+		s.childEnv = env
+		return nil
+
+	case s.config.Exec.ReloadSignal != nil:
+		s.logger.Info("rendered environment changed, signalling child process", "signal", s.config.Exec.ReloadSignal)
+		s.childEnv = env
+		return s.child.Signal(s.config.Exec.ReloadSignal)
+
+	default:
+		s.logger.Info("rendered environment changed, restarting child process")
+		s.stopChild()
+		return s.startChild(env)
+	}
+}
+
+func (s *Server) startChild(env map[string]string) error {
+	stdout, stderr := s.config.Stdout, s.config.Stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	environ := os.Environ()
+	for _, name := range names {
+		environ = append(environ, name+"="+env[name])
+	}
+
+	c, err := child.New(&child.NewInput{
+		Stdin:        os.Stdin,
+		Stdout:       stdout,
+		Stderr:       stderr,
+		Command:      s.config.Exec.Command[0],
+		Args:         s.config.Exec.Command[1:],
+		Env:          environ,
+		ReloadSignal: s.config.Exec.ReloadSignal,
+		KillSignal:   s.config.Exec.RestartStopSignal,
+		KillTimeout:  s.config.Exec.KillTimeout,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create child process: %w", err)
+	}
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("failed to start child process: %w", err)
+	}
+
+	s.logger.Info("started child process", "command", s.config.Exec.Command[0], "pid", c.Pid())
+	s.child = c
+	s.childEnv = env
+	return nil
+}
+
+// stopChild gracefully stops the child process, if it's running
+func (s *Server) stopChild() {
+	if s.child == nil {
+		return
+	}
+	s.logger.Info("stopping child process", "pid", s.child.Pid())
+	s.child.Stop()
+	s.child = nil
+}
+
+func envEqual(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, value := range a {
+		if bv, ok := b[name]; !ok || bv != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,172 @@
+package exec
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	ctconfig "github.com/hashicorp/consul-template/config"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/config"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/hashicorp/vault/sdk/helper/pointerutil"
+)
+
+// testVault serves a secret whose password depends on the token used to read
+// it
+func testVault(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/kv/myapp/config", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"lease_duration": 0, "data": {"username": "appuser", "password": "password-%s"}}`, r.Header.Get("X-Vault-Token"))
+	})
+	mux.HandleFunc("/v1/kv/myapp/perm-denied", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(403)
+		fmt.Fprintln(w, `{"errors":["1 error occurred:\n\t* permission denied\n\n"]}`)
+	})
+	return httptest.NewServer(mux)
+}
+
+func testServer(t *testing.T, address string, command string, envTemplates map[string]string) *Server {
+	t.Helper()
+	var ets []*config.EnvTemplate
+	for name, contents := range envTemplates {
+		ets = append(ets, &config.EnvTemplate{
+			Name: name,
+			Template: &ctconfig.TemplateConfig{
+				Contents: pointerutil.StringPtr(contents),
+			},
+		})
+	}
+
+	server := NewServer(&ServerConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace),
+		VaultConf: &config.Vault{
+			Address: address,
+		},
+		LogLevel:     hclog.Trace,
+		LogWriter:    hclog.DefaultOutput,
+		EnvTemplates: ets,
+		Exec: &config.Exec{
+			Command:                []string{"/bin/sh", "-c", command},
+			RestartOnSecretChanges: config.ExecRestartAlways,
+			RestartStopSignal:      syscall.SIGTERM,
+			KillTimeout:            5 * time.Second,
+		},
+	})
+	server.testingLimitRetry = 3
+	return server
+}
+
+// waitForLines waits for the file at path to contain the given number of
+// lines
+func waitForLines(t *testing.T, path string, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		content, err := ioutil.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		if len(content) > 0 && len(lines) >= n {
+			return lines
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d lines in %s, got %q", n, path, content)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestServerRun_Restart(t *testing.T) {
+	vault := testVault(t)
+	defer vault.Close()
+
+	tmpDir, err := ioutil.TempDir("", "agent-tests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+	out := filepath.Join(tmpDir, "env")
+
+	server := testServer(t, vault.URL, fmt.Sprintf(`echo "$DB_USERNAME:$DB_PASSWORD" >> %s; exec sleep 60`, out), map[string]string{
+		"DB_USERNAME": `{{ with secret "kv/myapp/config" }}{{ .Data.username }}{{ end }}`,
+		"DB_PASSWORD": `{{ with secret "kv/myapp/config" }}{{ .Data.password }}{{ end }}`,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tokenCh := make(chan string, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.Run(ctx, tokenCh)
+	}()
+
+	tokenCh <- "one"
+	lines := waitForLines(t, out, 1)
+	if lines[0] != "appuser:password-one" {
+		t.Fatalf("unexpected child environment %q", lines[0])
+	}
+
+	// The secret changes with the new token, restarting the child process
+	tokenCh <- "two"
+	lines = waitForLines(t, out, 2)
+	if lines[1] != "appuser:password-two" {
+		t.Fatalf("unexpected child environment after restart %q", lines[1])
+	}
+
+	cancel()
+	if err := <-errCh; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerRun_ChildExit(t *testing.T) {
+	vault := testVault(t)
+	defer vault.Close()
+
+	server := testServer(t, vault.URL, `exit 3`, map[string]string{
+		"DB_PASSWORD": `{{ with secret "kv/myapp/config" }}{{ .Data.password }}{{ end }}`,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tokenCh := make(chan string, 1)
+	tokenCh <- "one"
+	err := server.Run(ctx, tokenCh)
+	if err == nil || !strings.Contains(err.Error(), "exited with code 3") {
+		t.Fatalf("expected child exit error, got %v", err)
+	}
+}
+
+func TestServerRun_PermissionDenied(t *testing.T) {
+	vault := testVault(t)
+	defer vault.Close()
+
+	server := testServer(t, vault.URL, `exec sleep 60`, map[string]string{
+		"DB_PASSWORD": `{{ with secret "kv/myapp/perm-denied" }}{{ .Data.password }}{{ end }}`,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	tokenCh := make(chan string, 1)
+	tokenCh <- "one"
+	if err := server.Run(ctx, tokenCh); err == nil || ctx.Err() != nil {
+		t.Fatalf("expected error before the timeout, got %v", err)
+	}
+	if server.child != nil {
+		t.Fatal("expected child process not to be started")
+	}
+}
@@ -0,0 +1,17 @@
+// +build !windows
+
+package exec
+
+import (
+	"os"
+	"syscall"
+)
+
+// forwardedSignals are the signals received by the agent which are passed on
+// to the child process. Interrupts are not forwarded, they shut the agent
+// down, stopping the child process. Neither is SIGUSR1, which makes the agent
+// dump its in-memory telemetry as the server does.
+var forwardedSignals = []os.Signal{
+	syscall.SIGHUP,
+	syscall.SIGUSR2,
+}
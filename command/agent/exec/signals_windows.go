@@ -0,0 +1,9 @@
+// +build windows
+
+package exec
+
+import "os"
+
+// forwardedSignals are the signals received by the agent which are passed on
+// to the child process. None can be forwarded on Windows.
+var forwardedSignals = []os.Signal{}
@@ -1,23 +1,41 @@
 package file
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	osuser "os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/errwrap"
 	hclog "github.com/hashicorp/go-hclog"
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/sdk/helper/parseutil"
 )
 
+const defaultPostWriteTimeout = 30 * time.Second
+
 // fileSink is a Sink implementation that writes a token to a file
 type fileSink struct {
 	path   string
 	mode   os.FileMode
 	logger hclog.Logger
+
+	// uid and gid are the owner and group of the token file, or -1 to leave
+	// them unchanged
+	uid int
+	gid int
+
+	// postWriteCommand is run after every token written, within
+	// postWriteTimeout
+	postWriteCommand []string
+	postWriteTimeout time.Duration
 }
 
 // NewFileSink creates a new file sink with the given configuration
@@ -29,8 +47,11 @@ func NewFileSink(conf *sink.SinkConfig) (sink.Sink, error) {
 	conf.Logger.Info("creating file sink")
 
 	f := &fileSink{
-		logger: conf.Logger,
-		mode:   0640,
+		logger:           conf.Logger,
+		mode:             0640,
+		uid:              -1,
+		gid:              -1,
+		postWriteTimeout: defaultPostWriteTimeout,
 	}
 
 	pathRaw, ok := conf.Config["path"]
@@ -59,18 +80,98 @@ func NewFileSink(conf *sink.SinkConfig) (sink.Sink, error) {
 		f.mode = os.FileMode(mode)
 	}
 
+	if ownerRaw, ok := conf.Config["owner"]; ok {
+		uid, err := lookupID(ownerRaw, func(name string) (string, error) {
+			u, err := osuser.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return nil, errwrap.Wrapf("could not parse 'owner': {{err}}", err)
+		}
+		f.uid = uid
+	}
+
+	if groupRaw, ok := conf.Config["group"]; ok {
+		gid, err := lookupID(groupRaw, func(name string) (string, error) {
+			g, err := osuser.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return nil, errwrap.Wrapf("could not parse 'group': {{err}}", err)
+		}
+		f.gid = gid
+	}
+
+	if commandRaw, ok := conf.Config["post_write_command"]; ok {
+		switch command := commandRaw.(type) {
+		case string:
+			f.postWriteCommand = []string{"sh", "-c", command}
+		case []interface{}:
+			for _, arg := range command {
+				argStr, ok := arg.(string)
+				if !ok {
+					return nil, errors.New("could not parse 'post_write_command' as a list of strings")
+				}
+				f.postWriteCommand = append(f.postWriteCommand, argStr)
+			}
+		default:
+			return nil, errors.New("could not parse 'post_write_command' as a string or list of strings")
+		}
+		if len(f.postWriteCommand) == 0 || f.postWriteCommand[0] == "" {
+			return nil, errors.New("'post_write_command' is empty")
+		}
+	}
+
+	if timeoutRaw, ok := conf.Config["post_write_timeout"]; ok {
+		timeout, err := parseutil.ParseDurationSecond(timeoutRaw)
+		if err != nil {
+			return nil, errwrap.Wrapf("could not parse 'post_write_timeout': {{err}}", err)
+		}
+		if timeout <= 0 {
+			return nil, errors.New("'post_write_timeout' must be positive")
+		}
+		f.postWriteTimeout = timeout
+	}
+
 	if err := f.WriteToken(""); err != nil {
 		return nil, errwrap.Wrapf("error during write check: {{err}}", err)
 	}
 
-	f.logger.Info("file sink configured", "path", f.path, "mode", f.mode)
+	f.logger.Info("file sink configured", "path", f.path, "mode", f.mode, "uid", f.uid, "gid", f.gid)
 
 	return f, nil
 }
 
+// lookupID parses a user or group given either by its numeric ID or its name
+func lookupID(raw interface{}, lookup func(string) (string, error)) (int, error) {
+	switch v := raw.(type) {
+	case int:
+		return v, nil
+	case string:
+		if id, err := strconv.Atoi(v); err == nil {
+			return id, nil
+		}
+		idStr, err := lookup(v)
+		if err != nil {
+			return 0, err
+		}
+		return strconv.Atoi(idStr)
+	default:
+		return 0, fmt.Errorf("could not parse %v as a name or ID", raw)
+	}
+}
+
 // WriteToken implements the Server interface and writes the token to a path on
 // disk. It writes into the path's directory into a temp file and does an
-// atomic rename to ensure consistency. If a blank token is passed in, it
+// atomic rename to ensure consistency. The temp file is synced and given its
+// final mode and ownership before the rename, so readers never see a partial
+// token or a token with looser permissions. If a blank token is passed in, it
 // performs a write check but does not write a blank value to the final
 // location.
 func (f *fileSink) WriteToken(token string) error {
@@ -86,9 +187,9 @@ func (f *fileSink) WriteToken(token string) error {
 	fileName := filepath.Base(f.path)
 	tmpSuffix := strings.Split(u, "-")[0]
 
-	tmpFile, err := os.OpenFile(filepath.Join(targetDir, fmt.Sprintf("%s.tmp.%s", fileName, tmpSuffix)), os.O_WRONLY|os.O_CREATE, f.mode)
+	tmpFile, err := f.createTempFile(filepath.Join(targetDir, fmt.Sprintf("%s.tmp.%s", fileName, tmpSuffix)))
 	if err != nil {
-		return errwrap.Wrapf(fmt.Sprintf("error opening temp file in dir %s for writing: {{err}}", targetDir), err)
+		return err
 	}
 
 	valToWrite := token
@@ -104,6 +205,13 @@ func (f *fileSink) WriteToken(token string) error {
 		return errwrap.Wrapf(fmt.Sprintf("error writing to %s: {{err}}", tmpFile.Name()), err)
 	}
 
+	err = tmpFile.Sync()
+	if err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return errwrap.Wrapf(fmt.Sprintf("error syncing %s: {{err}}", tmpFile.Name()), err)
+	}
+
 	err = tmpFile.Close()
 	if err != nil {
 		return errwrap.Wrapf(fmt.Sprintf("error closing %s: {{err}}", tmpFile.Name()), err)
@@ -125,5 +233,61 @@ func (f *fileSink) WriteToken(token string) error {
 	}
 
 	f.logger.Info("token written", "path", f.path)
+
+	if len(f.postWriteCommand) > 0 {
+		if err := f.runPostWriteCommand(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setPermissions sets the mode and the configured ownership of the temp file
+// createTempFile creates the temp file the token is written to before being
+// renamed into place. The file is created readable by the agent only, and is
+// given the configured mode and ownership before anything is written to it.
+func (f *fileSink) createTempFile(name string) (*os.File, error) {
+	tmpFile, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, errwrap.Wrapf(fmt.Sprintf("error opening temp file in dir %s for writing: {{err}}", filepath.Dir(name)), err)
+	}
+
+	// The mode given to OpenFile is subject to the umask
+	if err := f.setPermissions(tmpFile); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return nil, err
+	}
+	return tmpFile, nil
+}
+
+func (f *fileSink) setPermissions(tmpFile *os.File) error {
+	if err := tmpFile.Chmod(f.mode); err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("error setting mode of %s: {{err}}", tmpFile.Name()), err)
+	}
+	if f.uid == -1 && f.gid == -1 {
+		return nil
+	}
+	if err := tmpFile.Chown(f.uid, f.gid); err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("error setting ownership of %s: {{err}}", tmpFile.Name()), err)
+	}
+	return nil
+}
+
+// runPostWriteCommand runs the configured command after a token was written.
+// The path of the token file is given in the VAULT_AGENT_SINK_PATH
+// environment variable.
+func (f *fileSink) runPostWriteCommand() error {
+	ctx, cancel := context.WithTimeout(context.Background(), f.postWriteTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, f.postWriteCommand[0], f.postWriteCommand[1:]...)
+	cmd.Env = append(os.Environ(), "VAULT_AGENT_SINK_PATH="+f.path)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return errwrap.Wrapf(fmt.Sprintf("error running post write command, output %q: {{err}}", strings.TrimSpace(string(output))), err)
+	}
+
+	f.logger.Debug("post write command run", "path", f.path)
 	return nil
 }
@@ -142,3 +142,27 @@ func TestFileSinkMode(t *testing.T) {
 		t.Fatalf("expected %s, got %s", uuidStr, string(fileBytes))
 	}
 }
+
+func TestFileSinkBadConfig(t *testing.T) {
+	log := logging.NewVaultLogger(hclog.Trace)
+
+	for name, config := range map[string]map[string]interface{}{
+		"unknown owner":         {"owner": "vault-agent-test-no-such-user"},
+		"bad group":             {"group": 1.5},
+		"empty hook":            {"post_write_command": []interface{}{}},
+		"bad hook":              {"post_write_command": 1},
+		"bad hook timeout":      {"post_write_command": "true", "post_write_timeout": "foo"},
+		"negative hook timeout": {"post_write_command": "true", "post_write_timeout": "-1s"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			config["path"] = filepath.Join(os.TempDir(), "token")
+			_, err := NewFileSink(&sink.SinkConfig{
+				Logger: log.Named("sink.file"),
+				Config: config,
+			})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
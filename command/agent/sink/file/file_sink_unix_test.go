@@ -0,0 +1,136 @@
+// +build !windows
+
+package file
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	hclog "github.com/hashicorp/go-hclog"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+)
+
+func TestFileSinkOwnershipAndHook(t *testing.T) {
+	log := logging.NewVaultLogger(hclog.Trace)
+
+	tmpDir, err := ioutil.TempDir("", fmt.Sprintf("%s.", fileServerTestDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	path := filepath.Join(tmpDir, "token")
+	hookPath := filepath.Join(tmpDir, "hook")
+
+	config := &sink.SinkConfig{
+		Logger: log.Named("sink.file"),
+		Config: map[string]interface{}{
+			"path":               path,
+			"mode":               0600,
+			"owner":              strconv.Itoa(os.Getuid()),
+			"group":              os.Getgid(),
+			"post_write_command": `cp "$VAULT_AGENT_SINK_PATH" "` + hookPath + `"`,
+		},
+	}
+	s, err := NewFileSink(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The hook isn't run on the write check
+	if _, err := os.Stat(hookPath); !os.IsNotExist(err) {
+		t.Fatalf("expected the hook not to have run: %v", err)
+	}
+
+	// The mode is set regardless of the umask
+	oldUmask := syscall.Umask(0077)
+	defer syscall.Umask(oldUmask)
+	config.Config["mode"] = 0640
+	s, err = NewFileSink(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uuidStr, _ := uuid.GenerateUUID()
+	if err := s.WriteToken(uuidStr); err != nil {
+		t.Fatal(err)
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != os.FileMode(0640) {
+		t.Fatalf("wrong file mode %v was detected at %s", fi.Mode(), path)
+	}
+
+	hookBytes, err := ioutil.ReadFile(hookPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(hookBytes) != uuidStr {
+		t.Fatalf("expected the hook to copy %s, got %s", uuidStr, string(hookBytes))
+	}
+
+	// A failing hook fails the write, so that it's retried
+	config.Config["post_write_command"] = []interface{}{"false"}
+	s, err = NewFileSink(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.WriteToken(uuidStr); err == nil {
+		t.Fatal("expected an error from the failing hook")
+	}
+}
+
+func TestFileSinkTempFilePermissions(t *testing.T) {
+	log := logging.NewVaultLogger(hclog.Trace)
+
+	tmpDir, err := ioutil.TempDir("", fmt.Sprintf("%s.", fileServerTestDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	oldUmask := syscall.Umask(0)
+	defer syscall.Umask(oldUmask)
+
+	s, err := NewFileSink(&sink.SinkConfig{
+		Logger: log.Named("sink.file"),
+		Config: map[string]interface{}{
+			"path": filepath.Join(tmpDir, "token"),
+			"mode": 0640,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The temp file has its final mode before the token is written to it
+	name := filepath.Join(tmpDir, "token.tmp")
+	tmpFile, err := s.(*fileSink).createTempFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tmpFile.Close()
+
+	fi, err := tmpFile.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode() != os.FileMode(0640) || fi.Size() != 0 {
+		t.Fatalf("expected an empty temp file with mode 0640, got mode %v and size %d", fi.Mode(), fi.Size())
+	}
+
+	// An existing file, which may be readable by others, isn't reused
+	if _, err := s.(*fileSink).createTempFile(name); err == nil {
+		t.Fatal("expected an error creating an existing temp file")
+	}
+}
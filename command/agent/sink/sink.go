@@ -9,6 +9,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	metrics "github.com/armon/go-metrics"
 	"github.com/hashicorp/errwrap"
 	hclog "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/api"
@@ -149,6 +150,7 @@ func (ss *SinkServer) Run(ctx context.Context, incoming chan string, sinks []*Si
 			}
 
 			if err := writeSink(st.sink, st.token); err != nil {
+				metrics.IncrCounter([]string{"agent", "sink", "failure"}, 1)
 				backoff := 2*time.Second + time.Duration(ss.random.Int63()%int64(time.Second*2)-int64(time.Second))
 				ss.logger.Error("error returned by sink function, retrying", "error", err, "backoff", backoff.String())
 				select {
@@ -159,6 +161,7 @@ func (ss *SinkServer) Run(ctx context.Context, incoming chan string, sinks []*Si
 					sinkCh <- st
 				}
 			} else {
+				metrics.IncrCounter([]string{"agent", "sink", "success"}, 1)
 				if atomic.LoadInt32(ss.remaining) == 0 && ss.exitAfterAuth {
 					return nil
 				}
@@ -0,0 +1,30 @@
+package socket
+
+import (
+	"net"
+	"syscall"
+)
+
+const peerCredentialsSupported = true
+
+// peerCredentials returns the uid and gid of the process connected to the
+// other end of the socket, as reported by SO_PEERCRED
+func peerCredentials(conn *net.UnixConn) (int, int, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if credErr != nil {
+		return 0, 0, credErr
+	}
+	return int(cred.Uid), int(cred.Gid), nil
+}
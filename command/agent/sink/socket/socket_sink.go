@@ -0,0 +1,200 @@
+package socket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/errwrap"
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/internalshared/listenerutil"
+)
+
+const writeTimeout = 5 * time.Second
+
+// socketSink is a Sink implementation that serves the token to the local
+// processes connecting to a unix socket. Each connection receives the current
+// token, after which it is closed.
+type socketSink struct {
+	path     string
+	logger   hclog.Logger
+	listener net.Listener
+
+	// allowedUIDs and allowedGIDs restrict the peers the token is served to,
+	// based on their credentials. If both are empty, any peer able to connect
+	// to the socket is served.
+	allowedUIDs map[int]bool
+	allowedGIDs map[int]bool
+
+	l     sync.RWMutex
+	token string
+}
+
+// NewSocketSink creates a new unix socket sink with the given configuration.
+// The socket is closed and removed once the context is done.
+func NewSocketSink(ctx context.Context, conf *sink.SinkConfig) (sink.Sink, error) {
+	if conf.Logger == nil {
+		return nil, errors.New("nil logger provided")
+	}
+
+	conf.Logger.Info("creating unix socket sink")
+
+	s := &socketSink{
+		logger: conf.Logger,
+	}
+
+	pathRaw, ok := conf.Config["path"]
+	if !ok {
+		return nil, errors.New("'path' not specified for unix socket sink")
+	}
+	path, ok := pathRaw.(string)
+	if !ok || path == "" {
+		return nil, errors.New("could not parse 'path' as string")
+	}
+	s.path = path
+
+	socketConfig := &listenerutil.UnixSocketsConfig{
+		Mode: "600",
+	}
+	if modeRaw, ok := conf.Config["mode"]; ok {
+		mode, ok := modeRaw.(int)
+		if !ok {
+			return nil, errors.New("could not parse 'mode' as integer")
+		}
+		socketConfig.Mode = strconv.FormatInt(int64(mode), 8)
+	}
+	for key, target := range map[string]*string{"user": &socketConfig.User, "group": &socketConfig.Group} {
+		raw, ok := conf.Config[key]
+		if !ok {
+			continue
+		}
+		switch v := raw.(type) {
+		case string:
+			*target = v
+		case int:
+			*target = strconv.Itoa(v)
+		default:
+			return nil, fmt.Errorf("could not parse %q as a name or ID", key)
+		}
+	}
+
+	var err error
+	if s.allowedUIDs, err = parseIDs(conf.Config["allowed_uids"]); err != nil {
+		return nil, errwrap.Wrapf("could not parse 'allowed_uids': {{err}}", err)
+	}
+	if s.allowedGIDs, err = parseIDs(conf.Config["allowed_gids"]); err != nil {
+		return nil, errwrap.Wrapf("could not parse 'allowed_gids': {{err}}", err)
+	}
+	if (len(s.allowedUIDs) > 0 || len(s.allowedGIDs) > 0) && !peerCredentialsSupported {
+		return nil, errors.New("'allowed_uids' and 'allowed_gids' are not supported on this platform")
+	}
+
+	s.listener, err = listenerutil.UnixSocketListener(s.path, socketConfig)
+	if err != nil {
+		return nil, errwrap.Wrapf("error creating unix socket: {{err}}", err)
+	}
+
+	go s.serve()
+	go func() {
+		<-ctx.Done()
+		s.listener.Close()
+	}()
+
+	s.logger.Info("unix socket sink configured", "path", s.path, "mode", socketConfig.Mode)
+
+	return s, nil
+}
+
+// parseIDs parses a list of user or group IDs
+func parseIDs(raw interface{}) (map[int]bool, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.New("expected a list of IDs")
+	}
+
+	ids := make(map[int]bool, len(list))
+	for _, item := range list {
+		switch v := item.(type) {
+		case int:
+			ids[v] = true
+		case string:
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ID %q", v)
+			}
+			ids[id] = true
+		default:
+			return nil, fmt.Errorf("invalid ID %v", item)
+		}
+	}
+	return ids, nil
+}
+
+// WriteToken implements the Sink interface and sets the token served to the
+// peers connecting from then on
+func (s *socketSink) WriteToken(token string) error {
+	if token == "" {
+		return nil
+	}
+
+	s.l.Lock()
+	s.token = token
+	s.l.Unlock()
+
+	s.logger.Info("token updated", "path", s.path)
+	return nil
+}
+
+func (s *socketSink) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			// The listener has been closed
+			s.logger.Debug("unix socket sink stopped", "path", s.path)
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle writes the token to an allowed peer and closes the connection
+func (s *socketSink) handle(conn net.Conn) {
+	defer conn.Close()
+
+	if len(s.allowedUIDs) > 0 || len(s.allowedGIDs) > 0 {
+		unixConn, ok := conn.(*net.UnixConn)
+		if !ok {
+			s.logger.Error("unexpected connection type on unix socket", "path", s.path)
+			return
+		}
+		uid, gid, err := peerCredentials(unixConn)
+		if err != nil {
+			s.logger.Error("error reading peer credentials", "path", s.path, "error", err)
+			return
+		}
+		if !s.allowedUIDs[uid] && !s.allowedGIDs[gid] {
+			s.logger.Warn("peer not allowed to read the token", "path", s.path, "uid", uid, "gid", gid)
+			return
+		}
+	}
+
+	s.l.RLock()
+	token := s.token
+	s.l.RUnlock()
+	if token == "" {
+		return
+	}
+
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := conn.Write([]byte(token)); err != nil {
+		s.logger.Error("error writing token to peer", "path", s.path, "error", err)
+	}
+}
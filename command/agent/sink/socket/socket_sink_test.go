@@ -0,0 +1,141 @@
+package socket
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/command/agent/sink"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+)
+
+func testSocketSink(t *testing.T, ctx context.Context, config map[string]interface{}) (sink.Sink, string) {
+	t.Helper()
+
+	tmpDir, err := ioutil.TempDir("", "vault-agent-socket-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(tmpDir, "agent.sock")
+	config["path"] = path
+
+	s, err := NewSocketSink(ctx, &sink.SinkConfig{
+		Logger: logging.NewVaultLogger(hclog.Trace).Named("sink.unix"),
+		Config: config,
+	})
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		t.Fatal(err)
+	}
+	return s, tmpDir
+}
+
+func readToken(t *testing.T, path string) string {
+	t.Helper()
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	token, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(token)
+}
+
+func TestSocketSink(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	s, tmpDir := testSocketSink(t, ctx, map[string]interface{}{})
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "agent.sock")
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Mode().Perm() != 0600 {
+		t.Fatalf("unexpected socket mode %v", fi.Mode().Perm())
+	}
+
+	// Nothing is served until a token is written
+	if token := readToken(t, path); token != "" {
+		t.Fatalf("unexpected token %q", token)
+	}
+
+	if err := s.WriteToken("token1"); err != nil {
+		t.Fatal(err)
+	}
+	if token := readToken(t, path); token != "token1" {
+		t.Fatalf("unexpected token %q", token)
+	}
+	if err := s.WriteToken("token2"); err != nil {
+		t.Fatal(err)
+	}
+	if token := readToken(t, path); token != "token2" {
+		t.Fatalf("unexpected token %q", token)
+	}
+}
+
+func TestSocketSink_AllowedIDs(t *testing.T) {
+	if !peerCredentialsSupported {
+		t.Skip("peer credentials are not supported on this platform")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	allowed, allowedDir := testSocketSink(t, ctx, map[string]interface{}{
+		"allowed_uids": []interface{}{os.Getuid()},
+	})
+	defer os.RemoveAll(allowedDir)
+	allowed.WriteToken("token")
+	if token := readToken(t, filepath.Join(allowedDir, "agent.sock")); token != "token" {
+		t.Fatalf("unexpected token %q", token)
+	}
+
+	allowedGroup, allowedGroupDir := testSocketSink(t, ctx, map[string]interface{}{
+		"allowed_uids": []interface{}{os.Getuid() + 1},
+		"allowed_gids": []interface{}{os.Getgid()},
+	})
+	defer os.RemoveAll(allowedGroupDir)
+	allowedGroup.WriteToken("token")
+	if token := readToken(t, filepath.Join(allowedGroupDir, "agent.sock")); token != "token" {
+		t.Fatalf("unexpected token %q", token)
+	}
+
+	denied, deniedDir := testSocketSink(t, ctx, map[string]interface{}{
+		"allowed_uids": []interface{}{os.Getuid() + 1},
+	})
+	defer os.RemoveAll(deniedDir)
+	denied.WriteToken("token")
+	if token := readToken(t, filepath.Join(deniedDir, "agent.sock")); token != "" {
+		t.Fatalf("expected no token to be served to a denied peer, got %q", token)
+	}
+}
+
+func TestSocketSink_Close(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, tmpDir := testSocketSink(t, ctx, map[string]interface{}{})
+	defer os.RemoveAll(tmpDir)
+	path := filepath.Join(tmpDir, "agent.sock")
+
+	cancel()
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("expected the socket to be removed once the context is done")
+}
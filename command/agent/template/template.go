@@ -14,6 +14,7 @@ import (
 
 	"go.uber.org/atomic"
 
+	metrics "github.com/armon/go-metrics"
 	ctconfig "github.com/hashicorp/consul-template/config"
 	ctlogging "github.com/hashicorp/consul-template/logging"
 	"github.com/hashicorp/consul-template/manager"
@@ -111,7 +112,7 @@ func (ts *Server) Run(ctx context.Context, incoming chan string, templates []*ct
 	// configuration
 	var runnerConfig *ctconfig.Config
 	var runnerConfigErr error
-	if runnerConfig, runnerConfigErr = newRunnerConfig(ts.config, templates); runnerConfigErr != nil {
+	if runnerConfig, runnerConfigErr = NewRunnerConfig(ts.config, templates); runnerConfigErr != nil {
 		return fmt.Errorf("template server failed to runner generate config: %w", runnerConfigErr)
 	}
 
@@ -182,11 +183,13 @@ func (ts *Server) Run(ctx context.Context, incoming chan string, templates []*ct
 			}
 
 		case err := <-ts.runner.ErrCh:
+			metrics.IncrCounter([]string{"agent", "template", "render", "error"}, 1)
 			ts.runner.StopImmediately()
 			return fmt.Errorf("template server: %w", err)
 
 		case <-ts.runner.TemplateRenderedCh():
 			// A template has been rendered, figure out what to do
+			metrics.IncrCounter([]string{"agent", "template", "rendered"}, 1)
 			events := ts.runner.RenderEvents()
 
 			// events are keyed by template ID, and can be matched up to the id's from
@@ -222,9 +225,9 @@ func (ts *Server) Stop() {
 	}
 }
 
-// newRunnerConfig returns a consul-template runner configuration, setting the
+// NewRunnerConfig returns a consul-template runner configuration, setting the
 // Vault and Consul configurations based on the clients configs.
-func newRunnerConfig(sc *ServerConfig, templates ctconfig.TemplateConfigs) (*ctconfig.Config, error) {
+func NewRunnerConfig(sc *ServerConfig, templates ctconfig.TemplateConfigs) (*ctconfig.Config, error) {
 	conf := ctconfig.DefaultConfig()
 	conf.Templates = templates.Copy()
 
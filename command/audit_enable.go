@@ -43,6 +43,20 @@ Usage: vault audit enable [options] TYPE [CONFIG K=V...]
 
       $ vault audit enable file file_path=/var/log/audit.log
 
+  Any audit device can be given a "filter" expression restricting the entries
+  it receives. Entries matching no device's filter are sent to all devices,
+  so that every request is audited. For example, to only send writes to KV
+  mounts and failed requests to a socket device:
+
+      $ vault audit enable socket address=siem:9090 \
+          filter="(mount_type == kv and operation != read) or error == true"
+
+  The "format" option selects how entries are written: "json" (the default),
+  "jsonx", "cef" (ArcSight Common Event Format), "leef" (IBM QRadar Log Event
+  Extended Format) or "otel" (OpenTelemetry log records):
+
+      $ vault audit enable syslog format=cef
+
 ` + c.Flags().Help()
 
 	return strings.TrimSpace(helpText)
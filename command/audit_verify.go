@@ -0,0 +1,167 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*AuditVerifyCommand)(nil)
+var _ cli.CommandAutocomplete = (*AuditVerifyCommand)(nil)
+
+type AuditVerifyCommand struct {
+	*BaseCommand
+}
+
+func (c *AuditVerifyCommand) Synopsis() string {
+	return "Verifies the hash chain of an audit log"
+}
+
+func (c *AuditVerifyCommand) Help() string {
+	helpText := `
+Usage: vault audit verify [options] PATH FILE
+
+  Verifies the hash chain of a log written by the file audit device enabled at
+  PATH with "hash_chain=true". Each entry carries the HMAC of the entry before
+  it, so removed or edited entries are reported as breaks in the chain. The
+  HMACs are computed by Vault using the audit device's salt, so the device
+  must still be enabled. Only logs in the "json" format can be verified.
+
+  Verify the log written by the audit device enabled at "file/":
+
+      $ vault audit verify file/ /var/log/vault_audit.log
+
+  The first entry of the file is not verified, as it may be linked to an entry
+  in a previous, rotated, file. Edits to the last entry cannot be detected.
+  The log can be verified while the device is still writing to it: only the
+  entries written before the verification started are verified, as hashing
+  the entries writes new ones to the log.
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *AuditVerifyCommand) Flags() *FlagSets {
+	return c.flagSet(FlagSetHTTP)
+}
+
+func (c *AuditVerifyCommand) AutocompleteArgs() complete.Predictor {
+	return c.PredictVaultAudits()
+}
+
+func (c *AuditVerifyCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *AuditVerifyCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	switch {
+	case len(args) < 2:
+		c.UI.Error(fmt.Sprintf("Not enough arguments (expected 2, got %d)", len(args)))
+		return 1
+	case len(args) > 2:
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 2, got %d)", len(args)))
+		return 1
+	}
+
+	path := ensureNoTrailingSlash(sanitizePath(args[0]))
+
+	file, err := os.Open(args[1])
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error opening audit log: %s", err))
+		return 1
+	}
+	defer file.Close()
+
+	// Hashing an entry is audited, possibly by the device being verified, so
+	// only the entries already written are read
+	info, err := file.Stat()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error opening audit log: %s", err))
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	var breaks int
+	var prev []byte
+	reader := bufio.NewReader(io.LimitReader(file, info.Size()))
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		// The last entry may have been partially written when the size of the
+		// log was taken
+		if err == io.EOF {
+			lineNum--
+			if breaks > 0 {
+				c.UI.Error(fmt.Sprintf("Verified %d entries, found %d breaks in the hash chain", lineNum, breaks))
+				return 2
+			}
+			c.UI.Output(fmt.Sprintf("Success! Verified the hash chain of %d entries", lineNum))
+			return 0
+		}
+		if err != nil && err != io.EOF {
+			c.UI.Error(fmt.Sprintf("Error reading audit log: %s", err))
+			return 1
+		}
+
+		prevHMAC, parseErr := auditEntryPrevHMAC(line)
+		switch {
+		case parseErr != nil:
+			c.UI.Error(fmt.Sprintf("Line %d: unable to parse entry: %s", lineNum, parseErr))
+			breaks++
+		case prev == nil:
+			// The first entry may be linked to an entry in a rotated file
+		case prevHMAC == "":
+			c.UI.Error(fmt.Sprintf("Line %d: entry is not chained", lineNum))
+			breaks++
+		default:
+			expected, err := client.Sys().AuditHash(path, string(prev))
+			if err != nil {
+				c.UI.Error(fmt.Sprintf("Error hashing audit entry: %s", err))
+				return 2
+			}
+			if prevHMAC != expected {
+				c.UI.Error(fmt.Sprintf("Line %d: hash chain broken, the previous entry was edited or entries before this one were removed", lineNum))
+				breaks++
+			}
+		}
+
+		prev = line
+	}
+}
+
+// auditEntryPrevHMAC returns the HMAC of the previous entry carried by a
+// JSON formatted audit entry, which may have a prefix
+func auditEntryPrevHMAC(line []byte) (string, error) {
+	start := bytes.IndexByte(line, '{')
+	if start < 0 {
+		return "", fmt.Errorf("not a JSON entry")
+	}
+
+	var entry struct {
+		PrevHMAC string `json:"prev_hmac"`
+	}
+	if err := json.Unmarshal(line[start:], &entry); err != nil {
+		return "", err
+	}
+	return entry.PrevHMAC, nil
+}
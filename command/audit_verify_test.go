@@ -0,0 +1,124 @@
+package command
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/mitchellh/cli"
+)
+
+func testAuditVerifyCommand(tb testing.TB) (*cli.MockUi, *AuditVerifyCommand) {
+	tb.Helper()
+
+	ui := cli.NewMockUi()
+	return ui, &AuditVerifyCommand{
+		BaseCommand: &BaseCommand{
+			UI: ui,
+		},
+	}
+}
+
+func TestAuditVerifyCommand_Run(t *testing.T) {
+	t.Parallel()
+
+	t.Run("validations", func(t *testing.T) {
+		t.Parallel()
+
+		cases := []struct {
+			name string
+			args []string
+			out  string
+		}{
+			{
+				"not_enough_args",
+				[]string{"file/"},
+				"Not enough arguments",
+			},
+			{
+				"too_many_args",
+				[]string{"file/", "audit.log", "extra"},
+				"Too many arguments",
+			},
+		}
+
+		for _, tc := range cases {
+			ui, cmd := testAuditVerifyCommand(t)
+
+			code := cmd.Run(tc.args)
+			if code != 1 {
+				t.Errorf("%s: expected %d to be %d", tc.name, code, 1)
+			}
+
+			combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+			if !strings.Contains(combined, tc.out) {
+				t.Errorf("%s: expected %q to contain %q", tc.name, combined, tc.out)
+			}
+		}
+	})
+
+	t.Run("live_log", func(t *testing.T) {
+		t.Parallel()
+
+		client, closer := testVaultServer(t)
+		defer closer()
+
+		dir, err := ioutil.TempDir("", "vault-audit-verify-")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(dir)
+		logPath := filepath.Join(dir, "audit.log")
+
+		if err := client.Sys().EnableAuditWithOptions("file", &api.EnableAuditOptions{
+			Type: "file",
+			Options: map[string]string{
+				"file_path":  logPath,
+				"hash_chain": "true",
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 5; i++ {
+			if _, err := client.Sys().ListMounts(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// The device is still writing to the log, including the requests
+		// made by the verification
+		ui, cmd := testAuditVerifyCommand(t)
+		cmd.client = client
+
+		codeCh := make(chan int, 1)
+		go func() {
+			codeCh <- cmd.Run([]string{"file/", logPath})
+		}()
+
+		select {
+		case code := <-codeCh:
+			if code != 0 {
+				t.Errorf("expected %d to be %d: %s", code, 0, ui.ErrorWriter.String())
+			}
+		case <-time.After(30 * time.Second):
+			t.Fatal("verification of a live log didn't terminate")
+		}
+
+		expected := "Success! Verified the hash chain"
+		combined := ui.OutputWriter.String() + ui.ErrorWriter.String()
+		if !strings.Contains(combined, expected) {
+			t.Errorf("expected %q to contain %q", combined, expected)
+		}
+	})
+
+	t.Run("no_tabs", func(t *testing.T) {
+		t.Parallel()
+
+		_, cmd := testAuditVerifyCommand(t)
+		assertNoTabs(t, cmd)
+	})
+}
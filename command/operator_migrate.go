@@ -1,13 +1,18 @@
 package command
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/url"
 	"os"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/errwrap"
@@ -29,6 +34,18 @@ var _ cli.CommandAutocomplete = (*OperatorMigrateCommand)(nil)
 
 var errAbort = errors.New("Migration aborted")
 
+const (
+	// storageMigrationCheckpoint is the key of the destination under which
+	// the progress of an interrupted migration is kept
+	storageMigrationCheckpoint = "core/migration-checkpoint"
+
+	// migrationCheckpointInterval is the number of keys copied between two
+	// checkpoints
+	migrationCheckpointInterval = 1000
+
+	defaultMigrationMaxParallel = 10
+)
+
 type OperatorMigrateCommand struct {
 	*BaseCommand
 
@@ -36,10 +53,42 @@ type OperatorMigrateCommand struct {
 	flagConfig       string
 	flagStart        string
 	flagReset        bool
+	flagMaxParallel  int
+	flagVerify       bool
+	flagDryRun       bool
 	logger           log.Logger
 	ShutdownCh       chan struct{}
 }
 
+// migrationCheckpoint records the progress of a migration: all the keys of
+// Source at or after Start, up to and including LastKey, have been copied
+type migrationCheckpoint struct {
+	Source  string    `json:"source"`
+	Start   string    `json:"start"`
+	LastKey string    `json:"last_key"`
+	Updated time.Time `json:"updated"`
+}
+
+// migrationStats counts the keys scanned by a dry run or verification
+type migrationStats struct {
+	l sync.Mutex
+
+	Keys     int
+	Size     int64
+	Prefixes map[string]*migrationPrefixStats
+
+	// Missing, Differing and Extra are the keys found only in the source,
+	// with different values, and only in the destination when verifying
+	Missing   int
+	Differing int
+	Extra     int
+}
+
+type migrationPrefixStats struct {
+	Keys int
+	Size int64
+}
+
 type migratorConfig struct {
 	StorageSource      *server.Storage `hcl:"-"`
 	StorageDestination *server.Storage `hcl:"-"`
@@ -62,6 +111,21 @@ Usage: vault operator migrate [options]
 
       $ vault operator migrate -config=migrate.hcl
 
+  The progress of the migration is checkpointed in the destination, so that
+  running the same command again after an interruption resumes it after the
+  last key copied. A migration can only be resumed with the same source and
+  start key.
+
+  Report the number and size of the keys which would be migrated, without
+  copying them:
+
+      $ vault operator migrate -config=migrate.hcl -dry-run
+
+  Compare the keys and values of the source and destination after a
+  migration:
+
+      $ vault operator migrate -config=migrate.hcl -verify
+
   For more information, please see the documentation.
 
 ` + c.Flags().Help()
@@ -95,6 +159,28 @@ func (c *OperatorMigrateCommand) Flags() *FlagSets {
 		Usage:  "Reset the migration lock. No migration will occur.",
 	})
 
+	f.IntVar(&IntVar{
+		Name:    "max-parallel",
+		Target:  &c.flagMaxParallel,
+		Default: defaultMigrationMaxParallel,
+		Usage: "Maximum number of keys copied or compared in parallel. This " +
+			"can speed up migrations from or to slow backends.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:   "verify",
+		Target: &c.flagVerify,
+		Usage: "Compare the keys and values of the source and destination " +
+			"instead of migrating. Fails if they differ.",
+	})
+
+	f.BoolVar(&BoolVar{
+		Name:   "dry-run",
+		Target: &c.flagDryRun,
+		Usage: "Report the number and size of the keys which would be " +
+			"migrated, without copying them or mounting the destination.",
+	})
+
 	return set
 }
 
@@ -120,6 +206,11 @@ func (c *OperatorMigrateCommand) Run(args []string) int {
 		return 1
 	}
 
+	if c.flagVerify && c.flagDryRun {
+		c.UI.Error("Only one of -verify and -dry-run can be specified")
+		return 1
+	}
+
 	config, err := c.loadMigratorConfig(c.flagConfig)
 	if err != nil {
 		c.UI.Error(fmt.Sprintf("Error loading configuration from %s: %s", c.flagConfig, err))
@@ -134,9 +225,13 @@ func (c *OperatorMigrateCommand) Run(args []string) int {
 		return 2
 	}
 
-	if c.flagReset {
+	switch {
+	case c.flagReset:
 		c.UI.Output("Success! Migration lock reset (if it was set).")
-	} else {
+	case c.flagVerify:
+		c.UI.Output("Success! The source and destination are identical.")
+	case c.flagDryRun:
+	default:
 		c.UI.Output("Success! All of the keys have been migrated.")
 	}
 
@@ -158,11 +253,36 @@ func (c *OperatorMigrateCommand) migrate(config *migratorConfig) error {
 		return nil
 	}
 
+	if c.flagDryRun {
+		return c.runWithShutdown(func(ctx context.Context) error {
+			stats, err := c.dryRun(ctx, from)
+			if err != nil {
+				return err
+			}
+			c.outputStats(stats)
+			return nil
+		})
+	}
+
 	to, err := c.createDestinationBackend(config.StorageDestination.Type, config.StorageDestination.Config, config)
 	if err != nil {
 		return errwrap.Wrapf("error mounting 'storage_destination': {{err}}", err)
 	}
 
+	if c.flagVerify {
+		return c.runWithShutdown(func(ctx context.Context) error {
+			stats, err := c.verify(ctx, from, to)
+			if err != nil {
+				return err
+			}
+			if stats.Missing > 0 || stats.Differing > 0 || stats.Extra > 0 {
+				return fmt.Errorf("verification failed: %d keys missing, %d keys differing and %d extra keys in the destination, out of %d keys", stats.Missing, stats.Differing, stats.Extra, stats.Keys)
+			}
+			c.UI.Output(fmt.Sprintf("Verified %d keys.", stats.Keys))
+			return nil
+		})
+	}
+
 	migrationStatus, err := CheckStorageMigration(from)
 	if err != nil {
 		return errwrap.Wrapf("error checking migration status: {{err}}", err)
@@ -185,11 +305,18 @@ func (c *OperatorMigrateCommand) migrate(config *migratorConfig) error {
 		defer SetStorageMigration(from, false)
 	}
 
+	return c.runWithShutdown(func(ctx context.Context) error {
+		return c.migrateAll(ctx, from, to, migrationSourceID(config.StorageSource))
+	})
+}
+
+// runWithShutdown runs fn, cancelling its context when shutdown is triggered
+func (c *OperatorMigrateCommand) runWithShutdown(fn func(ctx context.Context) error) error {
 	ctx, cancelFunc := context.WithCancel(context.Background())
 
 	doneCh := make(chan error)
 	go func() {
-		doneCh <- c.migrateAll(ctx, from, to)
+		doneCh <- fn(ctx)
 	}()
 
 	select {
@@ -204,13 +331,38 @@ func (c *OperatorMigrateCommand) migrate(config *migratorConfig) error {
 	}
 }
 
-// migrateAll copies all keys in lexicographic order.
-func (c *OperatorMigrateCommand) migrateAll(ctx context.Context, from physical.Backend, to physical.Backend) error {
-	return dfsScan(ctx, from, func(ctx context.Context, path string) error {
-		if path < c.flagStart || path == storageMigrationLock || path == vault.CoreLockPath {
-			return nil
+// migrateAll copies all keys in lexicographic order. It resumes after the
+// last key recorded by the checkpoint of an interrupted migration, which must
+// have been from the same source, identified by migrationSourceID, and start
+// key.
+func (c *OperatorMigrateCommand) migrateAll(ctx context.Context, from physical.Backend, to physical.Backend, source string) error {
+	checkpoint, err := readMigrationCheckpoint(ctx, to)
+	if err != nil {
+		return errwrap.Wrapf("error reading migration checkpoint: {{err}}", err)
+	}
+
+	var resumeAfter string
+	switch {
+	case checkpoint == nil:
+		// Checkpoint right away, so that the migration can be resumed even if
+		// it is interrupted before the first periodic checkpoint
+		if err := writeMigrationCheckpoint(ctx, to, source, c.flagStart, ""); err != nil {
+			return errwrap.Wrapf("error writing migration checkpoint: {{err}}", err)
 		}
+	case checkpoint.Source != source:
+		return errors.New("the destination holds the checkpoint of an interrupted migration from a different 'storage_source'; migrate to an empty destination instead")
+	case checkpoint.Start != c.flagStart:
+		return fmt.Errorf("the destination holds the checkpoint of an interrupted migration started at %q; resume it with the same -start value", checkpoint.Start)
+	default:
+		c.logger.Info("resuming migration from checkpoint", "last_key", checkpoint.LastKey, "updated", checkpoint.Updated.Format(time.RFC3339))
+		resumeAfter = checkpoint.LastKey
+	}
 
+	var copied int
+	var lastKey string
+	err = c.forEachKey(ctx, from, func(path string) bool {
+		return resumeAfter == "" || path > resumeAfter
+	}, func(ctx context.Context, path string) error {
 		entry, err := from.Get(ctx, path)
 
 		if err != nil {
@@ -226,7 +378,307 @@ func (c *OperatorMigrateCommand) migrateAll(ctx context.Context, from physical.B
 		}
 		c.logger.Info("copied key", "path", path)
 		return nil
+	}, func(path string) {
+		lastKey = path
+		copied++
+		if copied%migrationCheckpointInterval == 0 {
+			c.checkpoint(to, source, lastKey)
+		}
 	})
+
+	if err != nil || ctx.Err() != nil {
+		// Record the progress made since the last checkpoint so that the
+		// migration resumes from there
+		if lastKey != "" {
+			c.checkpoint(to, source, lastKey)
+		}
+		return err
+	}
+
+	// The migration is complete, so it won't need to be resumed
+	if err := to.Delete(ctx, storageMigrationCheckpoint); err != nil {
+		return errwrap.Wrapf("error deleting migration checkpoint: {{err}}", err)
+	}
+	return nil
+}
+
+// checkpoint records the last key copied to the destination. Checkpoints are
+// written even once the migration is cancelled, so they don't use its context.
+func (c *OperatorMigrateCommand) checkpoint(to physical.Backend, source, lastKey string) {
+	if err := writeMigrationCheckpoint(context.Background(), to, source, c.flagStart, lastKey); err != nil {
+		c.logger.Warn("failed to write migration checkpoint", "error", err)
+	}
+}
+
+// dryRun counts the keys which would be migrated, and their size
+func (c *OperatorMigrateCommand) dryRun(ctx context.Context, from physical.Backend) (*migrationStats, error) {
+	stats := &migrationStats{
+		Prefixes: make(map[string]*migrationPrefixStats),
+	}
+
+	err := c.forEachKey(ctx, from, nil, func(ctx context.Context, path string) error {
+		entry, err := from.Get(ctx, path)
+		if err != nil {
+			return errwrap.Wrapf("error reading entry: {{err}}", err)
+		}
+		if entry == nil {
+			return nil
+		}
+		stats.add(path, int64(len(entry.Value)))
+		return nil
+	}, nil)
+
+	return stats, err
+}
+
+// verify compares the keys and values of the source and destination
+func (c *OperatorMigrateCommand) verify(ctx context.Context, from, to physical.Backend) (*migrationStats, error) {
+	stats := &migrationStats{
+		Prefixes: make(map[string]*migrationPrefixStats),
+	}
+
+	err := c.forEachKey(ctx, from, nil, func(ctx context.Context, path string) error {
+		fromEntry, err := from.Get(ctx, path)
+		if err != nil {
+			return errwrap.Wrapf("error reading source entry: {{err}}", err)
+		}
+		if fromEntry == nil {
+			return nil
+		}
+		stats.add(path, int64(len(fromEntry.Value)))
+
+		toEntry, err := to.Get(ctx, path)
+		if err != nil {
+			return errwrap.Wrapf("error reading destination entry: {{err}}", err)
+		}
+
+		stats.l.Lock()
+		defer stats.l.Unlock()
+		switch {
+		case toEntry == nil:
+			c.logger.Warn("key missing from destination", "path", path)
+			stats.Missing++
+		case !bytes.Equal(fromEntry.Value, toEntry.Value):
+			c.logger.Warn("value differs in destination", "path", path)
+			stats.Differing++
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Look for the keys only present in the destination
+	err = c.forEachKey(ctx, to, nil, func(ctx context.Context, path string) error {
+		fromEntry, err := from.Get(ctx, path)
+		if err != nil {
+			return errwrap.Wrapf("error reading source entry: {{err}}", err)
+		}
+		if fromEntry != nil {
+			return nil
+		}
+
+		stats.l.Lock()
+		defer stats.l.Unlock()
+		c.logger.Warn("extra key in destination", "path", path)
+		stats.Extra++
+		return nil
+	}, nil)
+
+	return stats, err
+}
+
+// forEachKey calls fn with every key of the source which is migrated, from up
+// to max-parallel goroutines. Keys at or after the start key are migrated,
+// apart from the ones Vault and the migrator use for coordination, and the
+// ones rejected by the optional filter.
+//
+// The optional done function is called with the greatest key such that it and
+// all the keys before it were processed successfully, whenever it changes.
+func (c *OperatorMigrateCommand) forEachKey(ctx context.Context, source physical.Backend, filter func(path string) bool, fn func(ctx context.Context, path string) error, done func(lastKey string)) error {
+	parallel := c.flagMaxParallel
+	if parallel < 1 {
+		parallel = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var errOnce sync.Once
+	var firstErr error
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+
+	type migrationKey struct {
+		seq  int
+		path string
+	}
+	keysCh := make(chan migrationKey, parallel)
+
+	// Keys are processed out of order, so their completion is tracked to
+	// only report a key as done once all the ones before it are
+	var doneLock sync.Mutex
+	pending := make(map[int]string)
+	next := 0
+	markDone := func(k migrationKey) {
+		if done == nil {
+			return
+		}
+		doneLock.Lock()
+		defer doneLock.Unlock()
+		pending[k.seq] = k.path
+		for {
+			path, ok := pending[next]
+			if !ok {
+				return
+			}
+			delete(pending, next)
+			next++
+			done(path)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range keysCh {
+				if ctx.Err() != nil {
+					continue
+				}
+				if err := fn(ctx, k.path); err != nil {
+					fail(err)
+					continue
+				}
+				markDone(k)
+			}
+		}()
+	}
+
+	seq := 0
+	scanErr := dfsScan(ctx, source, func(ctx context.Context, path string) error {
+		if path < c.flagStart || path == storageMigrationLock || path == storageMigrationCheckpoint || path == vault.CoreLockPath {
+			return nil
+		}
+		if filter != nil && !filter(path) {
+			return nil
+		}
+
+		select {
+		case keysCh <- migrationKey{seq: seq, path: path}:
+			seq++
+		case <-ctx.Done():
+		}
+		return nil
+	})
+	close(keysCh)
+	wg.Wait()
+
+	if scanErr != nil {
+		return scanErr
+	}
+	return firstErr
+}
+
+// add counts a key of the given size
+func (s *migrationStats) add(path string, size int64) {
+	s.l.Lock()
+	defer s.l.Unlock()
+
+	prefix := path
+	if i := strings.Index(path, "/"); i >= 0 {
+		prefix = path[:i+1]
+	}
+	p, ok := s.Prefixes[prefix]
+	if !ok {
+		p = new(migrationPrefixStats)
+		s.Prefixes[prefix] = p
+	}
+
+	s.Keys++
+	s.Size += size
+	p.Keys++
+	p.Size += size
+}
+
+// outputStats reports the number and size of the keys, by top-level prefix
+func (c *OperatorMigrateCommand) outputStats(stats *migrationStats) {
+	c.UI.Output(tableOutput([]string{
+		fmt.Sprintf("Total Keys | %d", stats.Keys),
+		fmt.Sprintf("Total Size | %d", stats.Size),
+	}, nil))
+
+	prefixes := make([]string, 0, len(stats.Prefixes))
+	for prefix := range stats.Prefixes {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	c.UI.Output("")
+	out := []string{"Prefix | Keys | Size"}
+	for _, prefix := range prefixes {
+		p := stats.Prefixes[prefix]
+		out = append(out, fmt.Sprintf("%s | %d | %d", prefix, p.Keys, p.Size))
+	}
+	c.UI.Output(tableOutput(out, nil))
+}
+
+// readMigrationCheckpoint returns the checkpoint of an interrupted migration
+// to the destination, if any
+func readMigrationCheckpoint(ctx context.Context, to physical.Backend) (*migrationCheckpoint, error) {
+	entry, err := to.Get(ctx, storageMigrationCheckpoint)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var checkpoint migrationCheckpoint
+	if err := json.Unmarshal(entry.Value, &checkpoint); err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// writeMigrationCheckpoint records that all the keys of the source from the
+// start key up to and including the last key were copied to the destination
+func writeMigrationCheckpoint(ctx context.Context, to physical.Backend, source, start, lastKey string) error {
+	enc, err := json.Marshal(migrationCheckpoint{
+		Source:  source,
+		Start:   start,
+		LastKey: lastKey,
+		Updated: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	return to.Put(ctx, &physical.Entry{
+		Key:   storageMigrationCheckpoint,
+		Value: enc,
+	})
+}
+
+// migrationSourceID identifies a source backend by its type and a hash of its
+// configuration, so that a migration is only resumed from the same source
+func migrationSourceID(storage *server.Storage) string {
+	keys := make([]string, 0, len(storage.Config))
+	for k := range storage.Config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%q=%q\n", k, storage.Config[k])
+	}
+	return storage.Type + ":" + hex.EncodeToString(h.Sum(nil))
 }
 
 func (c *OperatorMigrateCommand) newBackend(kind string, conf map[string]string) (physical.Backend, error) {
@@ -259,13 +711,21 @@ func (c *OperatorMigrateCommand) createDestinationBackend(kind string, conf map[
 		if err != nil {
 			return nil, errwrap.Wrapf("error parsing cluster address: {{err}}", err)
 		}
-		if err := raftStorage.Bootstrap([]raft.Peer{
-			{
-				ID:      raftStorage.NodeID(),
-				Address: parsedClusterAddr.Host,
-			},
-		}); err != nil {
-			return nil, errwrap.Wrapf("could not bootstrap clustered storage: {{err}}", err)
+		// A destination with existing state was already bootstrapped, and
+		// can only be the target of a migration being resumed or verified
+		hasState, err := raftStorage.HasState()
+		if err != nil {
+			return nil, errwrap.Wrapf("could not check clustered storage state: {{err}}", err)
+		}
+		if !hasState {
+			if err := raftStorage.Bootstrap([]raft.Peer{
+				{
+					ID:      raftStorage.NodeID(),
+					Address: parsedClusterAddr.Host,
+				},
+			}); err != nil {
+				return nil, errwrap.Wrapf("could not bootstrap clustered storage: {{err}}", err)
+			}
 		}
 
 		if err := raftStorage.SetupCluster(context.Background(), raft.SetupOpts{
@@ -273,6 +733,20 @@ func (c *OperatorMigrateCommand) createDestinationBackend(kind string, conf map[
 		}); err != nil {
 			return nil, errwrap.Wrapf("could not start clustered storage: {{err}}", err)
 		}
+
+		if hasState && !c.flagVerify {
+			checkpoint, err := readMigrationCheckpoint(context.Background(), raftStorage)
+			if err != nil {
+				raftStorage.TeardownCluster(nil)
+				raftStorage.Close()
+				return nil, errwrap.Wrapf("error reading migration checkpoint: {{err}}", err)
+			}
+			if checkpoint == nil {
+				raftStorage.TeardownCluster(nil)
+				raftStorage.Close()
+				return nil, errors.New("clustered storage already has state, but no interrupted migration to resume")
+			}
+		}
 	}
 
 	return storage, nil
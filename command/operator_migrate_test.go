@@ -18,6 +18,7 @@ import (
 	log "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/command/server"
 	"github.com/hashicorp/vault/helper/testhelpers"
+	"github.com/hashicorp/vault/physical/raft"
 	"github.com/hashicorp/vault/sdk/helper/base62"
 	"github.com/hashicorp/vault/sdk/physical"
 	"github.com/hashicorp/vault/vault"
@@ -59,7 +60,7 @@ func TestMigration(t *testing.T) {
 		cmd := OperatorMigrateCommand{
 			logger: log.NewNullLogger(),
 		}
-		if err := cmd.migrateAll(context.Background(), from, to); err != nil {
+		if err := cmd.migrateAll(context.Background(), from, to, "source"); err != nil {
 			t.Fatal(err)
 		}
 
@@ -99,7 +100,7 @@ func TestMigration(t *testing.T) {
 			logger:    log.NewNullLogger(),
 			flagStart: start,
 		}
-		if err := cmd.migrateAll(context.Background(), from, to); err != nil {
+		if err := cmd.migrateAll(context.Background(), from, to, "source"); err != nil {
 			t.Fatal(err)
 		}
 
@@ -108,6 +109,272 @@ func TestMigration(t *testing.T) {
 		}
 	})
 
+	t.Run("Parallel", func(t *testing.T) {
+		data := generateData()
+
+		from, _ := physicalBackends["inmem"](map[string]string{}, nil)
+		if err := storeData(from, data); err != nil {
+			t.Fatal(err)
+		}
+		to, _ := physicalBackends["inmem"](map[string]string{}, nil)
+
+		cmd := OperatorMigrateCommand{
+			logger:          log.NewNullLogger(),
+			flagMaxParallel: 8,
+		}
+		if err := cmd.migrateAll(context.Background(), from, to, "source"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := compareStoredData(to, data, ""); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		data := generateData()
+
+		from, _ := physicalBackends["inmem"](map[string]string{}, nil)
+		if err := storeData(from, data); err != nil {
+			t.Fatal(err)
+		}
+		to, _ := physicalBackends["inmem"](map[string]string{}, nil)
+
+		// An interrupted migration copied all the keys up to "m"
+		const lastKey = "m"
+		if err := writeMigrationCheckpoint(context.Background(), to, "source", "", lastKey); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := OperatorMigrateCommand{
+			logger:          log.NewNullLogger(),
+			flagMaxParallel: 4,
+		}
+		if err := cmd.migrateAll(context.Background(), from, to, "source"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := compareStoredData(to, data, lastKey); err != nil {
+			t.Fatal(err)
+		}
+
+		checkpoint, err := readMigrationCheckpoint(context.Background(), to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if checkpoint != nil {
+			t.Fatalf("checkpoint wasn't deleted: %#v", checkpoint)
+		}
+	})
+
+	t.Run("Resume mismatch", func(t *testing.T) {
+		data := generateData()
+
+		from, _ := physicalBackends["inmem"](map[string]string{}, nil)
+		if err := storeData(from, data); err != nil {
+			t.Fatal(err)
+		}
+
+		cases := []struct {
+			name   string
+			source string
+			start  string
+			err    string
+		}{
+			{
+				"different_source",
+				"other",
+				"",
+				"different 'storage_source'",
+			},
+			{
+				"different_start",
+				"source",
+				"a",
+				"resume it with the same -start value",
+			},
+		}
+
+		for _, tc := range cases {
+			to, _ := physicalBackends["inmem"](map[string]string{}, nil)
+			if err := writeMigrationCheckpoint(context.Background(), to, tc.source, tc.start, "m"); err != nil {
+				t.Fatal(err)
+			}
+
+			cmd := OperatorMigrateCommand{
+				logger: log.NewNullLogger(),
+			}
+			err := cmd.migrateAll(context.Background(), from, to, "source")
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("%s: expected error containing %q, got %v", tc.name, tc.err, err)
+			}
+
+			keys, err := to.List(context.Background(), "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != 1 {
+				t.Fatalf("%s: expected only the checkpoint in the destination, got %v", tc.name, keys)
+			}
+		}
+	})
+
+	t.Run("Raft destination with state", func(t *testing.T) {
+		folder := filepath.Join(os.TempDir(), testhelpers.RandomWithPrefix("migrator"))
+		defer os.RemoveAll(folder)
+		if err := os.MkdirAll(folder, 0700); err != nil {
+			t.Fatal(err)
+		}
+		config := &migratorConfig{
+			ClusterAddr: "https://127.0.0.1:8201",
+		}
+		confTo := map[string]string{
+			"path":    folder,
+			"node_id": "migrator",
+		}
+
+		cmd := OperatorMigrateCommand{
+			logger:           log.NewNullLogger(),
+			PhysicalBackends: physicalBackends,
+		}
+		closeRaft := func(b physical.Backend) {
+			if err := b.(*raft.RaftBackend).TeardownCluster(nil); err != nil {
+				t.Fatal(err)
+			}
+			if err := b.(*raft.RaftBackend).Close(); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		to, err := cmd.createDestinationBackend("raft", confTo, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := writeMigrationCheckpoint(context.Background(), to, "source", "", "m"); err != nil {
+			t.Fatal(err)
+		}
+		closeRaft(to)
+
+		// The migration can be resumed
+		to, err = cmd.createDestinationBackend("raft", confTo, config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := to.Delete(context.Background(), storageMigrationCheckpoint); err != nil {
+			t.Fatal(err)
+		}
+		closeRaft(to)
+
+		// Without a checkpoint, the existing state isn't from an interrupted
+		// migration
+		_, err = cmd.createDestinationBackend("raft", confTo, config)
+		if err == nil || !strings.Contains(err.Error(), "no interrupted migration to resume") {
+			t.Fatalf("expected an error about the existing state, got %v", err)
+		}
+	})
+
+	t.Run("Source ID", func(t *testing.T) {
+		id := migrationSourceID(&server.Storage{
+			Type:   "file",
+			Config: map[string]string{"path": "/vault/a"},
+		})
+		if id != migrationSourceID(&server.Storage{
+			Type:   "file",
+			Config: map[string]string{"path": "/vault/a"},
+		}) {
+			t.Fatal("expected the same source to have the same ID")
+		}
+
+		for _, other := range []*server.Storage{
+			{Type: "file", Config: map[string]string{"path": "/vault/b"}},
+			{Type: "raft", Config: map[string]string{"path": "/vault/a"}},
+		} {
+			if migrationSourceID(other) == id {
+				t.Fatalf("expected %#v to have a different ID", other)
+			}
+		}
+	})
+
+	t.Run("Verify", func(t *testing.T) {
+		data := generateData()
+
+		from, _ := physicalBackends["inmem"](map[string]string{}, nil)
+		if err := storeData(from, data); err != nil {
+			t.Fatal(err)
+		}
+		to, _ := physicalBackends["inmem"](map[string]string{}, nil)
+
+		cmd := OperatorMigrateCommand{
+			logger:          log.NewNullLogger(),
+			flagMaxParallel: 4,
+		}
+		if err := cmd.migrateAll(context.Background(), from, to, "source"); err != nil {
+			t.Fatal(err)
+		}
+
+		stats, err := cmd.verify(context.Background(), from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Missing != 0 || stats.Differing != 0 || stats.Extra != 0 {
+			t.Fatalf("unexpected differences: %d missing, %d differing, %d extra", stats.Missing, stats.Differing, stats.Extra)
+		}
+
+		var keys []string
+		dfsScan(context.Background(), to, func(ctx context.Context, path string) error {
+			keys = append(keys, path)
+			return nil
+		})
+		if err := to.Delete(context.Background(), keys[0]); err != nil {
+			t.Fatal(err)
+		}
+		if err := to.Put(context.Background(), &physical.Entry{Key: keys[1], Value: []byte("changed")}); err != nil {
+			t.Fatal(err)
+		}
+		if err := to.Put(context.Background(), &physical.Entry{Key: "extra/key", Value: []byte("extra")}); err != nil {
+			t.Fatal(err)
+		}
+
+		stats, err = cmd.verify(context.Background(), from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Missing != 1 || stats.Differing != 1 || stats.Extra != 1 {
+			t.Fatalf("expected one difference of each kind: %d missing, %d differing, %d extra", stats.Missing, stats.Differing, stats.Extra)
+		}
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		data := generateData()
+
+		from, _ := physicalBackends["inmem"](map[string]string{}, nil)
+		if err := storeData(from, data); err != nil {
+			t.Fatal(err)
+		}
+
+		cmd := OperatorMigrateCommand{
+			logger: log.NewNullLogger(),
+		}
+		stats, err := cmd.dryRun(context.Background(), from)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The lock, empty and trailing slash keys aren't migrated
+		expected := len(data) - 4
+		if stats.Keys != expected || stats.Size != int64(expected*100) {
+			t.Fatalf("bad totals: %d keys, %d bytes", stats.Keys, stats.Size)
+		}
+
+		var prefixKeys int
+		for _, p := range stats.Prefixes {
+			prefixKeys += p.Keys
+		}
+		if prefixKeys != expected {
+			t.Fatalf("bad prefixes: %d keys", prefixKeys)
+		}
+	})
+
 	t.Run("Config parsing", func(t *testing.T) {
 		cmd := new(OperatorMigrateCommand)
 
@@ -36,6 +36,10 @@ Usage: vault operator raft <subcommand> [options] [args]
 
       $ vault operator raft remove-peer
 
+  Returns the health of the raft cluster servers as tracked by autopilot:
+
+      $ vault operator raft autopilot state
+
   Restores and saves snapshots from the raft cluster:
 
       $ vault operator raft snapshot save out.snap
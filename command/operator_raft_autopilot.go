@@ -0,0 +1,51 @@
+package command
+
+import (
+	"strings"
+
+	"github.com/mitchellh/cli"
+)
+
+var _ cli.Command = (*OperatorRaftAutopilotCommand)(nil)
+
+type OperatorRaftAutopilotCommand struct {
+	*BaseCommand
+}
+
+func (c *OperatorRaftAutopilotCommand) Synopsis() string {
+	return "Inspects and configures the autopilot of the Raft cluster"
+}
+
+func (c *OperatorRaftAutopilotCommand) Help() string {
+	helpText := `
+Usage: vault operator raft autopilot <subcommand> [options] [args]
+
+  This command groups subcommands for operators interacting with the autopilot
+  of the integrated Raft storage backend, which tracks the health of the servers
+  of the cluster and optionally removes the dead ones. Here are a few examples
+  of the Raft autopilot operator commands:
+
+  Returns the health of the servers of the Raft cluster:
+
+      $ vault operator raft autopilot state
+
+  Returns the configuration of autopilot:
+
+      $ vault operator raft autopilot get-config
+
+  Enables the removal of the servers dead for more than an hour:
+
+      $ vault operator raft autopilot set-config \
+          -cleanup-dead-servers=true \
+          -dead-server-last-contact-threshold=1h \
+          -min-quorum=3
+
+  Please see the individual subcommand help for detailed usage information.
+`
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftAutopilotCommand) Run(args []string) int {
+	return cli.RunResultHelp
+}
@@ -0,0 +1,74 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*OperatorRaftAutopilotGetConfigCommand)(nil)
+var _ cli.CommandAutocomplete = (*OperatorRaftAutopilotGetConfigCommand)(nil)
+
+type OperatorRaftAutopilotGetConfigCommand struct {
+	*BaseCommand
+}
+
+func (c *OperatorRaftAutopilotGetConfigCommand) Synopsis() string {
+	return "Returns the configuration of the Raft autopilot"
+}
+
+func (c *OperatorRaftAutopilotGetConfigCommand) Help() string {
+	helpText := `
+Usage: vault operator raft autopilot get-config
+
+  Provides the configuration of the autopilot of the Raft cluster.
+
+      $ vault operator raft autopilot get-config
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftAutopilotGetConfigCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP | FlagSetOutputFormat)
+
+	return set
+}
+
+func (c *OperatorRaftAutopilotGetConfigCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictAnything
+}
+
+func (c *OperatorRaftAutopilotGetConfigCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *OperatorRaftAutopilotGetConfigCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	secret, err := client.Logical().Read("sys/storage/raft/autopilot/configuration")
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error reading the raft autopilot configuration: %s", err))
+		return 2
+	}
+	if secret == nil {
+		c.UI.Error("No raft autopilot configuration found")
+		return 2
+	}
+
+	return OutputSecret(c.UI, secret)
+}
@@ -0,0 +1,157 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*OperatorRaftAutopilotSetConfigCommand)(nil)
+var _ cli.CommandAutocomplete = (*OperatorRaftAutopilotSetConfigCommand)(nil)
+
+type OperatorRaftAutopilotSetConfigCommand struct {
+	*BaseCommand
+
+	flagCleanupDeadServers             bool
+	flagLastContactThreshold           time.Duration
+	flagDeadServerLastContactThreshold time.Duration
+	flagMaxTrailingLogs                uint64
+	flagMinQuorum                      uint
+	flagServerStabilizationTime        time.Duration
+}
+
+func (c *OperatorRaftAutopilotSetConfigCommand) Synopsis() string {
+	return "Modifies the configuration of the Raft autopilot"
+}
+
+func (c *OperatorRaftAutopilotSetConfigCommand) Help() string {
+	helpText := `
+Usage: vault operator raft autopilot set-config [options]
+
+  Modifies the configuration of the autopilot of the Raft cluster. Only the
+  given options are changed.
+
+  Removes the servers which haven't contacted the active node for an hour, as
+  long as at least 3 voters remain:
+
+      $ vault operator raft autopilot set-config \
+          -cleanup-dead-servers=true \
+          -dead-server-last-contact-threshold=1h \
+          -min-quorum=3
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftAutopilotSetConfigCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP)
+	f := set.NewFlagSet("Command Options")
+
+	f.BoolVar(&BoolVar{
+		Name:    "cleanup-dead-servers",
+		Target:  &c.flagCleanupDeadServers,
+		Default: false,
+		Usage:   "Remove the servers which haven't contacted the active node for the dead server threshold.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:       "last-contact-threshold",
+		Target:     &c.flagLastContactThreshold,
+		Completion: complete.PredictAnything,
+		Usage:      "Time after which a server which hasn't contacted the active node is considered unhealthy.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:       "dead-server-last-contact-threshold",
+		Target:     &c.flagDeadServerLastContactThreshold,
+		Completion: complete.PredictAnything,
+		Usage:      "Time after which a server which hasn't contacted the active node is considered dead.",
+	})
+
+	f.Uint64Var(&Uint64Var{
+		Name:       "max-trailing-logs",
+		Target:     &c.flagMaxTrailingLogs,
+		Completion: complete.PredictAnything,
+		Usage:      "Number of log entries a server can lag behind the leader and still be considered healthy.",
+	})
+
+	f.UintVar(&UintVar{
+		Name:       "min-quorum",
+		Target:     &c.flagMinQuorum,
+		Completion: complete.PredictAnything,
+		Usage:      "Number of voters below which dead servers aren't removed. Must be at least 3 to remove dead servers.",
+	})
+
+	f.DurationVar(&DurationVar{
+		Name:       "server-stabilization-time",
+		Target:     &c.flagServerStabilizationTime,
+		Completion: complete.PredictAnything,
+		Usage:      "Time a server must be healthy for before counting towards the failure tolerance.",
+	})
+
+	return set
+}
+
+func (c *OperatorRaftAutopilotSetConfigCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictNothing
+}
+
+func (c *OperatorRaftAutopilotSetConfigCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *OperatorRaftAutopilotSetConfigCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	if args = f.Args(); len(args) > 0 {
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 0, got %d)", len(args)))
+		return 1
+	}
+
+	// Set these values only if they are provided in the CLI
+	data := make(map[string]interface{})
+	f.Visit(func(fl *flag.Flag) {
+		switch fl.Name {
+		case "cleanup-dead-servers":
+			data["cleanup_dead_servers"] = c.flagCleanupDeadServers
+		case "last-contact-threshold":
+			data["last_contact_threshold"] = c.flagLastContactThreshold.String()
+		case "dead-server-last-contact-threshold":
+			data["dead_server_last_contact_threshold"] = c.flagDeadServerLastContactThreshold.String()
+		case "max-trailing-logs":
+			data["max_trailing_logs"] = c.flagMaxTrailingLogs
+		case "min-quorum":
+			data["min_quorum"] = c.flagMinQuorum
+		case "server-stabilization-time":
+			data["server_stabilization_time"] = c.flagServerStabilizationTime.String()
+		}
+	})
+	if len(data) == 0 {
+		c.UI.Error("No configuration options provided")
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	if _, err := client.Logical().Write("sys/storage/raft/autopilot/configuration", data); err != nil {
+		c.UI.Error(fmt.Sprintf("Error updating the raft autopilot configuration: %s", err))
+		return 2
+	}
+
+	c.UI.Output("Success! Updated the raft autopilot configuration.")
+	return 0
+}
@@ -0,0 +1,105 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mitchellh/cli"
+	"github.com/posener/complete"
+)
+
+var _ cli.Command = (*OperatorRaftAutopilotStateCommand)(nil)
+var _ cli.CommandAutocomplete = (*OperatorRaftAutopilotStateCommand)(nil)
+
+type OperatorRaftAutopilotStateCommand struct {
+	*BaseCommand
+}
+
+func (c *OperatorRaftAutopilotStateCommand) Synopsis() string {
+	return "Returns the health of the Raft cluster as tracked by autopilot"
+}
+
+func (c *OperatorRaftAutopilotStateCommand) Help() string {
+	helpText := `
+Usage: vault operator raft autopilot state
+
+  Provides the health of the servers of the Raft cluster, as tracked by
+  autopilot on the active node, along with the number of voters which can fail
+  without losing quorum.
+
+      $ vault operator raft autopilot state
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *OperatorRaftAutopilotStateCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP | FlagSetOutputFormat)
+
+	return set
+}
+
+func (c *OperatorRaftAutopilotStateCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictAnything
+}
+
+func (c *OperatorRaftAutopilotStateCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *OperatorRaftAutopilotStateCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	secret, err := client.Logical().Read("sys/storage/raft/autopilot/state")
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error reading the raft autopilot state: %s", err))
+		return 2
+	}
+	if secret == nil {
+		c.UI.Error("No raft autopilot state found")
+		return 2
+	}
+
+	if Format(c.UI) != "table" {
+		return OutputSecret(c.UI, secret)
+	}
+
+	c.UI.Output(tableOutput([]string{
+		fmt.Sprintf("Healthy | %v", secret.Data["healthy"]),
+		fmt.Sprintf("Failure Tolerance | %v", secret.Data["failure_tolerance"]),
+		fmt.Sprintf("Leader | %v", secret.Data["leader"]),
+	}, nil))
+	c.UI.Output("")
+
+	servers, _ := secret.Data["servers"].(map[string]interface{})
+	ids := make([]string, 0, len(servers))
+	for id := range servers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out := []string{"Node | Address | Status | Healthy | Last Contact | Last Index"}
+	for _, id := range ids {
+		server, ok := servers[id].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		out = append(out, fmt.Sprintf("%s | %v | %v | %v | %v | %v", id, server["address"], server["status"], server["healthy"], server["last_contact"], server["last_index"]))
+	}
+
+	c.UI.Output(tableOutput(out, nil))
+	return 0
+}
@@ -1060,6 +1060,13 @@ func isForm(head []byte, contentType string) bool {
 	return true
 }
 
+// isOCSPRequest reports whether the request body is a DER-encoded OCSP
+// request, as defined in RFC 6960 Appendix A.
+func isOCSPRequest(contentType string) bool {
+	contentType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && contentType == "application/ocsp-request"
+}
+
 func respondError(w http.ResponseWriter, status int, err error) {
 	logical.RespondError(w, status, err)
 }
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
 	"strconv"
@@ -122,6 +123,17 @@ func buildLogicalRequestNoAuth(perfStandby bool, w http.ResponseWriter, r *http.
 				}
 
 				data = formData
+			} else if isOCSPRequest(r.Header.Get("Content-Type")) {
+				// OCSP requests are DER-encoded, so hand the raw body to the
+				// backend rather than attempting to parse it
+				body, err := ioutil.ReadAll(r.Body)
+				if err != nil {
+					return nil, nil, http.StatusBadRequest, fmt.Errorf("error reading OCSP request: %w", err)
+				}
+
+				data = map[string]interface{}{
+					logical.HTTPRawBody: body,
+				}
 			} else {
 				origBody, err = parseJSONRequest(perfStandby, r, w, &data)
 				if err == io.EOF {
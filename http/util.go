@@ -26,8 +26,6 @@ var (
 	}
 
 	additionalRoutes = func(mux *http.ServeMux, core *vault.Core) {}
-
-	nonVotersAllowed = false
 )
 
 func rateLimitQuotaWrapping(handler http.Handler, core *vault.Core) http.Handler {
@@ -0,0 +1,367 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	log "github.com/hashicorp/go-hclog"
+)
+
+const (
+	// AutopilotStatusLeader, AutopilotStatusVoter and AutopilotStatusNonVoter
+	// are the statuses autopilot reports for servers
+	AutopilotStatusLeader   = "leader"
+	AutopilotStatusVoter    = "voter"
+	AutopilotStatusNonVoter = "non-voter"
+)
+
+var (
+	// autopilotUpdateInterval is how often autopilot updates the state of the
+	// servers and prunes dead ones
+	autopilotUpdateInterval = 10 * time.Second
+)
+
+// AutopilotConfig is the configuration of autopilot on the active node
+type AutopilotConfig struct {
+	// CleanupDeadServers enables the removal of the servers which haven't
+	// contacted the leader for DeadServerLastContactThreshold
+	CleanupDeadServers bool `json:"cleanup_dead_servers"`
+
+	// LastContactThreshold is the time after which a server which hasn't
+	// contacted the leader is considered unhealthy
+	LastContactThreshold time.Duration `json:"last_contact_threshold"`
+
+	// DeadServerLastContactThreshold is the time after which a server which
+	// hasn't contacted the leader is considered dead, and may be removed
+	DeadServerLastContactThreshold time.Duration `json:"dead_server_last_contact_threshold"`
+
+	// MaxTrailingLogs is the number of log entries a server may lag behind
+	// the leader and still be considered healthy
+	MaxTrailingLogs uint64 `json:"max_trailing_logs"`
+
+	// MinQuorum is the number of voters below which dead servers aren't
+	// removed
+	MinQuorum uint `json:"min_quorum"`
+
+	// ServerStabilizationTime is how long a server must be healthy before it
+	// counts towards the failure tolerance of the cluster
+	ServerStabilizationTime time.Duration `json:"server_stabilization_time"`
+}
+
+// DefaultAutopilotConfig returns the configuration autopilot runs with until
+// one is set
+func DefaultAutopilotConfig() *AutopilotConfig {
+	return &AutopilotConfig{
+		LastContactThreshold:           10 * time.Second,
+		DeadServerLastContactThreshold: 24 * time.Hour,
+		MaxTrailingLogs:                1000,
+		ServerStabilizationTime:        10 * time.Second,
+	}
+}
+
+// Validate checks the configuration is consistent
+func (c *AutopilotConfig) Validate() error {
+	switch {
+	case c.LastContactThreshold <= 0:
+		return errors.New("last_contact_threshold must be positive")
+	case c.DeadServerLastContactThreshold < time.Minute:
+		return errors.New("dead_server_last_contact_threshold must be at least one minute")
+	case c.DeadServerLastContactThreshold < c.LastContactThreshold:
+		return errors.New("dead_server_last_contact_threshold must not be less than last_contact_threshold")
+	case c.ServerStabilizationTime < 0:
+		return errors.New("server_stabilization_time must not be negative")
+	case c.CleanupDeadServers && c.MinQuorum < 3:
+		return errors.New("min_quorum must be at least 3 when cleanup_dead_servers is set")
+	}
+	return nil
+}
+
+// FollowerState is the state of a follower as last reported through its
+// heartbeats to the active node
+type FollowerState struct {
+	AppliedIndex  uint64
+	LastHeartbeat time.Time
+}
+
+// AutopilotServer is the state of a server as tracked by autopilot
+type AutopilotServer struct {
+	ID          string    `json:"id"`
+	Address     string    `json:"address"`
+	Status      string    `json:"status"`
+	Healthy     bool      `json:"healthy"`
+	LastContact string    `json:"last_contact"`
+	LastIndex   uint64    `json:"last_index"`
+	StableSince time.Time `json:"stable_since"`
+
+	lastContact time.Duration
+}
+
+// AutopilotState is the state of the cluster as tracked by autopilot
+type AutopilotState struct {
+	// Healthy is set if all the servers are healthy
+	Healthy bool `json:"healthy"`
+
+	// FailureTolerance is the number of voters which can fail without losing
+	// quorum, counting only the healthy and stable ones
+	FailureTolerance int                         `json:"failure_tolerance"`
+	Leader           string                      `json:"leader"`
+	Voters           []string                    `json:"voters"`
+	Servers          map[string]*AutopilotServer `json:"servers"`
+}
+
+// autopilotDelegate is the part of the raft backend used by autopilot
+type autopilotDelegate interface {
+	NodeID() string
+	AppliedIndex() uint64
+	GetConfiguration(context.Context) (*RaftConfigurationResponse, error)
+	RemovePeer(context.Context, string) error
+}
+
+// Autopilot tracks the health of the servers of the cluster on the active
+// node, and optionally removes the dead ones
+type Autopilot struct {
+	logger         log.Logger
+	delegate       autopilotDelegate
+	followerStates func() map[string]FollowerState
+
+	l           sync.RWMutex
+	config      *AutopilotConfig
+	state       *AutopilotState
+	stableSince map[string]time.Time
+
+	// started is used as the last contact of the followers which haven't
+	// sent any heartbeat yet
+	started time.Time
+}
+
+// NewAutopilot returns autopilot for the backend. followerStates returns the
+// state of the followers as reported through their heartbeats, and removePeer
+// removes a dead server from the cluster along with that state.
+func NewAutopilot(b *RaftBackend, followerStates func() map[string]FollowerState, removePeer func(context.Context, string) error, logger log.Logger) *Autopilot {
+	return newAutopilot(&autopilotBackend{
+		RaftBackend: b,
+		removePeer:  removePeer,
+	}, followerStates, logger)
+}
+
+// autopilotBackend removes the dead servers through the function given to
+// NewAutopilot rather than directly from the raft backend
+type autopilotBackend struct {
+	*RaftBackend
+	removePeer func(context.Context, string) error
+}
+
+func (b *autopilotBackend) RemovePeer(ctx context.Context, peerID string) error {
+	return b.removePeer(ctx, peerID)
+}
+
+func newAutopilot(delegate autopilotDelegate, followerStates func() map[string]FollowerState, logger log.Logger) *Autopilot {
+	return &Autopilot{
+		logger:         logger,
+		delegate:       delegate,
+		followerStates: followerStates,
+		config:         DefaultAutopilotConfig(),
+		stableSince:    make(map[string]time.Time),
+		started:        time.Now(),
+	}
+}
+
+// SetConfig updates the configuration of autopilot
+func (a *Autopilot) SetConfig(config *AutopilotConfig) {
+	a.l.Lock()
+	defer a.l.Unlock()
+	a.config = config
+}
+
+// Config returns a copy of the configuration of autopilot
+func (a *Autopilot) Config() *AutopilotConfig {
+	a.l.RLock()
+	defer a.l.RUnlock()
+	config := *a.config
+	return &config
+}
+
+// State returns the latest state computed by autopilot, or nil if it hasn't
+// run yet
+func (a *Autopilot) State() *AutopilotState {
+	a.l.RLock()
+	defer a.l.RUnlock()
+	return a.state
+}
+
+// Run updates the state of the cluster periodically until the stop channel is
+// closed
+func (a *Autopilot) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(autopilotUpdateInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := a.update(context.Background()); err != nil {
+			a.logger.Error("failed to update autopilot state", "error", err)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// update computes the state of the cluster and removes the dead servers if
+// configured to
+func (a *Autopilot) update(ctx context.Context) error {
+	config := a.Config()
+	state, err := a.computeState(ctx, config, time.Now())
+	if err != nil {
+		return err
+	}
+
+	a.l.Lock()
+	a.state = state
+	a.l.Unlock()
+
+	metrics.SetGauge([]string{"raft_storage", "autopilot", "healthy"}, boolToGauge(state.Healthy))
+	metrics.SetGauge([]string{"raft_storage", "autopilot", "failure_tolerance"}, float32(state.FailureTolerance))
+
+	if config.CleanupDeadServers {
+		return a.pruneDeadServers(ctx, config, state)
+	}
+	return nil
+}
+
+// computeState builds the state of the cluster from its configuration and the
+// heartbeats of the followers
+func (a *Autopilot) computeState(ctx context.Context, config *AutopilotConfig, now time.Time) (*AutopilotState, error) {
+	raftConfig, err := a.delegate.GetConfiguration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	followers := a.followerStates()
+	leaderIndex := a.delegate.AppliedIndex()
+
+	a.l.Lock()
+	defer a.l.Unlock()
+
+	state := &AutopilotState{
+		Healthy: true,
+		Servers: make(map[string]*AutopilotServer, len(raftConfig.Servers)),
+	}
+	healthyVoters := 0
+	for _, server := range raftConfig.Servers {
+		s := &AutopilotServer{
+			ID:      server.NodeID,
+			Address: server.Address,
+			Status:  AutopilotStatusNonVoter,
+		}
+		if server.Voter {
+			s.Status = AutopilotStatusVoter
+			state.Voters = append(state.Voters, server.NodeID)
+		}
+
+		if server.NodeID == a.delegate.NodeID() {
+			s.Status = AutopilotStatusLeader
+			s.LastIndex = leaderIndex
+			s.Healthy = true
+			state.Leader = server.NodeID
+		} else {
+			follower := followers[server.NodeID]
+			lastHeartbeat := follower.LastHeartbeat
+			if lastHeartbeat.IsZero() {
+				lastHeartbeat = a.started
+			}
+			s.lastContact = now.Sub(lastHeartbeat)
+			s.LastIndex = follower.AppliedIndex
+			s.Healthy = s.lastContact < config.LastContactThreshold &&
+				follower.AppliedIndex+config.MaxTrailingLogs >= leaderIndex
+		}
+		s.LastContact = s.lastContact.String()
+
+		if s.Healthy {
+			stableSince, ok := a.stableSince[s.ID]
+			if !ok {
+				stableSince = now
+				a.stableSince[s.ID] = now
+			}
+			s.StableSince = stableSince
+			if server.Voter && now.Sub(stableSince) >= config.ServerStabilizationTime {
+				healthyVoters++
+			}
+		} else {
+			delete(a.stableSince, s.ID)
+			state.Healthy = false
+		}
+
+		state.Servers[s.ID] = s
+	}
+
+	// Forget the servers which left the cluster
+	for id := range a.stableSince {
+		if _, ok := state.Servers[id]; !ok {
+			delete(a.stableSince, id)
+		}
+	}
+
+	sort.Strings(state.Voters)
+	quorum := len(state.Voters)/2 + 1
+	if healthyVoters > quorum {
+		state.FailureTolerance = healthyVoters - quorum
+	}
+
+	return state, nil
+}
+
+// pruneDeadServers removes the servers which haven't contacted the leader
+// for longer than the dead server threshold. Voters are only removed while
+// the number of voters stays at or above the minimum quorum.
+func (a *Autopilot) pruneDeadServers(ctx context.Context, config *AutopilotConfig, state *AutopilotState) error {
+	var dead []*AutopilotServer
+	for _, s := range state.Servers {
+		if s.Status != AutopilotStatusLeader && s.lastContact >= config.DeadServerLastContactThreshold {
+			dead = append(dead, s)
+		}
+	}
+	if len(dead) == 0 {
+		return nil
+	}
+
+	// Remove the non-voters first, then the servers which have been dead the
+	// longest
+	sort.Slice(dead, func(i, j int) bool {
+		if (dead[i].Status == AutopilotStatusNonVoter) != (dead[j].Status == AutopilotStatusNonVoter) {
+			return dead[i].Status == AutopilotStatusNonVoter
+		}
+		return dead[i].lastContact > dead[j].lastContact
+	})
+
+	voters := len(state.Voters)
+	for _, s := range dead {
+		if s.Status == AutopilotStatusVoter {
+			if uint(voters-1) < config.MinQuorum {
+				a.logger.Warn("not removing dead server, the cluster would fall below the minimum quorum", "id", s.ID, "last_contact", s.LastContact, "min_quorum", config.MinQuorum)
+				continue
+			}
+		}
+
+		a.logger.Info("removing dead server", "id", s.ID, "last_contact", s.LastContact)
+		if err := a.delegate.RemovePeer(ctx, s.ID); err != nil {
+			return err
+		}
+		metrics.IncrCounter([]string{"raft_storage", "autopilot", "dead_server_removed"}, 1)
+		if s.Status == AutopilotStatusVoter {
+			voters--
+		}
+	}
+	return nil
+}
+
+func boolToGauge(b bool) float32 {
+	if b {
+		return 1
+	}
+	return 0
+}
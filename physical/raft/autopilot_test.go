@@ -0,0 +1,228 @@
+package raft
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+type testAutopilotDelegate struct {
+	nodeID       string
+	appliedIndex uint64
+	servers      []*RaftServer
+	removed      []string
+}
+
+func (d *testAutopilotDelegate) NodeID() string {
+	return d.nodeID
+}
+
+func (d *testAutopilotDelegate) AppliedIndex() uint64 {
+	return d.appliedIndex
+}
+
+func (d *testAutopilotDelegate) GetConfiguration(context.Context) (*RaftConfigurationResponse, error) {
+	return &RaftConfigurationResponse{
+		Servers: d.servers,
+	}, nil
+}
+
+func (d *testAutopilotDelegate) RemovePeer(_ context.Context, id string) error {
+	d.removed = append(d.removed, id)
+	servers := d.servers[:0]
+	for _, s := range d.servers {
+		if s.NodeID != id {
+			servers = append(servers, s)
+		}
+	}
+	d.servers = servers
+	return nil
+}
+
+func testAutopilot(delegate *testAutopilotDelegate, followers map[string]FollowerState) *Autopilot {
+	return newAutopilot(delegate, func() map[string]FollowerState {
+		return followers
+	}, hclog.NewNullLogger())
+}
+
+func TestAutopilotConfig_Validate(t *testing.T) {
+	config := DefaultAutopilotConfig()
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	config.CleanupDeadServers = true
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected an error for cleanup without a min_quorum")
+	}
+
+	config.MinQuorum = 3
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	config.DeadServerLastContactThreshold = 30 * time.Second
+	if err := config.Validate(); err == nil {
+		t.Fatal("expected an error for a dead server threshold under a minute")
+	}
+}
+
+func TestAutopilot_State(t *testing.T) {
+	delegate := &testAutopilotDelegate{
+		nodeID:       "node1",
+		appliedIndex: 2000,
+		servers: []*RaftServer{
+			{NodeID: "node1", Address: "127.0.0.1:8201", Voter: true},
+			{NodeID: "node2", Address: "127.0.0.2:8201", Voter: true},
+			{NodeID: "node3", Address: "127.0.0.3:8201", Voter: true},
+			{NodeID: "node4", Address: "127.0.0.4:8201"},
+		},
+	}
+	now := time.Now()
+	a := testAutopilot(delegate, map[string]FollowerState{
+		"node2": {AppliedIndex: 1990, LastHeartbeat: now.Add(-time.Second)},
+		// Lagging too far behind the leader
+		"node3": {AppliedIndex: 500, LastHeartbeat: now.Add(-time.Second)},
+		"node4": {AppliedIndex: 2000, LastHeartbeat: now.Add(-time.Minute)},
+	})
+
+	config := DefaultAutopilotConfig()
+	config.ServerStabilizationTime = 5 * time.Second
+	state, err := a.computeState(context.Background(), config, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state.Healthy {
+		t.Fatal("expected the cluster to be unhealthy")
+	}
+	if state.Leader != "node1" {
+		t.Fatalf("bad leader: %q", state.Leader)
+	}
+	if len(state.Voters) != 3 {
+		t.Fatalf("bad voters: %v", state.Voters)
+	}
+
+	expected := map[string]struct {
+		status  string
+		healthy bool
+	}{
+		"node1": {AutopilotStatusLeader, true},
+		"node2": {AutopilotStatusVoter, true},
+		"node3": {AutopilotStatusVoter, false},
+		"node4": {AutopilotStatusNonVoter, false},
+	}
+	for id, e := range expected {
+		s := state.Servers[id]
+		if s == nil {
+			t.Fatalf("missing server %q", id)
+		}
+		if s.Status != e.status || s.Healthy != e.healthy {
+			t.Fatalf("bad state of %q: %#v", id, s)
+		}
+	}
+
+	// The healthy voters aren't stable yet
+	if state.FailureTolerance != 0 {
+		t.Fatalf("bad failure tolerance: %d", state.FailureTolerance)
+	}
+	state, err = a.computeState(context.Background(), config, now.Add(config.ServerStabilizationTime))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Servers["node2"].StableSince != now {
+		t.Fatalf("bad stable since: %v", state.Servers["node2"].StableSince)
+	}
+
+	// Two of the three voters are healthy, which is exactly quorum
+	if state.FailureTolerance != 0 {
+		t.Fatalf("bad failure tolerance: %d", state.FailureTolerance)
+	}
+}
+
+func TestAutopilot_PruneDeadServers(t *testing.T) {
+	delegate := &testAutopilotDelegate{
+		nodeID: "node1",
+		servers: []*RaftServer{
+			{NodeID: "node1", Voter: true},
+			{NodeID: "node2", Voter: true},
+			{NodeID: "node3", Voter: true},
+			{NodeID: "node4", Voter: true},
+			{NodeID: "node5", Voter: true},
+			{NodeID: "node6"},
+		},
+	}
+	now := time.Now()
+	dead := now.Add(-48 * time.Hour)
+	a := testAutopilot(delegate, map[string]FollowerState{
+		"node2": {LastHeartbeat: now},
+		"node3": {LastHeartbeat: now},
+		"node4": {LastHeartbeat: dead},
+		"node5": {LastHeartbeat: dead.Add(-time.Hour)},
+		"node6": {LastHeartbeat: dead},
+	})
+
+	config := DefaultAutopilotConfig()
+	config.CleanupDeadServers = true
+	config.MinQuorum = 4
+	a.SetConfig(config)
+
+	state, err := a.computeState(context.Background(), config, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.pruneDeadServers(context.Background(), config, state); err != nil {
+		t.Fatal(err)
+	}
+
+	// The non-voter goes first, then the voter dead the longest. Removing the
+	// other dead voter would leave fewer voters than the minimum quorum.
+	sort.Strings(delegate.removed)
+	if len(delegate.removed) != 2 || delegate.removed[0] != "node5" || delegate.removed[1] != "node6" {
+		t.Fatalf("bad removed servers: %v", delegate.removed)
+	}
+}
+
+func TestAutopilot_NoHeartbeat(t *testing.T) {
+	delegate := &testAutopilotDelegate{
+		nodeID: "node1",
+		servers: []*RaftServer{
+			{NodeID: "node1", Voter: true},
+			{NodeID: "node2", Voter: true},
+			{NodeID: "node3", Voter: true},
+			{NodeID: "node4", Voter: true},
+		},
+	}
+	a := testAutopilot(delegate, map[string]FollowerState{})
+
+	config := DefaultAutopilotConfig()
+	config.CleanupDeadServers = true
+	config.MinQuorum = 3
+	a.SetConfig(config)
+
+	// Servers which haven't sent a heartbeat since autopilot started are
+	// measured from its start
+	if err := a.update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(delegate.removed) != 0 {
+		t.Fatalf("bad removed servers: %v", delegate.removed)
+	}
+	if !a.State().Healthy {
+		t.Fatal("expected the cluster to be healthy")
+	}
+
+	a.started = a.started.Add(-48 * time.Hour)
+	if err := a.update(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(delegate.removed) != 1 {
+		t.Fatalf("bad removed servers: %v", delegate.removed)
+	}
+	if a.State().Healthy {
+		t.Fatal("expected the cluster to be unhealthy")
+	}
+}
@@ -166,6 +166,11 @@ type LeaderJoinInfo struct {
 	// only be provided via Vault's configuration file.
 	LeaderClientKeyFile string `json:"leader_client_key_file"`
 
+	// NonVoter makes the node join the cluster as a non-voter, receiving the
+	// replicated log without being part of the quorum. It must be set the same
+	// way on all the retry_join entries.
+	NonVoter bool `json:"non_voter"`
+
 	// Retry indicates if the join process should automatically be retried
 	Retry bool `json:"-"`
 
@@ -200,6 +205,10 @@ func (b *RaftBackend) JoinConfig() ([]*LeaderJoinInfo, error) {
 			return nil, fmt.Errorf("invalid scheme '%s'; must either be http or https", info.AutoJoinScheme)
 		}
 
+		if info.NonVoter != leaderInfos[0].NonVoter {
+			return nil, errors.New("non_voter must be set the same way on all the retry_join entries")
+		}
+
 		info.Retry = true
 		info.TLSConfig, err = parseTLSInfo(info)
 		if err != nil {
@@ -450,6 +459,10 @@ type RaftConfigurationResponse struct {
 type Peer struct {
 	ID      string `json:"id"`
 	Address string `json:"address"`
+
+	// NonVoter is true if the peer receives the replicated log without being
+	// part of the quorum
+	NonVoter bool `json:"non_voter,omitempty"`
 }
 
 // NodeID returns the identifier of the node
@@ -507,6 +520,9 @@ func (b *RaftBackend) Bootstrap(peers []Peer) error {
 			ID:      raft.ServerID(p.ID),
 			Address: raft.ServerAddress(p.Address),
 		}
+		if p.NonVoter {
+			raftConfig.Servers[i].Suffrage = raft.Nonvoter
+		}
 	}
 
 	// Store the config for later use
@@ -703,14 +719,6 @@ func (b *RaftBackend) SetupCluster(ctx context.Context, opts SetupOpts) error {
 			return errwrap.Wrapf("raft recovery failed to parse peers.json: {{err}}", err)
 		}
 
-		// Non-voting servers are only allowed in enterprise. If Suffrage is disabled,
-		// error out to indicate that it isn't allowed.
-		for idx := range recoveryConfig.Servers {
-			if !nonVotersAllowed && recoveryConfig.Servers[idx].Suffrage == raft.Nonvoter {
-				return fmt.Errorf("raft recovery failed to parse configuration for node %q: setting `non_voter` is only supported in enterprise", recoveryConfig.Servers[idx].ID)
-			}
-		}
-
 		b.logger.Info("raft recovery found new config", "config", recoveryConfig)
 
 		err = raft.RecoverCluster(raftConfig, b.fsm, b.logStore, b.stableStore, b.snapStore, b.raftTransport, recoveryConfig)
@@ -898,6 +906,22 @@ func (b *RaftBackend) AddPeer(ctx context.Context, peerID, clusterAddr string) e
 	return future.Error()
 }
 
+// AddNonVotingPeer adds a new server to the raft cluster which receives the
+// replicated log without being part of the quorum
+func (b *RaftBackend) AddNonVotingPeer(ctx context.Context, peerID, clusterAddr string) error {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	if b.raft == nil {
+		return errors.New("raft storage is not initialized")
+	}
+
+	b.logger.Debug("adding raft non-voting peer", "node_id", peerID, "cluster_addr", clusterAddr)
+
+	future := b.raft.AddNonvoter(raft.ServerID(peerID), raft.ServerAddress(clusterAddr), 0, 0)
+	return future.Error()
+}
+
 // Peers returns all the servers present in the raft cluster
 func (b *RaftBackend) Peers(ctx context.Context) ([]Peer, error) {
 	b.l.RLock()
@@ -915,8 +939,9 @@ func (b *RaftBackend) Peers(ctx context.Context) ([]Peer, error) {
 	ret := make([]Peer, len(future.Configuration().Servers))
 	for i, s := range future.Configuration().Servers {
 		ret[i] = Peer{
-			ID:      string(s.ID),
-			Address: string(s.Address),
+			ID:       string(s.ID),
+			Address:  string(s.Address),
+			NonVoter: s.Suffrage == raft.Nonvoter,
 		}
 	}
 
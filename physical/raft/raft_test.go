@@ -333,6 +333,77 @@ func TestRaft_Backend_ThreeNode(t *testing.T) {
 	compareFSMs(t, raft1.fsm, raft3.fsm)
 }
 
+func TestRaft_Backend_NonVoter(t *testing.T) {
+	raft1, dir := getRaft(t, true, true)
+	raft2, dir2 := getRaft(t, false, true)
+	raft3, dir3 := getRaft(t, false, true)
+	defer os.RemoveAll(dir)
+	defer os.RemoveAll(dir2)
+	defer os.RemoveAll(dir3)
+
+	addPeer(t, raft1, raft2)
+
+	// Add raft3 to the cluster as a non-voter
+	if err := raft1.AddNonVotingPeer(context.Background(), raft3.NodeID(), raft3.NodeID()); err != nil {
+		t.Fatal(err)
+	}
+	peers, err := raft1.Peers(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range peers {
+		if p.NonVoter != (p.ID == raft3.NodeID()) {
+			t.Fatalf("bad peer: %#v", p)
+		}
+	}
+	if err := raft3.Bootstrap(peers); err != nil {
+		t.Fatal(err)
+	}
+	if err := raft3.SetupCluster(context.Background(), SetupOpts{}); err != nil {
+		t.Fatal(err)
+	}
+	connectPeers(raft1, raft2, raft3)
+
+	config, err := raft1.GetConfiguration(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, server := range config.Servers {
+		if server.Voter != (server.NodeID != raft3.NodeID()) {
+			t.Fatalf("bad server: %#v", server)
+		}
+	}
+
+	physical.ExerciseBackend(t, raft1)
+
+	time.Sleep(10 * time.Second)
+	// The non-voter receives the replicated log too
+	compareFSMs(t, raft1.fsm, raft2.fsm)
+	compareFSMs(t, raft1.fsm, raft3.fsm)
+}
+
+func TestRaft_JoinConfig_NonVoter(t *testing.T) {
+	b := &RaftBackend{
+		conf: map[string]string{
+			"retry_join": `[{"leader_api_addr":"http://127.0.0.1:8200","non_voter":true},{"leader_api_addr":"http://127.0.0.2:8200","non_voter":true}]`,
+		},
+	}
+	leaderInfos, err := b.JoinConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, info := range leaderInfos {
+		if !info.NonVoter {
+			t.Fatalf("bad leader info: %#v", info)
+		}
+	}
+
+	b.conf["retry_join"] = `[{"leader_api_addr":"http://127.0.0.1:8200","non_voter":true},{"leader_api_addr":"http://127.0.0.2:8200"}]`
+	if _, err := b.JoinConfig(); err == nil {
+		t.Fatal("expected an error for inconsistent non_voter settings")
+	}
+}
+
 func TestRaft_Recovery(t *testing.T) {
 	// Create 4 raft nodes
 	raft1, dir1 := getRaft(t, true, true)
@@ -0,0 +1,349 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/hashicorp/errwrap"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/rboyer/safeio"
+)
+
+const (
+	// AutoSnapshotStorageLocal stores automated snapshots in a directory on
+	// the active node
+	AutoSnapshotStorageLocal = "local"
+
+	defaultAutoSnapshotFilePrefix = "vault-snapshot"
+	autoSnapshotSuffix            = ".snap"
+	autoSnapshotTimeFormat        = "20060102T150405Z"
+)
+
+// SnapshotDestination stores the snapshots taken by an AutoSnapshotter
+type SnapshotDestination interface {
+	// Write stores the snapshot read from r under the given name
+	Write(ctx context.Context, name string, r io.Reader) error
+
+	// List returns the names of the stored snapshots
+	List(ctx context.Context) ([]string, error)
+
+	// Delete removes the stored snapshot with the given name
+	Delete(ctx context.Context, name string) error
+}
+
+// SnapshotDestinationFactory creates the destination of the automated
+// snapshots of the given configuration
+type SnapshotDestinationFactory func(config *AutoSnapshotConfig) (SnapshotDestination, error)
+
+// SnapshotDestinations holds the factories of the destinations automated
+// snapshots can be stored in, keyed by storage type
+var SnapshotDestinations = map[string]SnapshotDestinationFactory{
+	AutoSnapshotStorageLocal: newLocalSnapshotDestination,
+}
+
+// AutoSnapshotConfig is the configuration of a schedule of automated
+// snapshots
+type AutoSnapshotConfig struct {
+	// Interval is the time between two snapshots
+	Interval time.Duration `json:"interval"`
+
+	// Retain is the number of snapshots to keep, the oldest ones being
+	// deleted first
+	Retain int `json:"retain"`
+
+	// StorageType is the destination of the snapshots
+	StorageType string `json:"storage_type"`
+
+	// PathPrefix is where the snapshots are stored within the destination,
+	// the directory for local storage
+	PathPrefix string `json:"path_prefix"`
+
+	// FilePrefix is the prefix of the snapshot names, which are followed by
+	// the time and raft index of the snapshot
+	FilePrefix string `json:"file_prefix"`
+}
+
+// DefaultAutoSnapshotConfig returns the configuration fields not given
+// default to
+func DefaultAutoSnapshotConfig() *AutoSnapshotConfig {
+	return &AutoSnapshotConfig{
+		Retain:      1,
+		StorageType: AutoSnapshotStorageLocal,
+		FilePrefix:  defaultAutoSnapshotFilePrefix,
+	}
+}
+
+// Validate checks the configuration is consistent
+func (c *AutoSnapshotConfig) Validate() error {
+	switch {
+	case c.Interval <= 0:
+		return errors.New("interval must be positive")
+	case c.Retain < 1:
+		return errors.New("retain must be at least 1")
+	case c.PathPrefix == "":
+		return errors.New("path_prefix is required")
+	case c.FilePrefix == "" || strings.ContainsAny(c.FilePrefix, `/\`):
+		return errors.New("file_prefix must be a non-empty file name")
+	}
+	if _, ok := SnapshotDestinations[c.StorageType]; !ok {
+		return fmt.Errorf("unsupported storage_type %q", c.StorageType)
+	}
+	return nil
+}
+
+// AutoSnapshotStatus is the outcome of the latest automated snapshots of a
+// schedule
+type AutoSnapshotStatus struct {
+	LastSnapshotStart time.Time `json:"last_snapshot_start"`
+	LastSnapshotEnd   time.Time `json:"last_snapshot_end"`
+	LastSnapshotError string    `json:"last_snapshot_error"`
+	ConsecutiveErrors int       `json:"consecutive_errors"`
+
+	// LastSuccess, LastSuccessName and LastSuccessIndex describe the latest
+	// snapshot successfully stored
+	LastSuccess      time.Time `json:"last_success"`
+	LastSuccessName  string    `json:"last_success_name"`
+	LastSuccessIndex uint64    `json:"last_success_index"`
+}
+
+// AutoSnapshotter takes snapshots at the configured interval, storing them in
+// the configured destination and deleting the ones beyond retention
+type AutoSnapshotter struct {
+	name        string
+	config      *AutoSnapshotConfig
+	destination SnapshotDestination
+	logger      log.Logger
+
+	// namePattern matches the names of the snapshots of this schedule,
+	// capturing their time and index
+	namePattern *regexp.Regexp
+
+	// snapshot writes a snapshot of the cluster, and index returns the raft
+	// index it will be taken at
+	snapshot func(io.Writer) error
+	index    func() uint64
+
+	l      sync.RWMutex
+	status AutoSnapshotStatus
+}
+
+// NewAutoSnapshotter returns the snapshotter of the schedule with the given
+// name and configuration
+func NewAutoSnapshotter(name string, config *AutoSnapshotConfig, snapshot func(io.Writer) error, index func() uint64, logger log.Logger) (*AutoSnapshotter, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	destination, err := SnapshotDestinations[config.StorageType](config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &AutoSnapshotter{
+		name:        name,
+		config:      config,
+		destination: destination,
+		logger:      logger,
+		namePattern: autoSnapshotNamePattern(config.FilePrefix),
+		snapshot:    snapshot,
+		index:       index,
+	}, nil
+}
+
+// Status returns the outcome of the latest snapshots
+func (s *AutoSnapshotter) Status() AutoSnapshotStatus {
+	s.l.RLock()
+	defer s.l.RUnlock()
+	return s.status
+}
+
+// Run takes snapshots at the configured interval until the stop channel is
+// closed
+func (s *AutoSnapshotter) Run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			select {
+			case <-stopCh:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		s.takeSnapshot(ctx)
+		cancel()
+	}
+}
+
+// takeSnapshot takes and stores a snapshot, then deletes the ones beyond
+// retention
+func (s *AutoSnapshotter) takeSnapshot(ctx context.Context) {
+	labels := []metrics.Label{{Name: "name", Value: s.name}}
+	start := time.Now()
+	index := s.index()
+	name := fmt.Sprintf("%s-%s-%d%s", s.config.FilePrefix, start.UTC().Format(autoSnapshotTimeFormat), index, autoSnapshotSuffix)
+
+	s.l.Lock()
+	s.status.LastSnapshotStart = start
+	s.l.Unlock()
+
+	err := s.store(ctx, name)
+	if err == nil {
+		if pruneErr := s.prune(ctx); pruneErr != nil {
+			// The snapshot was stored, so this isn't reported as a failure
+			s.logger.Error("failed to delete snapshots beyond retention", "error", pruneErr)
+		}
+	}
+
+	end := time.Now()
+	s.l.Lock()
+	defer s.l.Unlock()
+	s.status.LastSnapshotEnd = end
+	if err != nil {
+		s.logger.Error("failed to take automated snapshot", "name", name, "error", err)
+		s.status.LastSnapshotError = err.Error()
+		s.status.ConsecutiveErrors++
+		metrics.IncrCounterWithLabels([]string{"raft_storage", "snapshot_auto", "failure"}, 1, labels)
+		return
+	}
+
+	s.logger.Info("took automated snapshot", "name", name, "index", index)
+	s.status.LastSnapshotError = ""
+	s.status.ConsecutiveErrors = 0
+	s.status.LastSuccess = end
+	s.status.LastSuccessName = name
+	s.status.LastSuccessIndex = index
+	metrics.IncrCounterWithLabels([]string{"raft_storage", "snapshot_auto", "success"}, 1, labels)
+	metrics.MeasureSinceWithLabels([]string{"raft_storage", "snapshot_auto", "duration"}, start, labels)
+}
+
+// store streams a snapshot to the destination
+func (s *AutoSnapshotter) store(ctx context.Context, name string) error {
+	r, w := io.Pipe()
+	go func() {
+		w.CloseWithError(s.snapshot(w))
+	}()
+
+	err := s.destination.Write(ctx, name, r)
+	// Unblock the snapshot if the destination stopped reading early
+	r.CloseWithError(errors.New("snapshot destination closed"))
+	return err
+}
+
+// autoSnapshotNamePattern matches the names of the snapshots written with the
+// given file prefix. The whole name is matched so that the snapshots of a
+// schedule whose prefix starts with this one, sharing the destination, don't
+// match.
+func autoSnapshotNamePattern(filePrefix string) *regexp.Regexp {
+	return regexp.MustCompile(`^` + regexp.QuoteMeta(filePrefix) + `-(\d{8}T\d{6}Z)-(\d+)` + regexp.QuoteMeta(autoSnapshotSuffix) + `$`)
+}
+
+// prune deletes the oldest snapshots beyond retention
+func (s *AutoSnapshotter) prune(ctx context.Context) error {
+	names, err := s.destination.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	type snapshotName struct {
+		name  string
+		time  string
+		index uint64
+	}
+	var snapshots []snapshotName
+	for _, name := range names {
+		match := s.namePattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		index, err := strconv.ParseUint(match[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, snapshotName{name: name, time: match[1], index: index})
+	}
+	if len(snapshots) <= s.config.Retain {
+		return nil
+	}
+
+	// The times sort chronologically, and the index orders the snapshots
+	// taken within the same second
+	sort.Slice(snapshots, func(i, j int) bool {
+		if snapshots[i].time != snapshots[j].time {
+			return snapshots[i].time < snapshots[j].time
+		}
+		return snapshots[i].index < snapshots[j].index
+	})
+	for _, snapshot := range snapshots[:len(snapshots)-s.config.Retain] {
+		s.logger.Debug("deleting snapshot beyond retention", "name", snapshot.name)
+		if err := s.destination.Delete(ctx, snapshot.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// localSnapshotDestination stores snapshots in a directory of the active node
+type localSnapshotDestination struct {
+	path string
+}
+
+func newLocalSnapshotDestination(config *AutoSnapshotConfig) (SnapshotDestination, error) {
+	if !filepath.IsAbs(config.PathPrefix) {
+		return nil, errors.New("path_prefix must be an absolute path for local storage")
+	}
+	return &localSnapshotDestination{
+		path: config.PathPrefix,
+	}, nil
+}
+
+func (d *localSnapshotDestination) Write(ctx context.Context, name string, r io.Reader) error {
+	if err := os.MkdirAll(d.path, 0700); err != nil {
+		return errwrap.Wrapf("failed to create snapshot directory: {{err}}", err)
+	}
+
+	// The snapshot is written to a temporary file first so that partial
+	// snapshots are never left behind
+	if _, err := safeio.WriteToFile(r, filepath.Join(d.path, name), 0600); err != nil {
+		return errwrap.Wrapf("failed to write snapshot: {{err}}", err)
+	}
+	return nil
+}
+
+func (d *localSnapshotDestination) List(ctx context.Context) ([]string, error) {
+	files, err := ioutil.ReadDir(d.path)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		if file.Mode().IsRegular() {
+			names = append(names, file.Name())
+		}
+	}
+	return names, nil
+}
+
+func (d *localSnapshotDestination) Delete(ctx context.Context, name string) error {
+	return os.Remove(filepath.Join(d.path, name))
+}
@@ -0,0 +1,241 @@
+package raft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	hclog "github.com/hashicorp/go-hclog"
+)
+
+func testAutoSnapshotter(t *testing.T, config *AutoSnapshotConfig, snapshot func(io.Writer) error) *AutoSnapshotter {
+	t.Helper()
+
+	var index uint64
+	s, err := NewAutoSnapshotter("test", config, snapshot, func() uint64 {
+		index++
+		return index
+	}, hclog.NewNullLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAutoSnapshotConfig_Validate(t *testing.T) {
+	valid := func() *AutoSnapshotConfig {
+		config := DefaultAutoSnapshotConfig()
+		config.Interval = time.Hour
+		config.PathPrefix = "/var/snapshots"
+		return config
+	}
+
+	if err := valid().Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, mutate := range map[string]func(*AutoSnapshotConfig){
+		"no interval":          func(c *AutoSnapshotConfig) { c.Interval = 0 },
+		"no retention":         func(c *AutoSnapshotConfig) { c.Retain = 0 },
+		"no path prefix":       func(c *AutoSnapshotConfig) { c.PathPrefix = "" },
+		"file prefix with dir": func(c *AutoSnapshotConfig) { c.FilePrefix = "a/b" },
+		"unknown storage type": func(c *AutoSnapshotConfig) { c.StorageType = "tape" },
+	} {
+		config := valid()
+		mutate(config)
+		if err := config.Validate(); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestAutoSnapshotter_Retention(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-raft-snapshot-auto-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := DefaultAutoSnapshotConfig()
+	config.Interval = time.Hour
+	config.Retain = 2
+	config.PathPrefix = filepath.Join(dir, "snapshots")
+
+	var taken int
+	s := testAutoSnapshotter(t, config, func(w io.Writer) error {
+		taken++
+		_, err := fmt.Fprintf(w, "snapshot %d", taken)
+		return err
+	})
+
+	// A file not written by the snapshotter is left alone
+	if err := os.MkdirAll(config.PathPrefix, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(config.PathPrefix, "other.snap"), nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		s.takeSnapshot(context.Background())
+	}
+
+	status := s.Status()
+	if status.ConsecutiveErrors != 0 || status.LastSnapshotError != "" {
+		t.Fatalf("bad status: %#v", status)
+	}
+	if status.LastSuccessIndex != 3 || !strings.HasSuffix(status.LastSuccessName, "-3.snap") {
+		t.Fatalf("bad status: %#v", status)
+	}
+
+	names, err := s.destination.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 3 {
+		t.Fatalf("expected two snapshots and the other file, got %v", names)
+	}
+	for _, name := range names {
+		if strings.HasSuffix(name, "-1.snap") {
+			t.Fatalf("the oldest snapshot wasn't deleted: %v", names)
+		}
+	}
+
+	contents, err := ioutil.ReadFile(filepath.Join(config.PathPrefix, status.LastSuccessName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(contents) != "snapshot 3" {
+		t.Fatalf("bad snapshot contents: %q", contents)
+	}
+}
+
+func TestAutoSnapshotter_SharedDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-raft-snapshot-auto-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The prefix of the daily schedule starts with the one of the other
+	hourlyConfig := DefaultAutoSnapshotConfig()
+	hourlyConfig.Interval = time.Hour
+	hourlyConfig.PathPrefix = dir
+
+	dailyConfig := DefaultAutoSnapshotConfig()
+	dailyConfig.Interval = 24 * time.Hour
+	dailyConfig.PathPrefix = dir
+	dailyConfig.FilePrefix = hourlyConfig.FilePrefix + "-daily"
+
+	snapshot := func(w io.Writer) error {
+		_, err := w.Write([]byte("snapshot"))
+		return err
+	}
+	hourly := testAutoSnapshotter(t, hourlyConfig, snapshot)
+	daily := testAutoSnapshotter(t, dailyConfig, snapshot)
+
+	// Enough snapshots are taken for the indexes not to sort as strings
+	for i := 0; i < 12; i++ {
+		daily.takeSnapshot(context.Background())
+		hourly.takeSnapshot(context.Background())
+	}
+
+	names, err := hourly.destination.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(names)
+	expected := []string{daily.Status().LastSuccessName, hourly.Status().LastSuccessName}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected the latest snapshot of each schedule, got %v", names)
+	}
+	if !strings.HasSuffix(hourly.Status().LastSuccessName, "-12.snap") {
+		t.Fatalf("bad status: %#v", hourly.Status())
+	}
+}
+
+func TestAutoSnapshotter_Failure(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-raft-snapshot-auto-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := DefaultAutoSnapshotConfig()
+	config.Interval = time.Hour
+	config.PathPrefix = dir
+
+	s := testAutoSnapshotter(t, config, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return errors.New("raft storage backend is sealed")
+	})
+
+	s.takeSnapshot(context.Background())
+	s.takeSnapshot(context.Background())
+
+	status := s.Status()
+	if status.ConsecutiveErrors != 2 || !strings.Contains(status.LastSnapshotError, "sealed") {
+		t.Fatalf("bad status: %#v", status)
+	}
+	if !status.LastSuccess.IsZero() {
+		t.Fatalf("bad status: %#v", status)
+	}
+
+	// Partial snapshots aren't left behind
+	names, err := s.destination.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Fatalf("expected no snapshots, got %v", names)
+	}
+}
+
+func TestAutoSnapshotter_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault-raft-snapshot-auto-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	config := DefaultAutoSnapshotConfig()
+	config.Interval = 50 * time.Millisecond
+	config.PathPrefix = dir
+
+	s := testAutoSnapshotter(t, config, func(w io.Writer) error {
+		_, err := w.Write([]byte("snapshot"))
+		return err
+	})
+
+	stopCh := make(chan struct{})
+	doneCh := make(chan struct{})
+	go func() {
+		s.Run(stopCh)
+		close(doneCh)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Status().LastSuccessIndex < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("snapshots weren't taken: %#v", s.Status())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(stopCh)
+	select {
+	case <-doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("snapshotter didn't stop")
+	}
+}
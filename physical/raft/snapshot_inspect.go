@@ -0,0 +1,138 @@
+package raft
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/raft"
+	snapshot "github.com/hashicorp/raft-snapshot"
+	"github.com/hashicorp/vault/sdk/plugin/pb"
+)
+
+// SnapshotInfo describes the contents of a snapshot archive
+type SnapshotInfo struct {
+	ID      string `json:"id"`
+	Index   uint64 `json:"index"`
+	Term    uint64 `json:"term"`
+	Version int    `json:"version"`
+
+	// Size is the size of the uncompressed snapshot data
+	Size int64 `json:"size"`
+
+	Configuration []*SnapshotServer `json:"configuration"`
+
+	// TotalKeys and TotalSize count the storage entries in the snapshot, and
+	// the bytes of their keys and values
+	TotalKeys int   `json:"total_keys"`
+	TotalSize int64 `json:"total_size"`
+
+	// Prefixes break down the storage entries by top-level prefix, largest
+	// first
+	Prefixes []*SnapshotPrefixInfo `json:"prefixes"`
+}
+
+// SnapshotServer is a server of the raft configuration stored in a snapshot
+type SnapshotServer struct {
+	NodeID  string `json:"node_id"`
+	Address string `json:"address"`
+	Voter   bool   `json:"voter"`
+}
+
+// SnapshotPrefixInfo counts the storage entries under a top-level prefix, such
+// as logical/ or sys/
+type SnapshotPrefixInfo struct {
+	Prefix string `json:"prefix"`
+	Keys   int    `json:"keys"`
+	Size   int64  `json:"size"`
+}
+
+// InspectSnapshot reads a snapshot archive, as written by Snapshot, and
+// describes its contents. The integrity of the archive is verified against
+// its unsealed hashes, since the seal isn't available offline.
+func InspectSnapshot(in io.Reader) (*SnapshotInfo, error) {
+	r, w := io.Pipe()
+
+	type parseResult struct {
+		metadata *raft.SnapshotMeta
+		err      error
+	}
+	parseCh := make(chan parseResult, 1)
+	go func() {
+		metadata, err := snapshot.Parse(in, w)
+		w.CloseWithError(err)
+		parseCh <- parseResult{metadata, err}
+	}()
+
+	info := &SnapshotInfo{}
+	prefixes := make(map[string]*SnapshotPrefixInfo)
+	protoReader := NewDelimitedReader(r, math.MaxInt32)
+	entry := new(pb.StorageEntry)
+	var readErr error
+	for {
+		if readErr = protoReader.ReadMsg(entry); readErr != nil {
+			break
+		}
+
+		size := int64(len(entry.Key) + len(entry.Value))
+		info.TotalKeys++
+		info.TotalSize += size
+
+		prefix := snapshotKeyPrefix(entry.Key)
+		p, ok := prefixes[prefix]
+		if !ok {
+			p = &SnapshotPrefixInfo{Prefix: prefix}
+			prefixes[prefix] = p
+		}
+		p.Keys++
+		p.Size += size
+	}
+	// Unblock the parser if the data couldn't be read to the end
+	r.CloseWithError(io.ErrClosedPipe)
+
+	result := <-parseCh
+	switch {
+	case readErr == io.EOF && result.err == nil:
+	case result.err != nil && (readErr == io.EOF || readErr == result.err):
+		return nil, result.err
+	default:
+		return nil, errwrap.Wrapf("failed to read snapshot data: {{err}}", readErr)
+	}
+
+	metadata := result.metadata
+	info.ID = metadata.ID
+	info.Index = metadata.Index
+	info.Term = metadata.Term
+	info.Version = int(metadata.Version)
+	info.Size = metadata.Size
+	for _, server := range metadata.Configuration.Servers {
+		info.Configuration = append(info.Configuration, &SnapshotServer{
+			NodeID:  string(server.ID),
+			Address: string(server.Address),
+			Voter:   server.Suffrage == raft.Voter,
+		})
+	}
+
+	for _, p := range prefixes {
+		info.Prefixes = append(info.Prefixes, p)
+	}
+	sort.Slice(info.Prefixes, func(i, j int) bool {
+		if info.Prefixes[i].Size != info.Prefixes[j].Size {
+			return info.Prefixes[i].Size > info.Prefixes[j].Size
+		}
+		return info.Prefixes[i].Prefix < info.Prefixes[j].Prefix
+	})
+
+	return info, nil
+}
+
+// snapshotKeyPrefix returns the top-level prefix of a storage key, including
+// its trailing slash. Keys at the top level are their own prefix.
+func snapshotKeyPrefix(key string) string {
+	if i := strings.Index(key, "/"); i >= 0 {
+		return key[:i+1]
+	}
+	return key
+}
@@ -0,0 +1,81 @@
+package raft
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/physical"
+)
+
+func TestRaft_InspectSnapshot(t *testing.T) {
+	raft1, dir := getRaft(t, true, false)
+	defer os.RemoveAll(dir)
+
+	var expectedSize int64
+	put := func(key, value string) {
+		t.Helper()
+		err := raft1.Put(context.Background(), &physical.Entry{
+			Key:   key,
+			Value: []byte(value),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectedSize += int64(len(key) + len(value))
+	}
+	for i := 0; i < 20; i++ {
+		put(fmt.Sprintf("logical/mount/key-%d", i), strings.Repeat("v", 100))
+	}
+	for i := 0; i < 10; i++ {
+		put(fmt.Sprintf("sys/token/key-%d", i), "value")
+	}
+	put("core/seal-config", "config")
+
+	var snap bytes.Buffer
+	if err := raft1.Snapshot(&snap, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := InspectSnapshot(bytes.NewReader(snap.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Index == 0 || info.Term == 0 {
+		t.Fatalf("bad index or term: %#v", info)
+	}
+	if len(info.Configuration) != 1 || info.Configuration[0].NodeID != raft1.NodeID() || !info.Configuration[0].Voter {
+		t.Fatalf("bad configuration: %#v", info.Configuration)
+	}
+	if info.TotalKeys != 31 || info.TotalSize != expectedSize {
+		t.Fatalf("bad totals: %d keys, %d bytes", info.TotalKeys, info.TotalSize)
+	}
+
+	expected := []struct {
+		prefix string
+		keys   int
+	}{
+		{"logical/", 20},
+		{"sys/", 10},
+		{"core/", 1},
+	}
+	if len(info.Prefixes) != len(expected) {
+		t.Fatalf("bad prefixes: %#v", info.Prefixes)
+	}
+	for i, e := range expected {
+		if info.Prefixes[i].Prefix != e.prefix || info.Prefixes[i].Keys != e.keys {
+			t.Fatalf("bad prefix %d: %#v", i, info.Prefixes[i])
+		}
+	}
+
+	// A corrupted snapshot is rejected
+	corrupted := snap.Bytes()
+	corrupted[len(corrupted)/2] ^= 0xff
+	if _, err := InspectSnapshot(bytes.NewReader(corrupted)); err == nil {
+		t.Fatal("expected an error for a corrupted snapshot")
+	}
+}
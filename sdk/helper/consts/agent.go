@@ -3,3 +3,14 @@ package consts
 // AgentPathCacheClear is the path that the agent will use as its cache-clear
 // endpoint.
 const AgentPathCacheClear = "/agent/v1/cache-clear"
+
+// AgentPathAuthStatus is the path that the agent will use to report the state
+// of its auto-auth methods.
+const AgentPathAuthStatus = "/agent/v1/auth-status"
+
+// AgentPathMetrics is the path that the agent will use to report its
+// metrics.
+const AgentPathMetrics = "/agent/v1/metrics"
+
+// AgentPathHealth is the path that the agent will use to report its health.
+const AgentPathHealth = "/agent/v1/health"
@@ -0,0 +1,70 @@
+package keysutil
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+)
+
+// cmacRb is the constant used when generating the CMAC subkeys for a 128 bit
+// block cipher
+const cmacRb = 0x87
+
+// cmacAES computes the AES-CMAC of the message as defined in RFC 4493
+func cmacAES(key, message []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate the subkeys K1 and K2 from the encryption of the zero block
+	k1 := make([]byte, aes.BlockSize)
+	block.Encrypt(k1, k1)
+	cmacShift(k1)
+	k2 := make([]byte, aes.BlockSize)
+	copy(k2, k1)
+	cmacShift(k2)
+
+	n := (len(message) + aes.BlockSize - 1) / aes.BlockSize
+	complete := n > 0 && len(message)%aes.BlockSize == 0
+	if n == 0 {
+		n = 1
+	}
+
+	// The last block is XORed with K1 if it is complete, otherwise it is
+	// padded and XORed with K2
+	last := make([]byte, aes.BlockSize)
+	lastStart := (n - 1) * aes.BlockSize
+	if complete {
+		xorBlock(last, message[lastStart:], k1)
+	} else {
+		copy(last, message[lastStart:])
+		last[len(message)-lastStart] = 0x80
+		xorBlock(last, last, k2)
+	}
+
+	mac := make([]byte, aes.BlockSize)
+	for i := 0; i < n-1; i++ {
+		xorBlock(mac, mac, message[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(mac, mac)
+	}
+	xorBlock(mac, mac, last)
+	block.Encrypt(mac, mac)
+
+	return mac, nil
+}
+
+func xorBlock(dst, x, y []byte) {
+	for i := 0; i < aes.BlockSize; i++ {
+		dst[i] = x[i] ^ y[i]
+	}
+}
+
+// cmacShift shifts the block left by one bit, XORing in Rb if the most
+// significant bit was set
+func cmacShift(b []byte) {
+	msb := b[0] >> 7
+	for i := 0; i < len(b)-1; i++ {
+		b[i] = b[i]<<1 | b[i+1]>>7
+	}
+	b[len(b)-1] = b[len(b)-1]<<1 ^ byte(subtle.ConstantTimeSelect(int(msb), cmacRb, 0))
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
 	"github.com/hashicorp/vault/sdk/helper/jsonutil"
 	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -52,6 +53,15 @@ type PolicyRequest struct {
 
 	// Whether to allow plaintext backup
 	AllowPlaintextBackup bool
+
+	// Whether to allow rotation of an imported key
+	AllowImportedKeyRotation bool
+
+	// The key size in bytes for HMAC keys
+	KeySize int
+
+	// The period after which the key is rotated automatically
+	AutoRotatePeriod time.Duration
 }
 
 type LockManager struct {
@@ -327,56 +337,10 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest, rand io
 		// to the user to let them know that their request can't be satisfied
 		// because we don't know if the parameters match.
 
-		switch req.KeyType {
-		case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
-			if req.Convergent && !req.Derived {
-				cleanup()
-				return nil, false, fmt.Errorf("convergent encryption requires derivation to be enabled")
-			}
-
-		case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
-			if req.Derived || req.Convergent {
-				cleanup()
-				return nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		case KeyType_ED25519:
-			if req.Convergent {
-				cleanup()
-				return nil, false, fmt.Errorf("convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
-			if req.Derived || req.Convergent {
-				cleanup()
-				return nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
-			}
-
-		default:
+		p, err = policyFromRequest(req)
+		if err != nil {
 			cleanup()
-			return nil, false, fmt.Errorf("unsupported key type %v", req.KeyType)
-		}
-
-		p = &Policy{
-			l:                    new(sync.RWMutex),
-			Name:                 req.Name,
-			Type:                 req.KeyType,
-			Derived:              req.Derived,
-			Exportable:           req.Exportable,
-			AllowPlaintextBackup: req.AllowPlaintextBackup,
-		}
-
-		if req.Derived {
-			p.KDF = Kdf_hkdf_sha256
-			if req.Convergent {
-				p.ConvergentEncryption = true
-				// As of version 3 we store the version within each key, so we
-				// set to -1 to indicate that the value in the policy has no
-				// meaning. We still, for backwards compatibility, fall back to
-				// this value if the key doesn't have one, which means it will
-				// only be -1 in the case where every key version is >= 3
-				p.ConvergentVersion = -1
-			}
+			return nil, false, err
 		}
 
 		// Performs the actual persist and does setup
@@ -419,6 +383,122 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest, rand io
 	return
 }
 
+// policyFromRequest validates the parameters of a new policy and creates it,
+// without any key versions
+func policyFromRequest(req PolicyRequest) (*Policy, error) {
+	switch req.KeyType {
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		if req.Convergent && !req.Derived {
+			return nil, fmt.Errorf("convergent encryption requires derivation to be enabled")
+		}
+
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
+		if req.Derived || req.Convergent {
+			return nil, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	case KeyType_ED25519:
+		if req.Convergent {
+			return nil, fmt.Errorf("convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_AES128_CMAC, KeyType_AES256_CMAC:
+		if req.Derived || req.Convergent {
+			return nil, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+
+	case KeyType_HMAC:
+		if req.Derived || req.Convergent {
+			return nil, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+		}
+		if req.KeySize == 0 {
+			req.KeySize = DefaultHMACKeySize
+		}
+		if req.KeySize < HMACMinKeySize || req.KeySize > HMACMaxKeySize {
+			return nil, fmt.Errorf("key size for keys of type %v must be between %d and %d bytes", req.KeyType, HMACMinKeySize, HMACMaxKeySize)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported key type %v", req.KeyType)
+	}
+
+	p := &Policy{
+		l:                    new(sync.RWMutex),
+		Name:                 req.Name,
+		Type:                 req.KeyType,
+		Derived:              req.Derived,
+		Exportable:           req.Exportable,
+		AllowPlaintextBackup: req.AllowPlaintextBackup,
+		AutoRotatePeriod:     req.AutoRotatePeriod,
+	}
+
+	if req.KeyType == KeyType_HMAC {
+		p.KeySize = req.KeySize
+	}
+
+	if req.Derived {
+		p.KDF = Kdf_hkdf_sha256
+		if req.Convergent {
+			p.ConvergentEncryption = true
+			// As of version 3 we store the version within each key, so we
+			// set to -1 to indicate that the value in the policy has no
+			// meaning. We still, for backwards compatibility, fall back to
+			// this value if the key doesn't have one, which means it will
+			// only be -1 in the case where every key version is >= 3
+			p.ConvergentVersion = -1
+		}
+	}
+
+	return p, nil
+}
+
+// ImportPolicy creates a new policy from imported key material. It fails if
+// a policy of the same name already exists.
+func (lm *LockManager) ImportPolicy(ctx context.Context, req PolicyRequest, key []byte, rand io.Reader) error {
+	lock := locksutil.LockForKey(lm.keyLocks, req.Name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	var ok bool
+	if lm.useCache {
+		_, ok = lm.cache.Load(req.Name)
+	}
+	if !ok {
+		existing, err := lm.getPolicyFromStorage(ctx, req.Storage, req.Name)
+		if err != nil {
+			return err
+		}
+		ok = existing != nil
+	}
+	if ok {
+		return errutil.UserError{Err: fmt.Sprintf("key %q already exists", req.Name)}
+	}
+
+	// Further versions of an imported HMAC key, if rotation is allowed, are
+	// generated with the size of the imported key
+	if req.KeyType == KeyType_HMAC && req.KeySize == 0 {
+		req.KeySize = len(key)
+	}
+
+	p, err := policyFromRequest(req)
+	if err != nil {
+		return errutil.UserError{Err: err.Error()}
+	}
+	p.Imported = true
+	p.AllowImportedKeyRotation = req.AllowImportedKeyRotation
+
+	// Performs the actual persist and does setup
+	if err := p.Import(ctx, req.Storage, key, rand); err != nil {
+		return err
+	}
+
+	if lm.useCache {
+		lm.cache.Store(req.Name, p)
+	}
+
+	return nil
+}
+
 func (lm *LockManager) DeletePolicy(ctx context.Context, storage logical.Storage, name string) error {
 	var p *Policy
 	var err error
@@ -59,6 +59,19 @@ const (
 	KeyType_ECDSA_P521
 	KeyType_AES128_GCM96
 	KeyType_RSA3072
+	KeyType_HMAC
+	KeyType_AES128_CMAC
+	KeyType_AES256_CMAC
+)
+
+const (
+	// DefaultHMACKeySize is the size in bytes of generated HMAC keys when
+	// no key size is given
+	DefaultHMACKeySize = 32
+
+	// HMACMinKeySize and HMACMaxKeySize bound the size in bytes of HMAC keys
+	HMACMinKeySize = 32
+	HMACMaxKeySize = 512
 )
 
 const (
@@ -107,6 +120,14 @@ func (kt KeyType) DecryptionSupported() bool {
 	return false
 }
 
+func (kt KeyType) AssociatedDataSupported() bool {
+	switch kt {
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		return true
+	}
+	return false
+}
+
 func (kt KeyType) SigningSupported() bool {
 	switch kt {
 	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521, KeyType_ED25519, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
@@ -123,6 +144,14 @@ func (kt KeyType) HashSignatureInput() bool {
 	return false
 }
 
+func (kt KeyType) CMACSupported() bool {
+	switch kt {
+	case KeyType_AES128_CMAC, KeyType_AES256_CMAC:
+		return true
+	}
+	return false
+}
+
 func (kt KeyType) DerivationSupported() bool {
 	switch kt {
 	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_ED25519:
@@ -153,6 +182,12 @@ func (kt KeyType) String() string {
 		return "rsa-3072"
 	case KeyType_RSA4096:
 		return "rsa-4096"
+	case KeyType_HMAC:
+		return "hmac"
+	case KeyType_AES128_CMAC:
+		return "aes128-cmac"
+	case KeyType_AES256_CMAC:
+		return "aes256-cmac"
 	}
 
 	return "[unknown]"
@@ -374,6 +409,27 @@ type Policy struct {
 	// policy object.
 	StoragePrefix string `json:"storage_prefix"`
 
+	// KeySize is the size in bytes of generated keys of type HMAC
+	KeySize int `json:"key_size"`
+
+	// AutoRotatePeriod is the age of the latest version of the key after
+	// which a new version is generated automatically. Zero disables
+	// automatic rotation.
+	AutoRotatePeriod time.Duration `json:"auto_rotate_period"`
+
+	// MinDecryptionAge, if set, is how long a version of the key remains
+	// usable for decryption after it has been superseded. Older versions are
+	// excluded by automatically raising MinDecryptionVersion.
+	MinDecryptionAge time.Duration `json:"min_decryption_age"`
+
+	// Imported indicates that the key material was imported rather than
+	// generated by Vault
+	Imported bool `json:"imported_key"`
+
+	// AllowImportedKeyRotation allows Vault to generate new versions of an
+	// imported key
+	AllowImportedKeyRotation bool `json:"allow_imported_key_rotation"`
+
 	// versionPrefixCache stores caches of version prefix strings and the split
 	// version template.
 	versionPrefixCache sync.Map
@@ -808,9 +864,19 @@ func (p *Policy) convergentVersion(ver int) int {
 }
 
 func (p *Policy) Encrypt(ver int, context, nonce []byte, value string) (string, error) {
+	return p.EncryptWithAssociatedData(ver, context, nonce, value, nil)
+}
+
+// EncryptWithAssociatedData encrypts the value as Encrypt does, additionally
+// authenticating the associated data, which must then be given again on
+// decryption. Associated data is only supported by AEAD key types.
+func (p *Policy) EncryptWithAssociatedData(ver int, context, nonce []byte, value string, associatedData []byte) (string, error) {
 	if !p.Type.EncryptionSupported() {
 		return "", errutil.UserError{Err: fmt.Sprintf("message encryption not supported for key type %v", p.Type)}
 	}
+	if len(associatedData) != 0 && !p.Type.AssociatedDataSupported() {
+		return "", errutil.UserError{Err: fmt.Sprintf("associated data not supported for key type %v", p.Type)}
+	}
 
 	// Decode the plaintext value
 	plaintext, err := base64.StdEncoding.DecodeString(value)
@@ -870,9 +936,10 @@ func (p *Policy) Encrypt(ver int, context, nonce []byte, value string) (string,
 
 		ciphertext, err = p.SymmetricEncryptRaw(ver, encKey, plaintext,
 			SymmetricOpts{
-				Convergent: p.ConvergentEncryption,
-				HMACKey:    hmacKey,
-				Nonce:      nonce,
+				Convergent:     p.ConvergentEncryption,
+				HMACKey:        hmacKey,
+				Nonce:          nonce,
+				AdditionalData: associatedData,
 			})
 
 		if err != nil {
@@ -903,9 +970,18 @@ func (p *Policy) Encrypt(ver int, context, nonce []byte, value string) (string,
 }
 
 func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
+	return p.DecryptWithAssociatedData(context, nonce, value, nil)
+}
+
+// DecryptWithAssociatedData decrypts the value as Decrypt does, failing
+// unless the associated data matches that given on encryption
+func (p *Policy) DecryptWithAssociatedData(context, nonce []byte, value string, associatedData []byte) (string, error) {
 	if !p.Type.DecryptionSupported() {
 		return "", errutil.UserError{Err: fmt.Sprintf("message decryption not supported for key type %v", p.Type)}
 	}
+	if len(associatedData) != 0 && !p.Type.AssociatedDataSupported() {
+		return "", errutil.UserError{Err: fmt.Sprintf("associated data not supported for key type %v", p.Type)}
+	}
 
 	tplParts, err := p.getTemplateParts()
 	if err != nil {
@@ -974,6 +1050,7 @@ func (p *Policy) Decrypt(context, nonce []byte, value string) (string, error) {
 			SymmetricOpts{
 				Convergent:        p.ConvergentEncryption,
 				ConvergentVersion: p.ConvergentVersion,
+				AdditionalData:    associatedData,
 			})
 		if err != nil {
 			return "", err
@@ -1014,6 +1091,30 @@ func (p *Policy) HMACKey(version int) ([]byte, error) {
 	return keyEntry.HMACKey, nil
 }
 
+// CMAC computes the AES-CMAC (RFC 4493) of the message using the given key
+// version
+func (p *Policy) CMAC(ver int, message []byte) ([]byte, error) {
+	if !p.Type.CMACSupported() {
+		return nil, errutil.UserError{Err: fmt.Sprintf("CMAC not supported for key type %v", p.Type)}
+	}
+
+	switch {
+	case ver == 0:
+		ver = p.LatestVersion
+	case ver < 0:
+		return nil, errutil.UserError{Err: "requested version for CMAC is negative"}
+	case ver > p.LatestVersion:
+		return nil, errutil.UserError{Err: "requested version for CMAC is higher than the latest key version"}
+	}
+
+	keyEntry, err := p.safeGetKeyEntry(ver)
+	if err != nil {
+		return nil, err
+	}
+
+	return cmacAES(keyEntry.Key, message)
+}
+
 func (p *Policy) Sign(ver int, context, input []byte, hashAlgorithm HashType, sigAlgorithm string, marshaling MarshalingType) (*SigningResult, error) {
 	if !p.Type.SigningSupported() {
 		return nil, fmt.Errorf("message signing not supported for key type %v", p.Type)
@@ -1335,7 +1436,75 @@ func (p *Policy) VerifySignature(context, input []byte, hashAlgorithm HashType,
 	}
 }
 
-func (p *Policy) Rotate(ctx context.Context, storage logical.Storage, randReader io.Reader) (retErr error) {
+// RotationDue reports whether the latest version of the key is older than
+// the automatic rotation period
+func (p *Policy) RotationDue(now time.Time) bool {
+	if p.AutoRotatePeriod <= 0 {
+		return false
+	}
+	entry, ok := p.Keys[strconv.Itoa(p.LatestVersion)]
+	if !ok {
+		return false
+	}
+	return !now.Before(entry.creationTime().Add(p.AutoRotatePeriod))
+}
+
+// RatchetMinDecryptionVersion raises MinDecryptionVersion past the versions
+// which were superseded more than MinDecryptionAge ago, reporting whether it
+// changed. It is never raised past the latest version or a set minimum
+// encryption version, and never lowered.
+func (p *Policy) RatchetMinDecryptionVersion(now time.Time) bool {
+	if p.MinDecryptionAge <= 0 {
+		return false
+	}
+
+	limit := p.LatestVersion
+	if p.MinEncryptionVersion > 0 && p.MinEncryptionVersion < limit {
+		limit = p.MinEncryptionVersion
+	}
+
+	minVersion := p.MinDecryptionVersion
+	for minVersion < limit {
+		// A version is superseded when the next one is created
+		next, ok := p.Keys[strconv.Itoa(minVersion+1)]
+		if !ok || now.Before(next.creationTime().Add(p.MinDecryptionAge)) {
+			break
+		}
+		minVersion++
+	}
+
+	if minVersion <= p.MinDecryptionVersion {
+		return false
+	}
+	p.MinDecryptionVersion = minVersion
+	return true
+}
+
+func (ke KeyEntry) creationTime() time.Time {
+	if ke.CreationTime.IsZero() {
+		return time.Unix(ke.DeprecatedCreationTime, 0)
+	}
+	return ke.CreationTime
+}
+
+// Rotate generates a new version of the key
+func (p *Policy) Rotate(ctx context.Context, storage logical.Storage, randReader io.Reader) error {
+	return p.addVersion(ctx, storage, nil, randReader)
+}
+
+// Import adds externally generated key material as a new version of the key.
+// AES, ChaCha20 and HMAC keys are given as raw bytes; the private keys of
+// other key types are given as PKCS #8 DER.
+func (p *Policy) Import(ctx context.Context, storage logical.Storage, key []byte, randReader io.Reader) error {
+	if len(key) == 0 {
+		return errutil.UserError{Err: "no key material provided"}
+	}
+	return p.addVersion(ctx, storage, key, randReader)
+}
+
+// addVersion adds a new version of the key, generated or, if importedKey is
+// set, parsed from the imported key material
+func (p *Policy) addVersion(ctx context.Context, storage logical.Storage, importedKey []byte, randReader io.Reader) (retErr error) {
 	priorLatestVersion := p.LatestVersion
 	priorMinDecryptionVersion := p.MinDecryptionVersion
 	var priorKeys keyEntryMap
@@ -1375,6 +1544,36 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage, randReader
 	}
 	entry.HMACKey = hmacKey
 
+	if importedKey != nil {
+		if err := p.parseImportedKey(&entry, importedKey); err != nil {
+			return err
+		}
+	} else {
+		if err := p.generateKey(&entry, randReader); err != nil {
+			return err
+		}
+	}
+
+	if p.ConvergentEncryption {
+		if p.ConvergentVersion == -1 || p.ConvergentVersion > 1 {
+			entry.ConvergentVersion = currentConvergentVersion
+		}
+	}
+
+	p.Keys[strconv.Itoa(p.LatestVersion)] = entry
+
+	// This ensures that with new key creations min decryption version is set
+	// to 1 rather than the int default of 0, since keys start at 1 (either
+	// fresh or after migration to the key map)
+	if p.MinDecryptionVersion == 0 {
+		p.MinDecryptionVersion = 1
+	}
+
+	return p.Persist(ctx, storage)
+}
+
+func (p *Policy) generateKey(entry *KeyEntry, randReader io.Reader) error {
+	var err error
 	switch p.Type {
 	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
 		// Default to 256 bit key
@@ -1388,6 +1587,30 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage, randReader
 		}
 		entry.Key = newKey
 
+	case KeyType_AES128_CMAC, KeyType_AES256_CMAC:
+		numBytes := 32
+		if p.Type == KeyType_AES128_CMAC {
+			numBytes = 16
+		}
+		newKey, err := uuid.GenerateRandomBytesWithReader(numBytes, randReader)
+		if err != nil {
+			return err
+		}
+		entry.Key = newKey
+
+	case KeyType_HMAC:
+		// The HMAC key is the key material itself rather than a side-effect
+		// of another key type
+		keySize := p.KeySize
+		if keySize == 0 {
+			keySize = DefaultHMACKeySize
+		}
+		newKey, err := uuid.GenerateRandomBytesWithReader(keySize, randReader)
+		if err != nil {
+			return err
+		}
+		entry.HMACKey = newKey
+
 	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
 		var curve elliptic.Curve
 		switch p.Type {
@@ -1403,22 +1626,9 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage, randReader
 		if err != nil {
 			return err
 		}
-		entry.EC_D = privKey.D
-		entry.EC_X = privKey.X
-		entry.EC_Y = privKey.Y
-		derBytes, err := x509.MarshalPKIXPublicKey(privKey.Public())
-		if err != nil {
-			return errwrap.Wrapf("error marshaling public key: {{err}}", err)
-		}
-		pemBlock := &pem.Block{
-			Type:  "PUBLIC KEY",
-			Bytes: derBytes,
-		}
-		pemBytes := pem.EncodeToMemory(pemBlock)
-		if pemBytes == nil || len(pemBytes) == 0 {
-			return fmt.Errorf("error PEM-encoding public key")
+		if err := setECDSAKey(entry, privKey); err != nil {
+			return err
 		}
-		entry.FormattedPublicKey = string(pemBytes)
 
 	case KeyType_ED25519:
 		pub, pri, err := ed25519.GenerateKey(randReader)
@@ -1443,22 +1653,115 @@ func (p *Policy) Rotate(ctx context.Context, storage logical.Storage, randReader
 		}
 	}
 
-	if p.ConvergentEncryption {
-		if p.ConvergentVersion == -1 || p.ConvergentVersion > 1 {
-			entry.ConvergentVersion = currentConvergentVersion
+	return nil
+}
+
+func (p *Policy) parseImportedKey(entry *KeyEntry, key []byte) error {
+	switch p.Type {
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+		numBytes := 32
+		if p.Type == KeyType_AES128_GCM96 {
+			numBytes = 16
+		}
+		if len(key) != numBytes {
+			return errutil.UserError{Err: fmt.Sprintf("key of type %s must be %d bytes long, got %d", p.Type, numBytes, len(key))}
 		}
+		entry.Key = key
+		return nil
+
+	case KeyType_AES128_CMAC, KeyType_AES256_CMAC:
+		numBytes := 32
+		if p.Type == KeyType_AES128_CMAC {
+			numBytes = 16
+		}
+		if len(key) != numBytes {
+			return errutil.UserError{Err: fmt.Sprintf("key of type %s must be %d bytes long, got %d", p.Type, numBytes, len(key))}
+		}
+		entry.Key = key
+		return nil
+
+	case KeyType_HMAC:
+		if len(key) < HMACMinKeySize || len(key) > HMACMaxKeySize {
+			return errutil.UserError{Err: fmt.Sprintf("key of type %s must be between %d and %d bytes long, got %d", p.Type, HMACMinKeySize, HMACMaxKeySize, len(key))}
+		}
+		entry.HMACKey = key
+		return nil
 	}
 
-	p.Keys[strconv.Itoa(p.LatestVersion)] = entry
+	parsedKey, err := x509.ParsePKCS8PrivateKey(key)
+	if err != nil {
+		return errutil.UserError{Err: fmt.Sprintf("error parsing PKCS #8 private key: %s", err)}
+	}
 
-	// This ensures that with new key creations min decryption version is set
-	// to 1 rather than the int default of 0, since keys start at 1 (either
-	// fresh or after migration to the key map)
-	if p.MinDecryptionVersion == 0 {
-		p.MinDecryptionVersion = 1
+	switch p.Type {
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521:
+		privKey, ok := parsedKey.(*ecdsa.PrivateKey)
+		if !ok {
+			return errutil.UserError{Err: fmt.Sprintf("expected an ECDSA private key for key of type %s", p.Type)}
+		}
+		var curve elliptic.Curve
+		switch p.Type {
+		case KeyType_ECDSA_P384:
+			curve = elliptic.P384()
+		case KeyType_ECDSA_P521:
+			curve = elliptic.P521()
+		default:
+			curve = elliptic.P256()
+		}
+		if privKey.Curve != curve {
+			return errutil.UserError{Err: fmt.Sprintf("expected a private key on curve %s for key of type %s", curve.Params().Name, p.Type)}
+		}
+		return setECDSAKey(entry, privKey)
+
+	case KeyType_ED25519:
+		privKey, ok := parsedKey.(ed25519.PrivateKey)
+		if !ok {
+			return errutil.UserError{Err: fmt.Sprintf("expected an Ed25519 private key for key of type %s", p.Type)}
+		}
+		entry.Key = privKey
+		entry.FormattedPublicKey = base64.StdEncoding.EncodeToString(privKey.Public().(ed25519.PublicKey))
+		return nil
+
+	case KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096:
+		privKey, ok := parsedKey.(*rsa.PrivateKey)
+		if !ok {
+			return errutil.UserError{Err: fmt.Sprintf("expected an RSA private key for key of type %s", p.Type)}
+		}
+		bitSize := 2048
+		if p.Type == KeyType_RSA3072 {
+			bitSize = 3072
+		}
+		if p.Type == KeyType_RSA4096 {
+			bitSize = 4096
+		}
+		if privKey.N.BitLen() != bitSize {
+			return errutil.UserError{Err: fmt.Sprintf("expected a %d bit RSA private key for key of type %s, got %d bits", bitSize, p.Type, privKey.N.BitLen())}
+		}
+		entry.RSAKey = privKey
+		return nil
 	}
 
-	return p.Persist(ctx, storage)
+	return errutil.UserError{Err: fmt.Sprintf("importing keys of type %s is not supported", p.Type)}
+}
+
+func setECDSAKey(entry *KeyEntry, privKey *ecdsa.PrivateKey) error {
+	entry.EC_D = privKey.D
+	entry.EC_X = privKey.X
+	entry.EC_Y = privKey.Y
+	derBytes, err := x509.MarshalPKIXPublicKey(privKey.Public())
+	if err != nil {
+		return errwrap.Wrapf("error marshaling public key: {{err}}", err)
+	}
+	pemBlock := &pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: derBytes,
+	}
+	pemBytes := pem.EncodeToMemory(pemBlock)
+	if pemBytes == nil || len(pemBytes) == 0 {
+		return fmt.Errorf("error PEM-encoding public key")
+	}
+	entry.FormattedPublicKey = string(pemBytes)
+	return nil
 }
 
 func (p *Policy) MigrateKeyToKeysMap() {
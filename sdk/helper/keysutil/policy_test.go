@@ -641,3 +641,45 @@ func BenchmarkSymmetric(b *testing.B) {
 		}
 	}
 }
+
+func Test_AutoRotation(t *testing.T) {
+	now := time.Now()
+	p := &Policy{
+		Name:                 "test",
+		Type:                 KeyType_AES256_GCM96,
+		LatestVersion:        3,
+		MinDecryptionVersion: 1,
+		AutoRotatePeriod:     24 * time.Hour,
+		MinDecryptionAge:     48 * time.Hour,
+		Keys: keyEntryMap{
+			"1": {CreationTime: now.Add(-100 * time.Hour)},
+			"2": {CreationTime: now.Add(-50 * time.Hour)},
+			"3": {CreationTime: now.Add(-10 * time.Hour)},
+		},
+	}
+
+	if p.RotationDue(now) {
+		t.Fatal("expected rotation not to be due")
+	}
+	if !p.RotationDue(now.Add(14 * time.Hour)) {
+		t.Fatal("expected rotation to be due")
+	}
+
+	// Version 1 was superseded 50 hours ago, version 2 only 10 hours ago
+	if !p.RatchetMinDecryptionVersion(now) || p.MinDecryptionVersion != 2 {
+		t.Fatalf("expected min decryption version 2, got %d", p.MinDecryptionVersion)
+	}
+	if p.RatchetMinDecryptionVersion(now) {
+		t.Fatal("expected min decryption version not to change")
+	}
+
+	// The latest version always remains usable
+	if !p.RatchetMinDecryptionVersion(now.Add(1000*time.Hour)) || p.MinDecryptionVersion != 3 {
+		t.Fatalf("expected min decryption version 3, got %d", p.MinDecryptionVersion)
+	}
+
+	p.AutoRotatePeriod = 0
+	if p.RotationDue(now.Add(1000 * time.Hour)) {
+		t.Fatal("expected rotation to be disabled")
+	}
+}
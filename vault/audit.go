@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/hashicorp/errwrap"
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/audit"
 	"github.com/hashicorp/vault/helper/namespace"
@@ -94,6 +95,11 @@ func (c *Core) enableAudit(ctx context.Context, entry *MountEntry, updateStorage
 	view.setReadOnlyErr(logical.ErrSetupReadOnly)
 	defer view.setReadOnlyErr(origViewReadOnlyErr)
 
+	filter, err := auditFilter(entry)
+	if err != nil {
+		return err
+	}
+
 	// Lookup the new backend
 	backend, err := c.newAuditBackend(ctx, entry, view, entry.Options)
 	if err != nil {
@@ -108,6 +114,7 @@ func (c *Core) enableAudit(ctx context.Context, entry *MountEntry, updateStorage
 
 	ns, err := namespace.FromContext(ctx)
 	if err != nil {
+		cleanupAuditBackend(ctx, backend)
 		return err
 	}
 	entry.NamespaceID = ns.ID
@@ -115,6 +122,7 @@ func (c *Core) enableAudit(ctx context.Context, entry *MountEntry, updateStorage
 
 	if updateStorage {
 		if err := c.persistAudit(ctx, newTable, entry.Local); err != nil {
+			cleanupAuditBackend(ctx, backend)
 			return errors.New("failed to update audit table")
 		}
 	}
@@ -122,7 +130,7 @@ func (c *Core) enableAudit(ctx context.Context, entry *MountEntry, updateStorage
 	c.audit = newTable
 
 	// Register the backend
-	c.auditBroker.Register(entry.Path, backend, view, entry.Local)
+	c.auditBroker.Register(entry.Path, backend, view, entry.Local, filter)
 	if c.logger.IsInfo() {
 		c.logger.Info("enabled audit backend", "path", entry.Path, "type", entry.Type)
 	}
@@ -175,7 +183,7 @@ func (c *Core) disableAudit(ctx context.Context, path string, updateStorage bool
 	c.audit = newTable
 
 	// Unmount the backend
-	c.auditBroker.Deregister(path)
+	c.auditBroker.Deregister(ctx, path)
 	if c.logger.IsInfo() {
 		c.logger.Info("disabled audit backend", "path", path)
 	}
@@ -351,7 +359,7 @@ func (c *Core) persistAudit(ctx context.Context, table *MountTable, localOnly bo
 func (c *Core) setupAudits(ctx context.Context) error {
 	brokerLogger := c.baseLogger.Named("audit")
 	c.AddLogger(brokerLogger)
-	broker := NewAuditBroker(brokerLogger)
+	broker := NewAuditBroker(brokerLogger, c.router)
 
 	c.auditLock.Lock()
 	defer c.auditLock.Unlock()
@@ -373,6 +381,12 @@ func (c *Core) setupAudits(ctx context.Context) error {
 			view.setReadOnlyErr(origViewReadOnlyErr)
 		})
 
+		filter, err := auditFilter(entry)
+		if err != nil {
+			c.logger.Error("failed to create audit entry", "path", entry.Path, "error", err)
+			continue
+		}
+
 		// Initialize the backend
 		backend, err := c.newAuditBackend(ctx, entry, view, entry.Options)
 		if err != nil {
@@ -385,7 +399,7 @@ func (c *Core) setupAudits(ctx context.Context) error {
 		}
 
 		// Mount the backend
-		broker.Register(entry.Path, backend, view, entry.Local)
+		broker.Register(entry.Path, backend, view, entry.Local, filter)
 
 		successCount++
 	}
@@ -411,6 +425,10 @@ func (c *Core) teardownAudits() error {
 		}
 	}
 
+	if c.auditBroker != nil {
+		c.auditBroker.Cleanup(context.Background())
+	}
+
 	c.audit = nil
 	c.auditBroker = nil
 	return nil
@@ -431,7 +449,33 @@ func (c *Core) removeAuditReloadFunc(entry *MountEntry) {
 		delete(c.reloadFuncs, key)
 
 		c.reloadFuncsLock.Unlock()
+	case "http":
+		key := "audit_http|" + entry.Path
+		c.reloadFuncsLock.Lock()
+
+		if c.logger.IsDebug() {
+			c.baseLogger.Named("audit").Debug("removing reload function", "path", entry.Path)
+		}
+
+		delete(c.reloadFuncs, key)
+
+		c.reloadFuncsLock.Unlock()
+	}
+}
+
+// auditFilter parses the filter expression configured for the audit entry,
+// returning nil if the entry is not filtered
+func auditFilter(entry *MountEntry) (*audit.Filter, error) {
+	expression := entry.Options[audit.FilterOptionKey]
+	if expression == "" {
+		return nil, nil
+	}
+
+	filter, err := audit.ParseFilter(expression)
+	if err != nil {
+		return nil, errwrap.Wrapf("invalid audit filter: {{err}}", err)
 	}
+	return filter, nil
 }
 
 // newAuditBackend is used to create and configure a new audit backend by name
@@ -481,6 +525,27 @@ func (c *Core) newAuditBackend(ctx context.Context, entry *MountEntry, view logi
 			return be.Reload(ctx)
 		})
 
+		c.reloadFuncsLock.Unlock()
+	case "http":
+		key := "audit_http|" + entry.Path
+
+		c.reloadFuncsLock.Lock()
+
+		if auditLogger.IsDebug() {
+			auditLogger.Debug("adding reload function", "path", entry.Path)
+			if entry.Options != nil {
+				auditLogger.Debug("http backend options", "path", entry.Path, "address", entry.Options["address"])
+			}
+		}
+
+		// Reloading picks up rotated TLS certificates
+		c.reloadFuncs[key] = append(c.reloadFuncs[key], func() error {
+			if auditLogger.IsInfo() {
+				auditLogger.Info("reloading http audit backend", "path", entry.Path)
+			}
+			return be.Reload(ctx)
+		})
+
 		c.reloadFuncsLock.Unlock()
 	case "socket":
 		if auditLogger.IsDebug() {
@@ -17,6 +17,7 @@ type backendEntry struct {
 	backend audit.Backend
 	view    *BarrierView
 	local   bool
+	filter  *audit.Filter
 }
 
 // AuditBroker is used to provide a single ingest interface to auditable
@@ -25,33 +26,64 @@ type AuditBroker struct {
 	sync.RWMutex
 	backends map[string]backendEntry
 	logger   log.Logger
+
+	// router is used to resolve the mount of the logged requests for the
+	// backend filters
+	router *Router
 }
 
-// NewAuditBroker creates a new audit broker
-func NewAuditBroker(log log.Logger) *AuditBroker {
+// NewAuditBroker creates a new audit broker. The router may be nil, in which
+// case the filters only see the mount already set on the request.
+func NewAuditBroker(log log.Logger, router *Router) *AuditBroker {
 	b := &AuditBroker{
 		backends: make(map[string]backendEntry),
 		logger:   log,
+		router:   router,
 	}
 	return b
 }
 
-// Register is used to add new audit backend to the broker
-func (a *AuditBroker) Register(name string, b audit.Backend, v *BarrierView, local bool) {
+// Register is used to add new audit backend to the broker. If filter is
+// non-nil the backend only receives the entries matching it.
+func (a *AuditBroker) Register(name string, b audit.Backend, v *BarrierView, local bool, filter *audit.Filter) {
 	a.Lock()
 	defer a.Unlock()
 	a.backends[name] = backendEntry{
 		backend: b,
 		view:    v,
 		local:   local,
+		filter:  filter,
 	}
 }
 
-// Deregister is used to remove an audit backend from the broker
-func (a *AuditBroker) Deregister(name string) {
+// Deregister is used to remove an audit backend from the broker, cleaning it
+// up
+func (a *AuditBroker) Deregister(ctx context.Context, name string) {
 	a.Lock()
-	defer a.Unlock()
+	be, ok := a.backends[name]
 	delete(a.backends, name)
+	a.Unlock()
+
+	if ok {
+		cleanupAuditBackend(ctx, be.backend)
+	}
+}
+
+// Cleanup cleans up all the registered backends, when Vault seals
+func (a *AuditBroker) Cleanup(ctx context.Context) {
+	a.RLock()
+	defer a.RUnlock()
+	for _, be := range a.backends {
+		cleanupAuditBackend(ctx, be.backend)
+	}
+}
+
+// cleanupAuditBackend stops the background work of the backend, if it has
+// any
+func cleanupAuditBackend(ctx context.Context, backend audit.Backend) {
+	if cleaner, ok := backend.(audit.Cleaner); ok {
+		cleaner.Cleanup(ctx)
+	}
 }
 
 // IsRegistered is used to check if a given audit backend is registered
@@ -85,6 +117,40 @@ func (a *AuditBroker) GetHash(ctx context.Context, name string, input string) (s
 	return be.backend.GetHash(ctx, input)
 }
 
+// targets returns the backends whose filters match the given entry, which is
+// of the given type ("request" or "response"). As every entry must be
+// recorded by at least one backend, all backends are returned if none of them
+// match. The read lock must be held.
+func (a *AuditBroker) targets(ctx context.Context, entryType string, in *logical.LogInput) map[string]backendEntry {
+	filterIn := &audit.FilterInput{
+		Type:       entryType,
+		MountPoint: in.Request.MountPoint,
+		MountType:  in.Request.MountType,
+		Entry:      in,
+	}
+	// Requests are logged before they are routed, so the mount has to be
+	// looked up here rather than taken from the request
+	if a.router != nil {
+		if entry := a.router.MatchingMountEntry(ctx, in.Request.Path); entry != nil {
+			filterIn.MountPoint = a.router.MatchingMount(ctx, in.Request.Path)
+			filterIn.MountType = entry.Type
+		}
+	}
+	targets := make(map[string]backendEntry, len(a.backends))
+	for name, be := range a.backends {
+		if be.filter == nil || be.filter.Matches(ctx, filterIn) {
+			targets[name] = be
+		}
+	}
+	if len(targets) == 0 && len(a.backends) > 0 {
+		if a.logger.IsTrace() {
+			a.logger.Trace("no audit backend filter matched, logging to all backends", "request_path", in.Request.Path)
+		}
+		return a.backends
+	}
+	return targets
+}
+
 // LogRequest is used to ensure all the audit backends have an opportunity to
 // log the given request and that *at least one* succeeds.
 func (a *AuditBroker) LogRequest(ctx context.Context, in *logical.LogInput, headersConfig *AuditedHeadersConfig) (ret error) {
@@ -122,7 +188,8 @@ func (a *AuditBroker) LogRequest(ctx context.Context, in *logical.LogInput, head
 
 	// Ensure at least one backend logs
 	anyLogged := false
-	for name, be := range a.backends {
+	targets := a.targets(ctx, "request", in)
+	for name, be := range targets {
 		in.Request.Headers = nil
 		transHeaders, thErr := headersConfig.ApplyConfig(ctx, headers, be.backend.GetHash)
 		if thErr != nil {
@@ -140,7 +207,7 @@ func (a *AuditBroker) LogRequest(ctx context.Context, in *logical.LogInput, head
 			anyLogged = true
 		}
 	}
-	if !anyLogged && len(a.backends) > 0 {
+	if !anyLogged && len(targets) > 0 {
 		retErr = multierror.Append(retErr, fmt.Errorf("no audit backend succeeded in logging the request"))
 	}
 
@@ -178,7 +245,8 @@ func (a *AuditBroker) LogResponse(ctx context.Context, in *logical.LogInput, hea
 
 	// Ensure at least one backend logs
 	anyLogged := false
-	for name, be := range a.backends {
+	targets := a.targets(ctx, "response", in)
+	for name, be := range targets {
 		in.Request.Headers = nil
 		transHeaders, thErr := headersConfig.ApplyConfig(ctx, headers, be.backend.GetHash)
 		if thErr != nil {
@@ -196,7 +264,7 @@ func (a *AuditBroker) LogResponse(ctx context.Context, in *logical.LogInput, hea
 			anyLogged = true
 		}
 	}
-	if !anyLogged && len(a.backends) > 0 {
+	if !anyLogged && len(targets) > 0 {
 		retErr = multierror.Append(retErr, fmt.Errorf("no audit backend succeeded in logging the response"))
 	}
 
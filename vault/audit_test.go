@@ -352,13 +352,44 @@ func verifyDefaultAuditTable(t *testing.T, table *MountTable) {
 	}
 }
 
+// cleanupAudit is an audit backend counting the times it was cleaned up
+type cleanupAudit struct {
+	*NoopAudit
+	cleanups int
+}
+
+func (a *cleanupAudit) Cleanup(context.Context) {
+	a.cleanups++
+}
+
+func TestAuditBroker_Cleanup(t *testing.T) {
+	l := logging.NewVaultLogger(log.Trace)
+	b := NewAuditBroker(l, nil)
+	a1 := &cleanupAudit{NoopAudit: &NoopAudit{}}
+	a2 := &cleanupAudit{NoopAudit: &NoopAudit{}}
+	b.Register("foo", a1, nil, false, nil)
+	b.Register("bar", a2, nil, false, nil)
+
+	// Disabling a backend cleans it up
+	b.Deregister(context.Background(), "foo")
+	if a1.cleanups != 1 || a2.cleanups != 0 {
+		t.Fatalf("bad cleanups: %d, %d", a1.cleanups, a2.cleanups)
+	}
+
+	// Sealing cleans up the remaining backends
+	b.Cleanup(context.Background())
+	if a1.cleanups != 1 || a2.cleanups != 1 {
+		t.Fatalf("bad cleanups: %d, %d", a1.cleanups, a2.cleanups)
+	}
+}
+
 func TestAuditBroker_LogRequest(t *testing.T) {
 	l := logging.NewVaultLogger(log.Trace)
-	b := NewAuditBroker(l)
+	b := NewAuditBroker(l, nil)
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
-	b.Register("foo", a1, nil, false)
-	b.Register("bar", a2, nil, false)
+	b.Register("foo", a1, nil, false, nil)
+	b.Register("bar", a2, nil, false, nil)
 
 	auth := &logical.Auth{
 		ClientToken: "foo",
@@ -438,13 +469,185 @@ func TestAuditBroker_LogRequest(t *testing.T) {
 	}
 }
 
+func TestAuditBroker_Filter(t *testing.T) {
+	// Requests are logged before they are routed, so the broker resolves
+	// their mount through the router
+	r := NewRouter()
+	_, barrier, _ := mockBarrier(t)
+	err := r.Mount(&NoopBackend{}, "secret/", &MountEntry{
+		Table:       mountTableType,
+		Path:        "secret/",
+		Type:        "kv",
+		UUID:        "kv-uuid",
+		Accessor:    "kv-accessor",
+		NamespaceID: namespace.RootNamespaceID,
+		namespace:   namespace.RootNamespace,
+	}, NewBarrierView(barrier, "logical/kv-uuid/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.Mount(&NoopBackend{}, "auth/userpass/", &MountEntry{
+		Table:       credentialTableType,
+		Path:        "userpass/",
+		Type:        "userpass",
+		UUID:        "userpass-uuid",
+		Accessor:    "userpass-accessor",
+		NamespaceID: namespace.RootNamespaceID,
+		namespace:   namespace.RootNamespace,
+	}, NewBarrierView(barrier, "auth/userpass-uuid/"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := logging.NewVaultLogger(log.Trace)
+	b := NewAuditBroker(l, r)
+	kv := &NoopAudit{}
+	errs := &NoopAudit{}
+	logins := &NoopAudit{}
+	kvFilter, err := audit.ParseFilter(`mount_type == kv and mount_path == "secret/" and operation != read`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errsFilter, err := audit.ParseFilter("error == true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	loginsFilter, err := audit.ParseFilter("auth_method == userpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Register("kv", kv, nil, false, kvFilter)
+	b.Register("errs", errs, nil, false, errsFilter)
+	b.Register("logins", logins, nil, false, loginsFilter)
+
+	headersConf := &AuditedHeadersConfig{
+		Headers: make(map[string]*auditedHeaderSettings),
+	}
+	logRequest := func(op logical.Operation, path string, outerErr error) error {
+		t.Helper()
+		return b.LogRequest(namespace.RootContext(nil), &logical.LogInput{
+			Request: &logical.Request{
+				Operation: op,
+				Path:      path,
+			},
+			OuterErr: outerErr,
+		}, headersConf)
+	}
+	counts := func() []int {
+		return []int{len(kv.Req), len(errs.Req), len(logins.Req)}
+	}
+
+	if err := logRequest(logical.UpdateOperation, "secret/foo", nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c := counts(); !reflect.DeepEqual(c, []int{1, 0, 0}) {
+		t.Fatalf("expected write to be logged by the kv device only, got %v", c)
+	}
+
+	if err := logRequest(logical.UpdateOperation, "secret/foo", errors.New("failed")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c := counts(); !reflect.DeepEqual(c, []int{2, 1, 0}) {
+		t.Fatalf("expected failed write to be logged by the kv and error devices, got %v", c)
+	}
+
+	if err := logRequest(logical.UpdateOperation, "auth/userpass/login/alice", nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c := counts(); !reflect.DeepEqual(c, []int{2, 1, 1}) {
+		t.Fatalf("expected login to be logged by the login device only, got %v", c)
+	}
+
+	// A request matching no filter is still logged, by all devices
+	if err := logRequest(logical.ReadOperation, "secret/foo", nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if c := counts(); !reflect.DeepEqual(c, []int{3, 2, 2}) {
+		t.Fatalf("expected unmatched request to be logged by all devices, got %v", c)
+	}
+
+	// A failure of the only matching device is an error
+	kv.ReqErr = fmt.Errorf("failed")
+	err = logRequest(logical.UpdateOperation, "secret/foo", nil)
+	if !errwrap.Contains(err, "no audit backend succeeded in logging the request") {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestAuditBroker_FilterType(t *testing.T) {
+	l := logging.NewVaultLogger(log.Trace)
+	b := NewAuditBroker(l, nil)
+	reqs := &NoopAudit{}
+	resps := &NoopAudit{}
+	reqsFilter, err := audit.ParseFilter("type == request")
+	if err != nil {
+		t.Fatal(err)
+	}
+	respsFilter, err := audit.ParseFilter("type == response")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Register("reqs", reqs, nil, false, reqsFilter)
+	b.Register("resps", resps, nil, false, respsFilter)
+
+	headersConf := &AuditedHeadersConfig{
+		Headers: make(map[string]*auditedHeaderSettings),
+	}
+	in := &logical.LogInput{
+		Request: &logical.Request{
+			Operation: logical.ReadOperation,
+			Path:      "sys/mounts",
+		},
+		Response: &logical.Response{},
+	}
+
+	if err := b.LogRequest(namespace.RootContext(nil), in, headersConf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(reqs.Req) != 1 || len(resps.Req) != 0 {
+		t.Fatalf("expected request to be logged by the request device only, got %d and %d", len(reqs.Req), len(resps.Req))
+	}
+
+	if err := b.LogResponse(namespace.RootContext(nil), in, headersConf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(reqs.Resp) != 0 || len(resps.Resp) != 1 {
+		t.Fatalf("expected response to be logged by the response device only, got %d and %d", len(reqs.Resp), len(resps.Resp))
+	}
+}
+
+func TestCore_EnableAudit_InvalidFilter(t *testing.T) {
+	c, _, _ := TestCoreUnsealed(t)
+	c.auditBackends["noop"] = func(ctx context.Context, config *audit.BackendConfig) (audit.Backend, error) {
+		return &NoopAudit{
+			Config: config,
+		}, nil
+	}
+
+	me := &MountEntry{
+		Table: auditTableType,
+		Path:  "foo",
+		Type:  "noop",
+		Options: map[string]string{
+			"filter": "mount_type ==",
+		},
+	}
+	err := c.enableAudit(namespace.RootContext(nil), me, true)
+	if err == nil || !strings.Contains(err.Error(), "invalid audit filter") {
+		t.Fatalf("expected invalid filter error, got %v", err)
+	}
+	if c.auditBroker.IsRegistered("foo/") {
+		t.Fatal("expected audit backend not to be registered")
+	}
+}
+
 func TestAuditBroker_LogResponse(t *testing.T) {
 	l := logging.NewVaultLogger(log.Trace)
-	b := NewAuditBroker(l)
+	b := NewAuditBroker(l, nil)
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
-	b.Register("foo", a1, nil, false)
-	b.Register("bar", a2, nil, false)
+	b.Register("foo", a1, nil, false, nil)
+	b.Register("bar", a2, nil, false, nil)
 
 	auth := &logical.Auth{
 		NumUses:     10,
@@ -544,13 +747,13 @@ func TestAuditBroker_LogResponse(t *testing.T) {
 
 func TestAuditBroker_AuditHeaders(t *testing.T) {
 	logger := logging.NewVaultLogger(log.Trace)
-	b := NewAuditBroker(logger)
+	b := NewAuditBroker(logger, nil)
 	_, barrier, _ := mockBarrier(t)
 	view := NewBarrierView(barrier, "headers/")
 	a1 := &NoopAudit{}
 	a2 := &NoopAudit{}
-	b.Register("foo", a1, nil, false)
-	b.Register("bar", a2, nil, false)
+	b.Register("foo", a1, nil, false, nil)
+	b.Register("bar", a2, nil, false, nil)
 
 	auth := &logical.Auth{
 		ClientToken: "foo",
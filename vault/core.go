@@ -32,6 +32,7 @@ import (
 	"github.com/hashicorp/vault/helper/metricsutil"
 	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/internalshared/reloadutil"
+	"github.com/hashicorp/vault/physical/raft"
 	"github.com/hashicorp/vault/sdk/helper/certutil"
 	"github.com/hashicorp/vault/sdk/helper/consts"
 	"github.com/hashicorp/vault/sdk/helper/jsonutil"
@@ -508,6 +509,12 @@ type Core struct {
 	raftFollowerStates *raftFollowerStates
 	// Stop channel for raft TLS rotations
 	raftTLSRotationStopCh chan struct{}
+	// Tracks the health of the raft servers on the active node
+	raftAutopilot *raft.Autopilot
+	// Stop channel for raft autopilot
+	raftAutopilotStopCh chan struct{}
+	// Automated raft snapshot schedules run by the active node
+	raftAutoSnapshots *raftAutoSnapshots
 	// Stores the pending peers we are waiting to give answers
 	pendingRaftPeers *sync.Map
 
@@ -1925,7 +1932,7 @@ func (s standardUnsealStrategy) unseal(ctx context.Context, logger log.Logger, c
 			return err
 		}
 	} else {
-		c.auditBroker = NewAuditBroker(c.logger)
+		c.auditBroker = NewAuditBroker(c.logger, c.router)
 	}
 
 	if !c.ReplicationState().HasState(consts.ReplicationPerformanceSecondary | consts.ReplicationDRSecondary) {
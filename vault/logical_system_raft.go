@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"errors"
 	"strings"
+	"time"
 
 	proto "github.com/golang/protobuf/proto"
 	wrapping "github.com/hashicorp/go-kms-wrapping"
@@ -131,6 +132,143 @@ func (b *SystemBackend) raftStoragePaths() []*framework.Path {
 			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-force"][0]),
 			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-force"][1]),
 		},
+		{
+			Pattern: "storage/raft/autopilot/state",
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleRaftAutopilotStateRead(),
+					Summary:  "Returns the health of the servers of the raft cluster as tracked by autopilot.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-autopilot-state"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-autopilot-state"][1]),
+		},
+		{
+			Pattern: "storage/raft/autopilot/configuration",
+
+			Fields: map[string]*framework.FieldSchema{
+				"cleanup_dead_servers": {
+					Type:        framework.TypeBool,
+					Description: "Controls whether to remove dead servers from the raft cluster.",
+				},
+				"last_contact_threshold": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Limit on the amount of time a server can go without leader contact before being considered unhealthy.",
+				},
+				"dead_server_last_contact_threshold": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Limit on the amount of time a server can go without leader contact before being considered dead and removed, when cleanup_dead_servers is set.",
+				},
+				"max_trailing_logs": {
+					Type:        framework.TypeInt,
+					Description: "Amount of entries in the raft log that a server can be behind the leader before being considered unhealthy.",
+				},
+				"min_quorum": {
+					Type:        framework.TypeInt,
+					Description: "Minimum number of voters to keep in the raft cluster when removing dead servers.",
+				},
+				"server_stabilization_time": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Minimum amount of time a server must be healthy before counting towards the failure tolerance of the raft cluster.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleRaftAutopilotConfigRead(),
+					Summary:  "Returns the configuration of raft autopilot.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleRaftAutopilotConfigUpdate(),
+					Summary:  "Updates the configuration of raft autopilot.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-autopilot-configuration"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-autopilot-configuration"][1]),
+		},
+		{
+			Pattern: "storage/raft/snapshot-auto/config/?$",
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.handleRaftAutoSnapshotConfigList(),
+					Summary:  "Lists the automated raft snapshot configurations.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-config"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-config"][1]),
+		},
+		{
+			Pattern: "storage/raft/snapshot-auto/config/" + framework.GenericNameRegex("name"),
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the automated snapshot configuration.",
+				},
+				"interval": {
+					Type:        framework.TypeDurationSecond,
+					Description: "Time between snapshots.",
+				},
+				"retain": {
+					Type:        framework.TypeInt,
+					Description: "Number of snapshots to keep, deleting the oldest ones first. Defaults to 1.",
+				},
+				"storage_type": {
+					Type:        framework.TypeString,
+					Description: `Destination of the snapshots. Defaults to "local", storing them in a directory of the active node.`,
+				},
+				"path_prefix": {
+					Type:        framework.TypeString,
+					Description: "Where the snapshots are stored within the destination. For local storage, the absolute path of the directory.",
+				},
+				"file_prefix": {
+					Type:        framework.TypeString,
+					Description: `Prefix of the snapshot file names, which are followed by the time and raft index of the snapshot. Defaults to "vault-snapshot".`,
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleRaftAutoSnapshotConfigRead(),
+					Summary:  "Returns an automated raft snapshot configuration.",
+				},
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleRaftAutoSnapshotConfigUpdate(),
+					Summary:  "Creates or updates an automated raft snapshot configuration.",
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.handleRaftAutoSnapshotConfigDelete(),
+					Summary:  "Deletes an automated raft snapshot configuration, stopping its snapshots.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-config"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-config"][1]),
+		},
+		{
+			Pattern: "storage/raft/snapshot-auto/status/" + framework.GenericNameRegex("name"),
+
+			Fields: map[string]*framework.FieldSchema{
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the automated snapshot configuration.",
+				},
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleRaftAutoSnapshotStatusRead(),
+					Summary:  "Returns the outcome of the latest automated raft snapshots of a configuration.",
+				},
+			},
+
+			HelpSynopsis:    strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-status"][0]),
+			HelpDescription: strings.TrimSpace(sysRaftHelp["raft-snapshot-auto-status"][1]),
+		},
 	}
 }
 
@@ -166,17 +304,246 @@ func (b *SystemBackend) handleRaftRemovePeerUpdate() framework.OperationFunc {
 			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
 		}
 
-		if err := raftBackend.RemovePeer(ctx, serverID); err != nil {
+		if err := b.Core.removeRaftPeer(ctx, raftBackend, serverID); err != nil {
 			return nil, err
 		}
-		if b.Core.raftFollowerStates != nil {
-			b.Core.raftFollowerStates.delete(serverID)
+
+		return nil, nil
+	}
+}
+
+func (b *SystemBackend) handleRaftAutopilotStateRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.getRaftBackend() == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		autopilot := b.Core.raftAutopilot
+		if autopilot == nil {
+			return logical.ErrorResponse("raft autopilot is not running"), logical.ErrInvalidRequest
+		}
+
+		state := autopilot.State()
+		if state == nil {
+			return logical.ErrorResponse("raft autopilot has not computed the state of the cluster yet"), logical.ErrInvalidRequest
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"healthy":           state.Healthy,
+				"failure_tolerance": state.FailureTolerance,
+				"leader":            state.Leader,
+				"voters":            state.Voters,
+				"servers":           state.Servers,
+			},
+		}, nil
+	}
+}
+
+func (b *SystemBackend) handleRaftAutopilotConfigRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.getRaftBackend() == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		config, err := b.Core.loadRaftAutopilotConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"cleanup_dead_servers":               config.CleanupDeadServers,
+				"last_contact_threshold":             config.LastContactThreshold.String(),
+				"dead_server_last_contact_threshold": config.DeadServerLastContactThreshold.String(),
+				"max_trailing_logs":                  config.MaxTrailingLogs,
+				"min_quorum":                         config.MinQuorum,
+				"server_stabilization_time":          config.ServerStabilizationTime.String(),
+			},
+		}, nil
+	}
+}
+
+func (b *SystemBackend) handleRaftAutopilotConfigUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.getRaftBackend() == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		config, err := b.Core.loadRaftAutopilotConfig(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if cleanupRaw, ok := d.GetOk("cleanup_dead_servers"); ok {
+			config.CleanupDeadServers = cleanupRaw.(bool)
+		}
+		if thresholdRaw, ok := d.GetOk("last_contact_threshold"); ok {
+			config.LastContactThreshold = time.Duration(thresholdRaw.(int)) * time.Second
+		}
+		if thresholdRaw, ok := d.GetOk("dead_server_last_contact_threshold"); ok {
+			config.DeadServerLastContactThreshold = time.Duration(thresholdRaw.(int)) * time.Second
+		}
+		if maxTrailingLogsRaw, ok := d.GetOk("max_trailing_logs"); ok {
+			maxTrailingLogs := maxTrailingLogsRaw.(int)
+			if maxTrailingLogs < 0 {
+				return logical.ErrorResponse("max_trailing_logs must not be negative"), logical.ErrInvalidRequest
+			}
+			config.MaxTrailingLogs = uint64(maxTrailingLogs)
+		}
+		if minQuorumRaw, ok := d.GetOk("min_quorum"); ok {
+			minQuorum := minQuorumRaw.(int)
+			if minQuorum < 0 {
+				return logical.ErrorResponse("min_quorum must not be negative"), logical.ErrInvalidRequest
+			}
+			config.MinQuorum = uint(minQuorum)
+		}
+		if stabilizationRaw, ok := d.GetOk("server_stabilization_time"); ok {
+			config.ServerStabilizationTime = time.Duration(stabilizationRaw.(int)) * time.Second
+		}
+
+		if err := config.Validate(); err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+
+		if err := b.Core.saveRaftAutopilotConfig(ctx, config); err != nil {
+			return nil, err
 		}
 
 		return nil, nil
 	}
 }
 
+func (b *SystemBackend) handleRaftAutoSnapshotConfigList() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.getRaftBackend() == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		names, err := b.Core.listRaftAutoSnapshotConfigs(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return logical.ListResponse(names), nil
+	}
+}
+
+func (b *SystemBackend) handleRaftAutoSnapshotConfigRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.getRaftBackend() == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		config, err := b.Core.loadRaftAutoSnapshotConfig(ctx, d.Get("name").(string))
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"interval":     int64(config.Interval.Seconds()),
+				"retain":       config.Retain,
+				"storage_type": config.StorageType,
+				"path_prefix":  config.PathPrefix,
+				"file_prefix":  config.FilePrefix,
+			},
+		}, nil
+	}
+}
+
+func (b *SystemBackend) handleRaftAutoSnapshotConfigUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		raftBackend := b.Core.getRaftBackend()
+		if raftBackend == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+		if b.Core.isRaftHAOnly() {
+			return logical.ErrorResponse("raft storage is only used for HA"), logical.ErrInvalidRequest
+		}
+
+		name := d.Get("name").(string)
+		config, err := b.Core.loadRaftAutoSnapshotConfig(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			config = raft.DefaultAutoSnapshotConfig()
+		}
+
+		if intervalRaw, ok := d.GetOk("interval"); ok {
+			config.Interval = time.Duration(intervalRaw.(int)) * time.Second
+		}
+		if retainRaw, ok := d.GetOk("retain"); ok {
+			config.Retain = retainRaw.(int)
+		}
+		if storageTypeRaw, ok := d.GetOk("storage_type"); ok {
+			config.StorageType = storageTypeRaw.(string)
+		}
+		if pathPrefixRaw, ok := d.GetOk("path_prefix"); ok {
+			config.PathPrefix = pathPrefixRaw.(string)
+		}
+		if filePrefixRaw, ok := d.GetOk("file_prefix"); ok {
+			config.FilePrefix = filePrefixRaw.(string)
+		}
+
+		if err := config.Validate(); err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		if _, err := raft.SnapshotDestinations[config.StorageType](config); err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+
+		if err := b.Core.saveRaftAutoSnapshotConfig(ctx, name, config); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+func (b *SystemBackend) handleRaftAutoSnapshotConfigDelete() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.getRaftBackend() == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		if err := b.Core.deleteRaftAutoSnapshotConfig(ctx, d.Get("name").(string)); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+}
+
+func (b *SystemBackend) handleRaftAutoSnapshotStatusRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		if b.Core.getRaftBackend() == nil {
+			return logical.ErrorResponse("raft storage is not in use"), logical.ErrInvalidRequest
+		}
+
+		status, ok := b.Core.raftAutoSnapshotStatus(d.Get("name").(string))
+		if !ok {
+			return nil, nil
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"last_snapshot_start": status.LastSnapshotStart,
+				"last_snapshot_end":   status.LastSnapshotEnd,
+				"last_snapshot_error": status.LastSnapshotError,
+				"consecutive_errors":  status.ConsecutiveErrors,
+				"last_success":        status.LastSuccess,
+				"last_success_name":   status.LastSuccessName,
+				"last_success_index":  status.LastSuccessIndex,
+			},
+		}, nil
+	}
+}
+
 func (b *SystemBackend) handleRaftBootstrapChallengeWrite() framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 		serverID := d.Get("server_id").(string)
@@ -460,4 +827,37 @@ var sysRaftHelp = map[string][2]string{
 		"Force restore a raft cluster snapshot",
 		"",
 	},
+	"raft-snapshot-auto-config": {
+		"Configures automated raft snapshots.",
+		`
+The active node takes a snapshot at the configured interval for each of the
+automated snapshot configurations, storing it in the configured destination
+and deleting the oldest snapshots beyond the retained number.
+		`,
+	},
+	"raft-snapshot-auto-status": {
+		"Returns the outcome of the latest automated raft snapshots.",
+		`
+Reports the times of the latest snapshot attempt and of the latest successful
+snapshot, along with the error and number of consecutive failures if the
+latest attempts failed.
+		`,
+	},
+	"raft-autopilot-state": {
+		"Returns the health of the raft cluster servers as tracked by autopilot.",
+		`
+Autopilot runs on the active node, tracking the last contact, applied index
+and stability of each server of the raft cluster. This endpoint reports whether
+each server and the cluster as a whole are healthy, and how many voters can
+fail without losing quorum.
+		`,
+	},
+	"raft-autopilot-configuration": {
+		"Configures raft autopilot.",
+		`
+When cleanup_dead_servers is set, autopilot removes the servers which haven't
+contacted the active node for dead_server_last_contact_threshold, as long as
+at least min_quorum voters remain in the cluster.
+		`,
+	},
 }
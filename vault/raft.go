@@ -35,31 +35,46 @@ var (
 	raftTLSStoragePath    = "core/raft/tls"
 	raftTLSRotationPeriod = 24 * time.Hour
 
+	raftAutopilotConfigPath = "core/raft/autopilot/configuration"
+
 	// TestingUpdateClusterAddr is used in tests to override the cluster address
 	TestingUpdateClusterAddr uint32
 )
 
 type raftFollowerStates struct {
 	l         sync.RWMutex
-	followers map[string]uint64
+	followers map[string]raft.FollowerState
 }
 
 func (s *raftFollowerStates) update(nodeID string, appliedIndex uint64) {
 	s.l.Lock()
-	s.followers[nodeID] = appliedIndex
+	s.followers[nodeID] = raft.FollowerState{
+		AppliedIndex:  appliedIndex,
+		LastHeartbeat: time.Now(),
+	}
 	s.l.Unlock()
 }
 func (s *raftFollowerStates) delete(nodeID string) {
-	s.l.RLock()
+	s.l.Lock()
 	delete(s.followers, nodeID)
-	s.l.RUnlock()
+	s.l.Unlock()
 }
 func (s *raftFollowerStates) get(nodeID string) uint64 {
 	s.l.RLock()
-	index := s.followers[nodeID]
+	index := s.followers[nodeID].AppliedIndex
 	s.l.RUnlock()
 	return index
 }
+func (s *raftFollowerStates) all() map[string]raft.FollowerState {
+	s.l.RLock()
+	defer s.l.RUnlock()
+
+	followers := make(map[string]raft.FollowerState, len(s.followers))
+	for nodeID, state := range s.followers {
+		followers[nodeID] = state
+	}
+	return followers
+}
 func (s *raftFollowerStates) minIndex() uint64 {
 	var min uint64 = math.MaxUint64
 	minFunc := func(a, b uint64) uint64 {
@@ -70,8 +85,8 @@ func (s *raftFollowerStates) minIndex() uint64 {
 	}
 
 	s.l.RLock()
-	for _, i := range s.followers {
-		min = minFunc(min, i)
+	for _, state := range s.followers {
+		min = minFunc(min, state.AppliedIndex)
 	}
 	s.l.RUnlock()
 
@@ -199,14 +214,108 @@ func (c *Core) startRaftBackend(ctx context.Context) (retErr error) {
 
 func (c *Core) setupRaftActiveNode(ctx context.Context) error {
 	c.pendingRaftPeers = &sync.Map{}
-	return c.startPeriodicRaftTLSRotate(ctx)
+	if err := c.startPeriodicRaftTLSRotate(ctx); err != nil {
+		return err
+	}
+	if err := c.startRaftAutopilot(ctx); err != nil {
+		return err
+	}
+	return c.startRaftAutoSnapshots(ctx)
 }
 
 func (c *Core) stopRaftActiveNode() {
 	c.pendingRaftPeers = nil
+	c.stopRaftAutoSnapshots()
+	c.stopRaftAutopilot()
 	c.stopPeriodicRaftTLSRotate()
 }
 
+// startRaftAutopilot starts tracking the health of the raft servers. It relies
+// on the heartbeats of the followers, so isn't started when raft is used for
+// HA only.
+func (c *Core) startRaftAutopilot(ctx context.Context) error {
+	raftBackend := c.getRaftBackend()
+	followerStates := c.raftFollowerStates
+	if raftBackend == nil || followerStates == nil {
+		return nil
+	}
+
+	config, err := c.loadRaftAutopilotConfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	c.raftAutopilot = c.newRaftAutopilot(raftBackend, followerStates)
+	c.raftAutopilot.SetConfig(config)
+	c.raftAutopilotStopCh = make(chan struct{})
+	go c.raftAutopilot.Run(c.raftAutopilotStopCh)
+
+	return nil
+}
+
+// newRaftAutopilot returns autopilot for the raft backend, removing the dead
+// servers through removeRaftPeer
+func (c *Core) newRaftAutopilot(raftBackend *raft.RaftBackend, followerStates *raftFollowerStates) *raft.Autopilot {
+	removePeer := func(ctx context.Context, serverID string) error {
+		return c.removeRaftPeer(ctx, raftBackend, serverID)
+	}
+	return raft.NewAutopilot(raftBackend, followerStates.all, removePeer, c.logger.Named("raft.autopilot"))
+}
+
+// removeRaftPeer removes the server from the raft cluster and forgets its
+// follower state, which would otherwise hold back the TLS keyring rotation
+// waiting for the server to apply the new key
+func (c *Core) removeRaftPeer(ctx context.Context, raftBackend *raft.RaftBackend, serverID string) error {
+	if err := raftBackend.RemovePeer(ctx, serverID); err != nil {
+		return err
+	}
+	if followerStates := c.raftFollowerStates; followerStates != nil {
+		followerStates.delete(serverID)
+	}
+	return nil
+}
+
+func (c *Core) stopRaftAutopilot() {
+	if c.raftAutopilotStopCh != nil {
+		close(c.raftAutopilotStopCh)
+	}
+	c.raftAutopilotStopCh = nil
+	c.raftAutopilot = nil
+}
+
+// loadRaftAutopilotConfig reads the autopilot configuration from storage,
+// falling back to the default one
+func (c *Core) loadRaftAutopilotConfig(ctx context.Context) (*raft.AutopilotConfig, error) {
+	config := raft.DefaultAutopilotConfig()
+	entry, err := c.barrier.Get(ctx, raftAutopilotConfigPath)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read raft autopilot configuration: {{err}}", err)
+	}
+	if entry == nil {
+		return config, nil
+	}
+	if err := jsonutil.DecodeJSON(entry.Value, config); err != nil {
+		return nil, errwrap.Wrapf("failed to decode raft autopilot configuration: {{err}}", err)
+	}
+	return config, nil
+}
+
+// saveRaftAutopilotConfig persists the autopilot configuration and applies it
+// to the running autopilot
+func (c *Core) saveRaftAutopilotConfig(ctx context.Context, config *raft.AutopilotConfig) error {
+	entry, err := logical.StorageEntryJSON(raftAutopilotConfigPath, config)
+	if err != nil {
+		return err
+	}
+	if err := c.barrier.Put(ctx, entry); err != nil {
+		return errwrap.Wrapf("failed to persist raft autopilot configuration: {{err}}", err)
+	}
+	if c.raftAutopilot != nil {
+		c.raftAutopilot.SetConfig(config)
+	}
+	return nil
+}
+
 func (c *Core) startPeriodicRaftTLSRotate(ctx context.Context) error {
 	raftBackend := c.getRaftBackend()
 
@@ -335,7 +444,7 @@ func (c *Core) raftTLSRotateDirect(ctx context.Context, logger hclog.Logger, sto
 // is allowed for this same reason (max keyring size of 2).
 func (c *Core) raftTLSRotatePhased(ctx context.Context, logger hclog.Logger, raftBackend *raft.RaftBackend, stopCh chan struct{}) error {
 	followerStates := &raftFollowerStates{
-		followers: make(map[string]uint64),
+		followers: make(map[string]raft.FollowerState),
 	}
 
 	// Pre-populate the follower list with the set of peers.
@@ -701,9 +810,9 @@ func (c *Core) InitiateRetryJoin(ctx context.Context) error {
 		return nil
 	}
 
-	c.logger.Info("raft retry join initiated")
+	c.logger.Info("raft retry join initiated", "non_voter", leaderInfos[0].NonVoter)
 
-	if _, err = c.JoinRaftCluster(ctx, leaderInfos, false); err != nil {
+	if _, err = c.JoinRaftCluster(ctx, leaderInfos, leaderInfos[0].NonVoter); err != nil {
 		return err
 	}
 
@@ -0,0 +1,190 @@
+package vault
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/hashicorp/errwrap"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const raftAutoSnapshotConfigPrefix = "core/raft/snapshot-auto/config/"
+
+// raftAutoSnapshots holds the automated snapshot schedules run by the active
+// node, keyed by name
+type raftAutoSnapshots struct {
+	l         sync.RWMutex
+	schedules map[string]*raftAutoSnapshot
+}
+
+type raftAutoSnapshot struct {
+	snapshotter *raft.AutoSnapshotter
+	stopCh      chan struct{}
+}
+
+// startRaftAutoSnapshots starts the configured automated snapshot schedules.
+// Snapshots of raft used for HA only wouldn't contain Vault's data, so none
+// are taken then.
+func (c *Core) startRaftAutoSnapshots(ctx context.Context) error {
+	if c.getRaftBackend() == nil || c.isRaftHAOnly() {
+		return nil
+	}
+
+	c.raftAutoSnapshots = &raftAutoSnapshots{
+		schedules: make(map[string]*raftAutoSnapshot),
+	}
+
+	names, err := c.barrier.List(ctx, raftAutoSnapshotConfigPrefix)
+	if err != nil {
+		return errwrap.Wrapf("failed to list raft automated snapshot configurations: {{err}}", err)
+	}
+	for _, name := range names {
+		config, err := c.loadRaftAutoSnapshotConfig(ctx, name)
+		if err != nil {
+			return err
+		}
+		if config == nil {
+			continue
+		}
+		if err := c.startRaftAutoSnapshot(name, config); err != nil {
+			c.logger.Error("failed to start raft automated snapshots", "name", name, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// startRaftAutoSnapshot starts or restarts the automated snapshot schedule
+// with the given name
+func (c *Core) startRaftAutoSnapshot(name string, config *raft.AutoSnapshotConfig) error {
+	autoSnapshots := c.raftAutoSnapshots
+	raftBackend := c.getRaftBackend()
+	if autoSnapshots == nil || raftBackend == nil {
+		return nil
+	}
+
+	snapshot := func(w io.Writer) error {
+		return raftBackend.Snapshot(w, c.seal.GetAccess())
+	}
+	snapshotter, err := raft.NewAutoSnapshotter(name, config, snapshot, raftBackend.AppliedIndex, c.logger.Named("raft.snapshot-auto").With("name", name))
+	if err != nil {
+		return err
+	}
+
+	autoSnapshots.l.Lock()
+	defer autoSnapshots.l.Unlock()
+
+	if schedule, ok := autoSnapshots.schedules[name]; ok {
+		close(schedule.stopCh)
+	}
+	schedule := &raftAutoSnapshot{
+		snapshotter: snapshotter,
+		stopCh:      make(chan struct{}),
+	}
+	autoSnapshots.schedules[name] = schedule
+	go snapshotter.Run(schedule.stopCh)
+
+	return nil
+}
+
+// stopRaftAutoSnapshot stops the automated snapshot schedule with the given
+// name, if it's running
+func (c *Core) stopRaftAutoSnapshot(name string) {
+	autoSnapshots := c.raftAutoSnapshots
+	if autoSnapshots == nil {
+		return
+	}
+
+	autoSnapshots.l.Lock()
+	defer autoSnapshots.l.Unlock()
+
+	if schedule, ok := autoSnapshots.schedules[name]; ok {
+		close(schedule.stopCh)
+		delete(autoSnapshots.schedules, name)
+	}
+}
+
+// stopRaftAutoSnapshots stops all the automated snapshot schedules
+func (c *Core) stopRaftAutoSnapshots() {
+	autoSnapshots := c.raftAutoSnapshots
+	if autoSnapshots == nil {
+		return
+	}
+
+	autoSnapshots.l.Lock()
+	for _, schedule := range autoSnapshots.schedules {
+		close(schedule.stopCh)
+	}
+	autoSnapshots.schedules = nil
+	autoSnapshots.l.Unlock()
+
+	c.raftAutoSnapshots = nil
+}
+
+// raftAutoSnapshotStatus returns the outcome of the latest snapshots of the
+// schedule with the given name, and whether it's running
+func (c *Core) raftAutoSnapshotStatus(name string) (raft.AutoSnapshotStatus, bool) {
+	autoSnapshots := c.raftAutoSnapshots
+	if autoSnapshots == nil {
+		return raft.AutoSnapshotStatus{}, false
+	}
+
+	autoSnapshots.l.RLock()
+	defer autoSnapshots.l.RUnlock()
+
+	schedule, ok := autoSnapshots.schedules[name]
+	if !ok {
+		return raft.AutoSnapshotStatus{}, false
+	}
+	return schedule.snapshotter.Status(), true
+}
+
+// loadRaftAutoSnapshotConfig reads the configuration of the automated
+// snapshot schedule with the given name, returning nil if it doesn't exist
+func (c *Core) loadRaftAutoSnapshotConfig(ctx context.Context, name string) (*raft.AutoSnapshotConfig, error) {
+	entry, err := c.barrier.Get(ctx, raftAutoSnapshotConfigPrefix+name)
+	if err != nil {
+		return nil, errwrap.Wrapf("failed to read raft automated snapshot configuration: {{err}}", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	config := raft.DefaultAutoSnapshotConfig()
+	if err := jsonutil.DecodeJSON(entry.Value, config); err != nil {
+		return nil, errwrap.Wrapf("failed to decode raft automated snapshot configuration: {{err}}", err)
+	}
+	return config, nil
+}
+
+// saveRaftAutoSnapshotConfig persists the configuration of the automated
+// snapshot schedule with the given name and (re)starts it
+func (c *Core) saveRaftAutoSnapshotConfig(ctx context.Context, name string, config *raft.AutoSnapshotConfig) error {
+	entry, err := logical.StorageEntryJSON(raftAutoSnapshotConfigPrefix+name, config)
+	if err != nil {
+		return err
+	}
+	if err := c.barrier.Put(ctx, entry); err != nil {
+		return errwrap.Wrapf("failed to persist raft automated snapshot configuration: {{err}}", err)
+	}
+	return c.startRaftAutoSnapshot(name, config)
+}
+
+// deleteRaftAutoSnapshotConfig removes the configuration of the automated
+// snapshot schedule with the given name and stops it
+func (c *Core) deleteRaftAutoSnapshotConfig(ctx context.Context, name string) error {
+	if err := c.barrier.Delete(ctx, raftAutoSnapshotConfigPrefix+name); err != nil {
+		return errwrap.Wrapf("failed to delete raft automated snapshot configuration: {{err}}", err)
+	}
+	c.stopRaftAutoSnapshot(name)
+	return nil
+}
+
+// listRaftAutoSnapshotConfigs returns the names of the automated snapshot
+// schedules
+func (c *Core) listRaftAutoSnapshotConfigs(ctx context.Context) ([]string, error) {
+	return c.barrier.List(ctx, raftAutoSnapshotConfigPrefix)
+}
@@ -0,0 +1,121 @@
+package vault
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/physical/raft"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+)
+
+// testRaftLeader returns a single node raft cluster using the in-memory
+// transport, and a function tearing it down
+func testRaftLeader(t *testing.T, logger log.Logger) (*raft.RaftBackend, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "vault-raft-")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backend, err := raft.NewRaftBackend(map[string]string{
+		"path":    dir,
+		"node_id": "core-0",
+	}, logger.Named("raft"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	raftBackend := backend.(*raft.RaftBackend)
+	cleanup := func() {
+		raftBackend.TeardownCluster(nil)
+		os.RemoveAll(dir)
+	}
+
+	if err := raftBackend.Bootstrap([]raft.Peer{{ID: "core-0", Address: "core-0"}}); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	if err := raftBackend.SetupCluster(context.Background(), raft.SetupOpts{}); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	return raftBackend, cleanup
+}
+
+func TestRaft_Autopilot_PruneForgetsFollowerState(t *testing.T) {
+	logger := logging.NewVaultLogger(log.Trace)
+	raftBackend, cleanup := testRaftLeader(t, logger)
+	defer cleanup()
+
+	// Add a live and a dead non-voter, retrying until the node is leader
+	ctx := context.Background()
+	deadline := time.Now().Add(10 * time.Second)
+	for _, id := range []string{"core-1", "core-2"} {
+		for {
+			err := raftBackend.AddNonVotingPeer(ctx, id, id)
+			if err == nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal(err)
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	followerStates := &raftFollowerStates{
+		followers: map[string]raft.FollowerState{
+			"core-1": {AppliedIndex: 100, LastHeartbeat: time.Now()},
+			"core-2": {AppliedIndex: 0, LastHeartbeat: time.Now().Add(-48 * time.Hour)},
+		},
+	}
+	c := &Core{
+		logger:             logger,
+		underlyingPhysical: raftBackend,
+		raftFollowerStates: followerStates,
+	}
+
+	// The dead server holds back the rotation of a key applied at index 100,
+	// as checked by raftTLSRotatePhased
+	keyring := &raft.TLSKeyring{AppliedIndex: 100}
+	if followerStates.minIndex() >= keyring.AppliedIndex {
+		t.Fatalf("expected the dead server to hold back rotation, min index %d", followerStates.minIndex())
+	}
+
+	config := raft.DefaultAutopilotConfig()
+	config.CleanupDeadServers = true
+	config.DeadServerLastContactThreshold = time.Minute
+
+	autopilot := c.newRaftAutopilot(raftBackend, followerStates)
+	autopilot.SetConfig(config)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go autopilot.Run(stopCh)
+
+	deadline = time.Now().Add(10 * time.Second)
+	for followerStates.minIndex() < keyring.AppliedIndex {
+		if time.Now().After(deadline) {
+			t.Fatalf("rotation still held back, followers: %#v", followerStates.all())
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	raftConfig, err := raftBackend.GetConfiguration(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, server := range raftConfig.Servers {
+		if server.NodeID == "core-2" {
+			t.Fatal("the dead server wasn't removed from the cluster")
+		}
+	}
+	if _, ok := followerStates.all()["core-1"]; !ok {
+		t.Fatal("the live server was forgotten")
+	}
+}